@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pressly/goose/v3"
+
+	"Chirpy/internal/auth"
+	"Chirpy/internal/config"
+	"Chirpy/internal/database"
+	"Chirpy/internal/migrate"
+)
+
+// runCommand executes the administrative subcommand named by args[0]
+// instead of starting the server.
+func runCommand(conf config.Config, migrator *migrate.Migrator, queries database.Querier, args []string) error {
+	switch args[0] {
+	case "migrate":
+		return migrateCommand(migrator, args[1:])
+	case "bootstrap-admin":
+		return bootstrapAdmin(conf, queries, args[1:])
+	default:
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+}
+
+// bootstrapAdmin promotes the user with the given email to admin, creating
+// the account first (with the password in CHIRPY_ADMIN_PASSWORD) when it
+// does not exist yet.
+func bootstrapAdmin(conf config.Config, queries database.Querier, args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: chirpy bootstrap-admin <email>")
+	}
+	email := args[0]
+	ctx := context.Background()
+
+	user, err := queries.QueryUser(ctx, email)
+	if errors.Is(err, sql.ErrNoRows) {
+		password := conf.AdminPassword
+		if password == "" {
+			return errors.New("user not found: set CHIRPY_ADMIN_PASSWORD to create it")
+		}
+		hashed, err := auth.HashPasswordWithCost(password, conf.Auth.BcryptCost)
+		if err != nil {
+			return err
+		}
+		user, err = queries.CreateUser(ctx, database.CreateUserParams{
+			Email:          email,
+			HashedPassword: hashed,
+		})
+		if err != nil {
+			return err
+		}
+		slog.Info("user created", "user_id", user.ID)
+	} else if err != nil {
+		return err
+	}
+
+	user, err = queries.SetUserRole(ctx, database.SetUserRoleParams{
+		ID:   user.ID,
+		Role: auth.RoleAdmin,
+	})
+	if err != nil {
+		return err
+	}
+	slog.Info("role changed", "user_id", user.ID, "email", user.Email, "role", user.Role)
+	return nil
+}
+
+// configCommand implements "chirpy config print [--show-secrets]". Secrets
+// are redacted unless --show-secrets is given; --redacted is still accepted
+// for the scripts written when redaction was opt-in. A configuration that
+// cannot be read is reported without printing anything, as the settings
+// would be incomplete. Validation runs after printing instead, so that a
+// broken setup can be inspected.
+func configCommand(conf config.Config, readErr error, args []string) error {
+	usage := errors.New("usage: chirpy config print [--show-secrets]")
+	if len(args) == 0 || args[0] != "print" || len(args) > 2 {
+		return usage
+	}
+	showSecrets := false
+	if len(args) == 2 {
+		switch args[1] {
+		case "--show-secrets":
+			showSecrets = true
+		case "--redacted":
+		default:
+			return usage
+		}
+	}
+	if readErr != nil {
+		return readErr
+	}
+	if !showSecrets {
+		conf = conf.Redacted()
+	}
+	out, err := conf.YAML()
+	if err != nil {
+		return err
+	}
+	os.Stdout.Write(out)
+	return conf.Validate()
+}
+
+// migrateCommand implements "chirpy migrate up|down|status|redo".
+func migrateCommand(migrator *migrate.Migrator, args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: chirpy migrate up|down|status|redo")
+	}
+	ctx := context.Background()
+
+	switch args[0] {
+	case "up":
+		return migrateUp(ctx, migrator)
+	case "down":
+		result, err := migrator.Down(ctx)
+		if err != nil {
+			return err
+		}
+		logMigration(result)
+		return nil
+	case "redo":
+		results, err := migrator.Redo(ctx)
+		for _, result := range results {
+			logMigration(result)
+		}
+		return err
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tMIGRATION\tAPPLIED AT")
+		for _, s := range statuses {
+			applied := "pending"
+			if !s.AppliedAt.IsZero() {
+				applied = s.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\n", s.Source.Version, s.Source.Path, applied)
+		}
+		return w.Flush()
+	default:
+		return fmt.Errorf("unknown migrate command %q", args[0])
+	}
+}
+
+// migrateUp applies the pending migrations, logging each one.
+func migrateUp(ctx context.Context, migrator *migrate.Migrator) error {
+	results, err := migrator.Up(ctx)
+	for _, result := range results {
+		logMigration(result)
+	}
+	if err == nil && len(results) == 0 {
+		slog.Info("schema up to date", "version", migrator.Target())
+	}
+	return err
+}
+
+func logMigration(result *goose.MigrationResult) {
+	slog.Info("migration applied",
+		"version", result.Source.Version,
+		"migration", result.Source.Path,
+		"direction", result.Direction,
+		"duration_ms", result.Duration.Milliseconds())
+}
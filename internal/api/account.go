@@ -0,0 +1,285 @@
+package api
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"Chirpy/internal/auth"
+	"Chirpy/internal/database"
+	"Chirpy/internal/httpx"
+	"Chirpy/internal/store"
+	"github.com/google/uuid"
+)
+
+// accountDeletionGrace is how long a deleted account is kept, and can be
+// restored by logging in again, before it is removed for good.
+const accountDeletionGrace = 30 * 24 * time.Hour
+
+// dataExportTimeout is how long an export may stay running before it is
+// taken for lost, e.g. to a restart, and built again.
+const dataExportTimeout = 15 * time.Minute
+
+type DataExport struct {
+	ID          uuid.UUID  `json:"id"`
+	Status      string     `json:"status"`
+	CreatedAt   time.Time  `json:"created_at"`
+	CompletedAt *time.Time `json:"completed_at"`
+}
+
+func (cfg *API) deleteUser(res http.ResponseWriter, req *http.Request) {
+	type parameters struct {
+		Password string `json:"password" validate:"required"`
+	}
+
+	claims, ok := cfg.authorize(res, req, auth.RoleUser)
+	if !ok {
+		return
+	}
+
+	params := parameters{}
+	if !httpx.DecodeJSON(res, req, &params) {
+		return
+	}
+
+	user, err := cfg.store.QueryUserByID(req.Context(), claims.UserID)
+	if err != nil {
+		slog.ErrorContext(req.Context(), "cannot query user", "user_id", claims.UserID, "err", err)
+		httpx.RespondError(res, req, http.StatusInternalServerError, httpx.CodeInternal, "")
+		return
+	}
+
+	//la cancellazione richiede di confermare la password
+	err = cfg.checkPassword(req.Context(), user.HashedPassword, params.Password)
+	if err != nil {
+		slog.InfoContext(req.Context(), "account deletion refused: wrong password", "user_id", user.ID)
+		httpx.RespondError(res, req, http.StatusUnauthorized, httpx.CodeInvalidCredentials, "password is incorrect")
+		return
+	}
+
+	//un account cancellato non deve restare con sessioni attive
+	err = cfg.store.WithTx(req.Context(), func(q database.Querier) error {
+		_, err := q.SoftDeleteUser(req.Context(), user.ID)
+		if err != nil {
+			return err
+		}
+		return q.RevokeUserTokens(req.Context(), user.ID)
+	})
+	if err != nil {
+		respondStoreError(res, req, err, "account deletion failed", "user_id", user.ID)
+		return
+	}
+	slog.InfoContext(req.Context(), "user scheduled for deletion", "user_id", user.ID, "grace", accountDeletionGrace.String())
+	res.WriteHeader(204)
+}
+
+// requestDataExport queues an export of the data of the caller. While one
+// is waiting or being built, asking again returns it instead of queueing
+// another.
+func (cfg *API) requestDataExport(res http.ResponseWriter, req *http.Request) {
+	claims, ok := cfg.authorize(res, req, auth.RoleUser)
+	if !ok {
+		return
+	}
+
+	export, err := cfg.store.CreateDataExport(req.Context(), claims.UserID)
+	if errors.Is(store.Classify(err), store.ErrUniqueViolation) {
+		export, err = cfg.store.QueryLatestDataExport(req.Context(), claims.UserID)
+		if err != nil {
+			slog.ErrorContext(req.Context(), "cannot query data export", "user_id", claims.UserID, "err", err)
+			httpx.RespondError(res, req, http.StatusInternalServerError, httpx.CodeInternal, "")
+			return
+		}
+		httpx.RespondJSON(res, req, 202, outputDataExport(export))
+		return
+	}
+	if err != nil {
+		respondStoreError(res, req, err, "cannot create data export", "user_id", claims.UserID)
+		return
+	}
+	//la costruzione tocca al job, che lo spegnimento aspetta
+	select {
+	case cfg.exportRequested <- struct{}{}:
+	default:
+	}
+
+	httpx.RespondJSON(res, req, 202, outputDataExport(export))
+}
+
+// dataExport downloads the latest export of the caller once it is ready,
+// and reports its status until then.
+func (cfg *API) dataExport(res http.ResponseWriter, req *http.Request) {
+	claims, ok := cfg.authorize(res, req, auth.RoleUser)
+	if !ok {
+		return
+	}
+
+	export, err := cfg.store.QueryLatestDataExport(req.Context(), claims.UserID)
+	if errors.Is(err, sql.ErrNoRows) {
+		httpx.RespondError(res, req, http.StatusNotFound, httpx.CodeNotFound, "no data export was requested")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(req.Context(), "cannot query data export", "user_id", claims.UserID, "err", err)
+		httpx.RespondError(res, req, http.StatusInternalServerError, httpx.CodeInternal, "")
+		return
+	}
+
+	if export.Status == "ready" {
+		res.Header().Set("Content-Type", "application/zip")
+		res.Header().Set("Content-Disposition", `attachment; filename="chirpy-export.zip"`)
+		res.WriteHeader(200)
+		res.Write(export.Archive)
+		return
+	}
+
+	if export.Status == "failed" {
+		httpx.RespondError(res, req, http.StatusInternalServerError, httpx.CodeExportFailed, "the data export failed, request a new one")
+		return
+	}
+	httpx.RespondJSON(res, req, 202, outputDataExport(export))
+}
+
+func outputDataExport(export database.DataExport) DataExport {
+	out := DataExport{
+		ID:        export.ID,
+		Status:    export.Status,
+		CreatedAt: export.CreatedAt,
+	}
+	if export.CompletedAt.Valid {
+		out.CompletedAt = &export.CompletedAt.Time
+	}
+	return out
+}
+
+// buildDataExport generates the archive of a pending export, or of one
+// whose build is older than dataExportTimeout. Claiming the export first
+// makes it safe to call for the same export more than once.
+func (cfg *API) buildDataExport(ctx context.Context, exportID uuid.UUID) {
+	export, err := cfg.store.ClaimDataExport(ctx, database.ClaimDataExportParams{
+		ID:          exportID,
+		StaleBefore: time.Now().Add(-dataExportTimeout),
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "cannot claim data export", "export_id", exportID, "err", err)
+		return
+	}
+
+	archive, err := cfg.exportArchive(ctx, export.UserID)
+	if err != nil {
+		slog.ErrorContext(ctx, "data export failed", "export_id", export.ID, "err", err)
+		err = cfg.store.FailDataExport(ctx, export.ID)
+		if err != nil {
+			slog.ErrorContext(ctx, "cannot mark data export as failed", "export_id", export.ID, "err", err)
+		}
+		return
+	}
+
+	err = cfg.store.CompleteDataExport(ctx, database.CompleteDataExportParams{
+		ID:      export.ID,
+		Archive: archive,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "cannot save data export", "export_id", export.ID, "err", err)
+		return
+	}
+	slog.InfoContext(ctx, "data export ready", "export_id", export.ID, "user_id", export.UserID)
+}
+
+// exportArchive collects everything Chirpy stores about a user in a ZIP of
+// JSON documents: their profile, chirps and sessions. Chirpy has no likes,
+// so there are none to export.
+func (cfg *API) exportArchive(ctx context.Context, userID uuid.UUID) ([]byte, error) {
+	type session struct {
+		CreatedAt time.Time  `json:"created_at"`
+		ExpiresAt time.Time  `json:"expires_at"`
+		RevokedAt *time.Time `json:"revoked_at"`
+	}
+
+	user, err := cfg.store.QueryUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	chirps, err := cfg.store.QueryAllAuthorChirps(ctx, database.QueryAllAuthorChirpsParams{
+		UserID:   userID,
+		ViewerID: userID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	tokens, err := cfg.store.QueryUserRefreshTokens(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	profile := User{
+		ID:            user.ID,
+		CreatedAt:     user.CreatedAt,
+		UpdatedAt:     user.UpdatedAt,
+		Email:         user.Email,
+		Is_chirpy_red: user.IsChirpyRed,
+		Role:          user.Role,
+	}
+	outChirps := []Chirp{}
+	for _, c := range chirps {
+		outChirps = append(outChirps, Chirp{
+			ID:        c.ID,
+			CreatedAt: c.CreatedAt,
+			UpdatedAt: c.UpdatedAt,
+			Body:      c.Body,
+			User_id:   c.UserID,
+		})
+	}
+	//i token stessi non vengono esportati, solo i dati della sessione
+	sessions := []session{}
+	for _, t := range tokens {
+		s := session{
+			CreatedAt: t.CreatedAt,
+			ExpiresAt: t.ExpiresAt,
+		}
+		if t.RevokedAt.Valid {
+			s.RevokedAt = &t.RevokedAt.Time
+		}
+		sessions = append(sessions, s)
+	}
+
+	files := []struct {
+		name    string
+		content any
+	}{
+		{"profile.json", profile},
+		{"chirps.json", outChirps},
+		{"sessions.json", sessions},
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, f := range files {
+		w, err := zw.Create(f.name)
+		if err != nil {
+			return nil, err
+		}
+		data, err := json.MarshalIndent(f.content, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		_, err = w.Write(data)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = zw.Close()
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
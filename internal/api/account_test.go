@@ -0,0 +1,186 @@
+package api
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"Chirpy/internal/database"
+	"Chirpy/internal/httpx"
+	"Chirpy/internal/store"
+)
+
+func TestDeleteUser(t *testing.T) {
+	s := newTestServer(t)
+	user := s.signup("walt@breakingbad.com", "04234")
+	del := func(token string, body any) request {
+		return request{method: "DELETE", path: "/api/v1/users", token: token, body: body}
+	}
+
+	expectProblem(t, s.do(del("", map[string]string{"password": "04234"})), 401, httpx.CodeUnauthorized)
+	expectProblem(t, s.do(del("garbage", map[string]string{"password": "04234"})), 401, httpx.CodeUnauthorized)
+	expectProblem(t, s.do(del(user.Token, map[string]string{})), 422, httpx.CodeValidation)
+	expectProblem(t, s.do(del(user.Token, map[string]string{"password": "nope"})), 401, httpx.CodeInvalidCredentials)
+
+	expect(t, s.do(del(user.Token, map[string]string{"password": "04234"})), 204, nil)
+	expectProblem(t, s.do(del(user.Token, map[string]string{"password": "04234"})), 401, httpx.CodeUnauthorized)
+	expectProblem(t, s.do(request{method: "POST", path: "/api/v1/refresh", token: user.RefreshToken}), 401, httpx.CodeUnauthorized)
+	//il token di accesso non scade con la cancellazione, ma non può più pubblicare
+	expectProblem(t, s.do(postChirp(user, "I am the one who knocks")), 401, httpx.CodeUnauthorized)
+
+	//il login entro il periodo di grazia annulla la cancellazione
+	user = s.login("walt@breakingbad.com", "04234")
+	stored, err := s.store.QueryUserByID(context.Background(), user.ID)
+	if err != nil || stored.DeletedAt.Valid {
+		t.Errorf("user was not restored by logging in: %+v, %v", stored, err)
+	}
+}
+
+func TestDataExport(t *testing.T) {
+	s := newTestServer(t)
+	user := s.signup("walt@breakingbad.com", "04234")
+	s.chirp(user, "say my name")
+
+	exportPath := "/api/v1/users/export"
+	expectProblem(t, s.do(request{method: "POST", path: exportPath}), 401, httpx.CodeUnauthorized)
+	expectProblem(t, s.do(request{method: "GET", path: exportPath, token: "garbage"}), 401, httpx.CodeUnauthorized)
+	expectProblem(t, s.do(request{method: "GET", path: exportPath, token: user.Token}), 404, httpx.CodeNotFound)
+
+	var export, again DataExport
+	expect(t, s.do(request{method: "POST", path: exportPath, token: user.Token}), 202, &export)
+	if export.Status != "pending" {
+		t.Errorf("export = %+v", export)
+	}
+	//finché è in attesa, una nuova richiesta restituisce lo stesso export
+	expect(t, s.do(request{method: "POST", path: exportPath, token: user.Token}), 202, &again)
+	if again.ID != export.ID {
+		t.Errorf("second request queued %v, want %v", again.ID, export.ID)
+	}
+
+	//l'archivio viene costruito dal job, che lo spegnimento aspetta
+	ctx, cancel := context.WithCancel(context.Background())
+	s.api.StartJobs(ctx)
+	defer func() {
+		cancel()
+		s.api.WaitJobs()
+	}()
+	deadline := time.Now().Add(5 * time.Second)
+	rec := s.do(request{method: "GET", path: exportPath, token: user.Token})
+	for rec.Code == 202 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		rec = s.do(request{method: "GET", path: exportPath, token: user.Token})
+	}
+	expect(t, rec, 200, nil)
+	if rec.Header().Get("Content-Type") != "application/zip" {
+		t.Fatalf("Content-Type = %q; body: %s", rec.Header().Get("Content-Type"), rec.Body)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(rec.Body.Bytes()), int64(rec.Body.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+	}
+	if len(names) != 3 {
+		t.Errorf("archive holds %v", names)
+	}
+}
+
+func TestDataExportRecovery(t *testing.T) {
+	mem := store.NewMemory()
+	s := newTestServerWith(t, testConfig(), mem)
+	ctx := context.Background()
+	user := s.signup("walt@breakingbad.com", "04234")
+	exportPath := "/api/v1/users/export"
+
+	//una costruzione interrotta da un riavvio resta running finché non scade
+	export, err := s.store.CreateDataExport(ctx, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mem.Now = func() time.Time { return time.Now().Add(-2 * dataExportTimeout) }
+	if _, err := s.store.ClaimDataExport(ctx, database.ClaimDataExportParams{ID: export.ID}); err != nil {
+		t.Fatal(err)
+	}
+	mem.Now = time.Now
+	s.api.processPendingExports(ctx)
+	rec := s.do(request{method: "GET", path: exportPath, token: user.Token})
+	if rec.Code != 200 || rec.Header().Get("Content-Type") != "application/zip" {
+		t.Errorf("stale export: status = %d, Content-Type = %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+
+	failed, err := s.store.CreateDataExport(ctx, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.store.FailDataExport(ctx, failed.ID); err != nil {
+		t.Fatal(err)
+	}
+	expectProblem(t, s.do(request{method: "GET", path: exportPath, token: user.Token}), 500, httpx.CodeExportFailed)
+}
+
+func TestJobs(t *testing.T) {
+	mem := store.NewMemory()
+	s := newTestServerWith(t, testConfig(), mem)
+	ctx := context.Background()
+	user := s.signup("walt@breakingbad.com", "04234")
+	s.polka(t, "evt_1", "user.upgraded", user.ID.String(), 204)
+
+	now := time.Now()
+	mem.Now = func() time.Time { return now.Add(2 * accountDeletionGrace) }
+	s.api.expireSubscriptions(ctx)
+	stored, _ := s.store.QueryUserByID(ctx, user.ID)
+	if stored.IsChirpyRed {
+		t.Errorf("subscription did not expire")
+	}
+
+	mem.Now = time.Now
+	s.store.SoftDeleteUser(ctx, user.ID)
+	s.api.purgeDeletedUsers(ctx)
+	if _, err := s.store.QueryUserByID(ctx, user.ID); err != nil {
+		t.Errorf("user was purged within the grace period: %v", err)
+	}
+}
+
+// lockedElsewhere is a store where another replica holds every job lock.
+type lockedElsewhere struct {
+	*store.Memory
+}
+
+func (s lockedElsewhere) WithTx(ctx context.Context, fn func(q database.Querier) error) error {
+	return s.Memory.WithTx(ctx, func(q database.Querier) error {
+		return fn(lockedElsewhereQuerier{q})
+	})
+}
+
+type lockedElsewhereQuerier struct {
+	database.Querier
+}
+
+func (lockedElsewhereQuerier) TryLockJob(ctx context.Context, job string) (bool, error) {
+	return false, nil
+}
+
+func TestPurgeLockedElsewhere(t *testing.T) {
+	mem := store.NewMemory()
+	s := newTestServerWith(t, testConfig(), lockedElsewhere{mem})
+	ctx := context.Background()
+	user := s.signup("walt@breakingbad.com", "04234")
+	mem.Now = func() time.Time { return time.Now().Add(-2 * accountDeletionGrace) }
+	s.store.SoftDeleteUser(ctx, user.ID)
+	mem.Now = time.Now
+
+	//un'altra replica sta già ripulendo: questa salta il giro
+	s.api.purgeDeletedUsers(ctx)
+	if _, err := mem.QueryUserByID(ctx, user.ID); err != nil {
+		t.Errorf("user was purged without the job lock: %v", err)
+	}
+	s.api.store = mem
+	s.api.purgeDeletedUsers(ctx)
+	if _, err := mem.QueryUserByID(ctx, user.ID); err == nil {
+		t.Error("user was not purged once the job lock was free")
+	}
+}
@@ -0,0 +1,257 @@
+// Package api implements the HTTP API of Chirpy on top of a store.Store.
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	"Chirpy/internal/auth"
+	"Chirpy/internal/config"
+	"Chirpy/internal/database"
+	"Chirpy/internal/entitlements"
+	"Chirpy/internal/httpx"
+	"Chirpy/internal/mail"
+	"Chirpy/internal/spam"
+	"Chirpy/internal/store"
+	"Chirpy/internal/tracing"
+)
+
+type API struct {
+	metrics       *Metrics
+	store         store.Store
+	config        config.Config
+	secretToken   string
+	webhookSecret string
+	mailer        mail.Mailer
+	classifier    spam.Classifier
+	schemaTarget  int64
+	shuttingDown  atomic.Bool
+	// exportRequested wakes the export job when a user asks for an export.
+	exportRequested chan struct{}
+	jobs            sync.WaitGroup
+}
+
+// Deps are the optional collaborators of an API; zero values are replaced
+// by defaults built from the configuration.
+type Deps struct {
+	Metrics *Metrics
+	Mailer  mail.Mailer
+	// Classifier judges new chirps; the default holds or refuses them by
+	// their number of links.
+	Classifier spam.Classifier
+	// SchemaTarget is the schema version readiness waits for.
+	SchemaTarget int64
+}
+
+func New(conf config.Config, st store.Store, deps Deps) *API {
+	cfg := &API{
+		metrics:       deps.Metrics,
+		store:         st,
+		config:        conf,
+		secretToken:   conf.JWTSecret,
+		webhookSecret: conf.PolkaSecret,
+		mailer:        deps.Mailer,
+		classifier:    deps.Classifier,
+		schemaTarget:  deps.SchemaTarget,
+
+		exportRequested: make(chan struct{}, 1),
+	}
+	if cfg.metrics == nil {
+		cfg.metrics = NewMetrics()
+	}
+	if cfg.mailer == nil {
+		cfg.mailer = newMailer(conf)
+	}
+	if cfg.classifier == nil {
+		cfg.classifier = spam.Links{Hold: conf.Spam.HoldLinks, Reject: conf.Spam.RejectLinks}
+	}
+	return cfg
+}
+
+// Handler serves every route behind the middleware chain.
+func (cfg *API) Handler() http.Handler {
+	mux := http.NewServeMux()
+	for _, r := range cfg.routes() {
+		mux.Handle(r.pattern, r.handler)
+	}
+	//tracing e access log devono stare dentro a middlewareRequestID:
+	//leggono req.Pattern, che il mux imposta sulla richiesta che riceve
+	return middlewareRequestID(tracing.Middleware(middlewareAccessLog(cfg.middlewareMetrics(middlewareMaxBody(cfg.config.Server.MaxBodyBytes, mux)))))
+}
+
+// Drain makes readiness fail, so that load balancers stop sending traffic
+// while the server shuts down.
+func (cfg *API) Drain() {
+	cfg.shuttingDown.Store(true)
+}
+
+func serverStatus(res http.ResponseWriter, req *http.Request) {
+	res.Header().Set("Content-type", "text/plain; charset=utf-8")
+	res.WriteHeader(http.StatusOK)
+	res.Write([]byte("OK"))
+}
+
+func (cfg *API) middlewareMetricsInc(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		cfg.metrics.fileserverHits.Add(1) // Safely increment the counter
+		next.ServeHTTP(res, req)          // Pass control to the next handler
+	})
+}
+
+type claimsKey struct{}
+
+// requireRole only lets through requests carrying a valid access token whose
+// role grants at least the given one; next gets the claims of the token
+// from requestClaims.
+func (cfg *API) requireRole(role string, next http.HandlerFunc) http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		claims, ok := cfg.authorize(res, req, role)
+		if !ok {
+			return
+		}
+		next(res, req.WithContext(context.WithValue(req.Context(), claimsKey{}, claims)))
+	}
+}
+
+// requestClaims returns the claims that requireRole checked for req.
+func requestClaims(req *http.Request) auth.TokenClaims {
+	claims, _ := req.Context().Value(claimsKey{}).(auth.TokenClaims)
+	return claims
+}
+
+// authorize returns the claims of the access token of req when the current
+// role of its user grants at least the given one; otherwise it writes the
+// 401 or 403 and returns false. The user is read from the store rather than
+// trusted from the token, so that a demotion, a sanction or a deletion takes
+// effect before the token expires; the returned claims carry the current
+// role.
+func (cfg *API) authorize(res http.ResponseWriter, req *http.Request, role string) (auth.TokenClaims, bool) {
+	_, claims, ok := cfg.authenticate(res, req)
+	if !ok {
+		return auth.TokenClaims{}, false
+	}
+	if _, ok := cfg.currentPlan(res, req, claims); !ok {
+		return auth.TokenClaims{}, false
+	}
+	if !auth.HasRole(claims.Role, role) {
+		slog.WarnContext(req.Context(), "role too low for route", "user_id", claims.UserID, "role", claims.Role, "required_role", role)
+		httpx.RespondError(res, req, http.StatusForbidden, httpx.CodeForbidden, "this route requires the "+role+" role")
+		return auth.TokenClaims{}, false
+	}
+	return claims, true
+}
+
+// authenticate returns the user behind the access token of req and its
+// claims with the current role. It writes the 401 or 403 and returns false
+// when the token is missing or invalid, or the user was deleted or is
+// restricted.
+func (cfg *API) authenticate(res http.ResponseWriter, req *http.Request) (database.User, auth.TokenClaims, bool) {
+	reqBearer, err := auth.GetBearerToken(req.Header)
+	if err != nil || reqBearer == "" {
+		httpx.RespondError(res, req, http.StatusUnauthorized, httpx.CodeUnauthorized, "missing bearer token")
+		return database.User{}, auth.TokenClaims{}, false
+	}
+	claims, err := auth.ParseJWT(reqBearer, cfg.secretToken)
+	if err != nil {
+		slog.InfoContext(req.Context(), "invalid access token", "err", err)
+		httpx.RespondError(res, req, http.StatusUnauthorized, httpx.CodeUnauthorized, "invalid access token")
+		return database.User{}, auth.TokenClaims{}, false
+	}
+	user, err := cfg.store.QueryUserByID(req.Context(), claims.UserID)
+	if errors.Is(err, sql.ErrNoRows) || err == nil && user.DeletedAt.Valid {
+		httpx.RespondError(res, req, http.StatusUnauthorized, httpx.CodeUnauthorized, "the account was deleted")
+		return database.User{}, auth.TokenClaims{}, false
+	}
+	if err != nil {
+		slog.ErrorContext(req.Context(), "cannot query user", "user_id", claims.UserID, "err", err)
+		httpx.RespondError(res, req, http.StatusInternalServerError, httpx.CodeInternal, "")
+		return database.User{}, auth.TokenClaims{}, false
+	}
+	if respondRestricted(res, req, user) {
+		return database.User{}, auth.TokenClaims{}, false
+	}
+	claims.Role = user.Role
+	return user, claims, true
+}
+
+// currentPlan returns the plan of the user of claims. When the token claims
+// a Chirpy Red status the plan no longer has, it answers 401 so that the
+// client refreshes the token and gets one that matches the plan.
+func (cfg *API) currentPlan(res http.ResponseWriter, req *http.Request, claims auth.TokenClaims) (entitlements.Plan, bool) {
+	plan, err := userPlan(req.Context(), cfg.store, claims.UserID)
+	if err != nil {
+		slog.ErrorContext(req.Context(), "cannot query plan", "user_id", claims.UserID, "err", err)
+		httpx.RespondError(res, req, http.StatusInternalServerError, httpx.CodeInternal, "")
+		return "", false
+	}
+	if claims.ChirpyRed != (plan == entitlements.PlanChirpyRed) {
+		slog.InfoContext(req.Context(), "access token of a previous plan", "user_id", claims.UserID, "plan", plan)
+		httpx.RespondError(res, req, http.StatusUnauthorized, httpx.CodeUnauthorized, "the plan changed, refresh the access token")
+		return "", false
+	}
+	return plan, true
+}
+
+// respondStoreError answers a request whose write failed. Constraint
+// violations are the client's fault and get a 409 or a 422; anything else
+// is logged with msg and args and becomes a 500.
+func respondStoreError(res http.ResponseWriter, req *http.Request, err error, msg string, args ...any) {
+	err = store.Classify(err)
+	args = append(args, "err", err)
+	switch {
+	case errors.Is(err, store.ErrUniqueViolation):
+		slog.InfoContext(req.Context(), msg, args...)
+		httpx.RespondError(res, req, http.StatusConflict, httpx.CodeConflict, "the request conflicts with existing data")
+	case errors.Is(err, store.ErrForeignKeyViolation):
+		slog.InfoContext(req.Context(), msg, args...)
+		httpx.RespondError(res, req, http.StatusUnprocessableEntity, httpx.CodeConstraint, "the request refers to data that does not exist")
+	case errors.Is(err, store.ErrCheckViolation):
+		slog.InfoContext(req.Context(), msg, args...)
+		httpx.RespondError(res, req, http.StatusUnprocessableEntity, httpx.CodeConstraint, "the request breaks a rule of the data model")
+	default:
+		slog.ErrorContext(req.Context(), msg, args...)
+		httpx.RespondError(res, req, http.StatusInternalServerError, httpx.CodeInternal, "")
+	}
+}
+
+func (cfg *API) resetServerCount(res http.ResponseWriter, req *http.Request) {
+	plat := cfg.config.Platform
+	if plat != "dev" {
+		slog.WarnContext(req.Context(), "reset refused outside dev", "platform", plat)
+		httpx.RespondError(res, req, http.StatusForbidden, httpx.CodeForbidden, "reset is only allowed on the dev platform")
+		return
+	}
+
+	//il registro di audit non si cancella: registra anche il reset
+	actor := cfg.auditActorOf(req)
+	err := cfg.auditTx(req.Context(), func(q database.Querier) error {
+		if err := q.DeleteUsers(req.Context()); err != nil {
+			return err
+		}
+		return appendAudit(req.Context(), q, actor, auditRecord{action: "reset", targetType: "users"})
+	})
+	if err != nil {
+		slog.ErrorContext(req.Context(), "reset failed", "err", err)
+		httpx.RespondError(res, req, http.StatusInternalServerError, httpx.CodeInternal, "")
+		return
+	}
+	res.WriteHeader(http.StatusOK)
+}
+
+func (cfg *API) serverCount(res http.ResponseWriter, req *http.Request) {
+	res.Header().Set("Content-type", "text/html")
+	res.WriteHeader(http.StatusOK)
+	msg := fmt.Sprintf(`<html>
+		<body>
+			<h1>Welcome, Chirpy Admin</h1>
+			<p>Chirpy has been visited %d times!</p>
+		</body>
+		</html>`, cfg.metrics.fileserverHits.Load())
+	res.Write([]byte(msg))
+}
@@ -0,0 +1,398 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+
+	"Chirpy/internal/auth"
+	"Chirpy/internal/config"
+	"Chirpy/internal/database"
+	"Chirpy/internal/httpx"
+	"Chirpy/internal/mail"
+	"Chirpy/internal/store"
+)
+
+// testServer runs the API on an in-memory store.
+type testServer struct {
+	t       *testing.T
+	api     *API
+	store   store.Store
+	mailer  *recordingMailer
+	handler http.Handler
+}
+
+func testConfig() config.Config {
+	conf := config.Default()
+	conf.Platform = "dev"
+	conf.JWTSecret = "test-jwt-secret"
+	conf.PolkaSecret = "test-polka-secret"
+	conf.Auth.BcryptCost = bcrypt.MinCost
+	return conf
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	return newTestServerWith(t, testConfig(), store.NewMemory())
+}
+
+func newTestServerWith(t *testing.T, conf config.Config, st store.Store) *testServer {
+	t.Helper()
+	mailer := &recordingMailer{}
+	api := New(conf, st, Deps{Mailer: mailer})
+	return &testServer{t: t, api: api, store: st, mailer: mailer, handler: api.Handler()}
+}
+
+// recordingMailer keeps the messages it is asked to send.
+type recordingMailer struct {
+	mu   sync.Mutex
+	sent []mail.Message
+}
+
+func (m *recordingMailer) Send(ctx context.Context, msg mail.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+func (m *recordingMailer) last(to string) (mail.Message, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := len(m.sent) - 1; i >= 0; i-- {
+		if m.sent[i].To == to {
+			return m.sent[i], true
+		}
+	}
+	return mail.Message{}, false
+}
+
+// request is one call to the test server. Body is sent as is when it is a
+// string and encoded as JSON otherwise.
+type request struct {
+	method, path string
+	token        string
+	body         any
+	header       http.Header
+}
+
+func (s *testServer) do(r request) *httptest.ResponseRecorder {
+	s.t.Helper()
+	var body io.Reader
+	switch b := r.body.(type) {
+	case nil:
+	case string:
+		body = strings.NewReader(b)
+	default:
+		data, err := json.Marshal(b)
+		if err != nil {
+			s.t.Fatal(err)
+		}
+		body = bytes.NewReader(data)
+	}
+	req := httptest.NewRequest(r.method, r.path, body)
+	for k, v := range r.header {
+		req.Header[k] = v
+	}
+	if r.token != "" {
+		req.Header.Set("Authorization", "Bearer "+r.token)
+	}
+	rec := httptest.NewRecorder()
+	s.handler.ServeHTTP(rec, req)
+	s.checkOpenAPI(req, rec)
+	return rec
+}
+
+// expect checks the status of a response and decodes its body into out.
+func expect(t *testing.T, rec *httptest.ResponseRecorder, status int, out any) {
+	t.Helper()
+	if rec.Code != status {
+		t.Fatalf("status = %d, want %d; body: %s", rec.Code, status, rec.Body)
+	}
+	if out != nil {
+		if err := json.Unmarshal(rec.Body.Bytes(), out); err != nil {
+			t.Fatalf("decoding %s: %v", rec.Body, err)
+		}
+	}
+}
+
+// expectProblem checks that a response is a problem with the given code.
+func expectProblem(t *testing.T, rec *httptest.ResponseRecorder, status int, code string) httpx.Problem {
+	t.Helper()
+	var problem httpx.Problem
+	expect(t, rec, status, &problem)
+	if problem.Code != code {
+		t.Errorf("code = %q, want %q", problem.Code, code)
+	}
+	return problem
+}
+
+// signup creates a user through the API and logs them in.
+func (s *testServer) signup(email, password string) User {
+	s.t.Helper()
+	expect(s.t, s.do(request{method: "POST", path: "/api/v1/users", body: map[string]string{
+		"email":    email,
+		"password": password,
+	}}), 201, nil)
+	return s.login(email, password)
+}
+
+func (s *testServer) login(email, password string) User {
+	s.t.Helper()
+	var user User
+	expect(s.t, s.do(request{method: "POST", path: "/api/v1/login", body: map[string]string{
+		"email":    email,
+		"password": password,
+	}}), 200, &user)
+	return user
+}
+
+// signupAs creates a user with role and returns them logged in.
+func (s *testServer) signupAs(email, role string) User {
+	s.t.Helper()
+	user := s.signup(email, "password")
+	_, err := s.store.SetUserRole(context.Background(), database.SetUserRoleParams{ID: user.ID, Role: role})
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	return s.login(email, "password")
+}
+
+func TestOpsRoutes(t *testing.T) {
+	s := newTestServer(t)
+
+	tests := []struct {
+		name, path  string
+		wantStatus  int
+		wantType    string
+		wantContain string
+	}{
+		{"healthz", "/api/healthz", 200, "text/plain; charset=utf-8", "OK"},
+		{"readyz", "/api/readyz", 200, "application/json", `"status":"ok"`},
+		{"openapi", "/api/openapi.json", 200, "application/json", `"openapi"`},
+		{"docs", "/api/docs", 200, "text/html; charset=utf-8", "swagger-ui"},
+		{"metrics", "/metrics", 200, "", "chirpy_http_requests_total"},
+		{"unknown route", "/api/v1/nope", 404, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := s.do(request{method: "GET", path: tt.path})
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if tt.wantType != "" && rec.Header().Get("Content-Type") != tt.wantType {
+				t.Errorf("Content-Type = %q, want %q", rec.Header().Get("Content-Type"), tt.wantType)
+			}
+			if !strings.Contains(rec.Body.String(), tt.wantContain) {
+				t.Errorf("body does not contain %q: %s", tt.wantContain, rec.Body)
+			}
+			if rec.Header().Get(requestIDHeader) == "" {
+				t.Errorf("response has no %s", requestIDHeader)
+			}
+		})
+	}
+}
+
+func TestMetricsMethodLabel(t *testing.T) {
+	api := New(testConfig(), store.NewMemory(), Deps{Mailer: &recordingMailer{}})
+	handler := api.Handler()
+
+	//i metodi inventati finiscono tutti in "other"
+	for _, method := range []string{"GET", "BREW", "PROPFIND"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(method, "/api/healthz", nil))
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+	if !strings.Contains(body, `method="GET"`) || !strings.Contains(body, `method="other"`) {
+		t.Errorf("metrics miss the GET or other method:\n%s", body)
+	}
+	if strings.Contains(body, "BREW") || strings.Contains(body, "PROPFIND") {
+		t.Errorf("metrics label a method of the client:\n%s", body)
+	}
+}
+
+// unreachableStore fails every ping.
+type unreachableStore struct {
+	*store.Memory
+}
+
+func (unreachableStore) Ping(ctx context.Context) error {
+	return errors.New("connection refused")
+}
+
+func TestReadiness(t *testing.T) {
+	t.Run("draining", func(t *testing.T) {
+		s := newTestServer(t)
+		s.api.Drain()
+		rec := s.do(request{method: "GET", path: "/api/readyz"})
+		expect(t, rec, 503, nil)
+		if !strings.Contains(rec.Body.String(), `"shutdown"`) {
+			t.Errorf("body = %s", rec.Body)
+		}
+	})
+
+	t.Run("database unreachable", func(t *testing.T) {
+		api := New(testConfig(), unreachableStore{store.NewMemory()}, Deps{Mailer: &recordingMailer{}})
+		rec := httptest.NewRecorder()
+		api.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/api/readyz", nil))
+		expect(t, rec, 503, nil)
+		if !strings.Contains(rec.Body.String(), `"database":"unreachable"`) {
+			t.Errorf("body = %s", rec.Body)
+		}
+	})
+}
+
+func TestFileserverHits(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.signupAs("admin@chirpy.test", auth.RoleAdmin)
+
+	s.do(request{method: "GET", path: "/app/"})
+	s.do(request{method: "GET", path: "/app/assets/logo.png"})
+
+	rec := s.do(request{method: "GET", path: "/admin/metrics", token: admin.Token})
+	expect(t, rec, 200, nil)
+	if !strings.Contains(rec.Body.String(), "visited 2 times") {
+		t.Errorf("body = %s", rec.Body)
+	}
+}
+
+func TestAdminRoutesRequireAdmin(t *testing.T) {
+	s := newTestServer(t)
+	user := s.signup("walt@breakingbad.com", "password")
+	moderator := s.signupAs("mod@chirpy.test", auth.RoleModerator)
+
+	routes := []struct{ method, path string }{
+		{"GET", "/admin/metrics"},
+		{"POST", "/admin/reset"},
+		{"PUT", "/admin/users/" + user.ID.String() + "/role"},
+		{"POST", "/admin/webhooks/evt_1/replay"},
+	}
+	for _, r := range routes {
+		t.Run(r.method+" "+r.path, func(t *testing.T) {
+			expectProblem(t, s.do(request{method: r.method, path: r.path}), 401, httpx.CodeUnauthorized)
+			expectProblem(t, s.do(request{method: r.method, path: r.path, token: "garbage"}), 401, httpx.CodeUnauthorized)
+			expectProblem(t, s.do(request{method: r.method, path: r.path, token: user.Token}), 403, httpx.CodeForbidden)
+			expectProblem(t, s.do(request{method: r.method, path: r.path, token: moderator.Token}), 403, httpx.CodeForbidden)
+		})
+	}
+}
+
+func TestStaffTokensFollowTheStore(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.signupAs("admin@chirpy.test", auth.RoleAdmin)
+	demoted := s.signupAs("demoted@chirpy.test", auth.RoleAdmin)
+	banned := s.signupAs("banned@chirpy.test", auth.RoleModerator)
+
+	//i token emessi prima del cambio valgono ancora un'ora, ma il ruolo e lo stato si leggono dal database
+	expect(t, s.do(request{method: "PUT", path: "/admin/users/" + demoted.ID.String() + "/role", token: admin.Token,
+		body: map[string]string{"role": "user"}}), 200, nil)
+	expectProblem(t, s.do(request{method: "GET", path: "/admin/audit", token: demoted.Token}), 403, httpx.CodeForbidden)
+
+	expect(t, s.do(request{method: "POST", path: "/admin/users/" + banned.ID.String() + "/sanctions", token: admin.Token,
+		body: map[string]string{"status": "banned", "reason": "abuse"}}), 201, nil)
+	expectProblem(t, s.do(request{method: "GET", path: "/admin/reports", token: banned.Token}), 403, httpx.CodeAccountBanned)
+}
+
+func TestModeratorRoutesRequireModerator(t *testing.T) {
+	s := newTestServer(t)
+	user := s.signup("walt@breakingbad.com", "password")
+	id := uuid.NewString()
+
+	routes := []struct{ method, path string }{
+		{"GET", "/admin/users/" + id + "/sanctions"},
+		{"POST", "/admin/users/" + id + "/sanctions"},
+		{"DELETE", "/admin/users/" + id + "/sanctions"},
+		{"GET", "/admin/reports"},
+		{"GET", "/admin/reports/" + id},
+		{"POST", "/admin/reports/" + id + "/assign"},
+		{"POST", "/admin/reports/" + id + "/resolve"},
+		{"GET", "/admin/chirps/held"},
+		{"POST", "/admin/chirps/" + id + "/release"},
+	}
+	for _, r := range routes {
+		t.Run(r.method+" "+r.path, func(t *testing.T) {
+			expectProblem(t, s.do(request{method: r.method, path: r.path}), 401, httpx.CodeUnauthorized)
+			expectProblem(t, s.do(request{method: r.method, path: r.path, token: "garbage"}), 401, httpx.CodeUnauthorized)
+			expectProblem(t, s.do(request{method: r.method, path: r.path, token: user.Token}), 403, httpx.CodeForbidden)
+		})
+	}
+}
+
+func TestReset(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.signupAs("admin@chirpy.test", auth.RoleAdmin)
+	s.signup("walt@breakingbad.com", "password")
+
+	expect(t, s.do(request{method: "POST", path: "/admin/reset", token: admin.Token}), 200, nil)
+	if _, err := s.store.QueryUser(context.Background(), "walt@breakingbad.com"); err == nil {
+		t.Errorf("users survived the reset")
+	}
+
+	conf := testConfig()
+	conf.Platform = "production"
+	prod := newTestServerWith(t, conf, store.NewMemory())
+	admin = prod.signupAs("admin@chirpy.test", auth.RoleAdmin)
+	expectProblem(t, prod.do(request{method: "POST", path: "/admin/reset", token: admin.Token}), 403, httpx.CodeForbidden)
+}
+
+func TestSetUserRole(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.signupAs("admin@chirpy.test", auth.RoleAdmin)
+	user := s.signup("walt@breakingbad.com", "password")
+
+	tests := []struct {
+		name       string
+		userID     string
+		body       any
+		wantStatus int
+		wantCode   string
+	}{
+		{"promote", user.ID.String(), map[string]string{"role": "moderator"}, 200, ""},
+		{"unknown role", user.ID.String(), map[string]string{"role": "root"}, 422, httpx.CodeValidation},
+		{"malformed id", "walt", map[string]string{"role": "admin"}, 400, httpx.CodeInvalidRequest},
+		{"unknown user", "00000000-0000-0000-0000-000000000000", map[string]string{"role": "admin"}, 404, httpx.CodeNotFound},
+		{"invalid json", user.ID.String(), "{", 400, httpx.CodeInvalidJSON},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := s.do(request{method: "PUT", path: "/admin/users/" + tt.userID + "/role", token: admin.Token, body: tt.body})
+			if tt.wantCode != "" {
+				expectProblem(t, rec, tt.wantStatus, tt.wantCode)
+				return
+			}
+			var out User
+			expect(t, rec, tt.wantStatus, &out)
+			if out.Role != "moderator" {
+				t.Errorf("role = %q", out.Role)
+			}
+		})
+	}
+
+	//il nuovo ruolo vale dal prossimo refresh
+	var refreshed struct{ Token string }
+	expect(t, s.do(request{method: "POST", path: "/api/v1/refresh", token: user.RefreshToken}), 200, &refreshed)
+	claims, err := auth.ParseJWT(refreshed.Token, s.api.secretToken)
+	if err != nil || claims.Role != auth.RoleModerator {
+		t.Errorf("refreshed claims = %+v, %v", claims, err)
+	}
+}
+
+func TestLegacyAlias(t *testing.T) {
+	s := newTestServer(t)
+	rec := s.do(request{method: "GET", path: "/api/chirps"})
+	expect(t, rec, 200, nil)
+	if rec.Header().Get("Deprecation") == "" || !strings.Contains(rec.Header().Get("Link"), "/api/v1/chirps") {
+		t.Errorf("headers = %v", rec.Header())
+	}
+}
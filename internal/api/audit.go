@@ -0,0 +1,309 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"Chirpy/internal/audit"
+	"Chirpy/internal/database"
+	"Chirpy/internal/httpx"
+	"Chirpy/internal/logging"
+	"Chirpy/internal/store"
+	"Chirpy/internal/validate"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultAuditEntries = 100
+	maxAuditEntries     = 500
+	// auditAttempts is how many times auditTx runs a transaction that lost
+	// the race for the next seq of the audit log.
+	auditAttempts = 3
+)
+
+var errAuditRace = errors.New("audit log appended concurrently")
+
+type AuditEntry struct {
+	Seq        int64           `json:"seq"`
+	CreatedAt  time.Time       `json:"created_at"`
+	ActorID    *uuid.UUID      `json:"actor_id"`
+	Action     string          `json:"action"`
+	TargetType string          `json:"target_type"`
+	TargetID   string          `json:"target_id,omitempty"`
+	IP         string          `json:"ip,omitempty"`
+	RequestID  string          `json:"request_id,omitempty"`
+	Before     json.RawMessage `json:"before"`
+	After      json.RawMessage `json:"after"`
+	PrevHash   string          `json:"prev_hash"`
+	Hash       string          `json:"hash"`
+}
+
+type AuditVerification struct {
+	Valid    bool   `json:"valid"`
+	Entries  int    `json:"entries"`
+	BrokenAt *int64 `json:"broken_at"`
+}
+
+// auditActor is who did an audited action and from where. Actions of Polka
+// and of the server itself have no user.
+type auditActor struct {
+	userID    uuid.NullUUID
+	ip        string
+	requestID string
+}
+
+func (cfg *API) auditActorOf(req *http.Request) auditActor {
+	actor := auditActor{ip: req.RemoteAddr, requestID: logging.RequestID(req.Context())}
+	if host, _, err := net.SplitHostPort(req.RemoteAddr); err == nil {
+		actor.ip = host
+	}
+	if id := cfg.viewerID(req); id != uuid.Nil {
+		actor.userID = uuid.NullUUID{UUID: id, Valid: true}
+	}
+	return actor
+}
+
+// auditRecord is what an audited action did to its target; before and
+// after are snapshots of the target, encoded to JSON, nil when it did not
+// exist.
+type auditRecord struct {
+	action     string
+	targetType string
+	targetID   string
+	before     any
+	after      any
+}
+
+// appendAudit chains a new entry to the audit log. It must run in the
+// transaction of the action it records, so that neither is kept without
+// the other; when another transaction appended first it fails with
+// errAuditRace.
+func appendAudit(ctx context.Context, q database.Querier, actor auditActor, r auditRecord) error {
+	last, err := q.QueryLastAuditEntry(ctx)
+	var prev *audit.Entry
+	switch {
+	case err == nil:
+		e := auditEntry(last)
+		prev = &e
+	case !errors.Is(err, sql.ErrNoRows):
+		return err
+	}
+
+	before, err := auditSnapshot(r.before)
+	if err != nil {
+		return err
+	}
+	after, err := auditSnapshot(r.after)
+	if err != nil {
+		return err
+	}
+	e := audit.Next(prev, audit.Entry{
+		CreatedAt:  time.Now(),
+		ActorID:    nullUUIDString(actor.userID),
+		Action:     r.action,
+		TargetType: r.targetType,
+		TargetID:   r.targetID,
+		IP:         actor.ip,
+		RequestID:  actor.requestID,
+		Before:     before.String,
+		After:      after.String,
+	})
+	_, err = q.CreateAuditEntry(ctx, database.CreateAuditEntryParams{
+		Seq:         e.Seq,
+		CreatedAt:   e.CreatedAt,
+		ActorID:     actor.userID,
+		Action:      e.Action,
+		TargetType:  e.TargetType,
+		TargetID:    nullString(e.TargetID),
+		Ip:          nullString(e.IP),
+		RequestID:   nullString(e.RequestID),
+		BeforeState: before,
+		AfterState:  after,
+		PrevHash:    e.PrevHash,
+		Hash:        e.Hash,
+	})
+	if errors.Is(store.Classify(err), store.ErrUniqueViolation) {
+		return fmt.Errorf("%w: %w", errAuditRace, err)
+	}
+	return err
+}
+
+// auditTx runs fn in a transaction like WithTx, running it again when its
+// appendAudit lost the race for the next entry.
+func (cfg *API) auditTx(ctx context.Context, fn func(q database.Querier) error) error {
+	var err error
+	for range auditAttempts {
+		err = cfg.store.WithTx(ctx, fn)
+		if !errors.Is(err, errAuditRace) {
+			return err
+		}
+		slog.DebugContext(ctx, "audit log race, retrying", "err", err)
+	}
+	return err
+}
+
+func auditSnapshot(v any) (sql.NullString, error) {
+	if v == nil {
+		return sql.NullString{}, nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return sql.NullString{}, err
+	}
+	return sql.NullString{String: string(b), Valid: true}, nil
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+func nullUUIDString(id uuid.NullUUID) string {
+	if !id.Valid {
+		return ""
+	}
+	return id.UUID.String()
+}
+
+// auditEntry is the form of a stored entry that gets hashed.
+func auditEntry(e database.AuditLog) audit.Entry {
+	return audit.Entry{
+		Seq:        e.Seq,
+		CreatedAt:  e.CreatedAt,
+		ActorID:    nullUUIDString(e.ActorID),
+		Action:     e.Action,
+		TargetType: e.TargetType,
+		TargetID:   e.TargetID.String,
+		IP:         e.Ip.String,
+		RequestID:  e.RequestID.String,
+		Before:     e.BeforeState.String,
+		After:      e.AfterState.String,
+		PrevHash:   e.PrevHash,
+		Hash:       e.Hash,
+	}
+}
+
+func outputAuditEntry(e database.AuditLog) AuditEntry {
+	out := AuditEntry{
+		Seq:        e.Seq,
+		CreatedAt:  e.CreatedAt,
+		Action:     e.Action,
+		TargetType: e.TargetType,
+		TargetID:   e.TargetID.String,
+		IP:         e.Ip.String,
+		RequestID:  e.RequestID.String,
+		PrevHash:   e.PrevHash,
+		Hash:       e.Hash,
+	}
+	if e.ActorID.Valid {
+		out.ActorID = &e.ActorID.UUID
+	}
+	if e.BeforeState.Valid {
+		out.Before = json.RawMessage(e.BeforeState.String)
+	}
+	if e.AfterState.Valid {
+		out.After = json.RawMessage(e.AfterState.String)
+	}
+	return out
+}
+
+func respondInvalidQuery(res http.ResponseWriter, req *http.Request, field, rule, message string) {
+	httpx.RespondInvalid(res, req, http.StatusBadRequest, httpx.CodeInvalidRequest, "invalid query parameter", validate.Errors{{
+		Field:   field,
+		Rule:    rule,
+		Message: message,
+	}})
+}
+
+// listAudit lists the audit log newest first. The filters are combined;
+// to page through the log, pass the seq of the last entry received as
+// before.
+func (cfg *API) listAudit(res http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+	arg := database.QueryAuditLogParams{
+		Action:     nullString(query.Get("action")),
+		TargetType: nullString(query.Get("target_type")),
+		TargetID:   nullString(query.Get("target_id")),
+		MaxEntries: defaultAuditEntries,
+	}
+	if a := query.Get("actor_id"); a != "" {
+		actorID, ok := httpx.ParseUUID(res, req, "actor_id", a)
+		if !ok {
+			return
+		}
+		arg.ActorID = uuid.NullUUID{UUID: actorID, Valid: true}
+	}
+	for _, p := range []struct {
+		name string
+		to   *sql.NullTime
+	}{{"since", &arg.Since}, {"until", &arg.Until}} {
+		v := query.Get(p.name)
+		if v == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			respondInvalidQuery(res, req, p.name, "datetime", "must be an RFC 3339 timestamp")
+			return
+		}
+		*p.to = sql.NullTime{Time: t.UTC(), Valid: true}
+	}
+	if b := query.Get("before"); b != "" {
+		seq, err := strconv.ParseInt(b, 10, 64)
+		if err != nil || seq < 1 {
+			respondInvalidQuery(res, req, "before", "min", "must be a positive seq")
+			return
+		}
+		arg.BeforeSeq = sql.NullInt64{Int64: seq, Valid: true}
+	}
+	if l := query.Get("limit"); l != "" {
+		limit, err := strconv.Atoi(l)
+		if err != nil || limit < 1 || limit > maxAuditEntries {
+			respondInvalidQuery(res, req, "limit", "range", "must be between 1 and "+strconv.Itoa(maxAuditEntries))
+			return
+		}
+		arg.MaxEntries = int32(limit)
+	}
+
+	entries, err := cfg.store.QueryAuditLog(req.Context(), arg)
+	if err != nil {
+		slog.ErrorContext(req.Context(), "cannot query audit log", "err", err)
+		httpx.RespondError(res, req, http.StatusInternalServerError, httpx.CodeInternal, "")
+		return
+	}
+	out := []AuditEntry{}
+	for _, e := range entries {
+		out = append(out, outputAuditEntry(e))
+	}
+	httpx.RespondJSON(res, req, 200, out)
+}
+
+// verifyAudit walks the whole audit log and reports the first entry that
+// was changed, removed or reordered.
+func (cfg *API) verifyAudit(res http.ResponseWriter, req *http.Request) {
+	chain, err := cfg.store.QueryAuditChain(req.Context())
+	if err != nil {
+		slog.ErrorContext(req.Context(), "cannot query audit log", "err", err)
+		httpx.RespondError(res, req, http.StatusInternalServerError, httpx.CodeInternal, "")
+		return
+	}
+	entries := make([]audit.Entry, 0, len(chain))
+	for _, e := range chain {
+		entries = append(entries, auditEntry(e))
+	}
+	out := AuditVerification{Entries: len(entries)}
+	broken, ok := audit.Verify(entries)
+	out.Valid = ok
+	if !ok {
+		out.BrokenAt = &broken
+		slog.ErrorContext(req.Context(), "audit log chain is broken", "seq", broken)
+	}
+	httpx.RespondJSON(res, req, 200, out)
+}
@@ -0,0 +1,223 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"Chirpy/internal/audit"
+	"Chirpy/internal/auth"
+	"Chirpy/internal/database"
+	"Chirpy/internal/httpx"
+	"Chirpy/internal/spam"
+	"Chirpy/internal/store"
+)
+
+func (s *testServer) auditLog(token, query string) []AuditEntry {
+	s.t.Helper()
+	var entries []AuditEntry
+	expect(s.t, s.do(request{method: "GET", path: "/admin/audit" + query, token: token}), 200, &entries)
+	return entries
+}
+
+func (s *testServer) verifyAudit(token string) AuditVerification {
+	s.t.Helper()
+	var out AuditVerification
+	expect(s.t, s.do(request{method: "GET", path: "/admin/audit/verify", token: token}), 200, &out)
+	return out
+}
+
+func TestAuditLog(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.signupAs("admin@chirpy.test", auth.RoleAdmin)
+	moderator := s.signupAs("mod@chirpy.test", auth.RoleModerator)
+	walt := s.signup("walt@breakingbad.com", "04234")
+	jesse := s.signup("jesse@breakingbad.com", "password")
+	chirp := s.chirp(walt, "say my name")
+	chirpPath := "/api/v1/chirps/" + chirp.ID.String()
+
+	deletion := s.do(request{method: "DELETE", path: chirpPath, token: moderator.Token, body: map[string]string{"reason": "spoilers"}})
+	expect(t, deletion, 204, nil)
+	expect(t, s.do(request{method: "POST", path: chirpPath + "/restore", token: moderator.Token}), 200, nil)
+	s.polka(t, "evt_1", "user.upgraded", walt.ID.String(), 204)
+	expect(t, s.do(request{method: "PUT", path: "/admin/users/" + jesse.ID.String() + "/role", token: admin.Token,
+		body: map[string]string{"role": "moderator"}}), 200, nil)
+	expect(t, s.do(request{method: "POST", path: "/admin/users/" + jesse.ID.String() + "/sanctions", token: admin.Token,
+		body: map[string]string{"status": "banned", "reason": "cook"}}), 201, nil)
+
+	expectProblem(t, s.do(request{method: "GET", path: "/admin/audit", token: moderator.Token}), 403, httpx.CodeForbidden)
+	expectProblem(t, s.do(request{method: "GET", path: "/admin/audit/verify", token: moderator.Token}), 403, httpx.CodeForbidden)
+
+	entries := s.auditLog(admin.Token, "")
+	var actions []string
+	for _, e := range entries {
+		actions = append(actions, e.Action)
+	}
+	want := []string{"user.sanction", "user.role", "webhook.user.upgraded", "chirp.restore", "chirp.delete"}
+	if !slices.Equal(actions, want) {
+		t.Fatalf("actions = %v, want %v", actions, want)
+	}
+
+	deleted := entries[4]
+	if deleted.Seq != 1 || deleted.ActorID == nil || *deleted.ActorID != moderator.ID || deleted.TargetType != "chirp" ||
+		deleted.TargetID != chirp.ID.String() || deleted.IP != "192.0.2.1" || deleted.RequestID != deletion.Header().Get(requestIDHeader) {
+		t.Errorf("deletion entry = %+v", deleted)
+	}
+	var before, after Chirp
+	if err := json.Unmarshal(deleted.Before, &before); err != nil || before.Body != "say my name" || before.DeletedAt != nil {
+		t.Errorf("before = %s, %v", deleted.Before, err)
+	}
+	if err := json.Unmarshal(deleted.After, &after); err != nil || after.DeletedAt == nil || after.DeletionReason != "spoilers" {
+		t.Errorf("after = %s, %v", deleted.After, err)
+	}
+	upgrade := entries[2]
+	if upgrade.ActorID != nil || upgrade.TargetID != walt.ID.String() ||
+		string(upgrade.Before) != `{"is_chirpy_red":false}` || string(upgrade.After) != `{"is_chirpy_red":true}` {
+		t.Errorf("upgrade entry = %+v", upgrade)
+	}
+	if role := entries[1]; string(role.Before) != `{"role":"user"}` || string(role.After) != `{"role":"moderator"}` {
+		t.Errorf("role entry = %+v", role)
+	}
+	if deleted.PrevHash != audit.Genesis || entries[3].PrevHash != deleted.Hash {
+		t.Errorf("chain = %s <- %s", deleted.Hash, entries[3].PrevHash)
+	}
+
+	tests := []struct {
+		name  string
+		query string
+		want  int
+	}{
+		{"action", "?action=chirp.delete", 1},
+		{"actor", "?actor_id=" + moderator.ID.String(), 2},
+		{"target", "?target_type=chirp&target_id=" + chirp.ID.String(), 2},
+		{"target type", "?target_type=user", 3},
+		{"limit", "?limit=2", 2},
+		{"before", "?before=3", 2},
+		{"since", "?since=" + time.Now().Add(time.Hour).Format(time.RFC3339), 0},
+		{"until", "?until=" + time.Now().Add(time.Hour).Format(time.RFC3339), 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := s.auditLog(admin.Token, tt.query); len(got) != tt.want {
+				t.Errorf("got %d entries, want %d", len(got), tt.want)
+			}
+		})
+	}
+	for _, query := range []string{"?limit=0", "?limit=501", "?before=x", "?actor_id=walt", "?since=yesterday"} {
+		expectProblem(t, s.do(request{method: "GET", path: "/admin/audit" + query, token: admin.Token}), 400, httpx.CodeInvalidRequest)
+	}
+
+	if v := s.verifyAudit(admin.Token); !v.Valid || v.Entries != 5 || v.BrokenAt != nil {
+		t.Errorf("verification = %+v", v)
+	}
+
+	//il reset cancella gli utenti ma non il registro
+	expect(t, s.do(request{method: "POST", path: "/admin/reset", token: admin.Token}), 200, nil)
+	root := s.signupAs("root@chirpy.test", auth.RoleAdmin)
+	entries = s.auditLog(root.Token, "")
+	if len(entries) != 6 || entries[0].Action != "reset" || *entries[0].ActorID != admin.ID {
+		t.Errorf("after reset: %d entries, newest %+v", len(entries), entries[0])
+	}
+}
+
+func TestAuditModeration(t *testing.T) {
+	conf := testConfig()
+	conf.Moderation.AutoHideReports = 1
+	conf.Moderation.ReporterMinAge = 0
+	mem := store.NewMemory()
+	s := newTestServerWith(t, conf, mem)
+	ctx := context.Background()
+	admin := s.signupAs("admin@chirpy.test", auth.RoleAdmin)
+	moderator := s.signupAs("mod@chirpy.test", auth.RoleModerator)
+	walt := s.signup("walt@breakingbad.com", "04234")
+	jesse := s.signup("jesse@breakingbad.com", "password")
+	todd := s.signup("todd@vamonos.com", "password")
+	s.api.classifier = spam.ClassifierFunc(func(ctx context.Context, sub spam.Submission) (spam.Decision, error) {
+		if strings.Contains(sub.Body, "crystal") {
+			return spam.Decision{Verdict: spam.Hold, Reason: "drugs"}, nil
+		}
+		return spam.Decision{}, nil
+	})
+
+	var held Chirp
+	expect(t, s.do(postChirp(walt, "blue crystal")), 202, &held)
+	expect(t, s.do(request{method: "POST", path: "/admin/chirps/" + held.ID.String() + "/release", token: moderator.Token}), 200, nil)
+	chirp := s.chirp(walt, "say my name")
+	chirpReport := s.report(jesse, "/api/v1/chirps/"+chirp.ID.String(), "spam")
+	userReport := s.report(jesse, "/api/v1/users/"+walt.ID.String(), "spam")
+	for _, r := range []struct {
+		report     Report
+		resolution string
+	}{{chirpReport, resolutionChirpRemoved}, {userReport, resolutionUserSuspended}} {
+		expect(t, s.do(request{method: "POST", path: "/admin/reports/" + r.report.ID.String() + "/resolve", token: moderator.Token,
+			body: map[string]string{"resolution": r.resolution}}), 200, nil)
+	}
+
+	//i job di pulizia scrivono nel registro senza attore
+	expired := s.chirp(jesse, "yeah science")
+	mem.Now = func() time.Time { return time.Now().Add(-2 * max(chirpRetention, accountDeletionGrace)) }
+	expect(t, s.do(request{method: "DELETE", path: "/api/v1/chirps/" + expired.ID.String(), token: jesse.Token}), 204, nil)
+	if _, err := s.store.SoftDeleteUser(ctx, todd.ID); err != nil {
+		t.Fatal(err)
+	}
+	mem.Now = time.Now
+	s.api.purgeDeletedChirps(ctx)
+	s.api.purgeDeletedUsers(ctx)
+	//una pulizia che non trova niente non scrive
+	s.api.purgeDeletedUsers(ctx)
+
+	entries := s.auditLog(admin.Token, "")
+	var actions []string
+	for _, e := range entries {
+		actions = append(actions, e.Action)
+	}
+	want := []string{"users.purge", "chirps.purge", "chirp.delete", "user.sanction", "chirp.delete", "chirp.hide", "chirp.release"}
+	if !slices.Equal(actions, want) {
+		t.Fatalf("actions = %v, want %v", actions, want)
+	}
+	actors := []*User{nil, nil, &jesse, &moderator, &moderator, nil, &moderator}
+	for i, e := range entries {
+		if actor := actors[i]; actor == nil && e.ActorID != nil || actor != nil && (e.ActorID == nil || *e.ActorID != actor.ID) {
+			t.Errorf("%s entry has actor %v", e.Action, e.ActorID)
+		}
+	}
+	if purge := entries[0]; purge.TargetType != "users" || string(purge.After) != `{"purged":1}` {
+		t.Errorf("purge entry = %+v", purge)
+	}
+	if hide := entries[5]; hide.TargetID != chirp.ID.String() || hide.RequestID == "" {
+		t.Errorf("hide entry = %+v", hide)
+	}
+	if v := s.verifyAudit(admin.Token); !v.Valid || v.Entries != len(want) {
+		t.Errorf("verification = %+v", v)
+	}
+}
+
+func TestAuditLogTampering(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.signupAs("admin@chirpy.test", auth.RoleAdmin)
+	walt := s.signup("walt@breakingbad.com", "04234")
+	for _, role := range []string{"moderator", "user"} {
+		expect(t, s.do(request{method: "PUT", path: "/admin/users/" + walt.ID.String() + "/role", token: admin.Token,
+			body: map[string]string{"role": role}}), 200, nil)
+	}
+
+	//chi scrive nel database senza ricalcolare la catena si fa scoprire
+	last, err := s.store.QueryLastAuditEntry(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	forged := database.CreateAuditEntryParams(last)
+	forged.Seq++
+	forged.PrevHash = last.Hash
+	forged.Hash = "forged"
+	if _, err := s.store.CreateAuditEntry(context.Background(), forged); err != nil {
+		t.Fatal(err)
+	}
+	v := s.verifyAudit(admin.Token)
+	if v.Valid || v.Entries != 3 || v.BrokenAt == nil || *v.BrokenAt != 3 {
+		t.Errorf("verification = %+v", v)
+	}
+}
@@ -0,0 +1,438 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"Chirpy/internal/auth"
+	"Chirpy/internal/database"
+	"Chirpy/internal/entitlements"
+	"Chirpy/internal/httpx"
+	"Chirpy/internal/spam"
+	"Chirpy/internal/validate"
+	"github.com/google/uuid"
+)
+
+// Deleted chirps are kept as tombstones: they can be restored for
+// chirpRestoreWindow, and are purged for good after chirpRetention so that
+// moderators can still review them in the meantime.
+const (
+	chirpRestoreWindow = 7 * 24 * time.Hour
+	chirpRetention     = 90 * 24 * time.Hour
+)
+
+type Chirp struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Body      string    `json:"body"`
+	User_id   uuid.UUID `json:"user_id"`
+
+	//solo nella vista admin con include_deleted
+	DeletedAt      *time.Time `json:"deleted_at,omitempty"`
+	DeletedBy      *uuid.UUID `json:"deleted_by,omitempty"`
+	DeletionReason string     `json:"deletion_reason,omitempty"`
+
+	//solo per l'autore e i moderatori finché il chirp è trattenuto
+	HeldReason string `json:"held_reason,omitempty"`
+}
+
+func outputChirp(chirp database.Chirp) Chirp {
+	out := Chirp{
+		ID:             chirp.ID,
+		CreatedAt:      chirp.CreatedAt,
+		UpdatedAt:      chirp.UpdatedAt,
+		Body:           chirp.Body,
+		User_id:        chirp.UserID,
+		DeletionReason: chirp.DeletionReason.String,
+		HeldReason:     chirp.HeldReason.String,
+	}
+	if chirp.DeletedAt.Valid {
+		out.DeletedAt = &chirp.DeletedAt.Time
+	}
+	if chirp.DeletedBy.Valid {
+		out.DeletedBy = &chirp.DeletedBy.UUID
+	}
+	return out
+}
+
+// includeDeleted reports whether req asks for deleted chirps too, which
+// only admins may see. When it returns false for ok, it has answered req.
+func (cfg *API) includeDeleted(res http.ResponseWriter, req *http.Request) (include, ok bool) {
+	param := req.URL.Query().Get("include_deleted")
+	if param == "" {
+		return false, true
+	}
+	include, err := strconv.ParseBool(param)
+	if err != nil {
+		httpx.RespondInvalid(res, req, http.StatusBadRequest, httpx.CodeInvalidRequest, "include_deleted must be true or false", validate.Errors{{
+			Field:   "include_deleted",
+			Rule:    "boolean",
+			Message: "must be true or false",
+		}})
+		return false, false
+	}
+	if include {
+		_, ok = cfg.authorize(res, req, auth.RoleAdmin)
+		return true, ok
+	}
+	return false, true
+}
+
+func (cfg *API) chirpsQueryAll(res http.ResponseWriter, req *http.Request) {
+
+	var chirps []database.Chirp
+	var outChirps []Chirp
+	var err error
+
+	author := req.URL.Query().Get("author_id")
+	sorting := req.URL.Query().Get("sort")
+	includeDeleted, ok := cfg.includeDeleted(res, req)
+	if !ok {
+		return
+	}
+	viewer := cfg.viewerID(req)
+	if author == "" {
+		//crea il chirp
+		if includeDeleted {
+			chirps, err = cfg.store.QueryAllChirpsIncludingDeleted(req.Context())
+		} else {
+			chirps, err = cfg.store.QueryAllChirps(req.Context(), viewer)
+		}
+
+	} else {
+		authorId, ok := httpx.ParseUUID(res, req, "author_id", author)
+		if !ok {
+			return
+		}
+		if includeDeleted {
+			chirps, err = cfg.store.QueryAllAuthorChirpsIncludingDeleted(req.Context(), authorId)
+		} else {
+			chirps, err = cfg.store.QueryAllAuthorChirps(req.Context(), database.QueryAllAuthorChirpsParams{
+				UserID:   authorId,
+				ViewerID: viewer,
+			})
+		}
+
+	}
+	if err != nil {
+		slog.ErrorContext(req.Context(), "cannot list chirps", "err", err)
+		httpx.RespondError(res, req, http.StatusInternalServerError, httpx.CodeInternal, "")
+		return
+	}
+	for _, c := range chirps {
+		outChirps = append(outChirps, outputChirp(c))
+	}
+	if sorting == "asc" {
+		sort.Slice(outChirps, func(i, j int) bool { return outChirps[i].CreatedAt.Before(outChirps[j].CreatedAt) })
+	}
+	if sorting == "desc" {
+		sort.Slice(outChirps, func(i, j int) bool { return outChirps[i].CreatedAt.After(outChirps[j].CreatedAt) })
+	}
+
+	httpx.RespondJSON(res, req, 200, outChirps)
+
+}
+
+func (cfg *API) chirpsQuery(res http.ResponseWriter, req *http.Request) {
+
+	chirpID, ok := httpx.ParseUUID(res, req, "chirpID", req.PathValue("chirpID"))
+	if !ok {
+		return
+	}
+
+	includeDeleted, ok := cfg.includeDeleted(res, req)
+	if !ok {
+		return
+	}
+
+	//cerca il chirp
+	var chirp database.Chirp
+	var err error
+	if includeDeleted {
+		chirp, err = cfg.store.QueryChirpIncludingDeleted(req.Context(), chirpID)
+	} else {
+		chirp, err = cfg.store.QueryChirp(req.Context(), database.QueryChirpParams{ID: chirpID, ViewerID: cfg.viewerID(req)})
+	}
+	if err != nil {
+		slog.DebugContext(req.Context(), "chirp not found", "chirp_id", chirpID, "err", err)
+	}
+	//un chirp trattenuto lo vede solo il suo autore, finché non viene rilasciato
+	if chirp.Body == "" || chirp.HeldReason.Valid && !includeDeleted && cfg.viewerID(req) != chirp.UserID {
+		httpx.RespondError(res, req, http.StatusNotFound, httpx.CodeNotFound, "chirp not found")
+		return
+	}
+
+	httpx.RespondJSON(res, req, 200, outputChirp(chirp))
+
+}
+
+// chirpsDelete leaves a tombstone: the chirp disappears from every view
+// but the admins', and the purge job removes it after chirpRetention.
+func (cfg *API) chirpsDelete(res http.ResponseWriter, req *http.Request) {
+	type parameters struct {
+		Reason string `json:"reason" validate:"max=500"`
+	}
+
+	reqBearer, err := auth.GetBearerToken(req.Header)
+	if err != nil {
+		slog.InfoContext(req.Context(), "missing bearer token", "err", err)
+		httpx.RespondError(res, req, http.StatusUnauthorized, httpx.CodeUnauthorized, "missing bearer token")
+		return
+	}
+	claims, err := auth.ParseJWT(reqBearer, cfg.secretToken)
+	if err != nil {
+		slog.InfoContext(req.Context(), "invalid access token", "err", err)
+		httpx.RespondError(res, req, http.StatusUnauthorized, httpx.CodeUnauthorized, "invalid access token")
+		return
+	}
+
+	chirpID, ok := httpx.ParseUUID(res, req, "chirpID", req.PathValue("chirpID"))
+	if !ok {
+		return
+	}
+
+	//cerca il chirp; i moderatori vedono anche quelli nascosti a loro o
+	//agli altri, e possono cancellare qualsiasi chirp
+	moderator := auth.HasRole(claims.Role, auth.RoleModerator)
+	var chirp database.Chirp
+	if moderator {
+		chirp, err = cfg.store.QueryChirpIncludingDeleted(req.Context(), chirpID)
+		if chirp.DeletedAt.Valid {
+			chirp = database.Chirp{}
+		}
+	} else {
+		chirp, err = cfg.store.QueryChirp(req.Context(), database.QueryChirpParams{ID: chirpID, ViewerID: claims.UserID})
+	}
+	if err != nil {
+		slog.DebugContext(req.Context(), "chirp to delete not found", "chirp_id", chirpID, "err", err)
+	}
+	if chirp.Body == "" {
+		httpx.RespondError(res, req, http.StatusNotFound, httpx.CodeNotFound, "chirp not found")
+		return
+	}
+
+	if chirp.UserID != claims.UserID && !moderator {
+		httpx.RespondError(res, req, http.StatusForbidden, httpx.CodeForbidden, "only the author or a moderator can delete this chirp")
+		return
+	}
+
+	//il motivo è facoltativo, e con lui il body
+	params := parameters{}
+	if req.ContentLength != 0 && !httpx.DecodeJSON(res, req, &params) {
+		return
+	}
+
+	actor := cfg.auditActorOf(req)
+	err = cfg.auditTx(req.Context(), func(q database.Querier) error {
+		deleted, err := q.SoftDeleteChirp(req.Context(), database.SoftDeleteChirpParams{
+			ID:             chirpID,
+			DeletedBy:      uuid.NullUUID{UUID: claims.UserID, Valid: true},
+			DeletionReason: sql.NullString{String: params.Reason, Valid: params.Reason != ""},
+		})
+		if err != nil {
+			return err
+		}
+		return appendAudit(req.Context(), q, actor, auditRecord{
+			action:     "chirp.delete",
+			targetType: "chirp",
+			targetID:   chirpID.String(),
+			before:     outputChirp(chirp),
+			after:      outputChirp(deleted),
+		})
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		//cancellato da una richiesta concorrente
+		httpx.RespondError(res, req, http.StatusNotFound, httpx.CodeNotFound, "chirp not found")
+		return
+	}
+	if err != nil {
+		respondStoreError(res, req, err, "chirp deletion failed", "chirp_id", chirpID)
+		return
+	}
+	slog.InfoContext(req.Context(), "chirp deleted", "chirp_id", chirpID, "author_id", chirp.UserID, "deleted_by", claims.UserID)
+
+	res.WriteHeader(204)
+
+}
+
+// chirpsRestore undoes a deletion within chirpRestoreWindow. The author can
+// only undo their own deletions; moderators can undo any.
+func (cfg *API) chirpsRestore(res http.ResponseWriter, req *http.Request) {
+	reqBearer, err := auth.GetBearerToken(req.Header)
+	if err != nil {
+		slog.InfoContext(req.Context(), "missing bearer token", "err", err)
+		httpx.RespondError(res, req, http.StatusUnauthorized, httpx.CodeUnauthorized, "missing bearer token")
+		return
+	}
+	claims, err := auth.ParseJWT(reqBearer, cfg.secretToken)
+	if err != nil {
+		slog.InfoContext(req.Context(), "invalid access token", "err", err)
+		httpx.RespondError(res, req, http.StatusUnauthorized, httpx.CodeUnauthorized, "invalid access token")
+		return
+	}
+
+	chirpID, ok := httpx.ParseUUID(res, req, "chirpID", req.PathValue("chirpID"))
+	if !ok {
+		return
+	}
+
+	chirp, err := cfg.store.QueryChirpIncludingDeleted(req.Context(), chirpID)
+	moderator := auth.HasRole(claims.Role, auth.RoleModerator)
+	//agli altri utenti un chirp cancellato non esiste
+	if err != nil || chirp.UserID != claims.UserID && !moderator {
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			slog.ErrorContext(req.Context(), "cannot query chirp", "chirp_id", chirpID, "err", err)
+			httpx.RespondError(res, req, http.StatusInternalServerError, httpx.CodeInternal, "")
+			return
+		}
+		httpx.RespondError(res, req, http.StatusNotFound, httpx.CodeNotFound, "chirp not found")
+		return
+	}
+	if !chirp.DeletedAt.Valid {
+		httpx.RespondError(res, req, http.StatusConflict, httpx.CodeConflict, "the chirp is not deleted")
+		return
+	}
+	if !moderator && chirp.DeletedBy.UUID != claims.UserID {
+		httpx.RespondError(res, req, http.StatusForbidden, httpx.CodeForbidden, "only a moderator can restore a chirp removed by moderation")
+		return
+	}
+	if time.Since(chirp.DeletedAt.Time) > chirpRestoreWindow {
+		httpx.RespondError(res, req, http.StatusGone, httpx.CodeGone, "the chirp was deleted too long ago to be restored")
+		return
+	}
+
+	var restored database.Chirp
+	actor := cfg.auditActorOf(req)
+	err = cfg.auditTx(req.Context(), func(q database.Querier) error {
+		var err error
+		restored, err = q.RestoreChirp(req.Context(), chirpID)
+		if err != nil {
+			return err
+		}
+		return appendAudit(req.Context(), q, actor, auditRecord{
+			action:     "chirp.restore",
+			targetType: "chirp",
+			targetID:   chirpID.String(),
+			before:     outputChirp(chirp),
+			after:      outputChirp(restored),
+		})
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		httpx.RespondError(res, req, http.StatusConflict, httpx.CodeConflict, "the chirp is not deleted")
+		return
+	}
+	if err != nil {
+		respondStoreError(res, req, err, "chirp restore failed", "chirp_id", chirpID)
+		return
+	}
+	slog.InfoContext(req.Context(), "chirp restored", "chirp_id", chirpID, "restored_by", claims.UserID)
+
+	httpx.RespondJSON(res, req, 200, outputChirp(restored))
+}
+
+func (cfg *API) chirpsCreator(res http.ResponseWriter, req *http.Request) {
+	type parameters struct {
+		Body    string    `json:"body" validate:"required"`
+		User_id uuid.UUID `json:"user_id"`
+	}
+	type returnVals struct {
+		Clean string `json:"cleaned_body"`
+	}
+	//le sanzioni, la cancellazione e il piano valgono anche per i token
+	//emessi prima, quindi si leggono dal database
+	author, claims, ok := cfg.authenticate(res, req)
+	if !ok {
+		return
+	}
+	plan, ok := cfg.currentPlan(res, req, claims)
+	if !ok {
+		return
+	}
+
+	params := parameters{}
+	if !httpx.DecodeJSON(res, req, &params) {
+		return
+	}
+
+	//il limite dipende dal piano, non può stare nei tag di validazione
+	maxLength := entitlements.For(entitlements.PlanFree).MaxChirpLength
+	if entitlements.Can(plan, entitlements.FeatureLongChirps) {
+		maxLength = entitlements.For(plan).MaxChirpLength
+	}
+	if utf8.RuneCountInString(params.Body) > maxLength {
+		httpx.RespondInvalid(res, req, http.StatusUnprocessableEntity, httpx.CodeChirpTooLong, "Chirp is too long", validate.Errors{{
+			Field:   "body",
+			Rule:    "max",
+			Message: fmt.Sprintf("must be at most %d characters long", maxLength),
+		}})
+		return
+	}
+
+	clearingString := params.Body
+	clearingString = strings.Replace(clearingString, " kerfuffle ", " **** ", -1)
+	clearingString = strings.Replace(clearingString, " Kerfuffle ", " **** ", -1)
+	clearingString = strings.Replace(clearingString, " sharbert ", " **** ", -1)
+	clearingString = strings.Replace(clearingString, "Sharbert ", " **** ", -1)
+	clearingString = strings.Replace(clearingString, " fornax ", " **** ", -1)
+	clearingString = strings.Replace(clearingString, " Fornax ", " **** ", -1)
+
+	if !cfg.checkMentions(res, req, author, params.Body) {
+		return
+	}
+
+	//i controlli anti spam guardano il testo originale
+	hash := spam.Hash(params.Body)
+	held, ok := cfg.classifyChirp(res, req, author, plan, params.Body)
+	if !ok {
+		return
+	}
+
+	clearedParameters := database.CreateChirpParams{
+		Body:       clearingString,
+		UserID:     author.ID,
+		BodyHash:   hash,
+		HeldReason: held,
+	}
+	//crea il chirp; il lock sull'autore mette in fila i suoi chirp concorrenti,
+	//così i limiti contano anche quelli appena creati
+	rate := cfg.chirpRate(author, plan, time.Now())
+	var chirp database.Chirp
+	err := cfg.store.WithTx(req.Context(), func(q database.Querier) error {
+		if err := q.LockUser(req.Context(), author.ID); err != nil {
+			return err
+		}
+		if err := cfg.checkFlood(req.Context(), q, author, rate, hash); err != nil {
+			return err
+		}
+		var err error
+		chirp, err = q.CreateChirp(req.Context(), clearedParameters)
+		return err
+	})
+	if cfg.respondFlood(res, req, author, rate, err) {
+		return
+	}
+	if err != nil {
+		respondStoreError(res, req, err, "chirp creation failed", "user_id", author.ID)
+		return
+	}
+	if held.Valid {
+		cfg.metrics.chirpsFiltered.WithLabelValues("held").Inc()
+		slog.InfoContext(req.Context(), "chirp held for review", "chirp_id", chirp.ID, "user_id", author.ID, "reason", held.String)
+		httpx.RespondJSON(res, req, 202, outputChirp(chirp))
+		return
+	}
+	cfg.metrics.chirpsCreated.Inc()
+	slog.InfoContext(req.Context(), "chirp created", "chirp_id", chirp.ID, "user_id", author.ID)
+
+	httpx.RespondJSON(res, req, 201, outputChirp(chirp))
+
+}
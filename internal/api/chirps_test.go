@@ -0,0 +1,248 @@
+package api
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"Chirpy/internal/auth"
+	"Chirpy/internal/httpx"
+	"Chirpy/internal/store"
+)
+
+// chirp posts body as user and returns the chirp created.
+func (s *testServer) chirp(user User, body string) Chirp {
+	s.t.Helper()
+	var chirp Chirp
+	expect(s.t, s.do(request{method: "POST", path: "/api/v1/chirps", token: user.Token, body: map[string]string{"body": body}}), 201, &chirp)
+	return chirp
+}
+
+func TestChirpsCreator(t *testing.T) {
+	s := newTestServer(t)
+	user := s.signup("walt@breakingbad.com", "04234")
+
+	tests := []struct {
+		name       string
+		token      string
+		body       any
+		wantStatus int
+		wantCode   string
+		wantBody   string
+	}{
+		{"valid", user.Token, map[string]string{"body": "I am the one who knocks"}, 201, "", "I am the one who knocks"},
+		{"profane", user.Token, map[string]string{"body": "what a kerfuffle indeed"}, 201, "", "what a **** indeed"},
+		{"no token", "", map[string]string{"body": "hi"}, 401, httpx.CodeUnauthorized, ""},
+		{"bad token", "garbage", map[string]string{"body": "hi"}, 401, httpx.CodeUnauthorized, ""},
+		{"empty body", user.Token, map[string]string{"body": ""}, 422, httpx.CodeValidation, ""},
+		{"too long", user.Token, map[string]string{"body": strings.Repeat("a", 141)}, 422, httpx.CodeChirpTooLong, ""},
+		{"invalid json", user.Token, "{", 400, httpx.CodeInvalidJSON, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := s.do(request{method: "POST", path: "/api/v1/chirps", token: tt.token, body: tt.body})
+			if tt.wantCode != "" {
+				expectProblem(t, rec, tt.wantStatus, tt.wantCode)
+				return
+			}
+			var chirp Chirp
+			expect(t, rec, tt.wantStatus, &chirp)
+			if chirp.Body != tt.wantBody || chirp.User_id != user.ID {
+				t.Errorf("chirp = %+v", chirp)
+			}
+		})
+	}
+}
+
+func TestChirpsQuery(t *testing.T) {
+	s := newTestServer(t)
+	walt := s.signup("walt@breakingbad.com", "04234")
+	jesse := s.signup("jesse@breakingbad.com", "password")
+	first := s.chirp(walt, "first")
+	s.chirp(jesse, "second")
+	third := s.chirp(walt, "third")
+
+	list := func(query string) []Chirp {
+		t.Helper()
+		var chirps []Chirp
+		expect(t, s.do(request{method: "GET", path: "/api/v1/chirps" + query}), 200, &chirps)
+		return chirps
+	}
+	bodies := func(chirps []Chirp) string {
+		var out []string
+		for _, c := range chirps {
+			out = append(out, c.Body)
+		}
+		return strings.Join(out, ",")
+	}
+
+	if got := bodies(list("")); got != "first,second,third" {
+		t.Errorf("all chirps = %s", got)
+	}
+	if got := bodies(list("?sort=desc")); got != "third,second,first" {
+		t.Errorf("sorted desc = %s", got)
+	}
+	if got := bodies(list("?author_id=" + walt.ID.String() + "&sort=asc")); got != "first,third" {
+		t.Errorf("chirps of walt = %s", got)
+	}
+	expectProblem(t, s.do(request{method: "GET", path: "/api/v1/chirps?author_id=walt"}), 400, httpx.CodeInvalidRequest)
+
+	var chirp Chirp
+	expect(t, s.do(request{method: "GET", path: "/api/v1/chirps/" + first.ID.String()}), 200, &chirp)
+	if chirp != first {
+		t.Errorf("chirp = %+v, want %+v", chirp, first)
+	}
+	expectProblem(t, s.do(request{method: "GET", path: "/api/v1/chirps/nope"}), 400, httpx.CodeInvalidRequest)
+	expectProblem(t, s.do(request{method: "GET", path: "/api/v1/chirps/" + walt.ID.String()}), 404, httpx.CodeNotFound)
+
+	//i chirp degli account cancellati spariscono
+	expect(t, s.do(request{method: "DELETE", path: "/api/v1/users", token: walt.Token, body: map[string]string{"password": "04234"}}), 204, nil)
+	expectProblem(t, s.do(request{method: "GET", path: "/api/v1/chirps/" + third.ID.String()}), 404, httpx.CodeNotFound)
+	if got := bodies(list("")); got != "second" {
+		t.Errorf("chirps after deleting walt = %s", got)
+	}
+}
+
+func TestChirpsDelete(t *testing.T) {
+	s := newTestServer(t)
+	walt := s.signup("walt@breakingbad.com", "04234")
+	jesse := s.signup("jesse@breakingbad.com", "password")
+	moderator := s.signupAs("mod@chirpy.test", auth.RoleModerator)
+	byWalt := s.chirp(walt, "say my name")
+	moderated := s.chirp(walt, "tread lightly")
+
+	path := func(c Chirp) string { return "/api/v1/chirps/" + c.ID.String() }
+	expectProblem(t, s.do(request{method: "DELETE", path: path(byWalt)}), 401, httpx.CodeUnauthorized)
+	expectProblem(t, s.do(request{method: "DELETE", path: path(byWalt), token: "garbage"}), 401, httpx.CodeUnauthorized)
+	expectProblem(t, s.do(request{method: "DELETE", path: path(byWalt), token: jesse.Token}), 403, httpx.CodeForbidden)
+	expectProblem(t, s.do(request{method: "DELETE", path: "/api/v1/chirps/nope", token: walt.Token}), 400, httpx.CodeInvalidRequest)
+
+	expect(t, s.do(request{method: "DELETE", path: path(byWalt), token: walt.Token}), 204, nil)
+	expectProblem(t, s.do(request{method: "DELETE", path: path(byWalt), token: walt.Token}), 404, httpx.CodeNotFound)
+	reason := map[string]string{"reason": "threat"}
+	expect(t, s.do(request{method: "DELETE", path: path(moderated), token: moderator.Token, body: reason}), 204, nil)
+	expectProblem(t, s.do(request{method: "GET", path: path(moderated)}), 404, httpx.CodeNotFound)
+
+	var chirps []Chirp
+	expect(t, s.do(request{method: "GET", path: "/api/v1/chirps"}), 200, &chirps)
+	if len(chirps) != 0 {
+		t.Errorf("deleted chirps are listed: %+v", chirps)
+	}
+}
+
+func TestChirpsIncludeDeleted(t *testing.T) {
+	s := newTestServer(t)
+	walt := s.signup("walt@breakingbad.com", "04234")
+	moderator := s.signupAs("mod@chirpy.test", auth.RoleModerator)
+	admin := s.signupAs("admin@chirpy.test", auth.RoleAdmin)
+	kept := s.chirp(walt, "say my name")
+	deleted := s.chirp(walt, "tread lightly")
+	expect(t, s.do(request{method: "DELETE", path: "/api/v1/chirps/" + deleted.ID.String(), token: moderator.Token,
+		body: map[string]string{"reason": "threat"}}), 204, nil)
+
+	list := "/api/v1/chirps?include_deleted=true&author_id=" + walt.ID.String()
+	expectProblem(t, s.do(request{method: "GET", path: list}), 401, httpx.CodeUnauthorized)
+	expectProblem(t, s.do(request{method: "GET", path: list, token: moderator.Token}), 403, httpx.CodeForbidden)
+	expectProblem(t, s.do(request{method: "GET", path: "/api/v1/chirps?include_deleted=maybe"}), 400, httpx.CodeInvalidRequest)
+
+	var chirps []Chirp
+	expect(t, s.do(request{method: "GET", path: list, token: admin.Token}), 200, &chirps)
+	if len(chirps) != 2 || chirps[0].ID != kept.ID || chirps[0].DeletedAt != nil {
+		t.Fatalf("chirps = %+v", chirps)
+	}
+	tombstone := chirps[1]
+	if tombstone.DeletedAt == nil || tombstone.DeletedBy == nil || *tombstone.DeletedBy != moderator.ID || tombstone.DeletionReason != "threat" {
+		t.Errorf("tombstone = %+v", tombstone)
+	}
+
+	var chirp Chirp
+	expect(t, s.do(request{method: "GET", path: "/api/v1/chirps/" + deleted.ID.String() + "?include_deleted=true", token: admin.Token}), 200, &chirp)
+	if chirp.DeletionReason != "threat" {
+		t.Errorf("chirp = %+v", chirp)
+	}
+}
+
+func TestChirpsRestore(t *testing.T) {
+	mem := store.NewMemory()
+	s := newTestServerWith(t, testConfig(), mem)
+	walt := s.signup("walt@breakingbad.com", "04234")
+	jesse := s.signup("jesse@breakingbad.com", "password")
+	moderator := s.signupAs("mod@chirpy.test", auth.RoleModerator)
+
+	path := func(c Chirp) string { return "/api/v1/chirps/" + c.ID.String() }
+	restore := func(c Chirp, user User) request {
+		return request{method: "POST", path: path(c) + "/restore", token: user.Token}
+	}
+
+	own := s.chirp(walt, "say my name")
+	expectProblem(t, s.do(restore(own, walt)), 409, httpx.CodeConflict)
+	expect(t, s.do(request{method: "DELETE", path: path(own), token: walt.Token}), 204, nil)
+	expectProblem(t, s.do(request{method: "POST", path: path(own) + "/restore"}), 401, httpx.CodeUnauthorized)
+	expectProblem(t, s.do(restore(own, jesse)), 404, httpx.CodeNotFound)
+	var restored Chirp
+	expect(t, s.do(restore(own, walt)), 200, &restored)
+	if restored.ID != own.ID || restored.DeletedAt != nil {
+		t.Errorf("restored = %+v", restored)
+	}
+	expect(t, s.do(request{method: "GET", path: path(own)}), 200, nil)
+
+	moderated := s.chirp(walt, "tread lightly")
+	expect(t, s.do(request{method: "DELETE", path: path(moderated), token: moderator.Token}), 204, nil)
+	expectProblem(t, s.do(restore(moderated, walt)), 403, httpx.CodeForbidden)
+	expect(t, s.do(restore(moderated, moderator)), 200, nil)
+
+	//una cancellazione oltre la finestra non si annulla più
+	old := s.chirp(walt, "I am the danger")
+	mem.Now = func() time.Time { return time.Now().Add(-2 * chirpRestoreWindow) }
+	expect(t, s.do(request{method: "DELETE", path: path(old), token: walt.Token}), 204, nil)
+	mem.Now = time.Now
+	expectProblem(t, s.do(restore(old, walt)), 410, httpx.CodeGone)
+	expectProblem(t, s.do(restore(old, moderator)), 410, httpx.CodeGone)
+}
+
+func TestPurgeDeletedChirps(t *testing.T) {
+	mem := store.NewMemory()
+	s := newTestServerWith(t, testConfig(), mem)
+	ctx := context.Background()
+	walt := s.signup("walt@breakingbad.com", "04234")
+	recent := s.chirp(walt, "say my name")
+	expired := s.chirp(walt, "tread lightly")
+
+	mem.Now = func() time.Time { return time.Now().Add(-2 * chirpRetention) }
+	expect(t, s.do(request{method: "DELETE", path: "/api/v1/chirps/" + expired.ID.String(), token: walt.Token}), 204, nil)
+	mem.Now = time.Now
+	expect(t, s.do(request{method: "DELETE", path: "/api/v1/chirps/" + recent.ID.String(), token: walt.Token}), 204, nil)
+
+	s.api.purgeDeletedChirps(ctx)
+	if _, err := s.store.QueryChirpIncludingDeleted(ctx, expired.ID); err == nil {
+		t.Errorf("chirp deleted past the retention was not purged")
+	}
+	if _, err := s.store.QueryChirpIncludingDeleted(ctx, recent.ID); err != nil {
+		t.Errorf("chirp was purged within the retention: %v", err)
+	}
+}
+
+func TestChirpyRedLength(t *testing.T) {
+	s := newTestServer(t)
+	user := s.signup("walt@breakingbad.com", "04234")
+	s.polka(t, "evt_red", "user.upgraded", user.ID.String(), 204)
+	user = s.login("walt@breakingbad.com", "04234")
+	if !user.Is_chirpy_red {
+		t.Fatal("user is not Chirpy Red after the upgrade")
+	}
+
+	s.chirp(user, strings.Repeat("a", 280))
+	expectProblem(t, s.do(request{method: "POST", path: "/api/v1/chirps", token: user.Token,
+		body: map[string]string{"body": strings.Repeat("a", 281)}}), 422, httpx.CodeChirpTooLong)
+
+	//il token dice ancora Chirpy Red, l'abbonamento no: va rinnovato
+	s.polka(t, "evt_free", "user.downgraded", user.ID.String(), 204)
+	expectProblem(t, s.do(request{method: "POST", path: "/api/v1/chirps", token: user.Token,
+		body: map[string]string{"body": "Say my name"}}), 401, httpx.CodeUnauthorized)
+	var refreshed struct{ Token string }
+	expect(t, s.do(request{method: "POST", path: "/api/v1/refresh", token: user.RefreshToken}), 200, &refreshed)
+	user.Token = refreshed.Token
+	expectProblem(t, s.do(request{method: "POST", path: "/api/v1/chirps", token: user.Token,
+		body: map[string]string{"body": strings.Repeat("b", 141)}}), 422, httpx.CodeChirpTooLong)
+}
@@ -0,0 +1,146 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+
+	"Chirpy/internal/auth"
+	"Chirpy/internal/config"
+	"Chirpy/internal/database"
+	"Chirpy/internal/httpx"
+	"Chirpy/internal/mail"
+)
+
+// emailChangeExpiration is how long the token sent to a new email address
+// stays valid.
+const emailChangeExpiration = 24 * time.Hour
+
+var errNoMailer = errors.New("no mail server configured")
+
+// newMailer delivers through the configured SMTP server when there is one.
+// Without one, messages are written to stdout in dev and not sent at all in
+// production, where they would leak confirmation tokens to the logs.
+func newMailer(conf config.Config) mail.Mailer {
+	if conf.Mail.SMTPAddr != "" {
+		return &mail.SMTPMailer{
+			Addr:     conf.Mail.SMTPAddr,
+			From:     conf.Mail.From,
+			Username: conf.Mail.SMTPUsername,
+			Password: conf.Mail.SMTPPassword,
+		}
+	}
+	if conf.Platform == "dev" {
+		return &mail.WriterMailer{W: os.Stdout}
+	}
+	return nil
+}
+
+// startEmailChange records a pending change of the user's email, replacing
+// any earlier one, sends the confirmation token to the new address and
+// warns the current one.
+func (cfg *API) startEmailChange(ctx context.Context, user database.User, newEmail string) error {
+	if cfg.mailer == nil {
+		return errNoMailer
+	}
+	token, err := auth.MakeRefreshToken()
+	if err != nil {
+		return err
+	}
+	var change database.EmailChange
+	err = cfg.store.WithTx(ctx, func(q database.Querier) error {
+		//un token inviato prima non deve poter confermare un altro indirizzo
+		err := q.DeletePendingEmailChanges(ctx, user.ID)
+		if err != nil {
+			return err
+		}
+		change, err = q.CreateEmailChange(ctx, database.CreateEmailChangeParams{
+			Token:     token,
+			UserID:    user.ID,
+			NewEmail:  newEmail,
+			ExpiresAt: time.Now().Add(emailChangeExpiration),
+		})
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	err = cfg.mailer.Send(ctx, mail.Message{
+		To:      change.NewEmail,
+		Subject: "Confirm your new Chirpy email",
+		Body: fmt.Sprintf("To use this address for your Chirpy account, confirm it with this token within %v:\n\n%s\n",
+			emailChangeExpiration, token),
+	})
+	if err != nil {
+		return err
+	}
+
+	err = cfg.mailer.Send(ctx, mail.Message{
+		To:      user.Email,
+		Subject: "Your Chirpy email is about to change",
+		Body: fmt.Sprintf("A change of your Chirpy email to %s was requested. If it wasn't you, change your password now.\n",
+			change.NewEmail),
+	})
+	if err != nil {
+		//la richiesta resta valida anche se l'avviso non parte
+		slog.WarnContext(ctx, "cannot notify email change", "user_id", user.ID, "err", err)
+	}
+	return nil
+}
+
+func (cfg *API) confirmEmailChange(res http.ResponseWriter, req *http.Request) {
+	type parameters struct {
+		Token string `json:"token" validate:"required"`
+	}
+
+	params := parameters{}
+	if !httpx.DecodeJSON(res, req, &params) {
+		return
+	}
+
+	change, err := cfg.store.QueryEmailChange(req.Context(), params.Token)
+	if err != nil {
+		httpx.RespondError(res, req, http.StatusNotFound, httpx.CodeNotFound, "unknown confirmation token")
+		return
+	}
+	if change.ConfirmedAt.Valid || change.ExpiresAt.Before(time.Now()) {
+		slog.InfoContext(req.Context(), "email change already confirmed or expired", "user_id", change.UserID)
+		httpx.RespondError(res, req, http.StatusGone, httpx.CodeGone, "the confirmation token was already used or has expired")
+		return
+	}
+
+	//il token si consuma solo se l'email cambia davvero; un indirizzo già
+	//in uso fa fallire l'aggiornamento con un 409
+	var user database.User
+	err = cfg.store.WithTx(req.Context(), func(q database.Querier) error {
+		var err error
+		user, err = q.UpdateUserEmail(req.Context(), database.UpdateUserEmailParams{
+			ID:    change.UserID,
+			Email: change.NewEmail,
+		})
+		if err != nil {
+			return err
+		}
+		return q.ConfirmEmailChange(req.Context(), change.Token)
+	})
+	if err != nil {
+		respondStoreError(res, req, err, "email update failed", "user_id", change.UserID)
+		return
+	}
+	slog.InfoContext(req.Context(), "email changed", "user_id", user.ID)
+
+	outputUser := User{
+		ID:            user.ID,
+		CreatedAt:     user.CreatedAt,
+		UpdatedAt:     user.UpdatedAt,
+		Email:         user.Email,
+		Is_chirpy_red: user.IsChirpyRed,
+		Role:          user.Role,
+	}
+	httpx.RespondJSON(res, req, 200, outputUser)
+}
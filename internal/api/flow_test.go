@@ -0,0 +1,123 @@
+package api
+
+import (
+	"context"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"Chirpy/internal/httpx"
+	"Chirpy/internal/pgtest"
+	"Chirpy/internal/sqlitetest"
+	"Chirpy/internal/store"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(pgtest.Run(m))
+}
+
+// backends open an empty store of each kind; Postgres is skipped when its
+// binaries are not installed.
+var backends = []struct {
+	name string
+	open func(t *testing.T) store.Store
+}{
+	{"memory", func(t *testing.T) store.Store { return store.NewMemory() }},
+	{"postgres", func(t *testing.T) store.Store { return store.NewPostgres(pgtest.NewDB(t), nil) }},
+	{"sqlite", func(t *testing.T) store.Store { return store.NewSQLite(sqlitetest.NewDB(t), nil) }},
+}
+
+// TestFlow follows a user from signup to a Chirpy Red upgrade.
+func TestFlow(t *testing.T) {
+	for _, b := range backends {
+		t.Run(b.name, func(t *testing.T) {
+			s := newTestServerWith(t, testConfig(), b.open(t))
+			expect(t, s.do(request{method: "GET", path: "/api/readyz"}), 200, nil)
+
+			user := s.signup("walt@breakingbad.com", "04234")
+			chirp := s.chirp(user, "I am the one who knocks")
+
+			var chirps []Chirp
+			expect(t, s.do(request{method: "GET", path: "/api/v1/chirps?author_id=" + user.ID.String()}), 200, &chirps)
+			if len(chirps) != 1 || chirps[0].ID != chirp.ID {
+				t.Errorf("chirps = %+v", chirps)
+			}
+
+			var refreshed struct{ Token string }
+			expect(t, s.do(request{method: "POST", path: "/api/v1/refresh", token: user.RefreshToken}), 200, &refreshed)
+			expect(t, s.do(request{method: "DELETE", path: "/api/v1/chirps/" + chirp.ID.String(), token: refreshed.Token}), 204, nil)
+
+			expect(t, s.do(request{method: "POST", path: "/api/v1/revoke", token: user.RefreshToken}), 204, nil)
+			expectProblem(t, s.do(request{method: "POST", path: "/api/v1/refresh", token: user.RefreshToken}), 401, httpx.CodeUnauthorized)
+
+			s.polka(t, "evt_flow", "user.upgraded", user.ID.String(), 204)
+			s.polka(t, "evt_flow", "user.upgraded", user.ID.String(), 204)
+			user = s.login("walt@breakingbad.com", "04234")
+			if !user.Is_chirpy_red {
+				t.Errorf("user is not Chirpy Red after the upgrade")
+			}
+			s.chirp(user, strings.Repeat("a", 200))
+		})
+	}
+}
+
+// TestConstraintErrors checks that every backend reports constraint
+// violations so that they map to the same responses.
+func TestConstraintErrors(t *testing.T) {
+	for _, b := range backends {
+		t.Run(b.name, func(t *testing.T) {
+			s := newTestServerWith(t, testConfig(), b.open(t))
+			user := s.signup("walt@breakingbad.com", "04234")
+
+			signup := map[string]string{"email": "walt@breakingbad.com", "password": "other"}
+			expectProblem(t, s.do(request{method: "POST", path: "/api/v1/users", body: signup}), 409, httpx.CodeConflict)
+
+			//il token sopravvive all'utente, ma il chirp non arriva al database
+			if err := s.store.DeleteUsers(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			body := map[string]string{"body": "I am the one who knocks"}
+			expectProblem(t, s.do(request{method: "POST", path: "/api/v1/chirps", token: user.Token, body: body}), 401, httpx.CodeUnauthorized)
+		})
+	}
+}
+
+// TestConcurrentChirps checks that the rate limit and the duplicate check
+// hold when an author posts several chirps at once.
+func TestConcurrentChirps(t *testing.T) {
+	for _, b := range backends {
+		t.Run(b.name, func(t *testing.T) {
+			s := newTestServerWith(t, testConfig(), b.open(t))
+			walt := s.signup("walt@breakingbad.com", "04234")
+			jesse := s.signup("jesse@breakingbad.com", "password")
+
+			//walt ha un account nuovo: 3 chirp al minuto
+			created := func(user User, body func(i int) string) int {
+				var mu sync.Mutex
+				var wg sync.WaitGroup
+				n := 0
+				for i := range 8 {
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						rec := s.do(postChirp(user, body(i)))
+						mu.Lock()
+						defer mu.Unlock()
+						if rec.Code == 201 {
+							n++
+						}
+					}()
+				}
+				wg.Wait()
+				return n
+			}
+			if n := created(walt, func(i int) string { return "chirp " + string(rune('a'+i)) }); n != 3 {
+				t.Errorf("%d chirps created at once, want 3", n)
+			}
+			if n := created(jesse, func(int) string { return "yeah science" }); n != 1 {
+				t.Errorf("%d copies of a chirp created at once, want 1", n)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"Chirpy/internal/httpx"
+)
+
+// serverReady reports whether this instance should receive traffic: the
+// database must answer and its schema must have reached the version of the
+// newest migration embedded in this binary. It starts failing as soon as a
+// shutdown begins, so the load balancer stops routing here while requests
+// are drained.
+func (cfg *API) serverReady(res http.ResponseWriter, req *http.Request) {
+	type readiness struct {
+		Status string            `json:"status"`
+		Checks map[string]string `json:"checks"`
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), 2*time.Second)
+	defer cancel()
+
+	out := readiness{Status: "ok", Checks: map[string]string{}}
+	fail := func(check, reason string) {
+		out.Status = "unavailable"
+		out.Checks[check] = reason
+	}
+
+	if cfg.shuttingDown.Load() {
+		fail("shutdown", "in progress")
+	}
+
+	err := cfg.store.Ping(ctx)
+	if err != nil {
+		slog.WarnContext(ctx, "readiness: database unreachable", "err", err)
+		fail("database", "unreachable")
+	} else {
+		out.Checks["database"] = "ok"
+
+		version, err := cfg.store.SchemaVersion(ctx)
+		expected := cfg.schemaTarget
+		switch {
+		case err != nil:
+			slog.WarnContext(ctx, "readiness: cannot read schema version", "err", err)
+			fail("migrations", "unknown version")
+		case version < expected:
+			slog.WarnContext(ctx, "readiness: schema behind", "version", version, "expected", expected)
+			fail("migrations", fmt.Sprintf("at version %d, expected %d", version, expected))
+		default:
+			out.Checks["migrations"] = "ok"
+		}
+	}
+
+	status := http.StatusOK
+	if out.Status != "ok" {
+		status = http.StatusServiceUnavailable
+	}
+	httpx.RespondJSON(res, req, status, out)
+}
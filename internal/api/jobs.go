@@ -0,0 +1,115 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"Chirpy/internal/database"
+)
+
+// StartJobs launches the background maintenance jobs; they stop when ctx
+// is cancelled, and WaitJobs waits for them.
+func (cfg *API) StartJobs(ctx context.Context) {
+	cfg.runEvery(ctx, time.Hour, nil, cfg.purgeDeletedUsers)
+	cfg.runEvery(ctx, time.Hour, nil, cfg.purgeDeletedChirps)
+	cfg.runEvery(ctx, time.Minute, cfg.exportRequested, cfg.processPendingExports)
+	cfg.runEvery(ctx, 10*time.Minute, nil, cfg.expireSubscriptions)
+}
+
+// WaitJobs returns once the jobs started by StartJobs have stopped.
+func (cfg *API) WaitJobs() {
+	cfg.jobs.Wait()
+}
+
+// runEvery calls job right away, then every interval and whenever wake
+// receives, until ctx is done. A nil wake only waits for the interval.
+func (cfg *API) runEvery(ctx context.Context, interval time.Duration, wake <-chan struct{}, job func(context.Context)) {
+	cfg.jobs.Add(1)
+	go func() {
+		defer cfg.jobs.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			job(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			case <-wake:
+			}
+		}
+	}()
+}
+
+// purgeDeletedUsers hard-deletes the accounts whose deletion grace period
+// is over; their chirps and refresh tokens go with them (ON DELETE CASCADE).
+func (cfg *API) purgeDeletedUsers(ctx context.Context) {
+	purged, err := cfg.auditedPurge(ctx, "users", func(q database.Querier) (int64, error) {
+		return q.PurgeDeletedUsers(ctx, time.Now().Add(-accountDeletionGrace))
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "purge of deleted users failed", "err", err)
+		return
+	}
+	if purged > 0 {
+		slog.InfoContext(ctx, "purged deleted users", "count", purged)
+	}
+}
+
+// purgeDeletedChirps hard-deletes the tombstones older than chirpRetention.
+func (cfg *API) purgeDeletedChirps(ctx context.Context) {
+	purged, err := cfg.auditedPurge(ctx, "chirps", func(q database.Querier) (int64, error) {
+		return q.PurgeDeletedChirps(ctx, time.Now().Add(-chirpRetention))
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "purge of deleted chirps failed", "err", err)
+		return
+	}
+	if purged > 0 {
+		slog.InfoContext(ctx, "purged deleted chirps", "count", purged)
+	}
+}
+
+// auditedPurge runs purge and, when it deleted something, records it in
+// the audit log with no actor. Only one replica purges a table at a time;
+// the others skip the run and purge nothing.
+func (cfg *API) auditedPurge(ctx context.Context, targetType string, purge func(q database.Querier) (int64, error)) (int64, error) {
+	var purged int64
+	err := cfg.auditTx(ctx, func(q database.Querier) error {
+		locked, err := q.TryLockJob(ctx, "purge:"+targetType)
+		if err != nil {
+			return err
+		}
+		if !locked {
+			slog.DebugContext(ctx, "purge running on another replica", "target_type", targetType)
+			return nil
+		}
+		purged, err = purge(q)
+		if err != nil || purged == 0 {
+			return err
+		}
+		return appendAudit(ctx, q, auditActor{}, auditRecord{
+			action:     targetType + ".purge",
+			targetType: targetType,
+			after:      map[string]int64{"purged": purged},
+		})
+	})
+	return purged, err
+}
+
+// processPendingExports builds the pending exports, and those running for
+// longer than dataExportTimeout, e.g. because a shutdown interrupted them.
+func (cfg *API) processPendingExports(ctx context.Context) {
+	exports, err := cfg.store.QueryPendingDataExports(ctx, time.Now().Add(-dataExportTimeout))
+	if err != nil {
+		slog.ErrorContext(ctx, "cannot query pending data exports", "err", err)
+		return
+	}
+	for _, export := range exports {
+		if ctx.Err() != nil {
+			return
+		}
+		cfg.buildDataExport(ctx, export.ID)
+	}
+}
@@ -0,0 +1,178 @@
+package api
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"Chirpy/internal/database"
+	"Chirpy/internal/metrics"
+	"Chirpy/internal/tracing"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Metrics are the Prometheus metrics of the server, served on /metrics.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	// fileserverHits is also shown on /admin/metrics, so it is kept here
+	// and exported through a CounterFunc.
+	fileserverHits atomic.Int64
+
+	httpRequests     *prometheus.CounterVec
+	httpDuration     *prometheus.HistogramVec
+	httpResponseSize *prometheus.HistogramVec
+	httpInFlight     prometheus.Gauge
+
+	dbQueryDuration *prometheus.HistogramVec
+	dbQueryErrors   *prometheus.CounterVec
+
+	bcryptDuration *prometheus.HistogramVec
+
+	chirpsCreated     prometheus.Counter
+	chirpsFiltered    *prometheus.CounterVec
+	loginsFailed      *prometheus.CounterVec
+	webhooksProcessed *prometheus.CounterVec
+}
+
+func NewMetrics() *Metrics {
+	r := prometheus.NewRegistry()
+	f := promauto.With(r)
+	m := &Metrics{
+		registry: r,
+
+		httpRequests: f.NewCounterVec(prometheus.CounterOpts{
+			Name: "chirpy_http_requests_total",
+			Help: "HTTP requests served, by route and status code.",
+		}, []string{"method", "route", "code"}),
+		httpDuration: f.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "chirpy_http_request_duration_seconds",
+			Help:    "Time spent serving HTTP requests.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"method", "route"}),
+		httpResponseSize: f.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "chirpy_http_response_size_bytes",
+			Help:    "Size of HTTP response bodies.",
+			Buckets: prometheus.ExponentialBuckets(100, 10, 5),
+		}, []string{"method", "route"}),
+		httpInFlight: f.NewGauge(prometheus.GaugeOpts{
+			Name: "chirpy_http_requests_in_flight",
+			Help: "HTTP requests currently being served.",
+		}),
+
+		dbQueryDuration: f.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "chirpy_db_query_duration_seconds",
+			Help:    "Time spent running database queries.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"query"}),
+		dbQueryErrors: f.NewCounterVec(prometheus.CounterOpts{
+			Name: "chirpy_db_query_errors_total",
+			Help: "Database queries that failed.",
+		}, []string{"query"}),
+
+		bcryptDuration: f.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "chirpy_bcrypt_duration_seconds",
+			Help:    "Time spent hashing and checking passwords.",
+			Buckets: []float64{.05, .1, .25, .5, 1, 2.5, 5, 10},
+		}, []string{"operation"}),
+
+		chirpsCreated: f.NewCounter(prometheus.CounterOpts{
+			Name: "chirpy_chirps_created_total",
+			Help: "Chirps created.",
+		}),
+		chirpsFiltered: f.NewCounterVec(prometheus.CounterOpts{
+			Name: "chirpy_chirps_filtered_total",
+			Help: "Chirps refused or held by the spam checks, by reason.",
+		}, []string{"reason"}),
+		loginsFailed: f.NewCounterVec(prometheus.CounterOpts{
+			Name: "chirpy_logins_failed_total",
+			Help: "Failed login attempts, by reason.",
+		}, []string{"reason"}),
+		webhooksProcessed: f.NewCounterVec(prometheus.CounterOpts{
+			Name: "chirpy_webhooks_processed_total",
+			Help: "Webhook events handled, by event and outcome.",
+		}, []string{"event", "status"}),
+	}
+	f.NewCounterFunc(prometheus.CounterOpts{
+		Name: "chirpy_fileserver_hits_total",
+		Help: "Requests served by the /app/ file server.",
+	}, func() float64 { return float64(m.fileserverHits.Load()) })
+	return m
+}
+
+// Handler serves the metrics in the Prometheus exposition format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// InstrumentDB wraps db so that every query is timed and traced; pass it
+// to store.NewPostgres.
+func (m *Metrics) InstrumentDB(db database.DBTX) database.DBTX {
+	return tracing.InstrumentDB(metrics.InstrumentDB(db, m.dbQueryDuration, m.dbQueryErrors))
+}
+
+// responseRecorder remembers the status code and body size of a response.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += n
+	return n, err
+}
+
+func (r *responseRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// httpMethods are the methods that get their own label value; the others
+// share "other", so that clients cannot grow the series with made-up
+// methods.
+var httpMethods = []string{
+	http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut,
+	http.MethodPatch, http.MethodDelete, http.MethodOptions,
+}
+
+// methodLabel returns the value of the method label for method.
+func methodLabel(method string) string {
+	if slices.Contains(httpMethods, method) {
+		return method
+	}
+	return "other"
+}
+
+// middlewareMetrics records the request count, latency and response size of
+// every request, labelled with the mux pattern that served it.
+func (cfg *API) middlewareMetrics(next http.Handler) http.Handler {
+	m := cfg.metrics
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		m.httpInFlight.Inc()
+		defer m.httpInFlight.Dec()
+
+		rec := &responseRecorder{ResponseWriter: res, status: http.StatusOK}
+		start := time.Now()
+		next.ServeHTTP(rec, req)
+
+		//il mux imposta req.Pattern quando trova la rotta
+		route := req.Pattern
+		if route == "" {
+			route = "unmatched"
+		}
+		method := methodLabel(req.Method)
+		m.httpRequests.WithLabelValues(method, route, strconv.Itoa(rec.status)).Inc()
+		m.httpDuration.WithLabelValues(method, route).Observe(time.Since(start).Seconds())
+		m.httpResponseSize.WithLabelValues(method, route).Observe(float64(rec.bytes))
+	})
+}
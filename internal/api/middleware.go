@@ -0,0 +1,64 @@
+package api
+
+import (
+	"log/slog"
+	"net/http"
+	"regexp"
+	"time"
+
+	"Chirpy/internal/logging"
+	"github.com/google/uuid"
+)
+
+const requestIDHeader = "X-Request-ID"
+
+// validRequestID limits the request IDs we accept from clients, so that
+// anything ending up in the logs is short and printable.
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// middlewareRequestID gives every request an ID, reusing the one sent by the
+// client or a proxy when it looks sane, and echoes it in the response.
+func middlewareRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		id := req.Header.Get(requestIDHeader)
+		if !validRequestID.MatchString(id) {
+			id = uuid.NewString()
+		}
+		res.Header().Set(requestIDHeader, id)
+		next.ServeHTTP(res, req.WithContext(logging.WithRequestID(req.Context(), id)))
+	})
+}
+
+// middlewareAccessLog logs one line per request once it is served. Only the
+// path is logged: query strings may carry data we do not want in the logs.
+func middlewareAccessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		rec := &responseRecorder{ResponseWriter: res, status: http.StatusOK}
+		start := time.Now()
+		next.ServeHTTP(rec, req)
+
+		level := slog.LevelInfo
+		if rec.status >= 500 {
+			level = slog.LevelError
+		}
+		slog.Log(req.Context(), level, "request served",
+			"method", req.Method,
+			"path", req.URL.Path,
+			"route", req.Pattern,
+			"status", rec.status,
+			"bytes", rec.bytes,
+			"duration_ms", time.Since(start).Milliseconds(),
+			"remote_addr", req.RemoteAddr,
+			"user_agent", req.UserAgent(),
+		)
+	})
+}
+
+// middlewareMaxBody refuses to read more than limit bytes of any request
+// body; handlers see an error from the body once the limit is crossed.
+func middlewareMaxBody(limit int64, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		req.Body = http.MaxBytesReader(res, req.Body, limit)
+		next.ServeHTTP(res, req)
+	})
+}
@@ -0,0 +1,362 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+// undocumented routes are not part of the API.
+var undocumented = map[string]bool{
+	"/app/":         true,
+	"GET /api/docs": true,
+}
+
+type openAPIDoc struct {
+	OpenAPI string                                `json:"openapi"`
+	Paths   map[string]map[string]json.RawMessage `json:"paths"`
+}
+
+func loadOpenAPI(t *testing.T) openAPIDoc {
+	t.Helper()
+	var doc openAPIDoc
+	err := json.Unmarshal(openAPISpec, &doc)
+	if err != nil {
+		t.Fatalf("openapi.json: %v", err)
+	}
+	return doc
+}
+
+func TestOpenAPICoversRoutes(t *testing.T) {
+	doc := loadOpenAPI(t)
+	if doc.OpenAPI != "3.1.0" {
+		t.Errorf("openapi = %q, want 3.1.0", doc.OpenAPI)
+	}
+
+	cfg := &API{metrics: NewMetrics()}
+	registered := map[string]bool{}
+	for _, r := range cfg.routes() {
+		if undocumented[r.pattern] {
+			continue
+		}
+		pattern := r.pattern
+		if r.successor != "" {
+			//gli alias deprecati sono documentati dalla route che sostituiscono
+			pattern = r.successor
+		}
+		method, path, ok := strings.Cut(pattern, " ")
+		if !ok {
+			t.Errorf("route %q has no method", r.pattern)
+			continue
+		}
+		registered[strings.ToLower(method)+" "+path] = true
+		if _, ok := doc.Paths[path][strings.ToLower(method)]; !ok {
+			t.Errorf("route %s is missing from openapi.json", pattern)
+		}
+	}
+
+	for path, item := range doc.Paths {
+		for method := range item {
+			if method == "parameters" {
+				continue
+			}
+			if !registered[method+" "+path] {
+				t.Errorf("openapi.json documents %s %s, which is not registered", strings.ToUpper(method), path)
+			}
+		}
+	}
+}
+
+// openAPIResponses checks responses against the operations of openapi.json
+// that document their routes. The operation of a request is found through
+// a mux of the route table, like the server finds its handler.
+type openAPIResponses struct {
+	doc map[string]any
+	mux *http.ServeMux
+	// successors maps deprecated aliases to the patterns they stand for.
+	successors map[string]string
+}
+
+var loadOpenAPIResponses = sync.OnceValues(func() (*openAPIResponses, error) {
+	o := &openAPIResponses{mux: http.NewServeMux(), successors: map[string]string{}}
+	if err := json.Unmarshal(openAPISpec, &o.doc); err != nil {
+		return nil, err
+	}
+	cfg := &API{metrics: NewMetrics()}
+	for _, r := range cfg.routes() {
+		o.mux.Handle(r.pattern, http.NotFoundHandler())
+		if r.successor != "" {
+			o.successors[r.pattern] = r.successor
+		}
+	}
+	return o, nil
+})
+
+// checkOpenAPI fails the test when the response to req does not match the
+// response openapi.json documents for its route and status.
+func (s *testServer) checkOpenAPI(req *http.Request, rec *httptest.ResponseRecorder) {
+	s.t.Helper()
+	o, err := loadOpenAPIResponses()
+	if err != nil {
+		s.t.Fatalf("openapi.json: %v", err)
+	}
+	if err := o.check(req, rec); err != nil {
+		s.t.Errorf("%s %s answered %d, not as openapi.json documents: %v", req.Method, req.URL.Path, rec.Code, err)
+	}
+}
+
+func (o *openAPIResponses) check(req *http.Request, rec *httptest.ResponseRecorder) error {
+	_, pattern := o.mux.Handler(req)
+	if pattern == "" || undocumented[pattern] {
+		return nil
+	}
+	if successor, ok := o.successors[pattern]; ok {
+		pattern = successor
+	}
+	method, path, _ := strings.Cut(pattern, " ")
+	if method == http.MethodHead {
+		method = http.MethodGet
+	}
+	op, _ := o.lookup("#/paths", path, strings.ToLower(method)).(map[string]any)
+	responses, _ := op["responses"].(map[string]any)
+	response, ok := responses[strconv.Itoa(rec.Code)].(map[string]any)
+	if !ok {
+		if response, ok = responses["default"].(map[string]any); !ok {
+			return fmt.Errorf("no %d response", rec.Code)
+		}
+	}
+	response = o.resolve(response)
+
+	content, _ := response["content"].(map[string]any)
+	if len(content) == 0 {
+		if rec.Body.Len() > 0 {
+			return fmt.Errorf("the response has no content, got %q", rec.Body)
+		}
+		return nil
+	}
+	mediaType, _, _ := mime.ParseMediaType(rec.Header().Get("Content-Type"))
+	media, ok := content[mediaType].(map[string]any)
+	if !ok {
+		return fmt.Errorf("no %q content", mediaType)
+	}
+	schema, _ := media["schema"].(map[string]any)
+	if schema == nil || mediaType != "application/json" && !strings.HasSuffix(mediaType, "+json") {
+		return nil
+	}
+	var body any
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		return err
+	}
+	return errors.Join(o.validate(schema, body, "body")...)
+}
+
+// lookup follows a JSON pointer into the document, then the given keys.
+func (o *openAPIResponses) lookup(ref string, keys ...string) any {
+	var v any = o.doc
+	for _, key := range append(strings.Split(strings.TrimPrefix(ref, "#/"), "/"), keys...) {
+		m, _ := v.(map[string]any)
+		v = m[key]
+	}
+	return v
+}
+
+// resolve follows the $ref of a response or schema.
+func (o *openAPIResponses) resolve(v map[string]any) map[string]any {
+	for {
+		ref, ok := v["$ref"].(string)
+		if !ok {
+			return v
+		}
+		v, _ = o.lookup(ref).(map[string]any)
+	}
+}
+
+// validate checks v against the part of JSON Schema that openapi.json
+// uses. Unlike JSON Schema, an object with properties and no
+// additionalProperties is closed: a field a handler sends but the schema
+// does not document is the drift this is meant to catch.
+func (o *openAPIResponses) validate(schema map[string]any, v any, at string) []error {
+	schema = o.resolve(schema)
+	var errs []error
+	fail := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%s: "+format, append([]any{at}, args...)...))
+	}
+
+	if t, ok := schema["type"]; ok {
+		var types []string
+		switch t := t.(type) {
+		case string:
+			types = []string{t}
+		case []any:
+			for _, name := range t {
+				types = append(types, name.(string))
+			}
+		}
+		if !slices.ContainsFunc(types, func(name string) bool { return jsonType(v, name) }) {
+			fail("%s is not %v", jsonValue(v), types)
+			return errs
+		}
+	}
+	if enum, ok := schema["enum"].([]any); ok && !slices.Contains(enum, v) {
+		fail("%s is not one of %v", jsonValue(v), enum)
+	}
+	if c, ok := schema["const"]; ok && c != v {
+		fail("%s is not %v", jsonValue(v), c)
+	}
+
+	switch v := v.(type) {
+	case string:
+		var err error
+		switch schema["format"] {
+		case "date-time":
+			_, err = time.Parse(time.RFC3339Nano, v)
+		case "uuid":
+			_, err = uuid.Parse(v)
+		}
+		if err != nil {
+			fail("%q is not a %s", v, schema["format"])
+		}
+		if n, ok := schema["maxLength"].(float64); ok && float64(utf8.RuneCountInString(v)) > n {
+			fail("%q is longer than %v", v, n)
+		}
+		if n, ok := schema["minLength"].(float64); ok && float64(utf8.RuneCountInString(v)) < n {
+			fail("%q is shorter than %v", v, n)
+		}
+	case float64:
+		if n, ok := schema["minimum"].(float64); ok && v < n {
+			fail("%v is less than %v", v, n)
+		}
+		if n, ok := schema["maximum"].(float64); ok && v > n {
+			fail("%v is more than %v", v, n)
+		}
+	case []any:
+		if items, ok := schema["items"].(map[string]any); ok {
+			for i, item := range v {
+				errs = append(errs, o.validate(items, item, fmt.Sprintf("%s[%d]", at, i))...)
+			}
+		}
+	case map[string]any:
+		required, _ := schema["required"].([]any)
+		for _, name := range required {
+			if _, ok := v[name.(string)]; !ok {
+				fail("required property %q is missing", name)
+			}
+		}
+		properties, closed := schema["properties"].(map[string]any)
+		for name, value := range v {
+			if property, ok := properties[name].(map[string]any); ok {
+				errs = append(errs, o.validate(property, value, at+"."+name)...)
+				continue
+			}
+			switch additional := schema["additionalProperties"].(type) {
+			case map[string]any:
+				errs = append(errs, o.validate(additional, value, at+"."+name)...)
+			case bool:
+				if !additional {
+					fail("property %q is not allowed", name)
+				}
+			default:
+				if closed {
+					fail("property %q is not documented", name)
+				}
+			}
+		}
+	}
+	return errs
+}
+
+// jsonType reports whether a decoded JSON value has the JSON Schema type
+// name.
+func jsonType(v any, name string) bool {
+	switch v := v.(type) {
+	case nil:
+		return name == "null"
+	case bool:
+		return name == "boolean"
+	case float64:
+		return name == "number" || name == "integer" && v == math.Trunc(v)
+	case string:
+		return name == "string"
+	case []any:
+		return name == "array"
+	case map[string]any:
+		return name == "object"
+	}
+	return false
+}
+
+func jsonValue(v any) string {
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+func TestOpenAPIResponseCheck(t *testing.T) {
+	o, err := loadOpenAPIResponses()
+	if err != nil {
+		t.Fatal(err)
+	}
+	chirp := `{"id":"00000000-0000-0000-0000-000000000001","created_at":"2026-01-01T12:00:00Z","updated_at":"2026-01-01T12:00:00Z",` +
+		`"user_id":"00000000-0000-0000-0000-000000000002","body":"say my name"}`
+
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		status      int
+		contentType string
+		body        string
+		wantErr     string
+	}{
+		{"matches", "GET", "/api/v1/chirps/00000000-0000-0000-0000-000000000001", 200, "application/json", chirp, ""},
+		{"deprecated alias", "GET", "/api/chirps/00000000-0000-0000-0000-000000000001", 200, "application/json", chirp, ""},
+		{"undocumented route", "GET", "/app/", 200, "text/html", "<html>", ""},
+		{"undocumented status", "GET", "/api/v1/chirps/00000000-0000-0000-0000-000000000001", 418, "application/json", "{}", "no 418 response"},
+		{"undocumented field", "GET", "/api/v1/chirps/00000000-0000-0000-0000-000000000001", 200, "application/json",
+			strings.Replace(chirp, "{", `{"likes":3,`, 1), `"likes" is not documented`},
+		{"missing field", "GET", "/api/v1/chirps/00000000-0000-0000-0000-000000000001", 200, "application/json",
+			strings.Replace(chirp, `"body":"say my name"`, `"author":"walt"`, 1), `"body" is missing`},
+		{"wrong type", "GET", "/api/v1/chirps/00000000-0000-0000-0000-000000000001", 200, "application/json",
+			strings.Replace(chirp, `"say my name"`, "42", 1), "body.body: 42 is not"},
+		{"wrong format", "GET", "/api/v1/chirps/00000000-0000-0000-0000-000000000001", 200, "application/json",
+			strings.Replace(chirp, "2026-01-01T12:00:00Z", "yesterday", 1), `"yesterday" is not a date-time`},
+		{"problem", "GET", "/api/v1/chirps/walt", 400, "application/problem+json", `{"type":"about:blank"}`, `"code" is missing`},
+		{"wrong content type", "GET", "/api/v1/chirps/walt", 400, "text/plain", "walt", `no "text/plain" content`},
+		{"unexpected body", "POST", "/api/v1/polka/webhooks", 204, "", "ok", "has no content"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			rec.Header().Set("Content-Type", tt.contentType)
+			rec.WriteHeader(tt.status)
+			rec.WriteString(tt.body)
+			err := o.check(httptest.NewRequest(tt.method, tt.path, nil), rec)
+			if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("check() = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestServeOpenAPI(t *testing.T) {
+	rec := httptest.NewRecorder()
+	serveOpenAPI(rec, httptest.NewRequest("GET", "/api/openapi.json", nil))
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("status = %d, Content-Type = %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	if !json.Valid(rec.Body.Bytes()) {
+		t.Errorf("served document is not JSON")
+	}
+}
@@ -0,0 +1,32 @@
+package api
+
+import (
+	"context"
+	"time"
+
+	"Chirpy/internal/auth"
+	"Chirpy/internal/tracing"
+)
+
+// hashPassword hashes with the configured bcrypt cost, timed and traced:
+// bcrypt is by far the slowest step of signing up or logging in.
+func (cfg *API) hashPassword(ctx context.Context, password string) (string, error) {
+	_, span := tracing.Tracer().Start(ctx, "bcrypt hash")
+	defer span.End()
+	start := time.Now()
+	defer func() {
+		cfg.metrics.bcryptDuration.WithLabelValues("hash").Observe(time.Since(start).Seconds())
+	}()
+	return auth.HashPasswordWithCost(password, cfg.config.Auth.BcryptCost)
+}
+
+// checkPassword is auth.CheckPasswordHash, timed and traced.
+func (cfg *API) checkPassword(ctx context.Context, hash, password string) error {
+	_, span := tracing.Tracer().Start(ctx, "bcrypt check")
+	defer span.End()
+	start := time.Now()
+	defer func() {
+		cfg.metrics.bcryptDuration.WithLabelValues("check").Observe(time.Since(start).Seconds())
+	}()
+	return auth.CheckPasswordHash(hash, password)
+}
@@ -0,0 +1,207 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"slices"
+	"time"
+
+	"Chirpy/internal/auth"
+	"Chirpy/internal/database"
+	"Chirpy/internal/httpx"
+	"github.com/google/uuid"
+)
+
+// Relation is a user the caller blocked or muted.
+type Relation struct {
+	UserID    uuid.UUID `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// relationKind abstracts blocks and mutes, which only differ in their
+// tables: the handlers below serve both.
+type relationKind struct {
+	name   string
+	create func(ctx context.Context, q database.Querier, from, to uuid.UUID) (Relation, error)
+	delete func(ctx context.Context, q database.Querier, from, to uuid.UUID) (int64, error)
+	query  func(ctx context.Context, q database.Querier, from uuid.UUID) ([]Relation, error)
+}
+
+// Blocks and mutes hide the chirps of the other user from the listings of
+// the caller. A block also keeps the other user from mentioning the caller,
+// see checkMentions.
+var (
+	blockKind = relationKind{
+		name: "block",
+		create: func(ctx context.Context, q database.Querier, from, to uuid.UUID) (Relation, error) {
+			block, err := q.CreateBlock(ctx, database.CreateBlockParams{BlockerID: from, BlockedID: to})
+			return Relation{UserID: block.BlockedID, CreatedAt: block.CreatedAt}, err
+		},
+		delete: func(ctx context.Context, q database.Querier, from, to uuid.UUID) (int64, error) {
+			return q.DeleteBlock(ctx, database.DeleteBlockParams{BlockerID: from, BlockedID: to})
+		},
+		query: func(ctx context.Context, q database.Querier, from uuid.UUID) ([]Relation, error) {
+			blocks, err := q.QueryBlocks(ctx, from)
+			out := []Relation{}
+			for _, b := range blocks {
+				out = append(out, Relation{UserID: b.BlockedID, CreatedAt: b.CreatedAt})
+			}
+			return out, err
+		},
+	}
+	muteKind = relationKind{
+		name: "mute",
+		create: func(ctx context.Context, q database.Querier, from, to uuid.UUID) (Relation, error) {
+			mute, err := q.CreateMute(ctx, database.CreateMuteParams{MuterID: from, MutedID: to})
+			return Relation{UserID: mute.MutedID, CreatedAt: mute.CreatedAt}, err
+		},
+		delete: func(ctx context.Context, q database.Querier, from, to uuid.UUID) (int64, error) {
+			return q.DeleteMute(ctx, database.DeleteMuteParams{MuterID: from, MutedID: to})
+		},
+		query: func(ctx context.Context, q database.Querier, from uuid.UUID) ([]Relation, error) {
+			mutes, err := q.QueryMutes(ctx, from)
+			out := []Relation{}
+			for _, m := range mutes {
+				out = append(out, Relation{UserID: m.MutedID, CreatedAt: m.CreatedAt})
+			}
+			return out, err
+		},
+	}
+)
+
+func (cfg *API) blockUser(res http.ResponseWriter, req *http.Request) {
+	cfg.createRelation(res, req, blockKind)
+}
+
+func (cfg *API) unblockUser(res http.ResponseWriter, req *http.Request) {
+	cfg.deleteRelation(res, req, blockKind)
+}
+
+func (cfg *API) listBlocks(res http.ResponseWriter, req *http.Request) {
+	cfg.listRelations(res, req, blockKind)
+}
+
+func (cfg *API) muteUser(res http.ResponseWriter, req *http.Request) {
+	cfg.createRelation(res, req, muteKind)
+}
+
+func (cfg *API) unmuteUser(res http.ResponseWriter, req *http.Request) {
+	cfg.deleteRelation(res, req, muteKind)
+}
+
+func (cfg *API) listMutes(res http.ResponseWriter, req *http.Request) {
+	cfg.listRelations(res, req, muteKind)
+}
+
+// relationTarget returns the caller and the user of the path, answering
+// 400 when they are the same.
+func (cfg *API) relationTarget(res http.ResponseWriter, req *http.Request, kind relationKind) (auth.TokenClaims, uuid.UUID, bool) {
+	claims, ok := cfg.authorize(res, req, auth.RoleUser)
+	if !ok {
+		return claims, uuid.Nil, false
+	}
+	userID, ok := httpx.ParseUUID(res, req, "userID", req.PathValue("userID"))
+	if !ok {
+		return claims, uuid.Nil, false
+	}
+	if userID == claims.UserID {
+		httpx.RespondError(res, req, http.StatusBadRequest, httpx.CodeInvalidRequest, "you cannot "+kind.name+" yourself")
+		return claims, uuid.Nil, false
+	}
+	return claims, userID, true
+}
+
+func (cfg *API) createRelation(res http.ResponseWriter, req *http.Request, kind relationKind) {
+	claims, userID, ok := cfg.relationTarget(res, req, kind)
+	if !ok {
+		return
+	}
+	user, err := cfg.store.QueryUserByID(req.Context(), userID)
+	if err != nil || user.DeletedAt.Valid {
+		httpx.RespondError(res, req, http.StatusNotFound, httpx.CodeNotFound, "user not found")
+		return
+	}
+
+	relation, err := kind.create(req.Context(), cfg.store, claims.UserID, user.ID)
+	if err != nil {
+		respondStoreError(res, req, err, kind.name+" failed", "user_id", claims.UserID, "target_id", user.ID)
+		return
+	}
+	slog.InfoContext(req.Context(), "user "+kind.name+" created", "user_id", claims.UserID, "target_id", user.ID)
+
+	httpx.RespondJSON(res, req, 201, relation)
+}
+
+func (cfg *API) deleteRelation(res http.ResponseWriter, req *http.Request, kind relationKind) {
+	claims, userID, ok := cfg.relationTarget(res, req, kind)
+	if !ok {
+		return
+	}
+	n, err := kind.delete(req.Context(), cfg.store, claims.UserID, userID)
+	if err != nil {
+		slog.ErrorContext(req.Context(), "cannot delete "+kind.name, "user_id", claims.UserID, "target_id", userID, "err", err)
+		httpx.RespondError(res, req, http.StatusInternalServerError, httpx.CodeInternal, "")
+		return
+	}
+	if n == 0 {
+		httpx.RespondError(res, req, http.StatusNotFound, httpx.CodeNotFound, kind.name+" not found")
+		return
+	}
+	slog.InfoContext(req.Context(), "user "+kind.name+" deleted", "user_id", claims.UserID, "target_id", userID)
+
+	res.WriteHeader(204)
+}
+
+// listRelations lists the users the caller blocked or muted, oldest first.
+func (cfg *API) listRelations(res http.ResponseWriter, req *http.Request, kind relationKind) {
+	claims, ok := cfg.authorize(res, req, auth.RoleUser)
+	if !ok {
+		return
+	}
+	relations, err := kind.query(req.Context(), cfg.store, claims.UserID)
+	if err != nil {
+		slog.ErrorContext(req.Context(), "cannot query "+kind.name+"s", "user_id", claims.UserID, "err", err)
+		httpx.RespondError(res, req, http.StatusInternalServerError, httpx.CodeInternal, "")
+		return
+	}
+	httpx.RespondJSON(res, req, 200, relations)
+}
+
+// mentionPattern matches a mention of a user in a chirp: @ followed by the
+// id of the user.
+var mentionPattern = regexp.MustCompile(`@([0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12})`)
+
+// mentions returns the users mentioned in body, each once.
+func mentions(body string) []uuid.UUID {
+	var ids []uuid.UUID
+	for _, m := range mentionPattern.FindAllStringSubmatch(body, -1) {
+		id, err := uuid.Parse(m[1])
+		if err == nil && !slices.Contains(ids, id) {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// checkMentions answers 403 when body mentions a user who blocked author.
+func (cfg *API) checkMentions(res http.ResponseWriter, req *http.Request, author database.User, body string) bool {
+	for _, id := range mentions(body) {
+		_, err := cfg.store.QueryBlock(req.Context(), database.QueryBlockParams{BlockerID: id, BlockedID: author.ID})
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			slog.ErrorContext(req.Context(), "cannot query block", "user_id", author.ID, "mentioned_id", id, "err", err)
+			httpx.RespondError(res, req, http.StatusInternalServerError, httpx.CodeInternal, "")
+			return false
+		}
+		slog.InfoContext(req.Context(), "mention of a blocker refused", "user_id", author.ID, "mentioned_id", id)
+		httpx.RespondError(res, req, http.StatusForbidden, httpx.CodeForbidden, "you cannot mention a user who blocked you")
+		return false
+	}
+	return true
+}
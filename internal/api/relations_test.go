@@ -0,0 +1,118 @@
+package api
+
+import (
+	"testing"
+
+	"Chirpy/internal/httpx"
+	"github.com/google/uuid"
+)
+
+func TestRelations(t *testing.T) {
+	s := newTestServer(t)
+	walt := s.signup("walt@breakingbad.com", "04234")
+	jesse := s.signup("jesse@breakingbad.com", "password")
+
+	for _, kind := range []string{"block", "mute"} {
+		t.Run(kind, func(t *testing.T) {
+			path := "/api/v1/users/" + walt.ID.String() + "/" + kind
+			tests := []struct {
+				name       string
+				method     string
+				path       string
+				token      string
+				wantStatus int
+				wantCode   string
+			}{
+				{"anonymous", "POST", path, "", 401, httpx.CodeUnauthorized},
+				{"self", "POST", "/api/v1/users/" + jesse.ID.String() + "/" + kind, jesse.Token, 400, httpx.CodeInvalidRequest},
+				{"bad id", "POST", "/api/v1/users/nope/" + kind, jesse.Token, 400, httpx.CodeInvalidRequest},
+				{"unknown user", "POST", "/api/v1/users/" + uuid.NewString() + "/" + kind, jesse.Token, 404, httpx.CodeNotFound},
+				{"not there", "DELETE", path, jesse.Token, 404, httpx.CodeNotFound},
+				{"anonymous list", "GET", "/api/v1/users/" + kind + "s", "", 401, httpx.CodeUnauthorized},
+			}
+			for _, tt := range tests {
+				t.Run(tt.name, func(t *testing.T) {
+					expectProblem(t, s.do(request{method: tt.method, path: tt.path, token: tt.token}), tt.wantStatus, tt.wantCode)
+				})
+			}
+
+			var relation Relation
+			expect(t, s.do(request{method: "POST", path: path, token: jesse.Token}), 201, &relation)
+			if relation.UserID != walt.ID || relation.CreatedAt.IsZero() {
+				t.Errorf("relation = %+v", relation)
+			}
+			expectProblem(t, s.do(request{method: "POST", path: path, token: jesse.Token}), 409, httpx.CodeConflict)
+
+			var relations []Relation
+			expect(t, s.do(request{method: "GET", path: "/api/v1/users/" + kind + "s", token: jesse.Token}), 200, &relations)
+			if len(relations) != 1 || relations[0].UserID != walt.ID {
+				t.Errorf("%ss of jesse = %+v", kind, relations)
+			}
+			expect(t, s.do(request{method: "GET", path: "/api/v1/users/" + kind + "s", token: walt.Token}), 200, &relations)
+			if len(relations) != 0 {
+				t.Errorf("%ss of walt = %+v", kind, relations)
+			}
+
+			expect(t, s.do(request{method: "DELETE", path: path, token: jesse.Token}), 204, nil)
+			expectProblem(t, s.do(request{method: "DELETE", path: path, token: jesse.Token}), 404, httpx.CodeNotFound)
+		})
+	}
+}
+
+func TestRelationsFilterListings(t *testing.T) {
+	s := newTestServer(t)
+	walt := s.signup("walt@breakingbad.com", "04234")
+	jesse := s.signup("jesse@breakingbad.com", "password")
+	hank := s.signup("hank@dea.gov", "password")
+	waltChirp := "/api/v1/chirps/" + s.chirp(walt, "say my name").ID.String()
+	s.chirp(jesse, "yeah science")
+	hankChirp := "/api/v1/chirps/" + s.chirp(hank, "minerals").ID.String()
+
+	expect(t, s.do(request{method: "POST", path: "/api/v1/users/" + walt.ID.String() + "/mute", token: jesse.Token}), 201, nil)
+	expect(t, s.do(request{method: "POST", path: "/api/v1/users/" + hank.ID.String() + "/block", token: jesse.Token}), 201, nil)
+
+	byWalt := "/api/v1/chirps?author_id=" + walt.ID.String()
+	tests := []struct {
+		name  string
+		path  string
+		token string
+		want  int
+	}{
+		{"anonymous", "/api/v1/chirps", "", 3},
+		{"muter and blocker", "/api/v1/chirps", jesse.Token, 1},
+		{"muted user", "/api/v1/chirps", walt.Token, 3},
+		{"blocked user", "/api/v1/chirps", hank.Token, 3},
+		{"anonymous by muted author", byWalt, "", 1},
+		{"muter by muted author", byWalt, jesse.Token, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var chirps []Chirp
+			expect(t, s.do(request{method: "GET", path: tt.path, token: tt.token}), 200, &chirps)
+			if len(chirps) != tt.want {
+				t.Errorf("got %d chirps, want %d", len(chirps), tt.want)
+			}
+		})
+	}
+
+	//lo stesso vale per i chirp cercati per id
+	for _, path := range []string{waltChirp, hankChirp} {
+		expectProblem(t, s.do(request{method: "GET", path: path, token: jesse.Token}), 404, httpx.CodeNotFound)
+		expect(t, s.do(request{method: "GET", path: path}), 200, nil)
+	}
+}
+
+func TestBlockedMention(t *testing.T) {
+	s := newTestServer(t)
+	walt := s.signup("walt@breakingbad.com", "04234")
+	jesse := s.signup("jesse@breakingbad.com", "password")
+	hank := s.signup("hank@dea.gov", "minerals")
+	expect(t, s.do(request{method: "POST", path: "/api/v1/users/" + jesse.ID.String() + "/block", token: walt.Token}), 201, nil)
+
+	//jesse non può menzionare walt, che lo ha bloccato; il contrario sì
+	expectProblem(t, s.do(postChirp(jesse, "yo @"+walt.ID.String())), 403, httpx.CodeForbidden)
+	expectProblem(t, s.do(postChirp(jesse, "@"+hank.ID.String()+" and @"+walt.ID.String())), 403, httpx.CodeForbidden)
+	s.chirp(jesse, "yo @"+hank.ID.String())
+	s.chirp(walt, "say my name @"+jesse.ID.String())
+	s.chirp(hank, "@"+walt.ID.String()+" how is the car wash")
+}
@@ -0,0 +1,598 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"Chirpy/internal/auth"
+	"Chirpy/internal/database"
+	"Chirpy/internal/httpx"
+	"Chirpy/internal/validate"
+	"github.com/google/uuid"
+)
+
+// Actions recorded in the trail of a report, besides its resolutions.
+const (
+	reportCreated       = "created"
+	reportAssigned      = "assigned"
+	reportChirpHidden   = "chirp_hidden"
+	reportChirpRestored = "chirp_restored"
+)
+
+// Resolutions of a report, stored as its final status.
+const (
+	resolutionDismissed     = "dismissed"
+	resolutionChirpRemoved  = "chirp_removed"
+	resolutionUserSuspended = "user_suspended"
+)
+
+type Report struct {
+	ID         uuid.UUID  `json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	ReporterID uuid.UUID  `json:"reporter_id"`
+	UserID     uuid.UUID  `json:"user_id"`
+	ChirpID    *uuid.UUID `json:"chirp_id,omitempty"`
+	Reason     string     `json:"reason"`
+	Details    string     `json:"details,omitempty"`
+	Status     string     `json:"status"`
+	AssigneeID *uuid.UUID `json:"assignee_id,omitempty"`
+	ResolvedAt *time.Time `json:"resolved_at,omitempty"`
+
+	//solo nel dettaglio per i moderatori
+	Events []ReportEvent `json:"events,omitempty"`
+}
+
+type ReportEvent struct {
+	CreatedAt time.Time  `json:"created_at"`
+	ActorID   *uuid.UUID `json:"actor_id,omitempty"`
+	Action    string     `json:"action"`
+	Note      string     `json:"note,omitempty"`
+}
+
+func outputReport(report database.Report) Report {
+	out := Report{
+		ID:         report.ID,
+		CreatedAt:  report.CreatedAt,
+		UpdatedAt:  report.UpdatedAt,
+		ReporterID: report.ReporterID,
+		UserID:     report.UserID,
+		Reason:     report.Reason,
+		Details:    report.Details.String,
+		Status:     report.Status,
+	}
+	if report.ChirpID.Valid {
+		out.ChirpID = &report.ChirpID.UUID
+	}
+	if report.AssigneeID.Valid {
+		out.AssigneeID = &report.AssigneeID.UUID
+	}
+	if report.ResolvedAt.Valid {
+		out.ResolvedAt = &report.ResolvedAt.Time
+	}
+	return out
+}
+
+func outputReportEvent(event database.ReportEvent) ReportEvent {
+	out := ReportEvent{
+		CreatedAt: event.CreatedAt,
+		Action:    event.Action,
+		Note:      event.Note.String,
+	}
+	if event.ActorID.Valid {
+		out.ActorID = &event.ActorID.UUID
+	}
+	return out
+}
+
+// reportParameters is the body of both report endpoints.
+type reportParameters struct {
+	Reason  string `json:"reason" validate:"required,oneof=spam harassment hate violence sexual misinformation other"`
+	Details string `json:"details" validate:"max=1000"`
+}
+
+// autoHidden reports whether chirp was hidden by reports rather than
+// deleted by someone.
+func autoHidden(chirp database.Chirp) bool {
+	return chirp.DeletedAt.Valid && !chirp.DeletedBy.Valid
+}
+
+func recordReportEvent(ctx context.Context, q database.Querier, reportID uuid.UUID, actor uuid.NullUUID, action, note string) error {
+	_, err := q.CreateReportEvent(ctx, database.CreateReportEventParams{
+		ReportID: reportID,
+		ActorID:  actor,
+		Action:   action,
+		Note:     sql.NullString{String: note, Valid: note != ""},
+	})
+	return err
+}
+
+func (cfg *API) reportChirp(res http.ResponseWriter, req *http.Request) {
+	claims, ok := cfg.authorize(res, req, auth.RoleUser)
+	if !ok {
+		return
+	}
+	chirpID, ok := httpx.ParseUUID(res, req, "chirpID", req.PathValue("chirpID"))
+	if !ok {
+		return
+	}
+	params := reportParameters{}
+	if !httpx.DecodeJSON(res, req, &params) {
+		return
+	}
+
+	//non si segnala quello che non si vede
+	chirp, err := cfg.store.QueryChirp(req.Context(), database.QueryChirpParams{ID: chirpID, ViewerID: claims.UserID})
+	if err != nil {
+		httpx.RespondError(res, req, http.StatusNotFound, httpx.CodeNotFound, "chirp not found")
+		return
+	}
+	if chirp.UserID == claims.UserID {
+		httpx.RespondError(res, req, http.StatusBadRequest, httpx.CodeInvalidRequest, "you cannot report your own chirp")
+		return
+	}
+
+	cfg.createReport(res, req, database.CreateReportParams{
+		ReporterID: claims.UserID,
+		UserID:     chirp.UserID,
+		ChirpID:    uuid.NullUUID{UUID: chirp.ID, Valid: true},
+		Reason:     params.Reason,
+		Details:    sql.NullString{String: params.Details, Valid: params.Details != ""},
+	})
+}
+
+func (cfg *API) reportUser(res http.ResponseWriter, req *http.Request) {
+	claims, ok := cfg.authorize(res, req, auth.RoleUser)
+	if !ok {
+		return
+	}
+	userID, ok := httpx.ParseUUID(res, req, "userID", req.PathValue("userID"))
+	if !ok {
+		return
+	}
+	params := reportParameters{}
+	if !httpx.DecodeJSON(res, req, &params) {
+		return
+	}
+
+	user, err := cfg.store.QueryUserByID(req.Context(), userID)
+	if err != nil || user.DeletedAt.Valid {
+		httpx.RespondError(res, req, http.StatusNotFound, httpx.CodeNotFound, "user not found")
+		return
+	}
+	if user.ID == claims.UserID {
+		httpx.RespondError(res, req, http.StatusBadRequest, httpx.CodeInvalidRequest, "you cannot report yourself")
+		return
+	}
+
+	cfg.createReport(res, req, database.CreateReportParams{
+		ReporterID: claims.UserID,
+		UserID:     user.ID,
+		Reason:     params.Reason,
+		Details:    sql.NullString{String: params.Details, Valid: params.Details != ""},
+	})
+}
+
+// createReport files the report and, once enough users have reported the
+// same chirp, hides it until a moderator resolves the reports.
+func (cfg *API) createReport(res http.ResponseWriter, req *http.Request, params database.CreateReportParams) {
+	ctx := req.Context()
+	threshold := int64(cfg.config.Moderation.AutoHideReports)
+	var report database.Report
+	hidden := false
+	//a nascondere il chirp è il server, non chi ha fatto l'ultima segnalazione
+	actor := cfg.auditActorOf(req)
+	actor.userID = uuid.NullUUID{}
+	err := cfg.auditTx(ctx, func(q database.Querier) error {
+		var err error
+		hidden = false
+		report, err = q.CreateReport(ctx, params)
+		if err != nil {
+			return err
+		}
+		err = recordReportEvent(ctx, q, report.ID, uuid.NullUUID{UUID: params.ReporterID, Valid: true}, reportCreated, "")
+		if err != nil || !params.ChirpID.Valid || threshold == 0 {
+			return err
+		}
+
+		reporters, err := cfg.countReporters(ctx, q, params.ChirpID)
+		if err != nil || reporters < threshold {
+			return err
+		}
+		note := fmt.Sprintf("hidden after reports by %d users", reporters)
+		chirp, err := q.QueryChirpIncludingDeleted(ctx, params.ChirpID.UUID)
+		if err != nil || chirp.DeletedAt.Valid {
+			//già nascosto o cancellato
+			return err
+		}
+		deleted, err := q.SoftDeleteChirp(ctx, database.SoftDeleteChirpParams{
+			ID:             chirp.ID,
+			DeletionReason: sql.NullString{String: note, Valid: true},
+		})
+		if err != nil {
+			return err
+		}
+		hidden = true
+		err = recordReportEvent(ctx, q, report.ID, uuid.NullUUID{}, reportChirpHidden, note)
+		if err != nil {
+			return err
+		}
+		return appendAudit(ctx, q, actor, auditRecord{
+			action:     "chirp.hide",
+			targetType: "chirp",
+			targetID:   chirp.ID.String(),
+			before:     outputChirp(chirp),
+			after:      outputChirp(deleted),
+		})
+	})
+	if err != nil {
+		respondStoreError(res, req, err, "report creation failed", "reporter_id", params.ReporterID, "user_id", params.UserID)
+		return
+	}
+	slog.InfoContext(ctx, "report created", "report_id", report.ID, "reporter_id", report.ReporterID,
+		"user_id", report.UserID, "reason", report.Reason)
+	if hidden {
+		slog.WarnContext(ctx, "chirp hidden by reports", "chirp_id", params.ChirpID.UUID, "report_id", report.ID)
+	}
+
+	httpx.RespondJSON(res, req, 201, outputReport(report))
+}
+
+// listReports is the moderators' queue: the pending reports, oldest first,
+// or those with the given status; assignee=me keeps the caller's ones.
+func (cfg *API) listReports(res http.ResponseWriter, req *http.Request) {
+	claims := requestClaims(req)
+	query := req.URL.Query()
+	status := query.Get("status")
+	var reports []database.Report
+	var err error
+	switch status {
+	case "":
+		reports, err = cfg.store.QueryPendingReports(req.Context())
+	case "open", "assigned", resolutionDismissed, resolutionChirpRemoved, resolutionUserSuspended:
+		reports, err = cfg.store.QueryReportsByStatus(req.Context(), status)
+	default:
+		httpx.RespondInvalid(res, req, http.StatusBadRequest, httpx.CodeInvalidRequest, "unknown report status", validate.Errors{{
+			Field:   "status",
+			Rule:    "oneof",
+			Message: "must be one of open, assigned, dismissed, chirp_removed, user_suspended",
+		}})
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(req.Context(), "cannot list reports", "err", err)
+		httpx.RespondError(res, req, http.StatusInternalServerError, httpx.CodeInternal, "")
+		return
+	}
+
+	var assignee uuid.UUID
+	switch a := query.Get("assignee"); a {
+	case "":
+	case "me":
+		assignee = claims.UserID
+	default:
+		var ok bool
+		assignee, ok = httpx.ParseUUID(res, req, "assignee", a)
+		if !ok {
+			return
+		}
+	}
+
+	out := []Report{}
+	for _, r := range reports {
+		if assignee != uuid.Nil && r.AssigneeID.UUID != assignee {
+			continue
+		}
+		out = append(out, outputReport(r))
+	}
+	httpx.RespondJSON(res, req, 200, out)
+}
+
+func (cfg *API) getReport(res http.ResponseWriter, req *http.Request) {
+	reportID, ok := httpx.ParseUUID(res, req, "reportID", req.PathValue("reportID"))
+	if !ok {
+		return
+	}
+	report, ok := cfg.findReport(res, req, reportID)
+	if !ok {
+		return
+	}
+	events, err := cfg.store.QueryReportEvents(req.Context(), report.ID)
+	if err != nil {
+		slog.ErrorContext(req.Context(), "cannot query report events", "report_id", report.ID, "err", err)
+		httpx.RespondError(res, req, http.StatusInternalServerError, httpx.CodeInternal, "")
+		return
+	}
+
+	out := outputReport(report)
+	for _, e := range events {
+		out.Events = append(out.Events, outputReportEvent(e))
+	}
+	httpx.RespondJSON(res, req, 200, out)
+}
+
+// findReport answers 404 when the report does not exist.
+func (cfg *API) findReport(res http.ResponseWriter, req *http.Request, id uuid.UUID) (database.Report, bool) {
+	report, err := cfg.store.QueryReport(req.Context(), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		httpx.RespondError(res, req, http.StatusNotFound, httpx.CodeNotFound, "report not found")
+		return report, false
+	}
+	if err != nil {
+		slog.ErrorContext(req.Context(), "cannot query report", "report_id", id, "err", err)
+		httpx.RespondError(res, req, http.StatusInternalServerError, httpx.CodeInternal, "")
+		return report, false
+	}
+	return report, true
+}
+
+func respondReportResolved(res http.ResponseWriter, req *http.Request) {
+	httpx.RespondError(res, req, http.StatusConflict, httpx.CodeConflict, "the report is already resolved")
+}
+
+// assignReport hands a pending report to a moderator, the caller unless
+// the body names another one.
+func (cfg *API) assignReport(res http.ResponseWriter, req *http.Request) {
+	type parameters struct {
+		AssigneeID *uuid.UUID `json:"assignee_id"`
+	}
+
+	claims := requestClaims(req)
+	reportID, ok := httpx.ParseUUID(res, req, "reportID", req.PathValue("reportID"))
+	if !ok {
+		return
+	}
+	params := parameters{}
+	if req.ContentLength != 0 && !httpx.DecodeJSON(res, req, &params) {
+		return
+	}
+	assignee := claims.UserID
+	if params.AssigneeID != nil {
+		assignee = *params.AssigneeID
+		user, err := cfg.store.QueryUserByID(req.Context(), assignee)
+		if err != nil || user.DeletedAt.Valid || !auth.HasRole(user.Role, auth.RoleModerator) {
+			httpx.RespondInvalid(res, req, http.StatusUnprocessableEntity, httpx.CodeValidation, "the request has invalid fields", validate.Errors{{
+				Field:   "assignee_id",
+				Rule:    "moderator",
+				Message: "must be a moderator",
+			}})
+			return
+		}
+	}
+
+	report, ok := cfg.findReport(res, req, reportID)
+	if !ok {
+		return
+	}
+	err := cfg.store.WithTx(req.Context(), func(q database.Querier) error {
+		var err error
+		report, err = q.AssignReport(req.Context(), database.AssignReportParams{
+			ID:         report.ID,
+			AssigneeID: uuid.NullUUID{UUID: assignee, Valid: true},
+		})
+		if err != nil {
+			return err
+		}
+		return recordReportEvent(req.Context(), q, report.ID, uuid.NullUUID{UUID: claims.UserID, Valid: true},
+			reportAssigned, "assigned to "+assignee.String())
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondReportResolved(res, req)
+		return
+	}
+	if err != nil {
+		respondStoreError(res, req, err, "report assignment failed", "report_id", reportID)
+		return
+	}
+	slog.InfoContext(req.Context(), "report assigned", "report_id", report.ID, "assignee_id", assignee, "by", claims.UserID)
+
+	httpx.RespondJSON(res, req, 200, outputReport(report))
+}
+
+// resolveReport closes a pending report and applies its outcome: a
+// dismissal brings back a chirp hidden by reports, a removal turns it into
+// a moderation tombstone and a suspension suspends the reported user.
+func (cfg *API) resolveReport(res http.ResponseWriter, req *http.Request) {
+	type parameters struct {
+		Resolution string `json:"resolution" validate:"required,oneof=dismissed chirp_removed user_suspended"`
+		Note       string `json:"note" validate:"max=1000"`
+	}
+
+	claims := requestClaims(req)
+	moderator := uuid.NullUUID{UUID: claims.UserID, Valid: true}
+	reportID, ok := httpx.ParseUUID(res, req, "reportID", req.PathValue("reportID"))
+	if !ok {
+		return
+	}
+	params := parameters{}
+	if !httpx.DecodeJSON(res, req, &params) {
+		return
+	}
+
+	report, ok := cfg.findReport(res, req, reportID)
+	if !ok {
+		return
+	}
+	if params.Resolution == resolutionChirpRemoved && !report.ChirpID.Valid {
+		httpx.RespondInvalid(res, req, http.StatusUnprocessableEntity, httpx.CodeValidation, "the request has invalid fields", validate.Errors{{
+			Field:   "resolution",
+			Rule:    "chirp_report",
+			Message: "chirp_removed only resolves the report of a chirp",
+		}})
+		return
+	}
+	if report.ReporterID == claims.UserID {
+		httpx.RespondError(res, req, http.StatusForbidden, httpx.CodeForbidden, "you cannot resolve your own report")
+		return
+	}
+	//una sospensione passa dagli stessi controlli di POST /admin/users/{userID}/sanctions
+	if params.Resolution == resolutionUserSuspended {
+		user, err := cfg.store.QueryUserByID(req.Context(), report.UserID)
+		if err != nil {
+			slog.ErrorContext(req.Context(), "cannot query user", "user_id", report.UserID, "err", err)
+			httpx.RespondError(res, req, http.StatusInternalServerError, httpx.CodeInternal, "")
+			return
+		}
+		if refusal := sanctionRefusal(user, claims); refusal != "" {
+			httpx.RespondError(res, req, http.StatusForbidden, httpx.CodeForbidden, refusal)
+			return
+		}
+	}
+
+	ctx := req.Context()
+	actor := cfg.auditActorOf(req)
+	err := cfg.auditTx(ctx, func(q database.Querier) error {
+		var err error
+		report, err = q.ResolveReport(ctx, database.ResolveReportParams{ID: report.ID, Status: params.Resolution})
+		if err != nil {
+			return err
+		}
+		err = recordReportEvent(ctx, q, report.ID, moderator, params.Resolution, params.Note)
+		if err != nil {
+			return err
+		}
+
+		switch params.Resolution {
+		case resolutionDismissed:
+			return cfg.unhideReportedChirp(ctx, q, report, actor)
+		case resolutionChirpRemoved:
+			//le altre segnalazioni dello stesso chirp non hanno più niente da decidere
+			others, err := q.ResolveChirpReports(ctx, database.ResolveChirpReportsParams{Status: resolutionChirpRemoved, ChirpID: report.ChirpID})
+			if err != nil {
+				return err
+			}
+			for _, other := range others {
+				note := fmt.Sprintf("resolved with report %s", report.ID)
+				if err := recordReportEvent(ctx, q, other.ID, moderator, resolutionChirpRemoved, note); err != nil {
+					return err
+				}
+			}
+			return removeReportedChirp(ctx, q, report, actor)
+		case resolutionUserSuspended:
+			return cfg.suspendReportedUser(ctx, q, report, actor)
+		}
+		return nil
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondReportResolved(res, req)
+		return
+	}
+	if err != nil {
+		respondStoreError(res, req, err, "report resolution failed", "report_id", reportID)
+		return
+	}
+	slog.InfoContext(ctx, "report resolved", "report_id", report.ID, "resolution", report.Status,
+		"user_id", report.UserID, "by", claims.UserID)
+
+	httpx.RespondJSON(res, req, 200, outputReport(report))
+}
+
+// countReporters counts the users whose pending reports of chirpID weigh
+// towards hiding it.
+func (cfg *API) countReporters(ctx context.Context, q database.Querier, chirpID uuid.NullUUID) (int64, error) {
+	now := time.Now()
+	return q.CountChirpReporters(ctx, database.CountChirpReportersParams{
+		ChirpID:       chirpID,
+		CreatedBefore: now.Add(-cfg.config.Moderation.ReporterMinAge),
+		Now:           now,
+	})
+}
+
+// removeReportedChirp deletes the chirp of a report resolved as
+// chirp_removed on behalf of the moderator, unless its author or a
+// moderator already deleted it.
+func removeReportedChirp(ctx context.Context, q database.Querier, report database.Report, actor auditActor) error {
+	chirp, err := q.QueryChirpIncludingDeleted(ctx, report.ChirpID.UUID)
+	if err != nil {
+		return err
+	}
+	if chirp.DeletedAt.Valid && !autoHidden(chirp) {
+		return nil
+	}
+	if autoHidden(chirp) {
+		_, err = q.RestoreChirp(ctx, chirp.ID)
+		if err != nil {
+			return err
+		}
+	}
+	deleted, err := q.SoftDeleteChirp(ctx, database.SoftDeleteChirpParams{
+		ID:             chirp.ID,
+		DeletedBy:      actor.userID,
+		DeletionReason: sql.NullString{String: "report: " + report.Reason, Valid: true},
+	})
+	if err != nil {
+		return err
+	}
+	return appendAudit(ctx, q, actor, auditRecord{
+		action:     "chirp.delete",
+		targetType: "chirp",
+		targetID:   chirp.ID.String(),
+		before:     outputChirp(chirp),
+		after:      outputChirp(deleted),
+	})
+}
+
+// unhideReportedChirp restores the chirp of a dismissed report if reports
+// hid it and the pending ones no longer reach the threshold.
+func (cfg *API) unhideReportedChirp(ctx context.Context, q database.Querier, report database.Report, actor auditActor) error {
+	if !report.ChirpID.Valid {
+		return nil
+	}
+	chirp, err := q.QueryChirpIncludingDeleted(ctx, report.ChirpID.UUID)
+	if err != nil || !autoHidden(chirp) {
+		return err
+	}
+	threshold := int64(cfg.config.Moderation.AutoHideReports)
+	reporters, err := cfg.countReporters(ctx, q, report.ChirpID)
+	if err != nil || threshold > 0 && reporters >= threshold {
+		return err
+	}
+	restored, err := q.RestoreChirp(ctx, chirp.ID)
+	if err != nil {
+		return err
+	}
+	err = recordReportEvent(ctx, q, report.ID, actor.userID, reportChirpRestored, "")
+	if err != nil {
+		return err
+	}
+	return appendAudit(ctx, q, actor, auditRecord{
+		action:     "chirp.restore",
+		targetType: "chirp",
+		targetID:   chirp.ID.String(),
+		before:     outputChirp(chirp),
+		after:      outputChirp(restored),
+	})
+}
+
+// suspendReportedUser suspends the reported user for the configured time,
+// unless they are banned, shadow-banned or already suspended for longer: a
+// suspension would replace the open-ended sanction, which would then be
+// lost when it ended.
+func (cfg *API) suspendReportedUser(ctx context.Context, q database.Querier, report database.Report, actor auditActor) error {
+	user, err := q.QueryUserByID(ctx, report.UserID)
+	if err != nil {
+		return err
+	}
+	until := time.Now().Add(cfg.config.Moderation.ReportSuspension).UTC()
+	if user.Status == statusBanned || user.Status == statusShadowBanned ||
+		user.Status == statusSuspended && (!user.SuspendedUntil.Valid || user.SuspendedUntil.Time.After(until)) {
+		return nil
+	}
+	sanction, err := applySanction(ctx, q, user.ID, actor.userID, statusSuspended, sql.NullTime{Time: until, Valid: true},
+		"report: "+report.Reason)
+	if err != nil {
+		return err
+	}
+	return appendAudit(ctx, q, actor, auditRecord{
+		action:     "user.sanction",
+		targetType: "user",
+		targetID:   user.ID.String(),
+		before:     statusSnapshot(user),
+		after:      outputSanction(sanction),
+	})
+}
@@ -0,0 +1,244 @@
+package api
+
+import (
+	"context"
+	"testing"
+
+	"Chirpy/internal/auth"
+	"Chirpy/internal/database"
+	"Chirpy/internal/httpx"
+	"Chirpy/internal/store"
+)
+
+// report files a report through the API and returns it.
+func (s *testServer) report(user User, path, reason string) Report {
+	s.t.Helper()
+	var report Report
+	expect(s.t, s.do(request{method: "POST", path: path + "/report", token: user.Token,
+		body: map[string]string{"reason": reason}}), 201, &report)
+	return report
+}
+
+func TestReportCreate(t *testing.T) {
+	s := newTestServer(t)
+	walt := s.signup("walt@breakingbad.com", "04234")
+	jesse := s.signup("jesse@breakingbad.com", "password")
+	chirp := s.chirp(walt, "say my name")
+	chirpPath := "/api/v1/chirps/" + chirp.ID.String()
+	userPath := "/api/v1/users/" + walt.ID.String()
+
+	tests := []struct {
+		name       string
+		path       string
+		token      string
+		body       any
+		wantStatus int
+		wantCode   string
+	}{
+		{"anonymous", chirpPath, "", map[string]string{"reason": "spam"}, 401, httpx.CodeUnauthorized},
+		{"unknown reason", chirpPath, jesse.Token, map[string]string{"reason": "boring"}, 422, httpx.CodeValidation},
+		{"no reason", userPath, jesse.Token, map[string]string{}, 422, httpx.CodeValidation},
+		{"own chirp", chirpPath, walt.Token, map[string]string{"reason": "spam"}, 400, httpx.CodeInvalidRequest},
+		{"self", userPath, walt.Token, map[string]string{"reason": "spam"}, 400, httpx.CodeInvalidRequest},
+		{"bad id", "/api/v1/chirps/nope", jesse.Token, map[string]string{"reason": "spam"}, 400, httpx.CodeInvalidRequest},
+		{"missing chirp", "/api/v1/chirps/" + walt.ID.String(), jesse.Token, map[string]string{"reason": "spam"}, 404, httpx.CodeNotFound},
+		{"missing user", "/api/v1/users/" + chirp.ID.String(), jesse.Token, map[string]string{"reason": "spam"}, 404, httpx.CodeNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := s.do(request{method: "POST", path: tt.path + "/report", token: tt.token, body: tt.body})
+			expectProblem(t, rec, tt.wantStatus, tt.wantCode)
+		})
+	}
+
+	report := s.report(jesse, chirpPath, "harassment")
+	if report.ChirpID == nil || *report.ChirpID != chirp.ID || report.UserID != walt.ID || report.ReporterID != jesse.ID || report.Status != "open" {
+		t.Errorf("chirp report = %+v", report)
+	}
+	expectProblem(t, s.do(request{method: "POST", path: chirpPath + "/report", token: jesse.Token,
+		body: map[string]string{"reason": "spam"}}), 409, httpx.CodeConflict)
+
+	report = s.report(jesse, userPath, "spam")
+	if report.ChirpID != nil || report.UserID != walt.ID {
+		t.Errorf("user report = %+v", report)
+	}
+}
+
+func TestReportAutoHide(t *testing.T) {
+	conf := testConfig()
+	conf.Moderation.AutoHideReports = 2
+	conf.Moderation.ReporterMinAge = 0
+	s := newTestServerWith(t, conf, store.NewMemory())
+	walt := s.signup("walt@breakingbad.com", "04234")
+	jesse := s.signup("jesse@breakingbad.com", "password")
+	hank := s.signup("hank@dea.gov", "password")
+	todd := s.signup("todd@vamonos.com", "password")
+	moderator := s.signupAs("mod@chirpy.test", auth.RoleModerator)
+	chirp := s.chirp(walt, "say my name")
+	chirpPath := "/api/v1/chirps/" + chirp.ID.String()
+
+	//chi è sanzionato può segnalare ma non nasconde niente
+	if _, err := s.store.SetUserStatus(context.Background(), database.SetUserStatusParams{ID: todd.ID, Status: "shadow_banned"}); err != nil {
+		t.Fatal(err)
+	}
+	s.report(todd, chirpPath, "spam")
+	first := s.report(jesse, chirpPath, "spam")
+	expect(t, s.do(request{method: "GET", path: chirpPath}), 200, nil)
+	second := s.report(hank, chirpPath, "harassment")
+	expectProblem(t, s.do(request{method: "GET", path: chirpPath}), 404, httpx.CodeNotFound)
+
+	var detail Report
+	expect(t, s.do(request{method: "GET", path: "/admin/reports/" + second.ID.String(), token: moderator.Token}), 200, &detail)
+	if len(detail.Events) != 2 || detail.Events[1].Action != reportChirpHidden || detail.Events[1].ActorID != nil {
+		t.Errorf("events = %+v", detail.Events)
+	}
+
+	//finché resta una segnalazione aperta sopra la soglia il chirp rimane nascosto
+	resolve := func(r Report, resolution string) request {
+		return request{method: "POST", path: "/admin/reports/" + r.ID.String() + "/resolve", token: moderator.Token,
+			body: map[string]string{"resolution": resolution}}
+	}
+	expect(t, s.do(resolve(first, resolutionDismissed)), 200, nil)
+	expect(t, s.do(request{method: "GET", path: chirpPath}), 200, nil)
+	expect(t, s.do(resolve(second, resolutionDismissed)), 200, nil)
+	expect(t, s.do(request{method: "GET", path: chirpPath}), 200, nil)
+}
+
+func TestReportAutoHideNewAccounts(t *testing.T) {
+	conf := testConfig()
+	conf.Moderation.AutoHideReports = 1
+	s := newTestServerWith(t, conf, store.NewMemory())
+	walt := s.signup("walt@breakingbad.com", "04234")
+	jesse := s.signup("jesse@breakingbad.com", "password")
+	chirpPath := "/api/v1/chirps/" + s.chirp(walt, "say my name").ID.String()
+
+	s.report(jesse, chirpPath, "spam")
+	expect(t, s.do(request{method: "GET", path: chirpPath}), 200, nil)
+}
+
+func TestModerationQueue(t *testing.T) {
+	s := newTestServer(t)
+	walt := s.signup("walt@breakingbad.com", "04234")
+	jesse := s.signup("jesse@breakingbad.com", "password")
+	moderator := s.signupAs("mod@chirpy.test", auth.RoleModerator)
+	other := s.signupAs("mod2@chirpy.test", auth.RoleModerator)
+	chirp := s.chirp(walt, "say my name")
+	chirpPath := "/api/v1/chirps/" + chirp.ID.String()
+	chirpReport := s.report(jesse, chirpPath, "hate")
+	userReport := s.report(jesse, "/api/v1/users/"+walt.ID.String(), "spam")
+	reportPath := func(r Report) string { return "/admin/reports/" + r.ID.String() }
+
+	expectProblem(t, s.do(request{method: "GET", path: "/admin/reports"}), 401, httpx.CodeUnauthorized)
+	expectProblem(t, s.do(request{method: "GET", path: "/admin/reports", token: jesse.Token}), 403, httpx.CodeForbidden)
+	expectProblem(t, s.do(request{method: "GET", path: reportPath(chirpReport), token: jesse.Token}), 403, httpx.CodeForbidden)
+	expectProblem(t, s.do(request{method: "GET", path: "/admin/reports?status=closed", token: moderator.Token}), 400, httpx.CodeInvalidRequest)
+	expectProblem(t, s.do(request{method: "GET", path: "/admin/reports/" + walt.ID.String(), token: moderator.Token}), 404, httpx.CodeNotFound)
+
+	var queue []Report
+	expect(t, s.do(request{method: "GET", path: "/admin/reports", token: moderator.Token}), 200, &queue)
+	if len(queue) != 2 || queue[0].ID != chirpReport.ID {
+		t.Fatalf("queue = %+v", queue)
+	}
+
+	assign := func(r Report, body any) request {
+		return request{method: "POST", path: reportPath(r) + "/assign", token: moderator.Token, body: body}
+	}
+	expectProblem(t, s.do(assign(chirpReport, map[string]string{"assignee_id": walt.ID.String()})), 422, httpx.CodeValidation)
+	var assigned Report
+	expect(t, s.do(assign(chirpReport, nil)), 200, &assigned)
+	if assigned.Status != "assigned" || assigned.AssigneeID == nil || *assigned.AssigneeID != moderator.ID {
+		t.Errorf("assigned = %+v", assigned)
+	}
+	expect(t, s.do(assign(userReport, map[string]string{"assignee_id": other.ID.String()})), 200, nil)
+	expect(t, s.do(request{method: "GET", path: "/admin/reports?assignee=me", token: moderator.Token}), 200, &queue)
+	if len(queue) != 1 || queue[0].ID != chirpReport.ID {
+		t.Errorf("queue of the moderator = %+v", queue)
+	}
+
+	resolve := func(r Report, resolution string) request {
+		return request{method: "POST", path: reportPath(r) + "/resolve", token: moderator.Token,
+			body: map[string]string{"resolution": resolution, "note": "checked"}}
+	}
+	expectProblem(t, s.do(resolve(userReport, "ignored")), 422, httpx.CodeValidation)
+	expectProblem(t, s.do(resolve(userReport, resolutionChirpRemoved)), 422, httpx.CodeValidation)
+
+	hank := s.signup("hank@dea.gov", "password")
+	sameChirp := s.report(hank, chirpPath, "spam")
+	var resolved Report
+	expect(t, s.do(resolve(chirpReport, resolutionChirpRemoved)), 200, &resolved)
+	if resolved.Status != resolutionChirpRemoved || resolved.ResolvedAt == nil {
+		t.Errorf("resolved = %+v", resolved)
+	}
+	//le altre segnalazioni del chirp si chiudono con la prima
+	var together Report
+	expect(t, s.do(request{method: "GET", path: reportPath(sameChirp), token: moderator.Token}), 200, &together)
+	if together.Status != resolutionChirpRemoved || together.ResolvedAt == nil ||
+		len(together.Events) != 2 || together.Events[1].Note != "resolved with report "+chirpReport.ID.String() {
+		t.Errorf("report on the same chirp = %+v", together)
+	}
+	expectProblem(t, s.do(request{method: "GET", path: chirpPath}), 404, httpx.CodeNotFound)
+	expectProblem(t, s.do(resolve(chirpReport, resolutionDismissed)), 409, httpx.CodeConflict)
+	expectProblem(t, s.do(assign(chirpReport, nil)), 409, httpx.CodeConflict)
+	//l'autore non può annullare la rimozione di un moderatore
+	expectProblem(t, s.do(request{method: "POST", path: chirpPath + "/restore", token: walt.Token}), 403, httpx.CodeForbidden)
+
+	expect(t, s.do(resolve(userReport, resolutionUserSuspended)), 200, nil)
+	expectProblem(t, s.do(request{method: "POST", path: "/api/v1/refresh", token: walt.RefreshToken}), 401, httpx.CodeUnauthorized)
+	expectProblem(t, s.do(request{method: "POST", path: "/api/v1/login", body: map[string]string{
+		"email": "walt@breakingbad.com", "password": "04234",
+	}}), 403, httpx.CodeAccountSuspended)
+
+	var detail Report
+	expect(t, s.do(request{method: "GET", path: reportPath(chirpReport), token: moderator.Token}), 200, &detail)
+	actions := []string{}
+	for _, e := range detail.Events {
+		actions = append(actions, e.Action)
+	}
+	if len(actions) != 3 || actions[0] != reportCreated || actions[1] != reportAssigned || actions[2] != resolutionChirpRemoved || detail.Events[2].Note != "checked" {
+		t.Errorf("events = %+v", detail.Events)
+	}
+	expect(t, s.do(request{method: "GET", path: "/admin/reports", token: moderator.Token}), 200, &queue)
+	if len(queue) != 0 {
+		t.Errorf("resolved reports are still queued: %+v", queue)
+	}
+}
+
+func TestReportSuspensionChecks(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.signupAs("admin@chirpy.test", auth.RoleAdmin)
+	moderator := s.signupAs("mod@chirpy.test", auth.RoleModerator)
+	other := s.signupAs("mod2@chirpy.test", auth.RoleModerator)
+	walt := s.signup("walt@breakingbad.com", "04234")
+	resolve := func(by User, r Report) request {
+		return request{method: "POST", path: "/admin/reports/" + r.ID.String() + "/resolve", token: by.Token,
+			body: map[string]string{"resolution": resolutionUserSuspended}}
+	}
+
+	//un moderatore non sospende lo staff, né sé stesso, passando da una segnalazione
+	adminReport := s.report(moderator, "/api/v1/users/"+admin.ID.String(), "spam")
+	expectProblem(t, s.do(resolve(other, adminReport)), 403, httpx.CodeForbidden)
+	selfReport := s.report(other, "/api/v1/users/"+moderator.ID.String(), "spam")
+	expectProblem(t, s.do(resolve(moderator, selfReport)), 403, httpx.CodeForbidden)
+	s.login("admin@chirpy.test", "password")
+	s.login("mod@chirpy.test", "password")
+
+	//né risolve le proprie segnalazioni
+	waltReport := s.report(moderator, "/api/v1/users/"+walt.ID.String(), "spam")
+	expectProblem(t, s.do(resolve(moderator, waltReport)), 403, httpx.CodeForbidden)
+
+	//un bando ombra non viene sostituito da una sospensione
+	expect(t, s.do(request{method: "POST", path: "/admin/users/" + walt.ID.String() + "/sanctions", token: admin.Token,
+		body: map[string]string{"status": "shadow_banned", "reason": "spam"}}), 201, nil)
+	expect(t, s.do(resolve(other, waltReport)), 200, nil)
+	user, err := s.store.QueryUserByID(context.Background(), walt.ID)
+	if err != nil || user.Status != statusShadowBanned {
+		t.Errorf("status = %q, %v", user.Status, err)
+	}
+
+	//un altro admin sì
+	root := s.signupAs("root@chirpy.test", auth.RoleAdmin)
+	expect(t, s.do(resolve(root, adminReport)), 200, nil)
+	expectProblem(t, s.do(request{method: "POST", path: "/api/v1/login", body: map[string]string{
+		"email": "admin@chirpy.test", "password": "password",
+	}}), 403, httpx.CodeAccountSuspended)
+}
@@ -0,0 +1,66 @@
+package api
+
+import (
+	"net/http"
+
+	"Chirpy/internal/auth"
+)
+
+// route is one pattern registered on the mux. The table is shared with the
+// tests that check the OpenAPI document against what the server serves.
+type route struct {
+	pattern string
+	handler http.Handler
+	// successor is the versioned pattern a deprecated alias stands for.
+	successor string
+}
+
+func (cfg *API) routes() []route {
+	/*	The .Handle() method is how you register a handler function for a specific URL path in your server. In this case, you need to register a handler for the root path (/), which is what browsers request when someone visits your base URL (http://localhost:8080).
+
+	You're not actually setting up a special handler for index.html specifically. Instead, what's happening is:
+
+	You're setting up a FileServer that points to your current directory (.)
+	When someone visits the root path (/), the FileServer automatically looks for an index.html file in the directory
+	This is a standard convention in web servers - when a directory is requested, the server looks for an index.html file to serve
+	So when you use:
+
+	mux.Handle("/", http.FileServer(http.Dir(".")))
+
+	You're telling the server "when someone requests '/', serve files from the current directory" - and the FileServer automatically knows to serve index.html when the root of that directory is requested.
+
+	*/
+	fileSystem := http.Dir(".")
+	fileserver := http.FileServer(fileSystem)
+
+	routes := []route{
+		{pattern: "/app/", handler: cfg.middlewareMetricsInc(http.StripPrefix("/app", fileserver))},
+		{pattern: "GET /api/healthz", handler: http.HandlerFunc(serverStatus)},
+		{pattern: "GET /api/readyz", handler: http.HandlerFunc(cfg.serverReady)},
+		{pattern: "GET /api/openapi.json", handler: http.HandlerFunc(serveOpenAPI)},
+		{pattern: "GET /api/docs", handler: http.HandlerFunc(serveAPIDocs)},
+		{pattern: "GET /metrics", handler: cfg.metrics.Handler()},
+		{pattern: "GET /admin/metrics", handler: cfg.requireRole(auth.RoleAdmin, cfg.serverCount)},
+		{pattern: "POST /admin/reset", handler: cfg.requireRole(auth.RoleAdmin, cfg.resetServerCount)},
+		{pattern: "GET /admin/audit", handler: cfg.requireRole(auth.RoleAdmin, cfg.listAudit)},
+		{pattern: "GET /admin/audit/verify", handler: cfg.requireRole(auth.RoleAdmin, cfg.verifyAudit)},
+		{pattern: "PUT /admin/users/{userID}/role", handler: cfg.requireRole(auth.RoleAdmin, cfg.setUserRole)},
+		{pattern: "GET /admin/users/{userID}/sanctions", handler: cfg.requireRole(auth.RoleModerator, cfg.userSanctions)},
+		{pattern: "POST /admin/users/{userID}/sanctions", handler: cfg.requireRole(auth.RoleModerator, cfg.sanctionUser)},
+		{pattern: "DELETE /admin/users/{userID}/sanctions", handler: cfg.requireRole(auth.RoleModerator, cfg.liftSanction)},
+		{pattern: "POST /admin/webhooks/{eventID}/replay", handler: cfg.requireRole(auth.RoleAdmin, cfg.replayWebhook)},
+		{pattern: "GET /admin/reports", handler: cfg.requireRole(auth.RoleModerator, cfg.listReports)},
+		{pattern: "GET /admin/reports/{reportID}", handler: cfg.requireRole(auth.RoleModerator, cfg.getReport)},
+		{pattern: "POST /admin/reports/{reportID}/assign", handler: cfg.requireRole(auth.RoleModerator, cfg.assignReport)},
+		{pattern: "POST /admin/reports/{reportID}/resolve", handler: cfg.requireRole(auth.RoleModerator, cfg.resolveReport)},
+		{pattern: "GET /admin/chirps/held", handler: cfg.requireRole(auth.RoleModerator, cfg.listHeldChirps)},
+		{pattern: "POST /admin/chirps/{chirpID}/release", handler: cfg.requireRole(auth.RoleModerator, cfg.releaseChirp)},
+	}
+
+	//le route senza versione restano come alias della prima versione
+	versions := cfg.apiVersions()
+	for _, v := range versions {
+		routes = append(routes, mountVersion(v)...)
+	}
+	return append(routes, legacyRoutes(versions[0])...)
+}
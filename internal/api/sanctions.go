@@ -0,0 +1,311 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"Chirpy/internal/auth"
+	"Chirpy/internal/database"
+	"Chirpy/internal/httpx"
+	"Chirpy/internal/validate"
+	"github.com/google/uuid"
+)
+
+// Account states. A suspension ends by itself at suspended_until; a
+// shadow-banned user keeps using the service, but only they see their
+// chirps listed.
+const (
+	statusActive       = "active"
+	statusSuspended    = "suspended"
+	statusBanned       = "banned"
+	statusShadowBanned = "shadow_banned"
+)
+
+type Sanction struct {
+	ID             uuid.UUID  `json:"id"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UserID         uuid.UUID  `json:"user_id"`
+	ActorID        *uuid.UUID `json:"actor_id,omitempty"`
+	Status         string     `json:"status"`
+	SuspendedUntil *time.Time `json:"suspended_until,omitempty"`
+	Reason         string     `json:"reason"`
+}
+
+func outputSanction(sanction database.Sanction) Sanction {
+	out := Sanction{
+		ID:        sanction.ID,
+		CreatedAt: sanction.CreatedAt,
+		UserID:    sanction.UserID,
+		Status:    sanction.Status,
+		Reason:    sanction.Reason,
+	}
+	if sanction.ActorID.Valid {
+		out.ActorID = &sanction.ActorID.UUID
+	}
+	if sanction.SuspendedUntil.Valid {
+		out.SuspendedUntil = &sanction.SuspendedUntil.Time
+	}
+	return out
+}
+
+// sanctioned reports whether user is under a sanction at now: a suspension
+// that has not ended yet, a ban or a shadow ban.
+func sanctioned(user database.User, now time.Time) bool {
+	switch user.Status {
+	case statusSuspended:
+		return !user.SuspendedUntil.Valid || user.SuspendedUntil.Time.After(now)
+	case statusBanned, statusShadowBanned:
+		return true
+	}
+	return false
+}
+
+// respondRestricted answers 403 when user may not log in, refresh their
+// tokens or post: when they are banned or suspended. Shadow-banned users
+// are not told.
+func respondRestricted(res http.ResponseWriter, req *http.Request, user database.User) bool {
+	if !sanctioned(user, time.Now()) {
+		return false
+	}
+	switch user.Status {
+	case statusBanned:
+		httpx.RespondError(res, req, http.StatusForbidden, httpx.CodeAccountBanned, "this account is banned")
+	case statusSuspended:
+		detail := "this account is suspended"
+		if user.SuspendedUntil.Valid {
+			detail += " until " + user.SuspendedUntil.Time.UTC().Format(time.RFC3339)
+		}
+		httpx.RespondError(res, req, http.StatusForbidden, httpx.CodeAccountSuspended, detail)
+	default:
+		return false
+	}
+	slog.InfoContext(req.Context(), "sanctioned user refused", "user_id", user.ID, "status", user.Status)
+	return true
+}
+
+// applySanction moves user to status, records it in their history and, for
+// suspensions and bans, signs them out of every session.
+func applySanction(ctx context.Context, q database.Querier, userID uuid.UUID, actor uuid.NullUUID, status string, until sql.NullTime, reason string) (database.Sanction, error) {
+	_, err := q.SetUserStatus(ctx, database.SetUserStatusParams{
+		ID:             userID,
+		Status:         status,
+		SuspendedUntil: until,
+	})
+	if err != nil {
+		return database.Sanction{}, err
+	}
+	sanction, err := q.CreateSanction(ctx, database.CreateSanctionParams{
+		UserID:         userID,
+		ActorID:        actor,
+		Status:         status,
+		SuspendedUntil: until,
+		Reason:         reason,
+	})
+	if err != nil {
+		return database.Sanction{}, err
+	}
+	if status == statusSuspended || status == statusBanned {
+		err = q.RevokeUserTokens(ctx, userID)
+	}
+	return sanction, err
+}
+
+// statusSnapshot is the part of a user that sanctions change.
+func statusSnapshot(user database.User) map[string]any {
+	snapshot := map[string]any{"status": user.Status}
+	if user.SuspendedUntil.Valid {
+		snapshot["suspended_until"] = user.SuspendedUntil.Time
+	}
+	return snapshot
+}
+
+// sanctionRefusal returns why the holder of claims may not sanction user,
+// or "" when they may: nobody sanctions themselves, and only admins
+// sanction moderators and admins.
+func sanctionRefusal(user database.User, claims auth.TokenClaims) string {
+	if user.ID == claims.UserID {
+		return "you cannot sanction yourself"
+	}
+	if auth.HasRole(user.Role, auth.RoleModerator) && !auth.HasRole(claims.Role, auth.RoleAdmin) {
+		return "only admins can sanction staff"
+	}
+	return ""
+}
+
+// sanctionTarget returns the user of the path, answering 404 when they do
+// not exist and 403 when sanctionRefusal forbids the caller to sanction
+// them.
+func (cfg *API) sanctionTarget(res http.ResponseWriter, req *http.Request, claims auth.TokenClaims) (database.User, bool) {
+	userID, ok := httpx.ParseUUID(res, req, "userID", req.PathValue("userID"))
+	if !ok {
+		return database.User{}, false
+	}
+	user, err := cfg.store.QueryUserByID(req.Context(), userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		httpx.RespondError(res, req, http.StatusNotFound, httpx.CodeNotFound, "user not found")
+		return user, false
+	}
+	if err != nil {
+		slog.ErrorContext(req.Context(), "cannot query user", "user_id", userID, "err", err)
+		httpx.RespondError(res, req, http.StatusInternalServerError, httpx.CodeInternal, "")
+		return user, false
+	}
+	if refusal := sanctionRefusal(user, claims); refusal != "" {
+		httpx.RespondError(res, req, http.StatusForbidden, httpx.CodeForbidden, refusal)
+		return user, false
+	}
+	return user, true
+}
+
+// sanctionUser suspends, bans or shadow-bans a user.
+func (cfg *API) sanctionUser(res http.ResponseWriter, req *http.Request) {
+	type parameters struct {
+		Status string     `json:"status" validate:"required,oneof=suspended banned shadow_banned"`
+		Until  *time.Time `json:"suspended_until"`
+		Reason string     `json:"reason" validate:"required,max=500"`
+	}
+
+	claims := requestClaims(req)
+	params := parameters{}
+	if !httpx.DecodeJSON(res, req, &params) {
+		return
+	}
+	//solo una sospensione ha una fine, e deve essere nel futuro
+	var until sql.NullTime
+	if params.Status == statusSuspended {
+		if params.Until == nil || !params.Until.After(time.Now()) {
+			httpx.RespondInvalid(res, req, http.StatusUnprocessableEntity, httpx.CodeValidation, "the request has invalid fields", validate.Errors{{
+				Field:   "suspended_until",
+				Rule:    "future",
+				Message: "a suspension must end in the future",
+			}})
+			return
+		}
+		until = sql.NullTime{Time: params.Until.UTC(), Valid: true}
+	} else if params.Until != nil {
+		httpx.RespondInvalid(res, req, http.StatusUnprocessableEntity, httpx.CodeValidation, "the request has invalid fields", validate.Errors{{
+			Field:   "suspended_until",
+			Rule:    "excluded",
+			Message: "only suspensions have an end",
+		}})
+		return
+	}
+
+	user, ok := cfg.sanctionTarget(res, req, claims)
+	if !ok {
+		return
+	}
+	var sanction database.Sanction
+	actor := cfg.auditActorOf(req)
+	err := cfg.auditTx(req.Context(), func(q database.Querier) error {
+		var err error
+		sanction, err = applySanction(req.Context(), q, user.ID, uuid.NullUUID{UUID: claims.UserID, Valid: true},
+			params.Status, until, params.Reason)
+		if err != nil {
+			return err
+		}
+		return appendAudit(req.Context(), q, actor, auditRecord{
+			action:     "user.sanction",
+			targetType: "user",
+			targetID:   user.ID.String(),
+			before:     statusSnapshot(user),
+			after:      outputSanction(sanction),
+		})
+	})
+	if err != nil {
+		respondStoreError(res, req, err, "sanction failed", "user_id", user.ID)
+		return
+	}
+	slog.InfoContext(req.Context(), "user sanctioned", "user_id", user.ID, "status", sanction.Status, "by", claims.UserID)
+
+	httpx.RespondJSON(res, req, 201, outputSanction(sanction))
+}
+
+// liftSanction brings a sanctioned user back to active.
+func (cfg *API) liftSanction(res http.ResponseWriter, req *http.Request) {
+	type parameters struct {
+		Reason string `json:"reason" validate:"required,max=500"`
+	}
+
+	claims := requestClaims(req)
+	params := parameters{}
+	if !httpx.DecodeJSON(res, req, &params) {
+		return
+	}
+	user, ok := cfg.sanctionTarget(res, req, claims)
+	if !ok {
+		return
+	}
+	if !sanctioned(user, time.Now()) {
+		httpx.RespondError(res, req, http.StatusConflict, httpx.CodeConflict, "the user is not sanctioned")
+		return
+	}
+
+	var sanction database.Sanction
+	actor := cfg.auditActorOf(req)
+	err := cfg.auditTx(req.Context(), func(q database.Querier) error {
+		var err error
+		sanction, err = applySanction(req.Context(), q, user.ID, uuid.NullUUID{UUID: claims.UserID, Valid: true},
+			statusActive, sql.NullTime{}, params.Reason)
+		if err != nil {
+			return err
+		}
+		return appendAudit(req.Context(), q, actor, auditRecord{
+			action:     "user.sanction_lift",
+			targetType: "user",
+			targetID:   user.ID.String(),
+			before:     statusSnapshot(user),
+			after:      outputSanction(sanction),
+		})
+	})
+	if err != nil {
+		respondStoreError(res, req, err, "lifting sanction failed", "user_id", user.ID)
+		return
+	}
+	slog.InfoContext(req.Context(), "sanction lifted", "user_id", user.ID, "previous_status", user.Status, "by", claims.UserID)
+
+	httpx.RespondJSON(res, req, 200, outputSanction(sanction))
+}
+
+// userSanctions lists the sanctions of a user and their lifting, oldest
+// first.
+func (cfg *API) userSanctions(res http.ResponseWriter, req *http.Request) {
+	userID, ok := httpx.ParseUUID(res, req, "userID", req.PathValue("userID"))
+	if !ok {
+		return
+	}
+	if _, err := cfg.store.QueryUserByID(req.Context(), userID); err != nil {
+		httpx.RespondError(res, req, http.StatusNotFound, httpx.CodeNotFound, "user not found")
+		return
+	}
+	sanctions, err := cfg.store.QueryUserSanctions(req.Context(), userID)
+	if err != nil {
+		slog.ErrorContext(req.Context(), "cannot query sanctions", "user_id", userID, "err", err)
+		httpx.RespondError(res, req, http.StatusInternalServerError, httpx.CodeInternal, "")
+		return
+	}
+
+	out := []Sanction{}
+	for _, s := range sanctions {
+		out = append(out, outputSanction(s))
+	}
+	httpx.RespondJSON(res, req, 200, out)
+}
+
+// viewerID is the user calling a public endpoint, or the nil UUID for
+// anonymous callers; an invalid token counts as anonymous.
+func (cfg *API) viewerID(req *http.Request) uuid.UUID {
+	token, err := auth.GetBearerToken(req.Header)
+	if err != nil || token == "" {
+		return uuid.Nil
+	}
+	claims, err := auth.ParseJWT(token, cfg.secretToken)
+	if err != nil {
+		return uuid.Nil
+	}
+	return claims.UserID
+}
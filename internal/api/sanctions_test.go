@@ -0,0 +1,163 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"Chirpy/internal/auth"
+	"Chirpy/internal/database"
+	"Chirpy/internal/httpx"
+	"github.com/google/uuid"
+)
+
+func TestSanctionUser(t *testing.T) {
+	s := newTestServer(t)
+	walt := s.signup("walt@breakingbad.com", "04234")
+	moderator := s.signupAs("mod@chirpy.test", auth.RoleModerator)
+	other := s.signupAs("mod2@chirpy.test", auth.RoleModerator)
+	admin := s.signupAs("admin@chirpy.test", auth.RoleAdmin)
+	path := func(u User) string { return "/admin/users/" + u.ID.String() + "/sanctions" }
+	until := time.Now().Add(24 * time.Hour).UTC().Format(time.RFC3339)
+
+	tests := []struct {
+		name       string
+		path       string
+		token      string
+		body       any
+		wantStatus int
+		wantCode   string
+	}{
+		{"anonymous", path(walt), "", map[string]string{"status": "banned", "reason": "spam"}, 401, httpx.CodeUnauthorized},
+		{"user", path(walt), walt.Token, map[string]string{"status": "banned", "reason": "spam"}, 403, httpx.CodeForbidden},
+		{"self", path(moderator), moderator.Token, map[string]string{"status": "banned", "reason": "spam"}, 403, httpx.CodeForbidden},
+		{"staff", path(other), moderator.Token, map[string]string{"status": "banned", "reason": "spam"}, 403, httpx.CodeForbidden},
+		{"unknown user", "/admin/users/" + uuid.NewString() + "/sanctions", moderator.Token, map[string]string{"status": "banned", "reason": "spam"}, 404, httpx.CodeNotFound},
+		{"unknown status", path(walt), moderator.Token, map[string]string{"status": "exiled", "reason": "spam"}, 422, httpx.CodeValidation},
+		{"no reason", path(walt), moderator.Token, map[string]string{"status": "banned"}, 422, httpx.CodeValidation},
+		{"endless suspension", path(walt), moderator.Token, map[string]string{"status": "suspended", "reason": "spam"}, 422, httpx.CodeValidation},
+		{"past suspension", path(walt), moderator.Token, map[string]string{"status": "suspended", "reason": "spam", "suspended_until": "2020-01-01T00:00:00Z"}, 422, httpx.CodeValidation},
+		{"ban with an end", path(walt), moderator.Token, map[string]string{"status": "banned", "reason": "spam", "suspended_until": until}, 422, httpx.CodeValidation},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := s.do(request{method: "POST", path: tt.path, token: tt.token, body: tt.body})
+			expectProblem(t, rec, tt.wantStatus, tt.wantCode)
+		})
+	}
+
+	//un admin può sanzionare un moderatore
+	expect(t, s.do(request{method: "POST", path: path(other), token: admin.Token,
+		body: map[string]string{"status": "shadow_banned", "reason": "spam"}}), 201, nil)
+
+	var sanction Sanction
+	expect(t, s.do(request{method: "POST", path: path(walt), token: moderator.Token,
+		body: map[string]string{"status": "suspended", "reason": "threats", "suspended_until": until}}), 201, &sanction)
+	if sanction.Status != statusSuspended || sanction.SuspendedUntil == nil || sanction.ActorID == nil || *sanction.ActorID != moderator.ID {
+		t.Errorf("sanction = %+v", sanction)
+	}
+
+	//le sessioni aperte vengono chiuse e il token d'accesso non basta per scrivere
+	expectProblem(t, s.do(request{method: "POST", path: "/api/v1/login", body: map[string]string{
+		"email": "walt@breakingbad.com", "password": "04234",
+	}}), 403, httpx.CodeAccountSuspended)
+	expectProblem(t, s.do(request{method: "POST", path: "/api/v1/refresh", token: walt.RefreshToken}), 401, httpx.CodeUnauthorized)
+	expectProblem(t, s.do(request{method: "POST", path: "/api/v1/chirps", token: walt.Token,
+		body: map[string]string{"body": "say my name"}}), 403, httpx.CodeAccountSuspended)
+	for _, r := range []request{
+		{method: "PATCH", path: "/api/v1/users", token: walt.Token, body: map[string]string{}},
+		{method: "DELETE", path: "/api/v1/users", token: walt.Token, body: map[string]string{"password": "04234"}},
+		{method: "POST", path: "/api/v1/users/export", token: walt.Token},
+		{method: "GET", path: "/api/v1/users/export", token: walt.Token},
+	} {
+		expectProblem(t, s.do(r), 403, httpx.CodeAccountSuspended)
+	}
+
+	lift := func(u User) request {
+		return request{method: "DELETE", path: path(u), token: moderator.Token, body: map[string]string{"reason": "appeal"}}
+	}
+	expectProblem(t, s.do(request{method: "DELETE", path: path(walt), token: moderator.Token, body: map[string]string{}}), 422, httpx.CodeValidation)
+	var lifting Sanction
+	expect(t, s.do(lift(walt)), 200, &lifting)
+	if lifting.Status != statusActive || lifting.SuspendedUntil != nil {
+		t.Errorf("lifting = %+v", lifting)
+	}
+	expectProblem(t, s.do(lift(walt)), 409, httpx.CodeConflict)
+	walt = s.login("walt@breakingbad.com", "04234")
+	s.chirp(walt, "say my name")
+
+	expect(t, s.do(request{method: "POST", path: path(walt), token: moderator.Token,
+		body: map[string]string{"status": "banned", "reason": "again"}}), 201, nil)
+	expectProblem(t, s.do(request{method: "POST", path: "/api/v1/login", body: map[string]string{
+		"email": "walt@breakingbad.com", "password": "04234",
+	}}), 403, httpx.CodeAccountBanned)
+
+	var history []Sanction
+	expectProblem(t, s.do(request{method: "GET", path: path(walt), token: walt.Token}), 403, httpx.CodeAccountBanned)
+	expect(t, s.do(request{method: "GET", path: path(walt), token: moderator.Token}), 200, &history)
+	if len(history) != 3 || history[0].Status != statusSuspended || history[1].Status != statusActive || history[2].Reason != "again" {
+		t.Errorf("history = %+v", history)
+	}
+}
+
+func TestSuspensionEnds(t *testing.T) {
+	s := newTestServer(t)
+	walt := s.signup("walt@breakingbad.com", "04234")
+	_, err := s.store.SetUserStatus(context.Background(), database.SetUserStatusParams{
+		ID:             walt.ID,
+		Status:         statusSuspended,
+		SuspendedUntil: sql.NullTime{Time: time.Now().Add(-time.Minute), Valid: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	walt = s.login("walt@breakingbad.com", "04234")
+	expect(t, s.do(request{method: "POST", path: "/api/v1/refresh", token: walt.RefreshToken}), 200, nil)
+	s.chirp(walt, "say my name")
+}
+
+func TestShadowBan(t *testing.T) {
+	s := newTestServer(t)
+	walt := s.signup("walt@breakingbad.com", "04234")
+	jesse := s.signup("jesse@breakingbad.com", "password")
+	moderator := s.signupAs("mod@chirpy.test", auth.RoleModerator)
+	expect(t, s.do(request{method: "POST", path: "/admin/users/" + walt.ID.String() + "/sanctions", token: moderator.Token,
+		body: map[string]string{"status": "shadow_banned", "reason": "spam"}}), 201, nil)
+
+	//nessun segnale per l'utente: il login e i chirp funzionano
+	walt = s.login("walt@breakingbad.com", "04234")
+	chirpPath := "/api/v1/chirps/" + s.chirp(walt, "say my name").ID.String()
+	s.chirp(jesse, "yeah science")
+
+	byWalt := "/api/v1/chirps?author_id=" + walt.ID.String()
+	tests := []struct {
+		name  string
+		path  string
+		token string
+		want  int
+	}{
+		{"anonymous", "/api/v1/chirps", "", 1},
+		{"other user", "/api/v1/chirps", jesse.Token, 1},
+		{"invalid token", "/api/v1/chirps", "garbage", 1},
+		{"author", "/api/v1/chirps", walt.Token, 2},
+		{"anonymous by author", byWalt, "", 0},
+		{"author by author", byWalt, walt.Token, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var chirps []Chirp
+			expect(t, s.do(request{method: "GET", path: tt.path, token: tt.token}), 200, &chirps)
+			if len(chirps) != tt.want {
+				t.Errorf("got %d chirps, want %d", len(chirps), tt.want)
+			}
+		})
+	}
+
+	//il chirp non si trova neanche per id, ma i moderatori possono cancellarlo
+	expectProblem(t, s.do(request{method: "GET", path: chirpPath}), 404, httpx.CodeNotFound)
+	expectProblem(t, s.do(request{method: "GET", path: chirpPath, token: jesse.Token}), 404, httpx.CodeNotFound)
+	expect(t, s.do(request{method: "GET", path: chirpPath, token: walt.Token}), 200, nil)
+	expect(t, s.do(request{method: "DELETE", path: chirpPath, token: moderator.Token}), 204, nil)
+}
@@ -0,0 +1,171 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"Chirpy/internal/database"
+	"Chirpy/internal/entitlements"
+	"Chirpy/internal/httpx"
+	"Chirpy/internal/spam"
+)
+
+// rateWindow is the period the chirps per minute of a plan are counted on.
+const rateWindow = time.Minute
+
+// chirpRate is how many chirps author may post per rateWindow: the limit
+// of their plan, lowered for free accounts younger than NewAccountAge.
+func (cfg *API) chirpRate(author database.User, plan entitlements.Plan, now time.Time) int {
+	rate := entitlements.For(plan).ChirpsPerMinute
+	if plan == entitlements.PlanFree && now.Sub(author.CreatedAt) < cfg.config.Spam.NewAccountAge {
+		rate = min(rate, cfg.config.Spam.NewAccountChirpsPerMinute)
+	}
+	return rate
+}
+
+var (
+	errRateLimited    = errors.New("chirp rate limited")
+	errDuplicateChirp = errors.New("duplicate chirp")
+)
+
+// checkFlood returns errRateLimited when author has used up the rate chirps
+// of the current window and errDuplicateChirp when they already posted the
+// same body, up to case, spacing and punctuation, within the duplicate
+// window. It runs in the transaction that creates the chirp, after the row
+// of author was locked, so that concurrent chirps of the same author are
+// counted one after the other.
+func (cfg *API) checkFlood(ctx context.Context, q database.Querier, author database.User, rate int, hash string) error {
+	now := time.Now()
+	recent, err := q.CountUserChirpsSince(ctx, database.CountUserChirpsSinceParams{
+		UserID:    author.ID,
+		CreatedAt: now.Add(-rateWindow),
+	})
+	if err != nil {
+		return err
+	}
+	if recent >= int64(rate) {
+		return errRateLimited
+	}
+
+	if cfg.config.Spam.DuplicateWindow == 0 {
+		return nil
+	}
+	duplicates, err := q.CountDuplicateChirps(ctx, database.CountDuplicateChirpsParams{
+		UserID:    author.ID,
+		BodyHash:  hash,
+		CreatedAt: now.Add(-cfg.config.Spam.DuplicateWindow),
+	})
+	if err != nil {
+		return err
+	}
+	if duplicates > 0 {
+		return errDuplicateChirp
+	}
+	return nil
+}
+
+// respondFlood answers 429 or 409 when err is a refusal of checkFlood and
+// reports whether it was.
+func (cfg *API) respondFlood(res http.ResponseWriter, req *http.Request, author database.User, rate int, err error) bool {
+	switch {
+	case errors.Is(err, errRateLimited):
+		cfg.metrics.chirpsFiltered.WithLabelValues("rate_limited").Inc()
+		slog.InfoContext(req.Context(), "chirp rate limited", "user_id", author.ID, "rate", rate)
+		res.Header().Set("Retry-After", strconv.Itoa(int(rateWindow.Seconds())))
+		httpx.RespondError(res, req, http.StatusTooManyRequests, httpx.CodeRateLimited,
+			"you can post "+strconv.Itoa(rate)+" chirps a minute")
+		return true
+	case errors.Is(err, errDuplicateChirp):
+		cfg.metrics.chirpsFiltered.WithLabelValues("duplicate").Inc()
+		slog.InfoContext(req.Context(), "duplicate chirp refused", "user_id", author.ID)
+		httpx.RespondError(res, req, http.StatusConflict, httpx.CodeDuplicateChirp, "you already posted this chirp")
+		return true
+	}
+	return false
+}
+
+// classifyChirp asks the classifier about a new chirp. It answers 422 and
+// returns false when the chirp is rejected; otherwise it returns the reason
+// to hold the chirp for, or an invalid one to publish it.
+func (cfg *API) classifyChirp(res http.ResponseWriter, req *http.Request, author database.User, plan entitlements.Plan, body string) (sql.NullString, bool) {
+	decision, err := cfg.classifier.Classify(req.Context(), spam.Submission{
+		UserID:     author.ID,
+		Body:       body,
+		AccountAge: time.Since(author.CreatedAt),
+		ChirpyRed:  plan == entitlements.PlanChirpyRed,
+	})
+	if err != nil {
+		slog.WarnContext(req.Context(), "spam classifier failed", "user_id", author.ID, "err", err)
+	}
+	switch decision.Verdict {
+	case spam.Reject:
+		cfg.metrics.chirpsFiltered.WithLabelValues("rejected").Inc()
+		slog.InfoContext(req.Context(), "chirp rejected", "user_id", author.ID, "reason", decision.Reason)
+		httpx.RespondError(res, req, http.StatusUnprocessableEntity, httpx.CodeChirpRejected, "this chirp looks like spam: "+decision.Reason)
+		return sql.NullString{}, false
+	case spam.Hold:
+		return sql.NullString{String: decision.Reason, Valid: true}, true
+	}
+	return sql.NullString{}, true
+}
+
+// listHeldChirps lists the chirps waiting for a moderator, oldest first.
+// Moderators publish them with releaseChirp or delete them.
+func (cfg *API) listHeldChirps(res http.ResponseWriter, req *http.Request) {
+	chirps, err := cfg.store.QueryHeldChirps(req.Context())
+	if err != nil {
+		slog.ErrorContext(req.Context(), "cannot query held chirps", "err", err)
+		httpx.RespondError(res, req, http.StatusInternalServerError, httpx.CodeInternal, "")
+		return
+	}
+	out := []Chirp{}
+	for _, c := range chirps {
+		out = append(out, outputChirp(c))
+	}
+	httpx.RespondJSON(res, req, 200, out)
+}
+
+// releaseChirp publishes a held chirp.
+func (cfg *API) releaseChirp(res http.ResponseWriter, req *http.Request) {
+	claims := requestClaims(req)
+	chirpID, ok := httpx.ParseUUID(res, req, "chirpID", req.PathValue("chirpID"))
+	if !ok {
+		return
+	}
+	var chirp database.Chirp
+	actor := cfg.auditActorOf(req)
+	err := cfg.auditTx(req.Context(), func(q database.Querier) error {
+		held, err := q.QueryChirpIncludingDeleted(req.Context(), chirpID)
+		if err != nil {
+			return err
+		}
+		chirp, err = q.ReleaseChirp(req.Context(), chirpID)
+		if err != nil {
+			return err
+		}
+		return appendAudit(req.Context(), q, actor, auditRecord{
+			action:     "chirp.release",
+			targetType: "chirp",
+			targetID:   chirpID.String(),
+			before:     outputChirp(held),
+			after:      outputChirp(chirp),
+		})
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		httpx.RespondError(res, req, http.StatusNotFound, httpx.CodeNotFound, "no held chirp with this id")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(req.Context(), "cannot release chirp", "chirp_id", chirpID, "err", err)
+		httpx.RespondError(res, req, http.StatusInternalServerError, httpx.CodeInternal, "")
+		return
+	}
+	slog.InfoContext(req.Context(), "held chirp released", "chirp_id", chirp.ID, "user_id", chirp.UserID, "by", claims.UserID)
+
+	httpx.RespondJSON(res, req, 200, outputChirp(chirp))
+}
@@ -0,0 +1,145 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"Chirpy/internal/auth"
+	"Chirpy/internal/httpx"
+	"Chirpy/internal/spam"
+	"Chirpy/internal/store"
+)
+
+func postChirp(user User, body string) request {
+	return request{method: "POST", path: "/api/v1/chirps", token: user.Token, body: map[string]string{"body": body}}
+}
+
+func TestChirpRateLimit(t *testing.T) {
+	s := newTestServer(t)
+	walt := s.signup("walt@breakingbad.com", "04234")
+	gus := s.signup("gus@lospollos.com", "password")
+	s.polka(t, "evt_red", "user.upgraded", gus.ID.String(), 204)
+	gus = s.login("gus@lospollos.com", "password")
+
+	//un account nuovo e gratuito pubblica al massimo 3 chirp al minuto
+	for i := range 3 {
+		s.chirp(walt, "chirp "+string(rune('a'+i)))
+	}
+	rec := s.do(postChirp(walt, "one more"))
+	expectProblem(t, rec, 429, httpx.CodeRateLimited)
+	if got := rec.Header().Get("Retry-After"); got != "60" {
+		t.Errorf("Retry-After = %q, want 60", got)
+	}
+
+	for i := range 5 {
+		s.chirp(gus, "chicken "+string(rune('a'+i)))
+	}
+}
+
+func TestChirpRateLimitOfPlan(t *testing.T) {
+	conf := testConfig()
+	conf.Spam.NewAccountAge = 0
+	s := newTestServerWith(t, conf, store.NewMemory())
+	walt := s.signup("walt@breakingbad.com", "04234")
+
+	for i := range 10 {
+		s.chirp(walt, "chirp "+string(rune('a'+i)))
+	}
+	expectProblem(t, s.do(postChirp(walt, "one more")), 429, httpx.CodeRateLimited)
+}
+
+func TestDuplicateChirp(t *testing.T) {
+	s := newTestServer(t)
+	walt := s.signup("walt@breakingbad.com", "04234")
+	jesse := s.signup("jesse@breakingbad.com", "password")
+
+	chirp := s.chirp(walt, "Say my name")
+	expectProblem(t, s.do(postChirp(walt, "  say my NAME!")), 409, httpx.CodeDuplicateChirp)
+	s.chirp(jesse, "say my name")
+
+	//un chirp cancellato conta ancora
+	expect(t, s.do(request{method: "DELETE", path: "/api/v1/chirps/" + chirp.ID.String(), token: walt.Token}), 204, nil)
+	expectProblem(t, s.do(postChirp(walt, "say my name")), 409, httpx.CodeDuplicateChirp)
+
+	conf := testConfig()
+	conf.Spam.DuplicateWindow = 0
+	s = newTestServerWith(t, conf, store.NewMemory())
+	walt = s.signup("walt@breakingbad.com", "04234")
+	s.chirp(walt, "say my name")
+	s.chirp(walt, "say my name")
+}
+
+func TestHeldChirps(t *testing.T) {
+	s := newTestServer(t)
+	walt := s.signup("walt@breakingbad.com", "04234")
+	jesse := s.signup("jesse@breakingbad.com", "password")
+	moderator := s.signupAs("mod@chirpy.test", auth.RoleModerator)
+
+	expectProblem(t, s.do(postChirp(walt, strings.Repeat("http://blue.sky ", 8))), 422, httpx.CodeChirpRejected)
+
+	var held Chirp
+	expect(t, s.do(postChirp(walt, "buy http://a.io http://b.io http://c.io")), 202, &held)
+	if held.HeldReason != "3 links" {
+		t.Errorf("held = %+v", held)
+	}
+	heldPath := "/api/v1/chirps/" + held.ID.String()
+
+	count := func(token string) int {
+		t.Helper()
+		var chirps []Chirp
+		expect(t, s.do(request{method: "GET", path: "/api/v1/chirps", token: token}), 200, &chirps)
+		return len(chirps)
+	}
+	if n := count(walt.Token); n != 0 {
+		t.Errorf("the author lists %d chirps, want 0", n)
+	}
+	expectProblem(t, s.do(request{method: "GET", path: heldPath, token: jesse.Token}), 404, httpx.CodeNotFound)
+	expect(t, s.do(request{method: "GET", path: heldPath, token: walt.Token}), 200, nil)
+
+	var queue []Chirp
+	expectProblem(t, s.do(request{method: "GET", path: "/admin/chirps/held", token: walt.Token}), 403, httpx.CodeForbidden)
+	expect(t, s.do(request{method: "GET", path: "/admin/chirps/held", token: moderator.Token}), 200, &queue)
+	if len(queue) != 1 || queue[0].ID != held.ID {
+		t.Fatalf("held chirps = %+v", queue)
+	}
+
+	release := request{method: "POST", path: "/admin/chirps/" + held.ID.String() + "/release", token: moderator.Token}
+	expectProblem(t, s.do(request{method: release.method, path: release.path, token: walt.Token}), 403, httpx.CodeForbidden)
+	var released Chirp
+	expect(t, s.do(release), 200, &released)
+	if released.HeldReason != "" {
+		t.Errorf("released = %+v", released)
+	}
+	expectProblem(t, s.do(release), 404, httpx.CodeNotFound)
+	if n := count(""); n != 1 {
+		t.Errorf("%d chirps listed after the release, want 1", n)
+	}
+	expect(t, s.do(request{method: "GET", path: "/admin/chirps/held", token: moderator.Token}), 200, &queue)
+	if len(queue) != 0 {
+		t.Errorf("held chirps after the release = %+v", queue)
+	}
+}
+
+func TestChirpClassifier(t *testing.T) {
+	s := newTestServer(t)
+	walt := s.signup("walt@breakingbad.com", "04234")
+
+	var got spam.Submission
+	s.api.classifier = spam.ClassifierFunc(func(ctx context.Context, sub spam.Submission) (spam.Decision, error) {
+		got = sub
+		if strings.Contains(sub.Body, "crystal") {
+			return spam.Decision{Verdict: spam.Hold, Reason: "drugs"}, nil
+		}
+		return spam.Decision{}, errors.New("classifier down")
+	})
+
+	var held Chirp
+	expect(t, s.do(postChirp(walt, "blue crystal")), 202, &held)
+	if held.HeldReason != "drugs" || got.UserID != walt.ID || got.Body != "blue crystal" || got.ChirpyRed {
+		t.Errorf("held = %+v, submission = %+v", held, got)
+	}
+	//un classificatore che non risponde non blocca nessuno
+	s.chirp(walt, "say my name")
+}
@@ -0,0 +1,109 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"time"
+
+	"Chirpy/internal/database"
+	"Chirpy/internal/entitlements"
+	"github.com/google/uuid"
+)
+
+// subscriptionPeriod is the length of a Chirpy Red billing period when
+// Polka does not tell us when the period ends.
+const subscriptionPeriod = 30 * 24 * time.Hour
+
+// userPlan returns the plan of the active subscription of userID, or the
+// free plan when they have none.
+func userPlan(ctx context.Context, q database.Querier, userID uuid.UUID) (entitlements.Plan, error) {
+	sub, err := q.QueryActiveSubscription(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return entitlements.PlanFor(""), nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return entitlements.PlanFor(sub.Plan), nil
+}
+
+// startSubscription makes userID a Chirpy Red member, opening a new billing
+// period unless they already have an active subscription.
+func startSubscription(ctx context.Context, q database.Querier, userID uuid.UUID, periodEnd time.Time) error {
+	_, err := q.UserPro(ctx, userID)
+	if err != nil {
+		return err
+	}
+	_, err = q.QueryActiveSubscription(ctx, userID)
+	if err == nil || !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	now := time.Now()
+	if periodEnd.IsZero() {
+		periodEnd = now.Add(subscriptionPeriod)
+	}
+	_, err = q.CreateSubscription(ctx, database.CreateSubscriptionParams{
+		UserID:             userID,
+		Plan:               string(entitlements.PlanChirpyRed),
+		CurrentPeriodStart: now,
+		CurrentPeriodEnd:   periodEnd,
+	})
+	return err
+}
+
+// renewSubscription extends the active subscription of userID to periodEnd,
+// or by one period when periodEnd is zero.
+func renewSubscription(ctx context.Context, q database.Querier, userID uuid.UUID, periodEnd time.Time) error {
+	sub, err := q.QueryActiveSubscription(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return startSubscription(ctx, q, userID, periodEnd)
+	}
+	if err != nil {
+		return err
+	}
+
+	if periodEnd.IsZero() {
+		from := sub.CurrentPeriodEnd
+		if from.Before(time.Now()) {
+			from = time.Now()
+		}
+		periodEnd = from.Add(subscriptionPeriod)
+	}
+	if !periodEnd.After(sub.CurrentPeriodEnd) {
+		return nil
+	}
+	_, err = q.ExtendSubscription(ctx, database.ExtendSubscriptionParams{
+		ID:               sub.ID,
+		CurrentPeriodEnd: periodEnd,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = q.UserPro(ctx, userID)
+	return err
+}
+
+// cancelSubscription ends the Chirpy Red membership of userID right away.
+func cancelSubscription(ctx context.Context, q database.Querier, userID uuid.UUID) error {
+	_, err := q.UserFree(ctx, userID)
+	if err != nil {
+		return err
+	}
+	return q.CancelSubscription(ctx, userID)
+}
+
+// expireSubscriptions ends the memberships whose billing period is over
+// without having been renewed.
+func (cfg *API) expireSubscriptions(ctx context.Context) {
+	expired, err := cfg.store.ExpireSubscriptions(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "expiry of subscriptions failed", "err", err)
+		return
+	}
+	for _, userID := range expired {
+		slog.InfoContext(ctx, "Chirpy Red membership expired", "user_id", userID)
+	}
+}
@@ -0,0 +1,410 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"Chirpy/internal/auth"
+	"Chirpy/internal/database"
+	"Chirpy/internal/entitlements"
+	"Chirpy/internal/httpx"
+	"github.com/google/uuid"
+)
+
+type User struct {
+	ID            uuid.UUID `json:"id"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+	Email         string    `json:"email"`
+	Token         string    `json:"token"`
+	RefreshToken  string    `json:"refresh_token"`
+	Is_chirpy_red bool      `json:"is_chirpy_red"`
+	Role          string    `json:"role"`
+	PendingEmail  string    `json:"pending_email,omitempty"`
+}
+
+// tokenClaims returns what the access tokens issued to user on plan assert
+// about them.
+func tokenClaims(user database.User, plan entitlements.Plan) auth.TokenClaims {
+	return auth.TokenClaims{
+		UserID:    user.ID,
+		Role:      user.Role,
+		ChirpyRed: plan == entitlements.PlanChirpyRed,
+	}
+}
+
+func (cfg *API) userCreator(res http.ResponseWriter, req *http.Request) {
+	type parameters struct {
+		Password string `json:"password" validate:"required,maxbytes=72"`
+		Email    string `json:"email" validate:"required,email,max=254"`
+	}
+
+	params := parameters{}
+	if !httpx.DecodeJSON(res, req, &params) {
+		return
+	}
+
+	//crea l'utenza
+	hashed, err := cfg.hashPassword(req.Context(), params.Password)
+	if err != nil {
+		slog.ErrorContext(req.Context(), "cannot hash password", "err", err)
+		httpx.RespondError(res, req, http.StatusInternalServerError, httpx.CodeInternal, "")
+		return
+	}
+	userParam := database.CreateUserParams{
+		Email:          params.Email,
+		HashedPassword: hashed,
+	}
+	user, err := cfg.store.CreateUser(req.Context(), userParam)
+	if err != nil {
+		respondStoreError(res, req, err, "user creation failed")
+		return
+	}
+	slog.InfoContext(req.Context(), "user created", "user_id", user.ID)
+	outputUser := User{
+		ID:            user.ID,
+		CreatedAt:     user.CreatedAt,
+		UpdatedAt:     user.UpdatedAt,
+		Email:         user.Email,
+		Is_chirpy_red: user.IsChirpyRed,
+		Role:          user.Role,
+	}
+
+	httpx.RespondJSON(res, req, 201, outputUser)
+}
+
+func (cfg *API) userLogin(res http.ResponseWriter, req *http.Request) {
+	type parameters struct {
+		Password string `json:"password" validate:"required"`
+		Email    string `json:"email" validate:"required"`
+		//ExpSec int `json:"expires_in_seconds"`
+	}
+
+	params := parameters{}
+	if !httpx.DecodeJSON(res, req, &params) {
+		return
+	}
+
+	var user database.User
+
+	user, err := cfg.store.QueryUser(req.Context(), params.Email)
+	if err != nil {
+		slog.InfoContext(req.Context(), "login failed: unknown email", "err", err)
+		cfg.metrics.loginsFailed.WithLabelValues("unknown_email").Inc()
+		httpx.RespondError(res, req, http.StatusUnauthorized, httpx.CodeInvalidCredentials, "incorrect email or password")
+		return
+	}
+	if user.Email == "" {
+		httpx.RespondError(res, req, http.StatusUnauthorized, httpx.CodeInvalidCredentials, "incorrect email or password")
+		slog.InfoContext(req.Context(), "login failed: unknown email")
+		cfg.metrics.loginsFailed.WithLabelValues("unknown_email").Inc()
+		return
+	}
+
+	err = cfg.checkPassword(req.Context(), user.HashedPassword, params.Password)
+	if err != nil {
+		httpx.RespondError(res, req, http.StatusUnauthorized, httpx.CodeInvalidCredentials, "incorrect email or password")
+		slog.InfoContext(req.Context(), "login failed: wrong password", "user_id", user.ID)
+		cfg.metrics.loginsFailed.WithLabelValues("wrong_password").Inc()
+		return
+	}
+	if respondRestricted(res, req, user) {
+		cfg.metrics.loginsFailed.WithLabelValues("sanctioned").Inc()
+		return
+	}
+
+	//generate Refresh Token
+	refreshToken, err := auth.MakeRefreshToken()
+	if err != nil {
+		httpx.RespondError(res, req, http.StatusInternalServerError, httpx.CodeInternal, "")
+		slog.ErrorContext(req.Context(), "cannot generate refresh token", "err", err)
+		return
+	}
+
+	//un login durante il periodo di grazia annulla la cancellazione,
+	//ma solo se la sessione viene davvero creata
+	restoring := user.DeletedAt.Valid
+	var refreshTokenCreated database.RefreshToken
+	err = cfg.store.WithTx(req.Context(), func(q database.Querier) error {
+		if restoring {
+			restored, err := q.RestoreUser(req.Context(), user.ID)
+			if err != nil {
+				return err
+			}
+			user = restored
+		}
+		var err error
+		refreshTokenCreated, err = q.CreateRefreshToken(req.Context(), database.CreateRefreshTokenParams{
+			Token:     refreshToken,
+			UserID:    user.ID,
+			ExpiresAt: time.Now().Add(cfg.config.Tokens.RefreshTTL),
+		})
+		return err
+	})
+	if err != nil {
+		respondStoreError(res, req, err, "cannot start session", "user_id", user.ID)
+		return
+	}
+	if restoring {
+		slog.InfoContext(req.Context(), "user deletion cancelled by login", "user_id", user.ID)
+	}
+	outputUser := User{
+		ID:            user.ID,
+		CreatedAt:     user.CreatedAt,
+		UpdatedAt:     user.UpdatedAt,
+		Email:         user.Email,
+		Is_chirpy_red: user.IsChirpyRed,
+		Role:          user.Role,
+		RefreshToken:  refreshToken,
+	}
+
+	//generate Access Token
+	plan, err := userPlan(req.Context(), cfg.store, user.ID)
+	if err != nil {
+		httpx.RespondError(res, req, http.StatusInternalServerError, httpx.CodeInternal, "")
+		slog.ErrorContext(req.Context(), "cannot query plan", "user_id", user.ID, "err", err)
+		return
+	}
+	userToken, err := auth.MakeJWT(tokenClaims(user, plan), cfg.secretToken, cfg.config.Tokens.AccessTTL)
+	if err != nil {
+		httpx.RespondError(res, req, http.StatusInternalServerError, httpx.CodeInternal, "")
+		slog.ErrorContext(req.Context(), "cannot issue access token", "user_id", user.ID, "err", err)
+		return
+	}
+	outputUser.Token = userToken
+	slog.InfoContext(req.Context(), "user logged in", "user_id", user.ID, "refresh_expires_at", refreshTokenCreated.ExpiresAt)
+
+	httpx.RespondJSON(res, req, 200, outputUser)
+}
+
+func (cfg *API) refreshToken(res http.ResponseWriter, req *http.Request) {
+
+	type returnToken struct {
+		Token string `json:"token"`
+	}
+	reftoken, err := auth.GetBearerToken(req.Header)
+	if err != nil {
+		httpx.RespondError(res, req, http.StatusUnauthorized, httpx.CodeUnauthorized, "missing bearer token")
+		return
+	}
+	foundToken, err := cfg.store.QueryRefreshToken(req.Context(), reftoken)
+	if err != nil {
+		httpx.RespondError(res, req, http.StatusUnauthorized, httpx.CodeUnauthorized, "invalid refresh token")
+		slog.InfoContext(req.Context(), "refresh token not found", "err", err)
+		return
+	}
+	// Verifica se il token è stato revocato
+	if foundToken.RevokedAt.Valid {
+		httpx.RespondError(res, req, http.StatusUnauthorized, httpx.CodeUnauthorized, "refresh token revoked")
+		slog.InfoContext(req.Context(), "refresh token revoked", "user_id", foundToken.UserID, "revoked_at", foundToken.RevokedAt.Time)
+		return
+	}
+
+	// Verifica separata per la scadenza
+	if foundToken.ExpiresAt.Before(time.Now()) {
+		httpx.RespondError(res, req, http.StatusUnauthorized, httpx.CodeUnauthorized, "refresh token expired")
+		slog.InfoContext(req.Context(), "refresh token expired", "user_id", foundToken.UserID, "expired_at", foundToken.ExpiresAt)
+		return
+	}
+
+	//il ruolo potrebbe essere cambiato dall'emissione del token precedente
+	user, err := cfg.store.QueryUserByID(req.Context(), foundToken.UserID)
+	if err != nil {
+		httpx.RespondError(res, req, http.StatusUnauthorized, httpx.CodeUnauthorized, "invalid refresh token")
+		slog.WarnContext(req.Context(), "user of refresh token not found", "user_id", foundToken.UserID, "err", err)
+		return
+	}
+	if respondRestricted(res, req, user) {
+		return
+	}
+
+	//generate Access Token
+	plan, err := userPlan(req.Context(), cfg.store, user.ID)
+	if err != nil {
+		httpx.RespondError(res, req, http.StatusInternalServerError, httpx.CodeInternal, "")
+		slog.ErrorContext(req.Context(), "cannot query plan", "user_id", user.ID, "err", err)
+		return
+	}
+	userToken, err := auth.MakeJWT(tokenClaims(user, plan), cfg.secretToken, cfg.config.Tokens.AccessTTL)
+	if err != nil {
+		httpx.RespondError(res, req, http.StatusInternalServerError, httpx.CodeInternal, "")
+		slog.ErrorContext(req.Context(), "cannot issue access token", "user_id", user.ID, "err", err)
+		return
+	}
+	outputToken := returnToken{
+		Token: userToken,
+	}
+	httpx.RespondJSON(res, req, 200, outputToken)
+
+}
+
+func (cfg *API) revokeToken(res http.ResponseWriter, req *http.Request) {
+	reftoken, err := auth.GetBearerToken(req.Header)
+	if err != nil {
+		httpx.RespondError(res, req, http.StatusUnauthorized, httpx.CodeUnauthorized, "missing bearer token")
+		return
+	}
+
+	revoked, err := cfg.store.RevokeToken(req.Context(), reftoken)
+	if err != nil {
+		httpx.RespondError(res, req, http.StatusUnauthorized, httpx.CodeUnauthorized, "invalid refresh token")
+		slog.InfoContext(req.Context(), "cannot revoke refresh token", "err", err)
+		return
+	}
+	slog.InfoContext(req.Context(), "refresh token revoked", "user_id", revoked.UserID)
+	res.WriteHeader(204)
+	return
+
+}
+
+// modifyUser applies a partial update to the caller: only the fields present
+// in the body change. Changing the password or the email requires the current
+// password, and a new email only replaces the old one once it is confirmed.
+func (cfg *API) modifyUser(res http.ResponseWriter, req *http.Request) {
+	type parameters struct {
+		Email           *string `json:"email" validate:"email,max=254"`
+		Password        *string `json:"password" validate:"min=1,maxbytes=72"`
+		CurrentPassword string  `json:"current_password"`
+	}
+
+	claims, ok := cfg.authorize(res, req, auth.RoleUser)
+	if !ok {
+		return
+	}
+
+	params := parameters{}
+	if !httpx.DecodeJSON(res, req, &params) {
+		return
+	}
+
+	user, err := cfg.store.QueryUserByID(req.Context(), claims.UserID)
+	if err != nil {
+		slog.ErrorContext(req.Context(), "cannot query user", "user_id", claims.UserID, "err", err)
+		httpx.RespondError(res, req, http.StatusInternalServerError, httpx.CodeInternal, "")
+		return
+	}
+
+	if params.Password != nil || params.Email != nil {
+		//modifiche sensibili: serve la password attuale
+		err = cfg.checkPassword(req.Context(), user.HashedPassword, params.CurrentPassword)
+		if err != nil {
+			slog.InfoContext(req.Context(), "user update refused: wrong current password", "user_id", user.ID)
+			httpx.RespondError(res, req, http.StatusUnauthorized, httpx.CodeInvalidCredentials, "current_password is incorrect")
+			return
+		}
+	}
+
+	if params.Password != nil {
+		hashed, err := cfg.hashPassword(req.Context(), *params.Password)
+		if err != nil {
+			slog.ErrorContext(req.Context(), "cannot hash password", "err", err)
+			httpx.RespondError(res, req, http.StatusInternalServerError, httpx.CodeInternal, "")
+			return
+		}
+		//chi conosceva la vecchia password non deve restare collegato
+		err = cfg.store.WithTx(req.Context(), func(q database.Querier) error {
+			var err error
+			user, err = q.UpdateUserPassword(req.Context(), database.UpdateUserPasswordParams{
+				ID:             user.ID,
+				HashedPassword: hashed,
+			})
+			if err != nil {
+				return err
+			}
+			return q.RevokeUserTokens(req.Context(), user.ID)
+		})
+		if err != nil {
+			respondStoreError(res, req, err, "password update failed", "user_id", claims.UserID)
+			return
+		}
+		slog.InfoContext(req.Context(), "password changed, sessions revoked", "user_id", user.ID)
+	}
+
+	outputUser := User{
+		ID:            user.ID,
+		CreatedAt:     user.CreatedAt,
+		UpdatedAt:     user.UpdatedAt,
+		Email:         user.Email,
+		Is_chirpy_red: user.IsChirpyRed,
+		Role:          user.Role,
+	}
+
+	if params.Email != nil && *params.Email != user.Email {
+		err = cfg.startEmailChange(req.Context(), user, *params.Email)
+		if errors.Is(err, errNoMailer) {
+			slog.ErrorContext(req.Context(), "email change refused: SMTP_ADDR is not set", "user_id", user.ID)
+			httpx.RespondError(res, req, http.StatusServiceUnavailable, httpx.CodeUnavailable, "email changes are unavailable")
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(req.Context(), "cannot start email change", "user_id", user.ID, "err", err)
+			httpx.RespondError(res, req, http.StatusInternalServerError, httpx.CodeInternal, "")
+			return
+		}
+		outputUser.PendingEmail = *params.Email
+	}
+
+	httpx.RespondJSON(res, req, 200, outputUser)
+}
+
+func (cfg *API) setUserRole(res http.ResponseWriter, req *http.Request) {
+	type parameters struct {
+		Role string `json:"role" validate:"required,oneof=user moderator admin"`
+	}
+
+	userID, ok := httpx.ParseUUID(res, req, "userID", req.PathValue("userID"))
+	if !ok {
+		return
+	}
+
+	params := parameters{}
+	if !httpx.DecodeJSON(res, req, &params) {
+		return
+	}
+
+	var user database.User
+	actor := cfg.auditActorOf(req)
+	err := cfg.auditTx(req.Context(), func(q database.Querier) error {
+		before, err := q.QueryUserByID(req.Context(), userID)
+		if err != nil {
+			return err
+		}
+		user, err = q.SetUserRole(req.Context(), database.SetUserRoleParams{
+			ID:   userID,
+			Role: params.Role,
+		})
+		if err != nil {
+			return err
+		}
+		return appendAudit(req.Context(), q, actor, auditRecord{
+			action:     "user.role",
+			targetType: "user",
+			targetID:   userID.String(),
+			before:     map[string]string{"role": before.Role},
+			after:      map[string]string{"role": user.Role},
+		})
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		slog.WarnContext(req.Context(), "role change failed", "user_id", userID, "err", err)
+		httpx.RespondError(res, req, http.StatusNotFound, httpx.CodeNotFound, "user not found")
+		return
+	}
+	if err != nil {
+		respondStoreError(res, req, err, "role change failed", "user_id", userID)
+		return
+	}
+	slog.InfoContext(req.Context(), "role changed", "user_id", user.ID, "role", user.Role)
+	outputUser := User{
+		ID:            user.ID,
+		CreatedAt:     user.CreatedAt,
+		UpdatedAt:     user.UpdatedAt,
+		Email:         user.Email,
+		Is_chirpy_red: user.IsChirpyRed,
+		Role:          user.Role,
+	}
+
+	httpx.RespondJSON(res, req, 200, outputUser)
+}
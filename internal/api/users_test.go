@@ -0,0 +1,209 @@
+package api
+
+import (
+	"strings"
+	"testing"
+
+	"Chirpy/internal/auth"
+	"Chirpy/internal/httpx"
+	"Chirpy/internal/store"
+)
+
+func TestUserCreator(t *testing.T) {
+	s := newTestServer(t)
+
+	tests := []struct {
+		name       string
+		body       any
+		wantStatus int
+		wantCode   string
+	}{
+		{"valid", map[string]string{"email": "walt@breakingbad.com", "password": "04234"}, 201, ""},
+		{"email in use", map[string]string{"email": "walt@breakingbad.com", "password": "04234"}, 409, httpx.CodeConflict},
+		{"invalid email", map[string]string{"email": "walt", "password": "04234"}, 422, httpx.CodeValidation},
+		{"missing password", map[string]string{"email": "jesse@breakingbad.com"}, 422, httpx.CodeValidation},
+		{"password too long", map[string]string{"email": "jesse@breakingbad.com", "password": strings.Repeat("x", 73)}, 422, httpx.CodeValidation},
+		{"password too many bytes", map[string]string{"email": "jesse@breakingbad.com", "password": strings.Repeat("é", 40)}, 422, httpx.CodeValidation},
+		{"unknown field", map[string]any{"email": "jesse@breakingbad.com", "password": "x", "role": "admin"}, 400, httpx.CodeInvalidJSON},
+		{"invalid json", "{", 400, httpx.CodeInvalidJSON},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := s.do(request{method: "POST", path: "/api/v1/users", body: tt.body})
+			if tt.wantCode != "" {
+				expectProblem(t, rec, tt.wantStatus, tt.wantCode)
+				return
+			}
+			var user User
+			expect(t, rec, tt.wantStatus, &user)
+			if user.Email != "walt@breakingbad.com" || user.Role != auth.RoleUser || user.Token != "" {
+				t.Errorf("user = %+v", user)
+			}
+		})
+	}
+}
+
+func TestUserLogin(t *testing.T) {
+	s := newTestServer(t)
+	s.signup("walt@breakingbad.com", "04234")
+
+	tests := []struct {
+		name       string
+		body       any
+		wantStatus int
+		wantCode   string
+	}{
+		{"valid", map[string]string{"email": "walt@breakingbad.com", "password": "04234"}, 200, ""},
+		{"wrong password", map[string]string{"email": "walt@breakingbad.com", "password": "nope"}, 401, httpx.CodeInvalidCredentials},
+		{"unknown email", map[string]string{"email": "jesse@breakingbad.com", "password": "04234"}, 401, httpx.CodeInvalidCredentials},
+		{"missing fields", map[string]string{}, 422, httpx.CodeValidation},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := s.do(request{method: "POST", path: "/api/v1/login", body: tt.body})
+			if tt.wantCode != "" {
+				expectProblem(t, rec, tt.wantStatus, tt.wantCode)
+				return
+			}
+			var user User
+			expect(t, rec, tt.wantStatus, &user)
+			if user.Token == "" || user.RefreshToken == "" {
+				t.Errorf("login returned no tokens: %+v", user)
+			}
+			id, err := auth.ValidateJWT(user.Token, s.api.secretToken)
+			if err != nil || id != user.ID {
+				t.Errorf("access token is for %v, %v", id, err)
+			}
+		})
+	}
+}
+
+func TestRefreshAndRevoke(t *testing.T) {
+	s := newTestServer(t)
+	user := s.signup("walt@breakingbad.com", "04234")
+
+	var out struct{ Token string }
+	expect(t, s.do(request{method: "POST", path: "/api/v1/refresh", token: user.RefreshToken}), 200, &out)
+	if id, err := auth.ValidateJWT(out.Token, s.api.secretToken); err != nil || id != user.ID {
+		t.Errorf("refreshed token is for %v, %v", id, err)
+	}
+
+	expectProblem(t, s.do(request{method: "POST", path: "/api/v1/refresh"}), 401, httpx.CodeUnauthorized)
+	expectProblem(t, s.do(request{method: "POST", path: "/api/v1/refresh", token: "unknown"}), 401, httpx.CodeUnauthorized)
+
+	expect(t, s.do(request{method: "POST", path: "/api/v1/revoke", token: user.RefreshToken}), 204, nil)
+	expectProblem(t, s.do(request{method: "POST", path: "/api/v1/refresh", token: user.RefreshToken}), 401, httpx.CodeUnauthorized)
+	expectProblem(t, s.do(request{method: "POST", path: "/api/v1/revoke", token: "unknown"}), 401, httpx.CodeUnauthorized)
+	expectProblem(t, s.do(request{method: "POST", path: "/api/v1/revoke"}), 401, httpx.CodeUnauthorized)
+}
+
+func TestModifyUser(t *testing.T) {
+	s := newTestServer(t)
+	user := s.signup("walt@breakingbad.com", "04234")
+
+	tests := []struct {
+		name       string
+		method     string
+		token      string
+		body       any
+		wantStatus int
+		wantCode   string
+	}{
+		{"no token", "PUT", "", map[string]string{}, 401, httpx.CodeUnauthorized},
+		{"bad token", "PUT", "garbage", map[string]string{}, 401, httpx.CodeUnauthorized},
+		{"wrong current password", "PATCH", user.Token, map[string]string{"password": "new", "current_password": "nope"}, 401, httpx.CodeInvalidCredentials},
+		{"empty password", "PATCH", user.Token, map[string]string{"password": "", "current_password": "04234"}, 422, httpx.CodeValidation},
+		{"password too many bytes", "PATCH", user.Token, map[string]string{"password": strings.Repeat("é", 40), "current_password": "04234"}, 422, httpx.CodeValidation},
+		{"invalid email", "PATCH", user.Token, map[string]string{"email": "walt", "current_password": "04234"}, 422, httpx.CodeValidation},
+		{"nothing to change", "PATCH", user.Token, map[string]string{}, 200, ""},
+		{"new password", "PUT", user.Token, map[string]string{"password": "heisenberg", "current_password": "04234"}, 200, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := s.do(request{method: tt.method, path: "/api/v1/users", token: tt.token, body: tt.body})
+			if tt.wantCode != "" {
+				expectProblem(t, rec, tt.wantStatus, tt.wantCode)
+				return
+			}
+			var out User
+			expect(t, rec, tt.wantStatus, &out)
+			if out.ID != user.ID || out.Email != user.Email {
+				t.Errorf("user = %+v", out)
+			}
+		})
+	}
+
+	//cambiare la password chiude le altre sessioni
+	expectProblem(t, s.do(request{method: "POST", path: "/api/v1/refresh", token: user.RefreshToken}), 401, httpx.CodeUnauthorized)
+	s.login("walt@breakingbad.com", "heisenberg")
+	expectProblem(t, s.do(request{method: "POST", path: "/api/v1/login", body: map[string]string{
+		"email":    "walt@breakingbad.com",
+		"password": "04234",
+	}}), 401, httpx.CodeInvalidCredentials)
+}
+
+func TestEmailChangeWithoutMailer(t *testing.T) {
+	conf := testConfig()
+	conf.Platform = "production"
+	api := New(conf, store.NewMemory(), Deps{})
+	s := &testServer{t: t, api: api, store: api.store, handler: api.Handler()}
+	user := s.signup("walt@breakingbad.com", "04234")
+
+	//in produzione senza SMTP i token non vanno scritti su stdout
+	expectProblem(t, s.do(request{method: "PATCH", path: "/api/v1/users", token: user.Token, body: map[string]string{
+		"email":            "heisenberg@breakingbad.com",
+		"current_password": "04234",
+	}}), 503, httpx.CodeUnavailable)
+}
+
+func TestEmailChange(t *testing.T) {
+	s := newTestServer(t)
+	user := s.signup("walt@breakingbad.com", "04234")
+	s.signup("jesse@breakingbad.com", "password")
+
+	var out User
+	expect(t, s.do(request{method: "PATCH", path: "/api/v1/users", token: user.Token, body: map[string]string{
+		"email":            "heisenberg@breakingbad.com",
+		"current_password": "04234",
+	}}), 200, &out)
+	if out.Email != "walt@breakingbad.com" || out.PendingEmail != "heisenberg@breakingbad.com" {
+		t.Errorf("user = %+v", out)
+	}
+	if _, ok := s.mailer.last("walt@breakingbad.com"); !ok {
+		t.Errorf("the current address was not warned")
+	}
+	msg, ok := s.mailer.last("heisenberg@breakingbad.com")
+	if !ok {
+		t.Fatal("no confirmation was sent to the new address")
+	}
+	token := strings.TrimSpace(msg.Body[strings.LastIndex(msg.Body, "\n\n"):])
+
+	confirm := func(token string) request {
+		return request{method: "POST", path: "/api/v1/users/email/confirm", body: map[string]string{"token": token}}
+	}
+	expectProblem(t, s.do(confirm("unknown")), 404, httpx.CodeNotFound)
+	expectProblem(t, s.do(request{method: "POST", path: "/api/v1/users/email/confirm", body: map[string]string{}}), 422, httpx.CodeValidation)
+
+	expect(t, s.do(confirm(token)), 200, &out)
+	if out.Email != "heisenberg@breakingbad.com" {
+		t.Errorf("email = %q after confirmation", out.Email)
+	}
+	expectProblem(t, s.do(confirm(token)), 410, httpx.CodeGone)
+
+	//una nuova richiesta annulla il token della precedente
+	change := func(email string) string {
+		t.Helper()
+		expect(t, s.do(request{method: "PATCH", path: "/api/v1/users", token: user.Token, body: map[string]string{
+			"email":            email,
+			"current_password": "04234",
+		}}), 200, nil)
+		msg, _ := s.mailer.last(email)
+		return strings.TrimSpace(msg.Body[strings.LastIndex(msg.Body, "\n\n"):])
+	}
+	superseded := change("walter.white@breakingbad.com")
+
+	//un indirizzo già in uso non può essere confermato
+	token = change("jesse@breakingbad.com")
+	expectProblem(t, s.do(confirm(superseded)), 404, httpx.CodeNotFound)
+	expectProblem(t, s.do(confirm(token)), 409, httpx.CodeConflict)
+}
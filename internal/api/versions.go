@@ -0,0 +1,111 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"Chirpy/internal/httpx"
+)
+
+// The unversioned /api/... paths predate /api/v1 and are kept as aliases
+// of it until legacySunset; afterwards they answer 410 Gone.
+var (
+	legacyDeprecatedAt = time.Date(2026, time.October, 19, 0, 0, 0, 0, time.UTC)
+	legacySunset       = time.Date(2027, time.April, 30, 0, 0, 0, 0, time.UTC)
+)
+
+// apiVersions lists the versions served side by side. A new version gets
+// its own route table, which reuses the handlers of the previous one for
+// the endpoints it does not change.
+func (cfg *API) apiVersions() []apiVersion {
+	return []apiVersion{
+		{prefix: "/api/v1", routes: cfg.apiV1Routes()},
+	}
+}
+
+type apiVersion struct {
+	prefix string
+	// routes have patterns relative to prefix, e.g. "GET /chirps".
+	routes []route
+}
+
+func (cfg *API) apiV1Routes() []route {
+	return []route{
+		{pattern: "GET /chirps", handler: http.HandlerFunc(cfg.chirpsQueryAll)},
+		{pattern: "GET /chirps/{chirpID}", handler: http.HandlerFunc(cfg.chirpsQuery)},
+		{pattern: "DELETE /chirps/{chirpID}", handler: http.HandlerFunc(cfg.chirpsDelete)},
+		{pattern: "POST /chirps/{chirpID}/restore", handler: http.HandlerFunc(cfg.chirpsRestore)},
+		{pattern: "POST /chirps/{chirpID}/report", handler: http.HandlerFunc(cfg.reportChirp)},
+		{pattern: "POST /chirps", handler: http.HandlerFunc(cfg.chirpsCreator)},
+		{pattern: "POST /users", handler: http.HandlerFunc(cfg.userCreator)},
+		{pattern: "PUT /users", handler: http.HandlerFunc(cfg.modifyUser)},
+		{pattern: "PATCH /users", handler: http.HandlerFunc(cfg.modifyUser)},
+		{pattern: "POST /users/email/confirm", handler: http.HandlerFunc(cfg.confirmEmailChange)},
+		{pattern: "DELETE /users", handler: http.HandlerFunc(cfg.deleteUser)},
+		{pattern: "POST /users/export", handler: http.HandlerFunc(cfg.requestDataExport)},
+		{pattern: "GET /users/export", handler: http.HandlerFunc(cfg.dataExport)},
+		{pattern: "POST /users/{userID}/report", handler: http.HandlerFunc(cfg.reportUser)},
+		{pattern: "GET /users/blocks", handler: http.HandlerFunc(cfg.listBlocks)},
+		{pattern: "POST /users/{userID}/block", handler: http.HandlerFunc(cfg.blockUser)},
+		{pattern: "DELETE /users/{userID}/block", handler: http.HandlerFunc(cfg.unblockUser)},
+		{pattern: "GET /users/mutes", handler: http.HandlerFunc(cfg.listMutes)},
+		{pattern: "POST /users/{userID}/mute", handler: http.HandlerFunc(cfg.muteUser)},
+		{pattern: "DELETE /users/{userID}/mute", handler: http.HandlerFunc(cfg.unmuteUser)},
+		{pattern: "POST /login", handler: http.HandlerFunc(cfg.userLogin)},
+		{pattern: "POST /refresh", handler: http.HandlerFunc(cfg.refreshToken)},
+		{pattern: "POST /revoke", handler: http.HandlerFunc(cfg.revokeToken)},
+		{pattern: "POST /polka/webhooks", handler: http.HandlerFunc(cfg.polkaWebhook)},
+	}
+}
+
+// mountVersion prefixes the patterns of v.
+func mountVersion(v apiVersion) []route {
+	routes := make([]route, 0, len(v.routes))
+	for _, r := range v.routes {
+		routes = append(routes, route{
+			pattern: withPrefix(r.pattern, v.prefix),
+			handler: r.handler,
+		})
+	}
+	return routes
+}
+
+// legacyRoutes serves the routes of v under /api as well, flagged as
+// deprecated in favour of the versioned path.
+func legacyRoutes(v apiVersion) []route {
+	routes := make([]route, 0, len(v.routes))
+	for _, r := range v.routes {
+		successor := withPrefix(r.pattern, v.prefix)
+		routes = append(routes, route{
+			pattern:   withPrefix(r.pattern, "/api"),
+			handler:   middlewareDeprecated(v.prefix, r.handler),
+			successor: successor,
+		})
+	}
+	return routes
+}
+
+func withPrefix(pattern, prefix string) string {
+	method, path, _ := strings.Cut(pattern, " ")
+	return method + " " + prefix + path
+}
+
+// middlewareDeprecated announces the deprecation (RFC 9745) and sunset
+// (RFC 8594) of an unversioned path and links the versioned one. Once the
+// sunset date has passed the path is gone.
+func middlewareDeprecated(prefix string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		successor := prefix + strings.TrimPrefix(req.URL.Path, "/api")
+		res.Header().Set("Deprecation", "@"+strconv.FormatInt(legacyDeprecatedAt.Unix(), 10))
+		res.Header().Set("Sunset", legacySunset.Format(http.TimeFormat))
+		res.Header().Add("Link", "<"+successor+`>; rel="successor-version"`)
+
+		if time.Now().After(legacySunset) {
+			httpx.RespondError(res, req, http.StatusGone, httpx.CodeGone, "this path was retired, use "+successor)
+			return
+		}
+		next.ServeHTTP(res, req)
+	})
+}
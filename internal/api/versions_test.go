@@ -0,0 +1,69 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestLegacyRoutes(t *testing.T) {
+	ok := http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		res.WriteHeader(http.StatusOK)
+	})
+	v := apiVersion{prefix: "/api/v1", routes: []route{
+		{pattern: "GET /chirps/{chirpID}", handler: ok},
+		{pattern: "POST /login", handler: ok},
+	}}
+
+	mux := http.NewServeMux()
+	for _, r := range append(mountVersion(v), legacyRoutes(v)...) {
+		mux.Handle(r.pattern, r.handler)
+	}
+
+	tests := []struct {
+		name           string
+		method, path   string
+		wantStatus     int
+		wantDeprecated bool
+		wantLink       string
+	}{
+		{"versioned", "GET", "/api/v1/chirps/123", 200, false, ""},
+		{"legacy", "GET", "/api/chirps/123", 200, true, `</api/v1/chirps/123>; rel="successor-version"`},
+		{"legacy post", "POST", "/api/login", 200, true, `</api/v1/login>; rel="successor-version"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			deprecated := rec.Header().Get("Deprecation") != ""
+			if deprecated != tt.wantDeprecated {
+				t.Errorf("Deprecation = %q, want set = %v", rec.Header().Get("Deprecation"), tt.wantDeprecated)
+			}
+			if deprecated && rec.Header().Get("Sunset") != legacySunset.Format(http.TimeFormat) {
+				t.Errorf("Sunset = %q", rec.Header().Get("Sunset"))
+			}
+			if link := rec.Header().Get("Link"); link != tt.wantLink {
+				t.Errorf("Link = %q, want %q", link, tt.wantLink)
+			}
+		})
+	}
+}
+
+func TestLegacyRoutesAfterSunset(t *testing.T) {
+	sunset := legacySunset
+	legacySunset = time.Now().Add(-time.Hour)
+	defer func() { legacySunset = sunset }()
+
+	h := middlewareDeprecated("/api/v1", http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		t.Error("handler called after sunset")
+	}))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/api/chirps", nil))
+	if rec.Code != http.StatusGone {
+		t.Errorf("status = %d, want 410", rec.Code)
+	}
+}
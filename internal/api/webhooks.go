@@ -0,0 +1,285 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"Chirpy/internal/database"
+	"Chirpy/internal/httpx"
+	"Chirpy/internal/webhook"
+	"github.com/google/uuid"
+)
+
+const (
+	// webhookTolerance is how far the signature timestamp of a delivery
+	// may be from our clock.
+	webhookTolerance = 5 * time.Minute
+	// webhookClaimTimeout is how long an event may stay processing before
+	// another delivery takes it over from a handler that never finished.
+	webhookClaimTimeout = 5 * time.Minute
+	maxWebhookBody      = 1 << 20
+)
+
+var (
+	errWebhookIgnored     = errors.New("event not handled")
+	errWebhookBadPayload  = errors.New("invalid event payload")
+	errWebhookUnknownUser = errors.New("user not found")
+	errWebhookClaimed     = errors.New("event is not claimable")
+)
+
+type WebhookEvent struct {
+	ID          string     `json:"id"`
+	Event       string     `json:"event"`
+	Status      string     `json:"status"`
+	Error       string     `json:"error,omitempty"`
+	Attempts    int32      `json:"attempts"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ProcessedAt *time.Time `json:"processed_at"`
+}
+
+type webhookPayload struct {
+	ID    string `json:"id"`
+	Event string `json:"event"`
+	Data  struct {
+		User_id   string    `json:"user_id"`
+		PeriodEnd time.Time `json:"period_end"`
+	} `json:"data"`
+}
+
+// polkaWebhook receives the events sent by Polka. Deliveries must be signed
+// and each event is stored by its Polka ID, so retries of an event that was
+// already handled are acknowledged without being applied twice. A delivery
+// that arrives while another one is applying the event gets a 409, for
+// Polka to retry it later.
+func (cfg *API) polkaWebhook(res http.ResponseWriter, req *http.Request) {
+	if cfg.webhookSecret == "" {
+		slog.ErrorContext(req.Context(), "POLKA_WEBHOOK_SECRET is not set, refusing webhook")
+		httpx.RespondError(res, req, http.StatusUnauthorized, httpx.CodeInvalidSignature, "")
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(res, req.Body, maxWebhookBody))
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		httpx.RespondError(res, req, http.StatusRequestEntityTooLarge, httpx.CodeBodyTooLarge, "")
+		return
+	}
+	if err != nil {
+		httpx.RespondError(res, req, http.StatusBadRequest, httpx.CodeInvalidRequest, "cannot read body")
+		return
+	}
+
+	err = webhook.Verify(cfg.webhookSecret, req.Header.Get(webhook.SignatureHeader), body, time.Now(), webhookTolerance)
+	if err != nil {
+		slog.WarnContext(req.Context(), "webhook refused", "err", err)
+		httpx.RespondError(res, req, http.StatusUnauthorized, httpx.CodeInvalidSignature, err.Error())
+		return
+	}
+
+	payload := webhookPayload{}
+	err = json.Unmarshal(body, &payload)
+	if err != nil || payload.ID == "" || payload.Event == "" {
+		httpx.RespondError(res, req, http.StatusBadRequest, httpx.CodeInvalidRequest, "payload needs an id and an event")
+		return
+	}
+
+	_, err = cfg.store.CreateWebhookEvent(req.Context(), database.CreateWebhookEventParams{
+		ID:      payload.ID,
+		Event:   payload.Event,
+		Payload: body,
+	})
+	//evento già ricevuto: il claim dice se va riprocessato
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		slog.ErrorContext(req.Context(), "cannot store webhook event", "event_id", payload.ID, "err", err)
+		httpx.RespondError(res, req, http.StatusInternalServerError, httpx.CodeInternal, "")
+		return
+	}
+
+	event, err := cfg.claimWebhookEvent(req.Context(), payload.ID)
+	if errors.Is(err, errWebhookClaimed) {
+		if event.Status == "processed" || event.Status == "ignored" {
+			slog.InfoContext(req.Context(), "duplicate webhook event", "event_id", event.ID, "status", event.Status)
+			res.WriteHeader(204)
+			return
+		}
+		httpx.RespondError(res, req, http.StatusConflict, httpx.CodeConflict, "the event is being processed")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(req.Context(), "cannot claim webhook event", "event_id", payload.ID, "err", err)
+		httpx.RespondError(res, req, http.StatusInternalServerError, httpx.CodeInternal, "")
+		return
+	}
+
+	_, status := cfg.handleWebhookEvent(req.Context(), event, cfg.auditActorOf(req))
+	switch status {
+	case http.StatusNoContent:
+		res.WriteHeader(status)
+	case http.StatusBadRequest:
+		httpx.RespondError(res, req, status, httpx.CodeInvalidRequest, "malformed event data")
+	case http.StatusNotFound:
+		httpx.RespondError(res, req, status, httpx.CodeNotFound, "user not found")
+	default:
+		httpx.RespondError(res, req, status, httpx.CodeInternal, "")
+	}
+}
+
+// replayWebhook processes again an event that was received but not
+// applied, or that failed. Events that were processed or ignored, or that a
+// delivery is applying, cannot be replayed.
+func (cfg *API) replayWebhook(res http.ResponseWriter, req *http.Request) {
+	event, err := cfg.claimWebhookEvent(req.Context(), req.PathValue("eventID"))
+	if errors.Is(err, sql.ErrNoRows) {
+		httpx.RespondError(res, req, http.StatusNotFound, httpx.CodeNotFound, "webhook event not found")
+		return
+	}
+	if errors.Is(err, errWebhookClaimed) {
+		httpx.RespondError(res, req, http.StatusConflict, httpx.CodeConflict, "the event is "+event.Status)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(req.Context(), "cannot claim webhook event", "err", err)
+		httpx.RespondError(res, req, http.StatusInternalServerError, httpx.CodeInternal, "")
+		return
+	}
+
+	event, _ = cfg.handleWebhookEvent(req.Context(), event, cfg.auditActorOf(req))
+	slog.InfoContext(req.Context(), "webhook event replayed", "event_id", event.ID, "status", event.Status)
+
+	httpx.RespondJSON(res, req, 200, outputWebhookEvent(event))
+}
+
+// claimWebhookEvent marks the event with the given id as processing, so
+// that no other delivery applies it at the same time. When the event is
+// processing, processed or ignored it returns the event as it is along with
+// errWebhookClaimed; when there is no such event, sql.ErrNoRows.
+func (cfg *API) claimWebhookEvent(ctx context.Context, id string) (database.WebhookEvent, error) {
+	event, err := cfg.store.ClaimWebhookEvent(ctx, database.ClaimWebhookEventParams{
+		ID:          id,
+		StaleBefore: time.Now().Add(-webhookClaimTimeout),
+	})
+	if !errors.Is(err, sql.ErrNoRows) {
+		return event, err
+	}
+	event, err = cfg.store.QueryWebhookEvent(ctx, id)
+	if err != nil {
+		return event, err
+	}
+	return event, errWebhookClaimed
+}
+
+// handleWebhookEvent applies an event claimed with claimWebhookEvent,
+// records the outcome and returns the updated event along with the status
+// code to answer Polka with. Plan changes are audited as done by actor.
+func (cfg *API) handleWebhookEvent(ctx context.Context, event database.WebhookEvent, actor auditActor) (database.WebhookEvent, int) {
+	err := cfg.applyWebhookEvent(ctx, event, actor)
+
+	status, code := "processed", 204
+	switch {
+	case err == nil:
+	case errors.Is(err, errWebhookIgnored):
+		status = "ignored"
+		err = nil
+	case errors.Is(err, errWebhookBadPayload):
+		status, code = "failed", 400
+	case errors.Is(err, errWebhookUnknownUser):
+		status, code = "failed", 404
+	default:
+		status, code = "failed", 500
+	}
+
+	errMsg := sql.NullString{}
+	if err != nil {
+		errMsg = sql.NullString{String: err.Error(), Valid: true}
+		slog.WarnContext(ctx, "webhook event failed", "event_id", event.ID, "event", event.Event, "err", err)
+	}
+	cfg.metrics.webhooksProcessed.WithLabelValues(event.Event, status).Inc()
+	marked, markErr := cfg.store.MarkWebhookEvent(ctx, database.MarkWebhookEventParams{
+		ID:     event.ID,
+		Status: status,
+		Error:  errMsg,
+	})
+	if markErr != nil {
+		slog.ErrorContext(ctx, "cannot record webhook event outcome", "event_id", event.ID, "status", status, "err", markErr)
+		return event, 500
+	}
+	return marked, code
+}
+
+func (cfg *API) applyWebhookEvent(ctx context.Context, event database.WebhookEvent, actor auditActor) error {
+	payload := webhookPayload{}
+	err := json.Unmarshal(event.Payload, &payload)
+	if err != nil {
+		return errWebhookBadPayload
+	}
+
+	switch payload.Event {
+	case "user.upgraded", "subscription.renewed", "user.downgraded":
+	default:
+		return errWebhookIgnored
+	}
+
+	userID, err := uuid.Parse(payload.Data.User_id)
+	if err != nil {
+		return errWebhookBadPayload
+	}
+
+	err = cfg.auditTx(ctx, func(q database.Querier) error {
+		before, err := q.QueryUserByID(ctx, userID)
+		if err != nil {
+			return err
+		}
+		switch payload.Event {
+		case "user.upgraded":
+			err = startSubscription(ctx, q, userID, payload.Data.PeriodEnd)
+		case "subscription.renewed":
+			err = renewSubscription(ctx, q, userID, payload.Data.PeriodEnd)
+		default:
+			err = cancelSubscription(ctx, q, userID)
+		}
+		if err != nil {
+			return err
+		}
+		after, err := q.QueryUserByID(ctx, userID)
+		if err != nil {
+			return err
+		}
+		return appendAudit(ctx, q, actor, auditRecord{
+			action:     "webhook." + payload.Event,
+			targetType: "user",
+			targetID:   userID.String(),
+			before:     planSnapshot(before),
+			after:      planSnapshot(after),
+		})
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return errWebhookUnknownUser
+	}
+	return err
+}
+
+// planSnapshot is the part of a user that webhooks change.
+func planSnapshot(user database.User) map[string]any {
+	return map[string]any{"is_chirpy_red": user.IsChirpyRed}
+}
+
+func outputWebhookEvent(event database.WebhookEvent) WebhookEvent {
+	out := WebhookEvent{
+		ID:        event.ID,
+		Event:     event.Event,
+		Status:    event.Status,
+		Error:     event.Error.String,
+		Attempts:  event.Attempts,
+		CreatedAt: event.CreatedAt,
+	}
+	if event.ProcessedAt.Valid {
+		out.ProcessedAt = &event.ProcessedAt.Time
+	}
+	return out
+}
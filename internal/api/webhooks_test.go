@@ -0,0 +1,118 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"Chirpy/internal/database"
+	"Chirpy/internal/httpx"
+	"Chirpy/internal/store"
+	"Chirpy/internal/webhook"
+)
+
+// polka delivers a signed Polka event about userID and checks the status
+// of the response.
+func (s *testServer) polka(t *testing.T, id, event, userID string, wantStatus int) {
+	t.Helper()
+	body, _ := json.Marshal(map[string]any{"id": id, "event": event, "data": map[string]string{"user_id": userID}})
+	rec := s.do(request{method: "POST", path: "/api/v1/polka/webhooks", body: string(body), header: http.Header{
+		webhook.SignatureHeader: {webhook.Sign(s.api.webhookSecret, time.Now(), body)},
+	}})
+	expect(t, rec, wantStatus, nil)
+}
+
+func TestPolkaWebhook(t *testing.T) {
+	s := newTestServer(t)
+	user := s.signup("walt@breakingbad.com", "04234")
+	isRed := func() bool {
+		t.Helper()
+		return s.login("walt@breakingbad.com", "04234").Is_chirpy_red
+	}
+
+	s.polka(t, "evt_1", "user.upgraded", user.ID.String(), 204)
+	if !isRed() {
+		t.Errorf("user is not Chirpy Red after user.upgraded")
+	}
+	//una seconda consegna dello stesso evento non viene riapplicata
+	s.polka(t, "evt_1", "user.upgraded", user.ID.String(), 204)
+	s.polka(t, "evt_2", "user.downgraded", user.ID.String(), 204)
+	if isRed() {
+		t.Errorf("user is still Chirpy Red after user.downgraded")
+	}
+	s.polka(t, "evt_3", "user.renamed", user.ID.String(), 204)
+	s.polka(t, "evt_4", "user.upgraded", "00000000-0000-0000-0000-000000000000", 404)
+	s.polka(t, "evt_5", "user.upgraded", "walt", 400)
+
+	body := `{"id":"evt_6","event":"user.upgraded","data":{"user_id":"` + user.ID.String() + `"}}`
+	tests := []struct {
+		name      string
+		signature string
+		body      string
+		wantCode  int
+		wantProb  string
+	}{
+		{"unsigned", "", body, 401, httpx.CodeInvalidSignature},
+		{"wrong secret", webhook.Sign("other", time.Now(), []byte(body)), body, 401, httpx.CodeInvalidSignature},
+		{"stale", webhook.Sign(s.api.webhookSecret, time.Now().Add(-time.Hour), []byte(body)), body, 401, httpx.CodeInvalidSignature},
+		{"no id", webhook.Sign(s.api.webhookSecret, time.Now(), []byte(`{"event":"x"}`)), `{"event":"x"}`, 400, httpx.CodeInvalidRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			if tt.signature != "" {
+				header.Set(webhook.SignatureHeader, tt.signature)
+			}
+			rec := s.do(request{method: "POST", path: "/api/v1/polka/webhooks", body: tt.body, header: header})
+			expectProblem(t, rec, tt.wantCode, tt.wantProb)
+		})
+	}
+}
+
+func TestReplayWebhook(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.signupAs("admin@chirpy.test", "admin")
+	s.polka(t, "evt_1", "user.upgraded", "00000000-0000-0000-0000-000000000000", 404)
+
+	var event WebhookEvent
+	expect(t, s.do(request{method: "POST", path: "/admin/webhooks/evt_1/replay", token: admin.Token}), 200, &event)
+	if event.ID != "evt_1" || event.Status != "failed" || event.Attempts != 2 || event.Error == "" {
+		t.Errorf("event = %+v", event)
+	}
+	expectProblem(t, s.do(request{method: "POST", path: "/admin/webhooks/evt_2/replay", token: admin.Token}), 404, httpx.CodeNotFound)
+
+	//un evento elaborato non si ripete
+	s.polka(t, "evt_3", "user.downgraded", admin.ID.String(), 204)
+	expectProblem(t, s.do(request{method: "POST", path: "/admin/webhooks/evt_3/replay", token: admin.Token}), 409, httpx.CodeConflict)
+}
+
+func TestWebhookClaim(t *testing.T) {
+	mem := store.NewMemory()
+	s := newTestServerWith(t, testConfig(), mem)
+	admin := s.signupAs("admin@chirpy.test", "admin")
+	s.polka(t, "evt_1", "user.upgraded", "00000000-0000-0000-0000-000000000000", 404)
+
+	//una consegna sta già applicando l'evento: né Polka né il replay lo applicano di nuovo
+	claim := database.ClaimWebhookEventParams{ID: "evt_1"}
+	if _, err := s.store.ClaimWebhookEvent(context.Background(), claim); err != nil {
+		t.Fatal(err)
+	}
+	s.polka(t, "evt_1", "user.upgraded", "00000000-0000-0000-0000-000000000000", 409)
+	expectProblem(t, s.do(request{method: "POST", path: "/admin/webhooks/evt_1/replay", token: admin.Token}), 409, httpx.CodeConflict)
+
+	//una consegna che non ha mai finito viene ripresa
+	s.polka(t, "evt_2", "user.upgraded", "00000000-0000-0000-0000-000000000000", 404)
+	mem.Now = func() time.Time { return time.Now().Add(-2 * webhookClaimTimeout) }
+	claim.ID = "evt_2"
+	if _, err := s.store.ClaimWebhookEvent(context.Background(), claim); err != nil {
+		t.Fatal(err)
+	}
+	mem.Now = time.Now
+	var event WebhookEvent
+	expect(t, s.do(request{method: "POST", path: "/admin/webhooks/evt_2/replay", token: admin.Token}), 200, &event)
+	if event.Status != "failed" || event.Attempts != 2 {
+		t.Errorf("event = %+v", event)
+	}
+}
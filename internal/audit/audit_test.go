@@ -0,0 +1,67 @@
+package audit
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func chain(n int) []Entry {
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	var entries []Entry
+	for i := range n {
+		var last *Entry
+		if i > 0 {
+			last = &entries[i-1]
+		}
+		entries = append(entries, Next(last, Entry{
+			CreatedAt:  start.Add(time.Duration(i) * time.Minute),
+			Action:     "chirp.delete",
+			TargetType: "chirp",
+			Before:     `{"body":"say my name"}`,
+		}))
+	}
+	return entries
+}
+
+func TestNext(t *testing.T) {
+	entries := chain(2)
+	if entries[0].Seq != 1 || entries[0].PrevHash != Genesis || entries[1].Seq != 2 || entries[1].PrevHash != entries[0].Hash {
+		t.Errorf("chain = %+v", entries)
+	}
+	if entries[0].Hash == entries[1].Hash || len(entries[0].Hash) != len(Genesis) {
+		t.Errorf("hashes = %q, %q", entries[0].Hash, entries[1].Hash)
+	}
+
+	//il fuso orario e i nanosecondi che il database non conserva non contano
+	e := entries[0]
+	e.CreatedAt = e.CreatedAt.In(time.FixedZone("CET", 3600)).Add(500 * time.Nanosecond)
+	if Hash(e) != entries[0].Hash {
+		t.Error("Hash() depends on the location or on sub-microsecond precision")
+	}
+}
+
+func TestVerify(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func(entries []Entry) []Entry
+		want   int64
+	}{
+		{"intact", func(e []Entry) []Entry { return e }, 0},
+		{"empty", func(e []Entry) []Entry { return nil }, 0},
+		{"edited", func(e []Entry) []Entry { e[1].Before = `{"body":"i am the danger"}`; return e }, 2},
+		{"edited and rehashed", func(e []Entry) []Entry { e[1].Action = "nothing"; e[1].Hash = Hash(e[1]); return e }, 3},
+		{"removed", func(e []Entry) []Entry { return slices.Delete(e, 1, 2) }, 3},
+		{"removed first", func(e []Entry) []Entry { return e[1:] }, 2},
+		{"swapped", func(e []Entry) []Entry { e[1], e[2] = e[2], e[1]; return e }, 3},
+		{"backdated", func(e []Entry) []Entry { e[3].CreatedAt = e[0].CreatedAt; return e }, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			broken, ok := Verify(tt.tamper(chain(4)))
+			if broken != tt.want || ok != (tt.want == 0) {
+				t.Errorf("Verify() = %d, %v, want %d", broken, ok, tt.want)
+			}
+		})
+	}
+}
@@ -8,14 +8,21 @@ import "github.com/golang-jwt/jwt/v5"
 import "fmt"
 import "net/http"
 import "strings"
-import "log"
 import "crypto/rand"
 import "encoding/hex"
 
 type auth struct {}
 
+// DefaultCost is the bcrypt cost used by HashPassword.
+const DefaultCost = 16
+
 func HashPassword(password string) (string, error){
-	hashedPsw, err := bcrypt.GenerateFromPassword([]byte(password),16)
+	return HashPasswordWithCost(password, DefaultCost)
+}
+
+// HashPasswordWithCost is HashPassword with a configurable bcrypt cost.
+func HashPasswordWithCost(password string, cost int) (string, error){
+	hashedPsw, err := bcrypt.GenerateFromPassword([]byte(password),cost)
 	if err != nil {
 		return "", errors.New("errore nell'hashing")
 	}
@@ -30,54 +37,78 @@ func CheckPasswordHash(hash, password string) error{
 	return nil
 }
 
-func MakeJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (string, error){
+// Claims are the claims carried by the access tokens issued by Chirpy.
+type Claims struct {
+	Role string `json:"role"`
+	ChirpyRed bool `json:"chirpy_red"`
+	jwt.RegisteredClaims
+}
 
-	/*type MyCustomClaims struct {
-		Foo string `json:"foo"`
-		jwt.RegisteredClaims
-	}
-	*/
+// MakeJWT issues an access token for user. The role and Chirpy Red status
+// are a snapshot: they are refreshed when a new token is issued.
+func MakeJWT(user TokenClaims, tokenSecret string, expiresIn time.Duration) (string, error){
 
-	// Create claims while leaving out some of the optional fields
-	//claims = MyCustomClaims{
-	//	"bar",
-	claims :=	jwt.RegisteredClaims{
+	claims := Claims{
+		Role: user.Role,
+		ChirpyRed: user.ChirpyRed,
+		RegisteredClaims: jwt.RegisteredClaims{
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiresIn)),
 			Issuer:    "chirpy",
-			Subject: userID.String(),
-		}
-	//}
+			Subject: user.UserID.String(),
+		},
+	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	ss, err := token.SignedString([]byte(tokenSecret))
-	fmt.Println(ss, err)
-	return ss, err
+	return token.SignedString([]byte(tokenSecret))
 }
 
 func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error){
-	
+	claims, err := ParseJWT(tokenString, tokenSecret)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	return claims.UserID, nil
+}
+
+// TokenClaims is the validated content of an access token.
+type TokenClaims struct {
+	UserID    uuid.UUID
+	Role      string
+	ChirpyRed bool
+}
+
+// ParseJWT validates tokenString and returns the user and role it was issued
+// for. Tokens issued before roles existed carry no role and are treated as
+// belonging to a plain user.
+func ParseJWT(tokenString, tokenSecret string) (TokenClaims, error){
 
-	token, err := jwt.ParseWithClaims(tokenString, &jwt.RegisteredClaims{}, func(token *jwt.Token) (interface{}, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
 		return []byte(tokenSecret), nil
 	})
 
 	if err != nil {
-		log.Printf("auth----ValidateJMT-----")
-		log.Printf("abbiamo un errore con il parsing del token:: %v", tokenString)
-		log.Printf("token che fa problemi:: %v", token)
-		return uuid.Nil, errors.New("error during parsing, err::: %v")
-	} else if claims, ok := token.Claims.(*jwt.RegisteredClaims); ok {
-		fmt.Println(claims.Issuer)
+		return TokenClaims{}, fmt.Errorf("error during parsing: %w", err)
+	} else if claims, ok := token.Claims.(*Claims); ok {
 		if token.Valid {
-			userID, _ := uuid.Parse(claims.Subject)
-			return userID, nil
+			userID, err := uuid.Parse(claims.Subject)
+			if err != nil {
+				return TokenClaims{}, errors.New("invalid subject")
+			}
+			role := claims.Role
+			if role == "" {
+				role = RoleUser
+			}
+			if !ValidRole(role) {
+				return TokenClaims{}, errors.New("invalid role")
+			}
+			return TokenClaims{UserID: userID, Role: role, ChirpyRed: claims.ChirpyRed}, nil
 		} else {
-			return uuid.Nil, errors.New("invalid token")
+			return TokenClaims{}, errors.New("invalid token")
 		}
 
 	} else {
-		return uuid.Nil, errors.New("unknown claims type, cannot proceed")
+		return TokenClaims{}, errors.New("unknown claims type, cannot proceed")
 	}
 
 }
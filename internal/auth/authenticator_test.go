@@ -71,9 +71,9 @@ func TestJWT(t *testing.T) {
 	exp1, _ := time.ParseDuration("10h")
 	exp2, _ := time.ParseDuration("10h")
 	exp3, _ := time.ParseDuration("1µs")
-	jwt1, _ := MakeJWT(user1, secretToken1, exp1)
-	jwt2, _ := MakeJWT(user2, secretToken2, exp2)
-	jwt3, _ := MakeJWT(user3, secretToken3, exp3)
+	jwt1, _ := MakeJWT(TokenClaims{UserID: user1, Role: RoleUser}, secretToken1, exp1)
+	jwt2, _ := MakeJWT(TokenClaims{UserID: user2, Role: RoleUser}, secretToken2, exp2)
+	jwt3, _ := MakeJWT(TokenClaims{UserID: user3, Role: RoleUser}, secretToken3, exp3)
 
 
 	tests := []struct {
@@ -122,4 +122,75 @@ func TestJWT(t *testing.T) {
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+func TestJWTRole(t *testing.T) {
+	user := uuid.New()
+	secret := "roleSecret"
+	exp, _ := time.ParseDuration("1h")
+
+	tests := []struct {
+		name     string
+		role     string
+		chirpyRed bool
+		wantRole string
+		wantErr  bool
+	}{
+		{
+			name:     "Admin role",
+			role:     RoleAdmin,
+			wantRole: RoleAdmin,
+			wantErr:  false,
+		},
+		{
+			name:     "Chirpy Red user",
+			role:     RoleUser,
+			chirpyRed: true,
+			wantRole: RoleUser,
+			wantErr:  false,
+		},
+		{
+			name:     "Missing role defaults to user",
+			role:     "",
+			wantRole: RoleUser,
+			wantErr:  false,
+		},
+		{
+			name:     "Unknown role",
+			role:     "superuser",
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, _ := MakeJWT(TokenClaims{UserID: user, Role: tt.role, ChirpyRed: tt.chirpyRed}, secret, exp)
+			claims, err := ParseJWT(token, secret)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseJWT() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && (claims.Role != tt.wantRole || claims.UserID != user || claims.ChirpyRed != tt.chirpyRed) {
+				t.Errorf("ParseJWT() = %+v, want role %v for %v", claims, tt.wantRole, user)
+			}
+		})
+	}
+}
+
+func TestHasRole(t *testing.T) {
+	tests := []struct {
+		role     string
+		required string
+		want     bool
+	}{
+		{RoleAdmin, RoleModerator, true},
+		{RoleModerator, RoleModerator, true},
+		{RoleUser, RoleModerator, false},
+		{RoleModerator, RoleAdmin, false},
+		{"", RoleUser, false},
+	}
+
+	for _, tt := range tests {
+		if got := HasRole(tt.role, tt.required); got != tt.want {
+			t.Errorf("HasRole(%q, %q) = %v, want %v", tt.role, tt.required, got, tt.want)
+		}
+	}
+}
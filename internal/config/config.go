@@ -0,0 +1,398 @@
+// Package config loads Chirpy's settings. Values come, from lowest to
+// highest precedence, from the defaults below, an optional YAML file, a
+// .env file and the process environment.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultFile is read when it exists and CHIRPY_CONFIG names no other file.
+const DefaultFile = "chirpy.yaml"
+
+const redacted = "[REDACTED]"
+
+// Config holds every setting of the server. Fields tagged env are read from
+// that variable; fields tagged secret are hidden by Redacted ("url" only
+// hides the password of a URL).
+type Config struct {
+	Platform       string `yaml:"platform" env:"PLATFORM"`
+	DBURL          string `yaml:"db_url" env:"DB_URL" secret:"url"`
+	JWTSecret      string `yaml:"jwt_secret" env:"SECRETTOKEN" secret:"true"`
+	PolkaSecret    string `yaml:"polka_webhook_secret" env:"POLKA_WEBHOOK_SECRET" secret:"true"`
+	AdminPassword  string `yaml:"admin_password" env:"CHIRPY_ADMIN_PASSWORD" secret:"true"`
+	LogLevel       string `yaml:"log_level" env:"LOG_LEVEL"`
+	TracesExporter string `yaml:"traces_exporter" env:"OTEL_TRACES_EXPORTER"`
+	AutoMigrate    bool   `yaml:"auto_migrate" env:"AUTO_MIGRATE"`
+
+	Server Server `yaml:"server"`
+	DB     DB     `yaml:"db"`
+	Tokens Tokens `yaml:"tokens"`
+	Auth   Auth   `yaml:"auth"`
+	Mail   Mail   `yaml:"mail"`
+
+	Moderation Moderation `yaml:"moderation"`
+	Spam       Spam       `yaml:"spam"`
+}
+
+type Server struct {
+	Port              int           `yaml:"port" env:"PORT"`
+	ReadTimeout       time.Duration `yaml:"read_timeout" env:"HTTP_READ_TIMEOUT"`
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout" env:"HTTP_READ_HEADER_TIMEOUT"`
+	WriteTimeout      time.Duration `yaml:"write_timeout" env:"HTTP_WRITE_TIMEOUT"`
+	IdleTimeout       time.Duration `yaml:"idle_timeout" env:"HTTP_IDLE_TIMEOUT"`
+	ShutdownDelay     time.Duration `yaml:"shutdown_delay" env:"SHUTDOWN_DELAY"`
+	ShutdownTimeout   time.Duration `yaml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT"`
+	MaxHeaderBytes    int           `yaml:"max_header_bytes" env:"HTTP_MAX_HEADER_BYTES"`
+	MaxBodyBytes      int64         `yaml:"max_body_bytes" env:"HTTP_MAX_BODY_BYTES"`
+}
+
+type DB struct {
+	MaxOpenConns    int           `yaml:"max_open_conns" env:"DB_MAX_OPEN_CONNS"`
+	MaxIdleConns    int           `yaml:"max_idle_conns" env:"DB_MAX_IDLE_CONNS"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime" env:"DB_CONN_MAX_LIFETIME"`
+	ConnMaxIdleTime time.Duration `yaml:"conn_max_idle_time" env:"DB_CONN_MAX_IDLE_TIME"`
+}
+
+type Tokens struct {
+	AccessTTL  time.Duration `yaml:"access_ttl" env:"ACCESS_TOKEN_TTL"`
+	RefreshTTL time.Duration `yaml:"refresh_ttl" env:"REFRESH_TOKEN_TTL"`
+}
+
+type Auth struct {
+	// BcryptCost is the work factor of password hashes; lowering it speeds
+	// up tests at the price of weaker hashes.
+	BcryptCost int `yaml:"bcrypt_cost" env:"BCRYPT_COST"`
+}
+
+type Moderation struct {
+	// AutoHideReports is the number of users whose pending reports hide a
+	// chirp until a moderator reviews it; 0 never hides chirps.
+	AutoHideReports int `yaml:"auto_hide_reports" env:"MODERATION_AUTO_HIDE_REPORTS"`
+	// ReporterMinAge is how old an account must be for its reports to count
+	// towards AutoHideReports; sanctioned accounts never count.
+	ReporterMinAge time.Duration `yaml:"reporter_min_age" env:"MODERATION_REPORTER_MIN_AGE"`
+	// ReportSuspension is how long a report resolved as user_suspended
+	// suspends the reported user.
+	ReportSuspension time.Duration `yaml:"report_suspension" env:"MODERATION_REPORT_SUSPENSION"`
+}
+
+// Spam tunes the checks on new chirps. The chirps per minute of each plan
+// are in package entitlements.
+type Spam struct {
+	// NewAccountAge is how long a free account counts as new and posts at
+	// most NewAccountChirpsPerMinute chirps a minute; 0 treats no account
+	// as new.
+	NewAccountAge             time.Duration `yaml:"new_account_age" env:"SPAM_NEW_ACCOUNT_AGE"`
+	NewAccountChirpsPerMinute int           `yaml:"new_account_chirps_per_minute" env:"SPAM_NEW_ACCOUNT_CHIRPS_PER_MINUTE"`
+	// DuplicateWindow is how long an author cannot post the same chirp
+	// again; 0 allows repeats.
+	DuplicateWindow time.Duration `yaml:"duplicate_window" env:"SPAM_DUPLICATE_WINDOW"`
+	// HoldLinks and RejectLinks are the numbers of links that hold a chirp
+	// for a moderator or refuse it; 0 disables either.
+	HoldLinks   int `yaml:"hold_links" env:"SPAM_HOLD_LINKS"`
+	RejectLinks int `yaml:"reject_links" env:"SPAM_REJECT_LINKS"`
+}
+
+type Mail struct {
+	SMTPAddr     string `yaml:"smtp_addr" env:"SMTP_ADDR"`
+	From         string `yaml:"from" env:"MAIL_FROM"`
+	SMTPUsername string `yaml:"smtp_username" env:"SMTP_USERNAME"`
+	SMTPPassword string `yaml:"smtp_password" env:"SMTP_PASSWORD" secret:"true"`
+}
+
+// Default returns the settings used when nothing overrides them.
+func Default() Config {
+	return Config{
+		Platform:       "production",
+		LogLevel:       "info",
+		TracesExporter: "none",
+		Server: Server{
+			Port:              8080,
+			ReadTimeout:       10 * time.Second,
+			ReadHeaderTimeout: 5 * time.Second,
+			WriteTimeout:      30 * time.Second,
+			IdleTimeout:       2 * time.Minute,
+			ShutdownDelay:     5 * time.Second,
+			ShutdownTimeout:   20 * time.Second,
+			MaxHeaderBytes:    64 << 10,
+			MaxBodyBytes:      1 << 20,
+		},
+		DB: DB{
+			MaxOpenConns:    25,
+			MaxIdleConns:    25,
+			ConnMaxLifetime: 30 * time.Minute,
+			ConnMaxIdleTime: 5 * time.Minute,
+		},
+		Tokens: Tokens{
+			AccessTTL:  time.Hour,
+			RefreshTTL: 60 * 24 * time.Hour,
+		},
+		Auth: Auth{
+			BcryptCost: 16,
+		},
+		Moderation: Moderation{
+			AutoHideReports:  5,
+			ReporterMinAge:   24 * time.Hour,
+			ReportSuspension: 7 * 24 * time.Hour,
+		},
+		Spam: Spam{
+			NewAccountAge:             24 * time.Hour,
+			NewAccountChirpsPerMinute: 3,
+			DuplicateWindow:           time.Hour,
+			HoldLinks:                 3,
+			RejectLinks:               8,
+		},
+	}
+}
+
+// Read loads the configuration without validating it.
+func Read() (Config, error) {
+	cfg := Default()
+
+	path := os.Getenv("CHIRPY_CONFIG")
+	if path == "" {
+		if _, err := os.Stat(DefaultFile); err == nil {
+			path = DefaultFile
+		}
+	}
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return cfg, fmt.Errorf("config file: %w", err)
+		}
+		err = yaml.Unmarshal(data, &cfg)
+		if err != nil {
+			return cfg, fmt.Errorf("config file %s: %w", path, err)
+		}
+	}
+
+	//.env non sovrascrive le variabili già presenti nell'ambiente
+	err := godotenv.Load()
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return cfg, fmt.Errorf(".env: %w", err)
+	}
+
+	err = applyEnv(reflect.ValueOf(&cfg).Elem())
+	return cfg, err
+}
+
+// Load reads the configuration and validates it.
+func Load() (Config, error) {
+	cfg, err := Read()
+	if err != nil {
+		return cfg, err
+	}
+	return cfg, cfg.Validate()
+}
+
+func applyEnv(v reflect.Value) error {
+	var errs []error
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := v.Field(i)
+		if field.Kind() == reflect.Struct {
+			errs = append(errs, applyEnv(field))
+			continue
+		}
+		name := t.Field(i).Tag.Get("env")
+		raw, ok := os.LookupEnv(name)
+		if name == "" || !ok || raw == "" {
+			continue
+		}
+		err := setField(field, raw)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func setField(field reflect.Value, raw string) error {
+	if field.Type() == reflect.TypeOf(time.Duration(0)) {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", raw)
+		}
+		field.SetInt(int64(d))
+		return nil
+	}
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(raw)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", raw)
+		}
+		field.SetInt(n)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", raw)
+		}
+		field.SetBool(b)
+	default:
+		return fmt.Errorf("unsupported type %v", field.Type())
+	}
+	return nil
+}
+
+// Validate reports every invalid setting at once, so that a misconfigured
+// server refuses to start instead of failing on the first request.
+func (c Config) Validate() error {
+	return c.ValidateFor("")
+}
+
+// ValidateFor checks the settings used by the subcommand named command, so
+// that "chirpy migrate" runs without the server's secrets. The empty name,
+// like any unknown one, stands for the server and checks everything.
+func (c Config) ValidateFor(command string) error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(c.DBURL != "", "DB_URL must be set")
+	if c.DBURL != "" {
+		scheme := dbScheme(c.DBURL)
+		check(scheme == "postgres" || scheme == "postgresql" || scheme == "sqlite",
+			"DB_URL must be a postgres:// or sqlite: URL or a key=value Postgres connection string, got scheme %q", scheme)
+	}
+	var level slog.Level
+	check(level.UnmarshalText([]byte(c.LogLevel)) == nil, "LOG_LEVEL %q is not a level", c.LogLevel)
+	check(c.TracesExporter == "none" || c.TracesExporter == "stdout" || c.TracesExporter == "otlp",
+		"OTEL_TRACES_EXPORTER must be none, stdout or otlp, got %q", c.TracesExporter)
+
+	check(c.DB.MaxOpenConns >= 0, "DB_MAX_OPEN_CONNS cannot be negative")
+	check(c.DB.MaxIdleConns >= 0, "DB_MAX_IDLE_CONNS cannot be negative")
+	check(c.DB.ConnMaxLifetime >= 0, "DB_CONN_MAX_LIFETIME cannot be negative")
+	check(c.DB.ConnMaxIdleTime >= 0, "DB_CONN_MAX_IDLE_TIME cannot be negative")
+
+	switch command {
+	case "migrate":
+		return errors.Join(errs...)
+	case "bootstrap-admin":
+		//limiti di golang.org/x/crypto/bcrypt
+		check(c.Auth.BcryptCost >= 4 && c.Auth.BcryptCost <= 31, "BCRYPT_COST must be between 4 and 31, got %d", c.Auth.BcryptCost)
+		return errors.Join(errs...)
+	}
+
+	check(c.JWTSecret != "", "SECRETTOKEN must be set")
+	check(c.PolkaSecret != "", "POLKA_WEBHOOK_SECRET must be set")
+	check(c.Platform == "dev" || c.Platform == "production", "PLATFORM must be dev or production, got %q", c.Platform)
+
+	check(c.Server.Port > 0 && c.Server.Port < 65536, "PORT %d is out of range", c.Server.Port)
+	check(c.Server.ReadTimeout > 0, "HTTP_READ_TIMEOUT must be positive")
+	check(c.Server.ReadHeaderTimeout > 0, "HTTP_READ_HEADER_TIMEOUT must be positive")
+	check(c.Server.WriteTimeout > 0, "HTTP_WRITE_TIMEOUT must be positive")
+	check(c.Server.IdleTimeout > 0, "HTTP_IDLE_TIMEOUT must be positive")
+	check(c.Server.ShutdownDelay >= 0, "SHUTDOWN_DELAY cannot be negative")
+	check(c.Server.ShutdownTimeout > 0, "SHUTDOWN_TIMEOUT must be positive")
+	check(c.Server.MaxHeaderBytes > 0, "HTTP_MAX_HEADER_BYTES must be positive")
+	check(c.Server.MaxBodyBytes > 0, "HTTP_MAX_BODY_BYTES must be positive")
+
+	check(c.Tokens.AccessTTL > 0, "ACCESS_TOKEN_TTL must be positive")
+	check(c.Tokens.RefreshTTL > c.Tokens.AccessTTL, "REFRESH_TOKEN_TTL must be longer than ACCESS_TOKEN_TTL")
+	//limiti di golang.org/x/crypto/bcrypt
+	check(c.Auth.BcryptCost >= 4 && c.Auth.BcryptCost <= 31, "BCRYPT_COST must be between 4 and 31, got %d", c.Auth.BcryptCost)
+
+	check(c.Moderation.AutoHideReports >= 0, "MODERATION_AUTO_HIDE_REPORTS cannot be negative")
+	check(c.Moderation.ReporterMinAge >= 0, "MODERATION_REPORTER_MIN_AGE cannot be negative")
+	check(c.Moderation.ReportSuspension > 0, "MODERATION_REPORT_SUSPENSION must be positive")
+
+	check(c.Spam.NewAccountAge >= 0, "SPAM_NEW_ACCOUNT_AGE cannot be negative")
+	check(c.Spam.NewAccountChirpsPerMinute > 0, "SPAM_NEW_ACCOUNT_CHIRPS_PER_MINUTE must be positive")
+	check(c.Spam.DuplicateWindow >= 0, "SPAM_DUPLICATE_WINDOW cannot be negative")
+	check(c.Spam.HoldLinks >= 0, "SPAM_HOLD_LINKS cannot be negative")
+	check(c.Spam.RejectLinks >= 0, "SPAM_REJECT_LINKS cannot be negative")
+	check(c.Spam.HoldLinks == 0 || c.Spam.RejectLinks == 0 || c.Spam.RejectLinks > c.Spam.HoldLinks,
+		"SPAM_REJECT_LINKS must be greater than SPAM_HOLD_LINKS")
+
+	//senza SMTP i token di conferma finirebbero nei log
+	check(c.Platform != "production" || c.Mail.SMTPAddr != "", "SMTP_ADDR must be set when PLATFORM is production")
+	check(c.Mail.SMTPAddr == "" || c.Mail.From != "", "MAIL_FROM must be set when SMTP_ADDR is")
+	return errors.Join(errs...)
+}
+
+// keyValueDSN matches the start of a Postgres connection string in
+// key=value form, such as "host=localhost dbname=chirpy".
+var keyValueDSN = regexp.MustCompile(`^\s*[a-z_]+\s*=`)
+
+// dsnPassword matches the password of a key=value connection string, quoted
+// or not.
+var dsnPassword = regexp.MustCompile(`\bpassword\s*=\s*('(?:[^'\\]|\\.)*'|\S*)`)
+
+func dbScheme(raw string) string {
+	if keyValueDSN.MatchString(raw) {
+		return "postgres"
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return ""
+	}
+	return u.Scheme
+}
+
+// Addr is the address the HTTP server listens on.
+func (c Config) Addr() string {
+	return ":" + strconv.Itoa(c.Server.Port)
+}
+
+// Redacted returns a copy of c with its secrets hidden, safe to print.
+func (c Config) Redacted() Config {
+	redactSecrets(reflect.ValueOf(&c).Elem())
+	return c
+}
+
+func redactSecrets(v reflect.Value) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := v.Field(i)
+		if field.Kind() == reflect.Struct {
+			redactSecrets(field)
+			continue
+		}
+		if field.Kind() != reflect.String || field.String() == "" {
+			continue
+		}
+		switch t.Field(i).Tag.Get("secret") {
+		case "true":
+			field.SetString(redacted)
+		case "url":
+			field.SetString(redactURL(field.String()))
+		}
+	}
+}
+
+func redactURL(raw string) string {
+	if keyValueDSN.MatchString(raw) {
+		return dsnPassword.ReplaceAllString(raw, "password=xxxxx")
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return redacted
+	}
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), "xxxxx")
+	}
+	return u.String()
+}
+
+// YAML renders c in the format of the config file.
+func (c Config) YAML() ([]byte, error) {
+	return yaml.Marshal(c)
+}
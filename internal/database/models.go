@@ -6,17 +6,70 @@ package database
 
 import (
 	"database/sql"
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+type AuditLog struct {
+	Seq         int64
+	CreatedAt   time.Time
+	ActorID     uuid.NullUUID
+	Action      string
+	TargetType  string
+	TargetID    sql.NullString
+	Ip          sql.NullString
+	RequestID   sql.NullString
+	BeforeState sql.NullString
+	AfterState  sql.NullString
+	PrevHash    string
+	Hash        string
+}
+
+type Block struct {
+	BlockerID uuid.UUID
+	BlockedID uuid.UUID
+	CreatedAt time.Time
+}
+
 type Chirp struct {
-	ID        uuid.UUID
+	ID             uuid.UUID
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	Body           string
+	UserID         uuid.UUID
+	DeletedAt      sql.NullTime
+	DeletedBy      uuid.NullUUID
+	DeletionReason sql.NullString
+	BodyHash       string
+	HeldReason     sql.NullString
+}
+
+type DataExport struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	UserID      uuid.UUID
+	Status      string
+	Archive     []byte
+	CompletedAt sql.NullTime
+}
+
+type EmailChange struct {
+	Token       string
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	UserID      uuid.UUID
+	NewEmail    string
+	ExpiresAt   time.Time
+	ConfirmedAt sql.NullTime
+}
+
+type Mute struct {
+	MuterID   uuid.UUID
+	MutedID   uuid.UUID
 	CreatedAt time.Time
-	UpdatedAt time.Time
-	Body      string
-	UserID    uuid.UUID
 }
 
 type RefreshToken struct {
@@ -28,6 +81,50 @@ type RefreshToken struct {
 	RevokedAt sql.NullTime
 }
 
+type Report struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	ReporterID uuid.UUID
+	UserID     uuid.UUID
+	ChirpID    uuid.NullUUID
+	Reason     string
+	Details    sql.NullString
+	Status     string
+	AssigneeID uuid.NullUUID
+	ResolvedAt sql.NullTime
+}
+
+type ReportEvent struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	ReportID  uuid.UUID
+	ActorID   uuid.NullUUID
+	Action    string
+	Note      sql.NullString
+}
+
+type Sanction struct {
+	ID             uuid.UUID
+	CreatedAt      time.Time
+	UserID         uuid.UUID
+	ActorID        uuid.NullUUID
+	Status         string
+	SuspendedUntil sql.NullTime
+	Reason         string
+}
+
+type Subscription struct {
+	ID                 uuid.UUID
+	CreatedAt          time.Time
+	UpdatedAt          time.Time
+	UserID             uuid.UUID
+	Plan               string
+	Status             string
+	CurrentPeriodStart time.Time
+	CurrentPeriodEnd   time.Time
+}
+
 type User struct {
 	ID             uuid.UUID
 	CreatedAt      time.Time
@@ -35,4 +132,20 @@ type User struct {
 	Email          string
 	HashedPassword string
 	IsChirpyRed    bool
+	Role           string
+	DeletedAt      sql.NullTime
+	Status         string
+	SuspendedUntil sql.NullTime
+}
+
+type WebhookEvent struct {
+	ID          string
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Event       string
+	Payload     json.RawMessage
+	Status      string
+	Error       sql.NullString
+	Attempts    int32
+	ProcessedAt sql.NullTime
 }
@@ -0,0 +1,117 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.28.0
+
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Querier interface {
+	AssignReport(ctx context.Context, arg AssignReportParams) (Report, error)
+	CancelSubscription(ctx context.Context, userID uuid.UUID) error
+	ClaimDataExport(ctx context.Context, arg ClaimDataExportParams) (DataExport, error)
+	ClaimWebhookEvent(ctx context.Context, arg ClaimWebhookEventParams) (WebhookEvent, error)
+	CompleteDataExport(ctx context.Context, arg CompleteDataExportParams) error
+	ConfirmEmailChange(ctx context.Context, token string) error
+	// CountChirpReporters only counts reporters in good standing who signed up
+	// before created_before, so that throwaway or sanctioned accounts cannot
+	// hide chirps.
+	CountChirpReporters(ctx context.Context, arg CountChirpReportersParams) (int64, error)
+	CountDuplicateChirps(ctx context.Context, arg CountDuplicateChirpsParams) (int64, error)
+	// Counts for the flood checks; they include deleted and held chirps, so
+	// that deleting chirps does not make room for more.
+	CountUserChirpsSince(ctx context.Context, arg CountUserChirpsSinceParams) (int64, error)
+	// The audit log is append-only: entries are created with the next seq and
+	// the hash of the last entry, and never changed.
+	CreateAuditEntry(ctx context.Context, arg CreateAuditEntryParams) (AuditLog, error)
+	CreateBlock(ctx context.Context, arg CreateBlockParams) (Block, error)
+	CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp, error)
+	CreateDataExport(ctx context.Context, userID uuid.UUID) (DataExport, error)
+	CreateEmailChange(ctx context.Context, arg CreateEmailChangeParams) (EmailChange, error)
+	CreateMute(ctx context.Context, arg CreateMuteParams) (Mute, error)
+	CreateRefreshToken(ctx context.Context, arg CreateRefreshTokenParams) (RefreshToken, error)
+	CreateReport(ctx context.Context, arg CreateReportParams) (Report, error)
+	CreateReportEvent(ctx context.Context, arg CreateReportEventParams) (ReportEvent, error)
+	CreateSanction(ctx context.Context, arg CreateSanctionParams) (Sanction, error)
+	CreateSubscription(ctx context.Context, arg CreateSubscriptionParams) (Subscription, error)
+	CreateUser(ctx context.Context, arg CreateUserParams) (User, error)
+	CreateWebhookEvent(ctx context.Context, arg CreateWebhookEventParams) (WebhookEvent, error)
+	DeleteBlock(ctx context.Context, arg DeleteBlockParams) (int64, error)
+	DeleteMute(ctx context.Context, arg DeleteMuteParams) (int64, error)
+	DeletePendingEmailChanges(ctx context.Context, userID uuid.UUID) error
+	DeleteUsers(ctx context.Context) error
+	ExpireSubscriptions(ctx context.Context) ([]uuid.UUID, error)
+	ExtendSubscription(ctx context.Context, arg ExtendSubscriptionParams) (Subscription, error)
+	FailDataExport(ctx context.Context, id uuid.UUID) error
+	// LockUser holds the row of a user until the end of the transaction, so
+	// that the checks on their recent chirps see the previous ones.
+	LockUser(ctx context.Context, id uuid.UUID) error
+	MarkWebhookEvent(ctx context.Context, arg MarkWebhookEventParams) (WebhookEvent, error)
+	// Chirps with pending reports are kept until a moderator resolves them.
+	PurgeDeletedChirps(ctx context.Context, deletedBefore time.Time) (int64, error)
+	PurgeDeletedUsers(ctx context.Context, deletedBefore time.Time) (int64, error)
+	QueryActiveSubscription(ctx context.Context, userID uuid.UUID) (Subscription, error)
+	QueryAllAuthorChirps(ctx context.Context, arg QueryAllAuthorChirpsParams) ([]Chirp, error)
+	QueryAllAuthorChirpsIncludingDeleted(ctx context.Context, userID uuid.UUID) ([]Chirp, error)
+	// Listings are for viewer_id, the nil UUID for anonymous viewers: the
+	// chirps of shadow-banned users are only listed to themselves, and those of
+	// the users the viewer muted or blocked are left out. Held chirps are not
+	// listed until a moderator releases them.
+	QueryAllChirps(ctx context.Context, viewerID uuid.UUID) ([]Chirp, error)
+	// The IncludingDeleted queries are the moderators' view: tombstones and
+	// the chirps of deleted accounts too.
+	QueryAllChirpsIncludingDeleted(ctx context.Context) ([]Chirp, error)
+	QueryAuditChain(ctx context.Context) ([]AuditLog, error)
+	// QueryAuditLog lists entries newest first; NULL filters match anything.
+	QueryAuditLog(ctx context.Context, arg QueryAuditLogParams) ([]AuditLog, error)
+	QueryBlock(ctx context.Context, arg QueryBlockParams) (Block, error)
+	QueryBlocks(ctx context.Context, blockerID uuid.UUID) ([]Block, error)
+	// QueryChirp hides what QueryAllChirps does from the viewer but held
+	// chirps, which their author still gets to see.
+	QueryChirp(ctx context.Context, arg QueryChirpParams) (Chirp, error)
+	QueryChirpIncludingDeleted(ctx context.Context, id uuid.UUID) (Chirp, error)
+	QueryEmailChange(ctx context.Context, token string) (EmailChange, error)
+	QueryHeldChirps(ctx context.Context) ([]Chirp, error)
+	QueryLastAuditEntry(ctx context.Context) (AuditLog, error)
+	QueryLatestDataExport(ctx context.Context, userID uuid.UUID) (DataExport, error)
+	QueryMutes(ctx context.Context, muterID uuid.UUID) ([]Mute, error)
+	QueryPendingDataExports(ctx context.Context, staleBefore time.Time) ([]DataExport, error)
+	QueryPendingReports(ctx context.Context) ([]Report, error)
+	QueryRefreshToken(ctx context.Context, token string) (RefreshToken, error)
+	QueryReport(ctx context.Context, id uuid.UUID) (Report, error)
+	QueryReportEvents(ctx context.Context, reportID uuid.UUID) ([]ReportEvent, error)
+	QueryReportsByStatus(ctx context.Context, status string) ([]Report, error)
+	QueryUser(ctx context.Context, email string) (User, error)
+	QueryUserByID(ctx context.Context, id uuid.UUID) (User, error)
+	QueryUserRefreshTokens(ctx context.Context, userID uuid.UUID) ([]RefreshToken, error)
+	QueryUserSanctions(ctx context.Context, userID uuid.UUID) ([]Sanction, error)
+	QueryWebhookEvent(ctx context.Context, id string) (WebhookEvent, error)
+	ReleaseChirp(ctx context.Context, id uuid.UUID) (Chirp, error)
+	// ResolveChirpReports closes the other pending reports of a chirp along
+	// with the one a moderator resolved.
+	ResolveChirpReports(ctx context.Context, arg ResolveChirpReportsParams) ([]Report, error)
+	ResolveReport(ctx context.Context, arg ResolveReportParams) (Report, error)
+	RestoreChirp(ctx context.Context, id uuid.UUID) (Chirp, error)
+	RestoreUser(ctx context.Context, id uuid.UUID) (User, error)
+	RevokeToken(ctx context.Context, token string) (RefreshToken, error)
+	RevokeUserTokens(ctx context.Context, userID uuid.UUID) error
+	SetUserRole(ctx context.Context, arg SetUserRoleParams) (User, error)
+	SetUserStatus(ctx context.Context, arg SetUserStatusParams) (User, error)
+	SoftDeleteChirp(ctx context.Context, arg SoftDeleteChirpParams) (Chirp, error)
+	SoftDeleteUser(ctx context.Context, id uuid.UUID) (User, error)
+	// TryLockJob takes the advisory lock of a background job until the end of
+	// the transaction, so that only one replica runs it at a time. It returns
+	// false when another replica holds it.
+	TryLockJob(ctx context.Context, job string) (bool, error)
+	UpdateUserEmail(ctx context.Context, arg UpdateUserEmailParams) (User, error)
+	UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) (User, error)
+	UserFree(ctx context.Context, id uuid.UUID) (User, error)
+	UserPro(ctx context.Context, id uuid.UUID) (User, error)
+}
+
+var _ Querier = (*Queries)(nil)
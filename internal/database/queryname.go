@@ -0,0 +1,14 @@
+package database
+
+import "strings"
+
+// QueryName extracts the name sqlc puts at the top of every generated query
+// ("-- name: CreateUser :one"), or "unknown" for hand-written SQL.
+func QueryName(query string) string {
+	rest, ok := strings.CutPrefix(strings.TrimSpace(query), "-- name: ")
+	if !ok {
+		return "unknown"
+	}
+	name, _, _ := strings.Cut(rest, " ")
+	return name
+}
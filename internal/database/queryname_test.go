@@ -0,0 +1,16 @@
+package database
+
+import "testing"
+
+func TestQueryName(t *testing.T) {
+	tests := map[string]string{
+		createUser:  "CreateUser",
+		deleteUsers: "DeleteUsers",
+		"SELECT 1":  "unknown",
+	}
+	for query, want := range tests {
+		if got := QueryName(query); got != want {
+			t.Errorf("QueryName(%q) = %q, want %q", query, got, want)
+		}
+	}
+}
@@ -0,0 +1,2253 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.28.0
+// source: users.sql
+
+package sqlitedb
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const assignReport = `-- name: AssignReport :one
+UPDATE reports
+SET assignee_id = ?1, status = 'assigned', updated_at = ?2
+WHERE id = ?3 AND status IN ('open', 'assigned')
+RETURNING id, created_at, updated_at, reporter_id, user_id, chirp_id, reason, details, status, assignee_id, resolved_at
+`
+
+type AssignReportParams struct {
+	AssigneeID uuid.NullUUID
+	Now        time.Time
+	ID         uuid.UUID
+}
+
+func (q *Queries) AssignReport(ctx context.Context, arg AssignReportParams) (Report, error) {
+	row := q.db.QueryRowContext(ctx, assignReport, arg.AssigneeID, arg.Now, arg.ID)
+	var i Report
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.ReporterID,
+		&i.UserID,
+		&i.ChirpID,
+		&i.Reason,
+		&i.Details,
+		&i.Status,
+		&i.AssigneeID,
+		&i.ResolvedAt,
+	)
+	return i, err
+}
+
+const cancelSubscription = `-- name: CancelSubscription :exec
+UPDATE subscriptions
+SET status = 'canceled', current_period_end = ?1, updated_at = ?1
+WHERE user_id = ?2 AND status = 'active'
+`
+
+type CancelSubscriptionParams struct {
+	Now    time.Time
+	UserID uuid.UUID
+}
+
+func (q *Queries) CancelSubscription(ctx context.Context, arg CancelSubscriptionParams) error {
+	_, err := q.db.ExecContext(ctx, cancelSubscription, arg.Now, arg.UserID)
+	return err
+}
+
+const claimDataExport = `-- name: ClaimDataExport :one
+UPDATE data_exports
+SET status = 'running', updated_at = ?1
+WHERE id = ?2 AND (status = 'pending' OR status = 'running' AND updated_at < ?3)
+RETURNING id, created_at, updated_at, user_id, status, archive, completed_at
+`
+
+type ClaimDataExportParams struct {
+	Now         time.Time
+	ID          uuid.UUID
+	StaleBefore time.Time
+}
+
+func (q *Queries) ClaimDataExport(ctx context.Context, arg ClaimDataExportParams) (DataExport, error) {
+	row := q.db.QueryRowContext(ctx, claimDataExport, arg.Now, arg.ID, arg.StaleBefore)
+	var i DataExport
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.Status,
+		&i.Archive,
+		&i.CompletedAt,
+	)
+	return i, err
+}
+
+const claimWebhookEvent = `-- name: ClaimWebhookEvent :one
+UPDATE webhook_events
+SET status = 'processing', updated_at = ?1
+WHERE id = ?2 AND (status IN ('received', 'failed') OR status = 'processing' AND updated_at < ?3)
+RETURNING id, created_at, updated_at, event, payload, status, error, attempts, processed_at
+`
+
+type ClaimWebhookEventParams struct {
+	Now         time.Time
+	ID          string
+	StaleBefore time.Time
+}
+
+func (q *Queries) ClaimWebhookEvent(ctx context.Context, arg ClaimWebhookEventParams) (WebhookEvent, error) {
+	row := q.db.QueryRowContext(ctx, claimWebhookEvent, arg.Now, arg.ID, arg.StaleBefore)
+	var i WebhookEvent
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Event,
+		&i.Payload,
+		&i.Status,
+		&i.Error,
+		&i.Attempts,
+		&i.ProcessedAt,
+	)
+	return i, err
+}
+
+const completeDataExport = `-- name: CompleteDataExport :exec
+UPDATE data_exports
+SET status = 'ready', updated_at = ?1, archive = ?2, completed_at = ?1
+WHERE id = ?3
+`
+
+type CompleteDataExportParams struct {
+	Now     time.Time
+	Archive []byte
+	ID      uuid.UUID
+}
+
+func (q *Queries) CompleteDataExport(ctx context.Context, arg CompleteDataExportParams) error {
+	_, err := q.db.ExecContext(ctx, completeDataExport, arg.Now, arg.Archive, arg.ID)
+	return err
+}
+
+const confirmEmailChange = `-- name: ConfirmEmailChange :exec
+UPDATE email_changes
+SET updated_at = ?1, confirmed_at = ?1
+WHERE token = ?2
+`
+
+type ConfirmEmailChangeParams struct {
+	Now   time.Time
+	Token string
+}
+
+func (q *Queries) ConfirmEmailChange(ctx context.Context, arg ConfirmEmailChangeParams) error {
+	_, err := q.db.ExecContext(ctx, confirmEmailChange, arg.Now, arg.Token)
+	return err
+}
+
+const countChirpReporters = `-- name: CountChirpReporters :one
+SELECT COUNT(DISTINCT reports.reporter_id) FROM reports
+JOIN users ON users.id = reports.reporter_id
+WHERE reports.chirp_id = ?1 AND reports.status IN ('open', 'assigned')
+    AND users.deleted_at IS NULL
+    AND users.created_at < ?2
+    AND (users.status = 'active' OR users.status = 'suspended' AND users.suspended_until <= ?3)
+`
+
+type CountChirpReportersParams struct {
+	ChirpID       uuid.NullUUID
+	CreatedBefore time.Time
+	Now           sql.NullTime
+}
+
+func (q *Queries) CountChirpReporters(ctx context.Context, arg CountChirpReportersParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countChirpReporters, arg.ChirpID, arg.CreatedBefore, arg.Now)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const countDuplicateChirps = `-- name: CountDuplicateChirps :one
+SELECT COUNT(*) FROM chirps
+WHERE user_id = ?1 AND body_hash = ?2 AND created_at > ?3
+`
+
+type CountDuplicateChirpsParams struct {
+	UserID    uuid.UUID
+	BodyHash  string
+	CreatedAt time.Time
+}
+
+func (q *Queries) CountDuplicateChirps(ctx context.Context, arg CountDuplicateChirpsParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countDuplicateChirps, arg.UserID, arg.BodyHash, arg.CreatedAt)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const countUserChirpsSince = `-- name: CountUserChirpsSince :one
+
+SELECT COUNT(*) FROM chirps
+WHERE user_id = ?1 AND created_at > ?2
+`
+
+type CountUserChirpsSinceParams struct {
+	UserID    uuid.UUID
+	CreatedAt time.Time
+}
+
+// Counts for the flood checks; they include deleted and held chirps, so
+// that deleting chirps does not make room for more.
+func (q *Queries) CountUserChirpsSince(ctx context.Context, arg CountUserChirpsSinceParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countUserChirpsSince, arg.UserID, arg.CreatedAt)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const createAuditEntry = `-- name: CreateAuditEntry :one
+INSERT INTO audit_log (seq, created_at, actor_id, action, target_type, target_id, ip, request_id, before_state, after_state, prev_hash, hash)
+VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?7, ?8, ?9, ?10, ?11, ?12)
+RETURNING seq, created_at, actor_id, "action", target_type, target_id, ip, request_id, before_state, after_state, prev_hash, hash
+`
+
+type CreateAuditEntryParams struct {
+	Seq         int64
+	CreatedAt   time.Time
+	ActorID     uuid.NullUUID
+	Action      string
+	TargetType  string
+	TargetID    sql.NullString
+	Ip          sql.NullString
+	RequestID   sql.NullString
+	BeforeState sql.NullString
+	AfterState  sql.NullString
+	PrevHash    string
+	Hash        string
+}
+
+func (q *Queries) CreateAuditEntry(ctx context.Context, arg CreateAuditEntryParams) (AuditLog, error) {
+	row := q.db.QueryRowContext(ctx, createAuditEntry,
+		arg.Seq,
+		arg.CreatedAt,
+		arg.ActorID,
+		arg.Action,
+		arg.TargetType,
+		arg.TargetID,
+		arg.Ip,
+		arg.RequestID,
+		arg.BeforeState,
+		arg.AfterState,
+		arg.PrevHash,
+		arg.Hash,
+	)
+	var i AuditLog
+	err := row.Scan(
+		&i.Seq,
+		&i.CreatedAt,
+		&i.ActorID,
+		&i.Action,
+		&i.TargetType,
+		&i.TargetID,
+		&i.Ip,
+		&i.RequestID,
+		&i.BeforeState,
+		&i.AfterState,
+		&i.PrevHash,
+		&i.Hash,
+	)
+	return i, err
+}
+
+const createBlock = `-- name: CreateBlock :one
+INSERT INTO blocks (blocker_id, blocked_id, created_at)
+VALUES (?1, ?2, ?3)
+RETURNING blocker_id, blocked_id, created_at
+`
+
+type CreateBlockParams struct {
+	BlockerID uuid.UUID
+	BlockedID uuid.UUID
+	Now       time.Time
+}
+
+func (q *Queries) CreateBlock(ctx context.Context, arg CreateBlockParams) (Block, error) {
+	row := q.db.QueryRowContext(ctx, createBlock, arg.BlockerID, arg.BlockedID, arg.Now)
+	var i Block
+	err := row.Scan(&i.BlockerID, &i.BlockedID, &i.CreatedAt)
+	return i, err
+}
+
+const createChirp = `-- name: CreateChirp :one
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, body_hash, held_reason)
+VALUES (?1, ?2, ?2, ?3, ?4, ?5, ?6)
+RETURNING id, created_at, updated_at, body, user_id, deleted_at, deleted_by, deletion_reason, body_hash, held_reason
+`
+
+type CreateChirpParams struct {
+	ID         uuid.UUID
+	Now        time.Time
+	Body       string
+	UserID     uuid.UUID
+	BodyHash   string
+	HeldReason sql.NullString
+}
+
+func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, createChirp,
+		arg.ID,
+		arg.Now,
+		arg.Body,
+		arg.UserID,
+		arg.BodyHash,
+		arg.HeldReason,
+	)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.DeletedAt,
+		&i.DeletedBy,
+		&i.DeletionReason,
+		&i.BodyHash,
+		&i.HeldReason,
+	)
+	return i, err
+}
+
+const createDataExport = `-- name: CreateDataExport :one
+INSERT INTO data_exports (id, created_at, updated_at, user_id, status)
+VALUES (?1, ?2, ?2, ?3, 'pending')
+RETURNING id, created_at, updated_at, user_id, status, archive, completed_at
+`
+
+type CreateDataExportParams struct {
+	ID     uuid.UUID
+	Now    time.Time
+	UserID uuid.UUID
+}
+
+func (q *Queries) CreateDataExport(ctx context.Context, arg CreateDataExportParams) (DataExport, error) {
+	row := q.db.QueryRowContext(ctx, createDataExport, arg.ID, arg.Now, arg.UserID)
+	var i DataExport
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.Status,
+		&i.Archive,
+		&i.CompletedAt,
+	)
+	return i, err
+}
+
+const createEmailChange = `-- name: CreateEmailChange :one
+INSERT INTO email_changes (token, created_at, updated_at, user_id, new_email, expires_at)
+VALUES (?1, ?2, ?2, ?3, ?4, ?5)
+RETURNING token, created_at, updated_at, user_id, new_email, expires_at, confirmed_at
+`
+
+type CreateEmailChangeParams struct {
+	Token     string
+	Now       time.Time
+	UserID    uuid.UUID
+	NewEmail  string
+	ExpiresAt time.Time
+}
+
+func (q *Queries) CreateEmailChange(ctx context.Context, arg CreateEmailChangeParams) (EmailChange, error) {
+	row := q.db.QueryRowContext(ctx, createEmailChange,
+		arg.Token,
+		arg.Now,
+		arg.UserID,
+		arg.NewEmail,
+		arg.ExpiresAt,
+	)
+	var i EmailChange
+	err := row.Scan(
+		&i.Token,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.NewEmail,
+		&i.ExpiresAt,
+		&i.ConfirmedAt,
+	)
+	return i, err
+}
+
+const createMute = `-- name: CreateMute :one
+INSERT INTO mutes (muter_id, muted_id, created_at)
+VALUES (?1, ?2, ?3)
+RETURNING muter_id, muted_id, created_at
+`
+
+type CreateMuteParams struct {
+	MuterID uuid.UUID
+	MutedID uuid.UUID
+	Now     time.Time
+}
+
+func (q *Queries) CreateMute(ctx context.Context, arg CreateMuteParams) (Mute, error) {
+	row := q.db.QueryRowContext(ctx, createMute, arg.MuterID, arg.MutedID, arg.Now)
+	var i Mute
+	err := row.Scan(&i.MuterID, &i.MutedID, &i.CreatedAt)
+	return i, err
+}
+
+const createRefreshToken = `-- name: CreateRefreshToken :one
+INSERT INTO refresh_tokens (token, created_at, updated_at, user_id, expires_at)
+VALUES (?1, ?2, ?2, ?3, ?4)
+RETURNING token, created_at, updated_at, user_id, expires_at, revoked_at
+`
+
+type CreateRefreshTokenParams struct {
+	Token     string
+	Now       time.Time
+	UserID    uuid.UUID
+	ExpiresAt time.Time
+}
+
+func (q *Queries) CreateRefreshToken(ctx context.Context, arg CreateRefreshTokenParams) (RefreshToken, error) {
+	row := q.db.QueryRowContext(ctx, createRefreshToken,
+		arg.Token,
+		arg.Now,
+		arg.UserID,
+		arg.ExpiresAt,
+	)
+	var i RefreshToken
+	err := row.Scan(
+		&i.Token,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.ExpiresAt,
+		&i.RevokedAt,
+	)
+	return i, err
+}
+
+const createReport = `-- name: CreateReport :one
+INSERT INTO reports (id, created_at, updated_at, reporter_id, user_id, chirp_id, reason, details)
+VALUES (?1, ?2, ?2, ?3, ?4, ?5, ?6, ?7)
+RETURNING id, created_at, updated_at, reporter_id, user_id, chirp_id, reason, details, status, assignee_id, resolved_at
+`
+
+type CreateReportParams struct {
+	ID         uuid.UUID
+	Now        time.Time
+	ReporterID uuid.UUID
+	UserID     uuid.UUID
+	ChirpID    uuid.NullUUID
+	Reason     string
+	Details    sql.NullString
+}
+
+func (q *Queries) CreateReport(ctx context.Context, arg CreateReportParams) (Report, error) {
+	row := q.db.QueryRowContext(ctx, createReport,
+		arg.ID,
+		arg.Now,
+		arg.ReporterID,
+		arg.UserID,
+		arg.ChirpID,
+		arg.Reason,
+		arg.Details,
+	)
+	var i Report
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.ReporterID,
+		&i.UserID,
+		&i.ChirpID,
+		&i.Reason,
+		&i.Details,
+		&i.Status,
+		&i.AssigneeID,
+		&i.ResolvedAt,
+	)
+	return i, err
+}
+
+const createReportEvent = `-- name: CreateReportEvent :one
+INSERT INTO report_events (id, created_at, report_id, actor_id, action, note)
+VALUES (?1, ?2, ?3, ?4, ?5, ?6)
+RETURNING id, created_at, report_id, actor_id, "action", note
+`
+
+type CreateReportEventParams struct {
+	ID       uuid.UUID
+	Now      time.Time
+	ReportID uuid.UUID
+	ActorID  uuid.NullUUID
+	Action   string
+	Note     sql.NullString
+}
+
+func (q *Queries) CreateReportEvent(ctx context.Context, arg CreateReportEventParams) (ReportEvent, error) {
+	row := q.db.QueryRowContext(ctx, createReportEvent,
+		arg.ID,
+		arg.Now,
+		arg.ReportID,
+		arg.ActorID,
+		arg.Action,
+		arg.Note,
+	)
+	var i ReportEvent
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.ReportID,
+		&i.ActorID,
+		&i.Action,
+		&i.Note,
+	)
+	return i, err
+}
+
+const createSanction = `-- name: CreateSanction :one
+INSERT INTO sanctions (id, created_at, user_id, actor_id, status, suspended_until, reason)
+VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?7)
+RETURNING id, created_at, user_id, actor_id, status, suspended_until, reason
+`
+
+type CreateSanctionParams struct {
+	ID             uuid.UUID
+	Now            time.Time
+	UserID         uuid.UUID
+	ActorID        uuid.NullUUID
+	Status         string
+	SuspendedUntil sql.NullTime
+	Reason         string
+}
+
+func (q *Queries) CreateSanction(ctx context.Context, arg CreateSanctionParams) (Sanction, error) {
+	row := q.db.QueryRowContext(ctx, createSanction,
+		arg.ID,
+		arg.Now,
+		arg.UserID,
+		arg.ActorID,
+		arg.Status,
+		arg.SuspendedUntil,
+		arg.Reason,
+	)
+	var i Sanction
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UserID,
+		&i.ActorID,
+		&i.Status,
+		&i.SuspendedUntil,
+		&i.Reason,
+	)
+	return i, err
+}
+
+const createSubscription = `-- name: CreateSubscription :one
+INSERT INTO subscriptions (id, created_at, updated_at, user_id, plan, status, current_period_start, current_period_end)
+VALUES (?1, ?2, ?2, ?3, ?4, 'active', ?5, ?6)
+RETURNING id, created_at, updated_at, user_id, "plan", status, current_period_start, current_period_end
+`
+
+type CreateSubscriptionParams struct {
+	ID                 uuid.UUID
+	Now                time.Time
+	UserID             uuid.UUID
+	Plan               string
+	CurrentPeriodStart time.Time
+	CurrentPeriodEnd   time.Time
+}
+
+func (q *Queries) CreateSubscription(ctx context.Context, arg CreateSubscriptionParams) (Subscription, error) {
+	row := q.db.QueryRowContext(ctx, createSubscription,
+		arg.ID,
+		arg.Now,
+		arg.UserID,
+		arg.Plan,
+		arg.CurrentPeriodStart,
+		arg.CurrentPeriodEnd,
+	)
+	var i Subscription
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.Plan,
+		&i.Status,
+		&i.CurrentPeriodStart,
+		&i.CurrentPeriodEnd,
+	)
+	return i, err
+}
+
+const createUser = `-- name: CreateUser :one
+
+INSERT INTO users (id, created_at, updated_at, email, hashed_password)
+VALUES (?1, ?2, ?2, ?3, ?4)
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, role, deleted_at, status, suspended_until
+`
+
+type CreateUserParams struct {
+	ID             uuid.UUID
+	Now            time.Time
+	Email          string
+	HashedPassword string
+}
+
+// The queries of sql/queries/users.sql for SQLite. IDs and timestamps are
+// generated in Go and passed as @id and @now: SQLite has no
+// gen_random_uuid() and stores timestamps as text, which only compare
+// correctly when written in the same format.
+func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, createUser,
+		arg.ID,
+		arg.Now,
+		arg.Email,
+		arg.HashedPassword,
+	)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.Role,
+		&i.DeletedAt,
+		&i.Status,
+		&i.SuspendedUntil,
+	)
+	return i, err
+}
+
+const createWebhookEvent = `-- name: CreateWebhookEvent :one
+INSERT INTO webhook_events (id, created_at, updated_at, event, payload)
+VALUES (?1, ?2, ?2, ?3, ?4)
+ON CONFLICT (id) DO NOTHING
+RETURNING id, created_at, updated_at, event, payload, status, error, attempts, processed_at
+`
+
+type CreateWebhookEventParams struct {
+	ID      string
+	Now     time.Time
+	Event   string
+	Payload json.RawMessage
+}
+
+func (q *Queries) CreateWebhookEvent(ctx context.Context, arg CreateWebhookEventParams) (WebhookEvent, error) {
+	row := q.db.QueryRowContext(ctx, createWebhookEvent,
+		arg.ID,
+		arg.Now,
+		arg.Event,
+		arg.Payload,
+	)
+	var i WebhookEvent
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Event,
+		&i.Payload,
+		&i.Status,
+		&i.Error,
+		&i.Attempts,
+		&i.ProcessedAt,
+	)
+	return i, err
+}
+
+const deleteBlock = `-- name: DeleteBlock :execrows
+DELETE FROM blocks
+WHERE blocker_id = ?1 AND blocked_id = ?2
+`
+
+type DeleteBlockParams struct {
+	BlockerID uuid.UUID
+	BlockedID uuid.UUID
+}
+
+func (q *Queries) DeleteBlock(ctx context.Context, arg DeleteBlockParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteBlock, arg.BlockerID, arg.BlockedID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const deleteMute = `-- name: DeleteMute :execrows
+DELETE FROM mutes
+WHERE muter_id = ?1 AND muted_id = ?2
+`
+
+type DeleteMuteParams struct {
+	MuterID uuid.UUID
+	MutedID uuid.UUID
+}
+
+func (q *Queries) DeleteMute(ctx context.Context, arg DeleteMuteParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteMute, arg.MuterID, arg.MutedID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const deletePendingEmailChanges = `-- name: DeletePendingEmailChanges :exec
+DELETE FROM email_changes
+WHERE user_id = ?1 AND confirmed_at IS NULL
+`
+
+func (q *Queries) DeletePendingEmailChanges(ctx context.Context, userID uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deletePendingEmailChanges, userID)
+	return err
+}
+
+const deleteUsers = `-- name: DeleteUsers :exec
+DELETE FROM users
+`
+
+func (q *Queries) DeleteUsers(ctx context.Context) error {
+	_, err := q.db.ExecContext(ctx, deleteUsers)
+	return err
+}
+
+const downgradeUser = `-- name: DowngradeUser :exec
+UPDATE users
+SET is_chirpy_red = FALSE, updated_at = ?1
+WHERE id = ?2
+`
+
+type DowngradeUserParams struct {
+	Now time.Time
+	ID  uuid.UUID
+}
+
+func (q *Queries) DowngradeUser(ctx context.Context, arg DowngradeUserParams) error {
+	_, err := q.db.ExecContext(ctx, downgradeUser, arg.Now, arg.ID)
+	return err
+}
+
+const expireSubscriptions = `-- name: ExpireSubscriptions :many
+UPDATE subscriptions
+SET status = 'expired', updated_at = ?1
+WHERE status = 'active' AND current_period_end < ?1
+RETURNING user_id
+`
+
+// SQLite has no UPDATE in WITH: the store calls DowngradeUser for each
+// returned user in the same transaction.
+func (q *Queries) ExpireSubscriptions(ctx context.Context, now time.Time) ([]uuid.UUID, error) {
+	rows, err := q.db.QueryContext(ctx, expireSubscriptions, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []uuid.UUID
+	for rows.Next() {
+		var user_id uuid.UUID
+		if err := rows.Scan(&user_id); err != nil {
+			return nil, err
+		}
+		items = append(items, user_id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const extendSubscription = `-- name: ExtendSubscription :one
+UPDATE subscriptions
+SET current_period_end = ?1, updated_at = ?2
+WHERE id = ?3
+RETURNING id, created_at, updated_at, user_id, "plan", status, current_period_start, current_period_end
+`
+
+type ExtendSubscriptionParams struct {
+	CurrentPeriodEnd time.Time
+	Now              time.Time
+	ID               uuid.UUID
+}
+
+func (q *Queries) ExtendSubscription(ctx context.Context, arg ExtendSubscriptionParams) (Subscription, error) {
+	row := q.db.QueryRowContext(ctx, extendSubscription, arg.CurrentPeriodEnd, arg.Now, arg.ID)
+	var i Subscription
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.Plan,
+		&i.Status,
+		&i.CurrentPeriodStart,
+		&i.CurrentPeriodEnd,
+	)
+	return i, err
+}
+
+const failDataExport = `-- name: FailDataExport :exec
+UPDATE data_exports
+SET status = 'failed', updated_at = ?1, completed_at = ?1
+WHERE id = ?2
+`
+
+type FailDataExportParams struct {
+	Now time.Time
+	ID  uuid.UUID
+}
+
+func (q *Queries) FailDataExport(ctx context.Context, arg FailDataExportParams) error {
+	_, err := q.db.ExecContext(ctx, failDataExport, arg.Now, arg.ID)
+	return err
+}
+
+const lockUser = `-- name: LockUser :exec
+
+SELECT id FROM users
+WHERE id = ?1
+`
+
+// SQLite has no row locks; the store has a single connection, so its
+// transactions already run one at a time.
+func (q *Queries) LockUser(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, lockUser, id)
+	return err
+}
+
+const markWebhookEvent = `-- name: MarkWebhookEvent :one
+UPDATE webhook_events
+SET status = ?1, error = ?2, attempts = attempts + 1, updated_at = ?3, processed_at = ?3
+WHERE id = ?4
+RETURNING id, created_at, updated_at, event, payload, status, error, attempts, processed_at
+`
+
+type MarkWebhookEventParams struct {
+	Status string
+	Error  sql.NullString
+	Now    time.Time
+	ID     string
+}
+
+func (q *Queries) MarkWebhookEvent(ctx context.Context, arg MarkWebhookEventParams) (WebhookEvent, error) {
+	row := q.db.QueryRowContext(ctx, markWebhookEvent,
+		arg.Status,
+		arg.Error,
+		arg.Now,
+		arg.ID,
+	)
+	var i WebhookEvent
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Event,
+		&i.Payload,
+		&i.Status,
+		&i.Error,
+		&i.Attempts,
+		&i.ProcessedAt,
+	)
+	return i, err
+}
+
+const purgeDeletedChirps = `-- name: PurgeDeletedChirps :execrows
+DELETE FROM chirps
+WHERE deleted_at IS NOT NULL AND deleted_at < ?1
+    AND NOT EXISTS (
+        SELECT 1 FROM reports
+        WHERE reports.chirp_id = chirps.id AND reports.status IN ('open', 'assigned')
+    )
+`
+
+// Chirps with pending reports are kept until a moderator resolves them.
+func (q *Queries) PurgeDeletedChirps(ctx context.Context, deletedBefore sql.NullTime) (int64, error) {
+	result, err := q.db.ExecContext(ctx, purgeDeletedChirps, deletedBefore)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const purgeDeletedUsers = `-- name: PurgeDeletedUsers :execrows
+DELETE FROM users
+WHERE deleted_at IS NOT NULL AND deleted_at < ?1
+`
+
+func (q *Queries) PurgeDeletedUsers(ctx context.Context, deletedBefore sql.NullTime) (int64, error) {
+	result, err := q.db.ExecContext(ctx, purgeDeletedUsers, deletedBefore)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const queryActiveSubscription = `-- name: QueryActiveSubscription :one
+SELECT id, created_at, updated_at, user_id, "plan", status, current_period_start, current_period_end FROM subscriptions
+WHERE user_id = ?1 AND status = 'active'
+`
+
+func (q *Queries) QueryActiveSubscription(ctx context.Context, userID uuid.UUID) (Subscription, error) {
+	row := q.db.QueryRowContext(ctx, queryActiveSubscription, userID)
+	var i Subscription
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.Plan,
+		&i.Status,
+		&i.CurrentPeriodStart,
+		&i.CurrentPeriodEnd,
+	)
+	return i, err
+}
+
+const queryAllAuthorChirps = `-- name: QueryAllAuthorChirps :many
+SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.deleted_at, chirps.deleted_by, chirps.deletion_reason, chirps.body_hash, chirps.held_reason FROM chirps
+JOIN users ON users.id = chirps.user_id
+LEFT JOIN mutes ON mutes.muter_id = ?1 AND mutes.muted_id = chirps.user_id
+LEFT JOIN blocks ON blocks.blocker_id = ?1 AND blocks.blocked_id = chirps.user_id
+WHERE chirps.user_id = ?2 AND users.deleted_at IS NULL AND chirps.deleted_at IS NULL
+    AND (users.status <> 'shadow_banned' OR users.id = ?1)
+    AND mutes.muter_id IS NULL AND blocks.blocker_id IS NULL
+    AND chirps.held_reason IS NULL
+ORDER BY chirps.created_at ASC
+`
+
+type QueryAllAuthorChirpsParams struct {
+	ViewerID uuid.UUID
+	UserID   uuid.UUID
+}
+
+func (q *Queries) QueryAllAuthorChirps(ctx context.Context, arg QueryAllAuthorChirpsParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, queryAllAuthorChirps, arg.ViewerID, arg.UserID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.DeletedAt,
+			&i.DeletedBy,
+			&i.DeletionReason,
+			&i.BodyHash,
+			&i.HeldReason,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const queryAllAuthorChirpsIncludingDeleted = `-- name: QueryAllAuthorChirpsIncludingDeleted :many
+SELECT id, created_at, updated_at, body, user_id, deleted_at, deleted_by, deletion_reason, body_hash, held_reason FROM chirps
+WHERE user_id = ?1
+ORDER BY created_at ASC
+`
+
+func (q *Queries) QueryAllAuthorChirpsIncludingDeleted(ctx context.Context, userID uuid.UUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, queryAllAuthorChirpsIncludingDeleted, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.DeletedAt,
+			&i.DeletedBy,
+			&i.DeletionReason,
+			&i.BodyHash,
+			&i.HeldReason,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const queryAllChirps = `-- name: QueryAllChirps :many
+
+SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.deleted_at, chirps.deleted_by, chirps.deletion_reason, chirps.body_hash, chirps.held_reason FROM chirps
+JOIN users ON users.id = chirps.user_id
+LEFT JOIN mutes ON mutes.muter_id = ?1 AND mutes.muted_id = chirps.user_id
+LEFT JOIN blocks ON blocks.blocker_id = ?1 AND blocks.blocked_id = chirps.user_id
+WHERE users.deleted_at IS NULL AND chirps.deleted_at IS NULL
+    AND (users.status <> 'shadow_banned' OR users.id = ?1)
+    AND mutes.muter_id IS NULL AND blocks.blocker_id IS NULL
+    AND chirps.held_reason IS NULL
+ORDER BY chirps.created_at ASC
+`
+
+// Listings are for viewer_id, the nil UUID for anonymous viewers: the
+// chirps of shadow-banned users are only listed to themselves, and those of
+// the users the viewer muted or blocked are left out. Held chirps are not
+// listed until a moderator releases them.
+func (q *Queries) QueryAllChirps(ctx context.Context, viewerID uuid.UUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, queryAllChirps, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.DeletedAt,
+			&i.DeletedBy,
+			&i.DeletionReason,
+			&i.BodyHash,
+			&i.HeldReason,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const queryAllChirpsIncludingDeleted = `-- name: QueryAllChirpsIncludingDeleted :many
+SELECT id, created_at, updated_at, body, user_id, deleted_at, deleted_by, deletion_reason, body_hash, held_reason FROM chirps
+ORDER BY created_at ASC
+`
+
+func (q *Queries) QueryAllChirpsIncludingDeleted(ctx context.Context) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, queryAllChirpsIncludingDeleted)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.DeletedAt,
+			&i.DeletedBy,
+			&i.DeletionReason,
+			&i.BodyHash,
+			&i.HeldReason,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const queryAuditChain = `-- name: QueryAuditChain :many
+SELECT seq, created_at, actor_id, "action", target_type, target_id, ip, request_id, before_state, after_state, prev_hash, hash FROM audit_log
+ORDER BY seq ASC
+`
+
+func (q *Queries) QueryAuditChain(ctx context.Context) ([]AuditLog, error) {
+	rows, err := q.db.QueryContext(ctx, queryAuditChain)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []AuditLog
+	for rows.Next() {
+		var i AuditLog
+		if err := rows.Scan(
+			&i.Seq,
+			&i.CreatedAt,
+			&i.ActorID,
+			&i.Action,
+			&i.TargetType,
+			&i.TargetID,
+			&i.Ip,
+			&i.RequestID,
+			&i.BeforeState,
+			&i.AfterState,
+			&i.PrevHash,
+			&i.Hash,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const queryAuditLog = `-- name: QueryAuditLog :many
+SELECT seq, created_at, actor_id, "action", target_type, target_id, ip, request_id, before_state, after_state, prev_hash, hash FROM audit_log
+WHERE (?1 IS NULL OR actor_id = ?1)
+    AND (?2 IS NULL OR action = ?2)
+    AND (?3 IS NULL OR target_type = ?3)
+    AND (?4 IS NULL OR target_id = ?4)
+    AND (?5 IS NULL OR created_at >= ?5)
+    AND (?6 IS NULL OR created_at < ?6)
+    AND (?7 IS NULL OR seq < ?7)
+ORDER BY seq DESC
+LIMIT ?8
+`
+
+type QueryAuditLogParams struct {
+	ActorID    interface{}
+	Action     interface{}
+	TargetType interface{}
+	TargetID   interface{}
+	Since      interface{}
+	Until      interface{}
+	BeforeSeq  interface{}
+	MaxEntries int64
+}
+
+func (q *Queries) QueryAuditLog(ctx context.Context, arg QueryAuditLogParams) ([]AuditLog, error) {
+	rows, err := q.db.QueryContext(ctx, queryAuditLog,
+		arg.ActorID,
+		arg.Action,
+		arg.TargetType,
+		arg.TargetID,
+		arg.Since,
+		arg.Until,
+		arg.BeforeSeq,
+		arg.MaxEntries,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []AuditLog
+	for rows.Next() {
+		var i AuditLog
+		if err := rows.Scan(
+			&i.Seq,
+			&i.CreatedAt,
+			&i.ActorID,
+			&i.Action,
+			&i.TargetType,
+			&i.TargetID,
+			&i.Ip,
+			&i.RequestID,
+			&i.BeforeState,
+			&i.AfterState,
+			&i.PrevHash,
+			&i.Hash,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const queryBlock = `-- name: QueryBlock :one
+SELECT blocker_id, blocked_id, created_at FROM blocks
+WHERE blocker_id = ?1 AND blocked_id = ?2
+`
+
+type QueryBlockParams struct {
+	BlockerID uuid.UUID
+	BlockedID uuid.UUID
+}
+
+func (q *Queries) QueryBlock(ctx context.Context, arg QueryBlockParams) (Block, error) {
+	row := q.db.QueryRowContext(ctx, queryBlock, arg.BlockerID, arg.BlockedID)
+	var i Block
+	err := row.Scan(&i.BlockerID, &i.BlockedID, &i.CreatedAt)
+	return i, err
+}
+
+const queryBlocks = `-- name: QueryBlocks :many
+SELECT blocker_id, blocked_id, created_at FROM blocks
+WHERE blocker_id = ?1
+ORDER BY created_at ASC
+`
+
+func (q *Queries) QueryBlocks(ctx context.Context, blockerID uuid.UUID) ([]Block, error) {
+	rows, err := q.db.QueryContext(ctx, queryBlocks, blockerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Block
+	for rows.Next() {
+		var i Block
+		if err := rows.Scan(&i.BlockerID, &i.BlockedID, &i.CreatedAt); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const queryChirp = `-- name: QueryChirp :one
+
+SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.deleted_at, chirps.deleted_by, chirps.deletion_reason, chirps.body_hash, chirps.held_reason FROM chirps
+JOIN users ON users.id = chirps.user_id
+LEFT JOIN mutes ON mutes.muter_id = ?1 AND mutes.muted_id = chirps.user_id
+LEFT JOIN blocks ON blocks.blocker_id = ?1 AND blocks.blocked_id = chirps.user_id
+WHERE chirps.id = ?2 AND users.deleted_at IS NULL AND chirps.deleted_at IS NULL
+    AND (users.status <> 'shadow_banned' OR users.id = ?1)
+    AND mutes.muter_id IS NULL AND blocks.blocker_id IS NULL
+`
+
+type QueryChirpParams struct {
+	ViewerID uuid.UUID
+	ID       uuid.UUID
+}
+
+// QueryChirp hides what QueryAllChirps does from the viewer but held
+// chirps, which their author still gets to see.
+func (q *Queries) QueryChirp(ctx context.Context, arg QueryChirpParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, queryChirp, arg.ViewerID, arg.ID)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.DeletedAt,
+		&i.DeletedBy,
+		&i.DeletionReason,
+		&i.BodyHash,
+		&i.HeldReason,
+	)
+	return i, err
+}
+
+const queryChirpIncludingDeleted = `-- name: QueryChirpIncludingDeleted :one
+SELECT id, created_at, updated_at, body, user_id, deleted_at, deleted_by, deletion_reason, body_hash, held_reason FROM chirps
+WHERE id = ?1
+`
+
+func (q *Queries) QueryChirpIncludingDeleted(ctx context.Context, id uuid.UUID) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, queryChirpIncludingDeleted, id)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.DeletedAt,
+		&i.DeletedBy,
+		&i.DeletionReason,
+		&i.BodyHash,
+		&i.HeldReason,
+	)
+	return i, err
+}
+
+const queryEmailChange = `-- name: QueryEmailChange :one
+SELECT token, created_at, updated_at, user_id, new_email, expires_at, confirmed_at FROM email_changes
+WHERE token = ?1
+`
+
+func (q *Queries) QueryEmailChange(ctx context.Context, token string) (EmailChange, error) {
+	row := q.db.QueryRowContext(ctx, queryEmailChange, token)
+	var i EmailChange
+	err := row.Scan(
+		&i.Token,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.NewEmail,
+		&i.ExpiresAt,
+		&i.ConfirmedAt,
+	)
+	return i, err
+}
+
+const queryHeldChirps = `-- name: QueryHeldChirps :many
+SELECT id, created_at, updated_at, body, user_id, deleted_at, deleted_by, deletion_reason, body_hash, held_reason FROM chirps
+WHERE held_reason IS NOT NULL AND deleted_at IS NULL
+ORDER BY created_at ASC
+`
+
+func (q *Queries) QueryHeldChirps(ctx context.Context) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, queryHeldChirps)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.DeletedAt,
+			&i.DeletedBy,
+			&i.DeletionReason,
+			&i.BodyHash,
+			&i.HeldReason,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const queryLastAuditEntry = `-- name: QueryLastAuditEntry :one
+SELECT seq, created_at, actor_id, "action", target_type, target_id, ip, request_id, before_state, after_state, prev_hash, hash FROM audit_log
+ORDER BY seq DESC
+LIMIT 1
+`
+
+func (q *Queries) QueryLastAuditEntry(ctx context.Context) (AuditLog, error) {
+	row := q.db.QueryRowContext(ctx, queryLastAuditEntry)
+	var i AuditLog
+	err := row.Scan(
+		&i.Seq,
+		&i.CreatedAt,
+		&i.ActorID,
+		&i.Action,
+		&i.TargetType,
+		&i.TargetID,
+		&i.Ip,
+		&i.RequestID,
+		&i.BeforeState,
+		&i.AfterState,
+		&i.PrevHash,
+		&i.Hash,
+	)
+	return i, err
+}
+
+const queryLatestDataExport = `-- name: QueryLatestDataExport :one
+SELECT id, created_at, updated_at, user_id, status, archive, completed_at FROM data_exports
+WHERE user_id = ?1
+ORDER BY created_at DESC
+LIMIT 1
+`
+
+func (q *Queries) QueryLatestDataExport(ctx context.Context, userID uuid.UUID) (DataExport, error) {
+	row := q.db.QueryRowContext(ctx, queryLatestDataExport, userID)
+	var i DataExport
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.Status,
+		&i.Archive,
+		&i.CompletedAt,
+	)
+	return i, err
+}
+
+const queryMutes = `-- name: QueryMutes :many
+SELECT muter_id, muted_id, created_at FROM mutes
+WHERE muter_id = ?1
+ORDER BY created_at ASC
+`
+
+func (q *Queries) QueryMutes(ctx context.Context, muterID uuid.UUID) ([]Mute, error) {
+	rows, err := q.db.QueryContext(ctx, queryMutes, muterID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Mute
+	for rows.Next() {
+		var i Mute
+		if err := rows.Scan(&i.MuterID, &i.MutedID, &i.CreatedAt); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const queryPendingDataExports = `-- name: QueryPendingDataExports :many
+SELECT id, created_at, updated_at, user_id, status, archive, completed_at FROM data_exports
+WHERE status = 'pending' OR status = 'running' AND updated_at < ?1
+ORDER BY created_at ASC
+`
+
+func (q *Queries) QueryPendingDataExports(ctx context.Context, staleBefore time.Time) ([]DataExport, error) {
+	rows, err := q.db.QueryContext(ctx, queryPendingDataExports, staleBefore)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []DataExport
+	for rows.Next() {
+		var i DataExport
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.UserID,
+			&i.Status,
+			&i.Archive,
+			&i.CompletedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const queryPendingReports = `-- name: QueryPendingReports :many
+SELECT id, created_at, updated_at, reporter_id, user_id, chirp_id, reason, details, status, assignee_id, resolved_at FROM reports
+WHERE status IN ('open', 'assigned')
+ORDER BY created_at ASC
+`
+
+func (q *Queries) QueryPendingReports(ctx context.Context) ([]Report, error) {
+	rows, err := q.db.QueryContext(ctx, queryPendingReports)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Report
+	for rows.Next() {
+		var i Report
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.ReporterID,
+			&i.UserID,
+			&i.ChirpID,
+			&i.Reason,
+			&i.Details,
+			&i.Status,
+			&i.AssigneeID,
+			&i.ResolvedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const queryRefreshToken = `-- name: QueryRefreshToken :one
+SELECT token, created_at, updated_at, user_id, expires_at, revoked_at FROM refresh_tokens
+WHERE token = ?1
+`
+
+func (q *Queries) QueryRefreshToken(ctx context.Context, token string) (RefreshToken, error) {
+	row := q.db.QueryRowContext(ctx, queryRefreshToken, token)
+	var i RefreshToken
+	err := row.Scan(
+		&i.Token,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.ExpiresAt,
+		&i.RevokedAt,
+	)
+	return i, err
+}
+
+const queryReport = `-- name: QueryReport :one
+SELECT id, created_at, updated_at, reporter_id, user_id, chirp_id, reason, details, status, assignee_id, resolved_at FROM reports
+WHERE id = ?1
+`
+
+func (q *Queries) QueryReport(ctx context.Context, id uuid.UUID) (Report, error) {
+	row := q.db.QueryRowContext(ctx, queryReport, id)
+	var i Report
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.ReporterID,
+		&i.UserID,
+		&i.ChirpID,
+		&i.Reason,
+		&i.Details,
+		&i.Status,
+		&i.AssigneeID,
+		&i.ResolvedAt,
+	)
+	return i, err
+}
+
+const queryReportEvents = `-- name: QueryReportEvents :many
+SELECT id, created_at, report_id, actor_id, "action", note FROM report_events
+WHERE report_id = ?1
+ORDER BY created_at ASC
+`
+
+func (q *Queries) QueryReportEvents(ctx context.Context, reportID uuid.UUID) ([]ReportEvent, error) {
+	rows, err := q.db.QueryContext(ctx, queryReportEvents, reportID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ReportEvent
+	for rows.Next() {
+		var i ReportEvent
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.ReportID,
+			&i.ActorID,
+			&i.Action,
+			&i.Note,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const queryReportsByStatus = `-- name: QueryReportsByStatus :many
+SELECT id, created_at, updated_at, reporter_id, user_id, chirp_id, reason, details, status, assignee_id, resolved_at FROM reports
+WHERE status = ?1
+ORDER BY created_at ASC
+`
+
+func (q *Queries) QueryReportsByStatus(ctx context.Context, status string) ([]Report, error) {
+	rows, err := q.db.QueryContext(ctx, queryReportsByStatus, status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Report
+	for rows.Next() {
+		var i Report
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.ReporterID,
+			&i.UserID,
+			&i.ChirpID,
+			&i.Reason,
+			&i.Details,
+			&i.Status,
+			&i.AssigneeID,
+			&i.ResolvedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const queryUser = `-- name: QueryUser :one
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, role, deleted_at, status, suspended_until FROM users
+WHERE email = ?1
+`
+
+func (q *Queries) QueryUser(ctx context.Context, email string) (User, error) {
+	row := q.db.QueryRowContext(ctx, queryUser, email)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.Role,
+		&i.DeletedAt,
+		&i.Status,
+		&i.SuspendedUntil,
+	)
+	return i, err
+}
+
+const queryUserByID = `-- name: QueryUserByID :one
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, role, deleted_at, status, suspended_until FROM users
+WHERE id = ?1
+`
+
+func (q *Queries) QueryUserByID(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, queryUserByID, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.Role,
+		&i.DeletedAt,
+		&i.Status,
+		&i.SuspendedUntil,
+	)
+	return i, err
+}
+
+const queryUserRefreshTokens = `-- name: QueryUserRefreshTokens :many
+SELECT token, created_at, updated_at, user_id, expires_at, revoked_at FROM refresh_tokens
+WHERE user_id = ?1
+ORDER BY created_at ASC
+`
+
+func (q *Queries) QueryUserRefreshTokens(ctx context.Context, userID uuid.UUID) ([]RefreshToken, error) {
+	rows, err := q.db.QueryContext(ctx, queryUserRefreshTokens, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []RefreshToken
+	for rows.Next() {
+		var i RefreshToken
+		if err := rows.Scan(
+			&i.Token,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.UserID,
+			&i.ExpiresAt,
+			&i.RevokedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const queryUserSanctions = `-- name: QueryUserSanctions :many
+SELECT id, created_at, user_id, actor_id, status, suspended_until, reason FROM sanctions
+WHERE user_id = ?1
+ORDER BY created_at ASC
+`
+
+func (q *Queries) QueryUserSanctions(ctx context.Context, userID uuid.UUID) ([]Sanction, error) {
+	rows, err := q.db.QueryContext(ctx, queryUserSanctions, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Sanction
+	for rows.Next() {
+		var i Sanction
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UserID,
+			&i.ActorID,
+			&i.Status,
+			&i.SuspendedUntil,
+			&i.Reason,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const queryWebhookEvent = `-- name: QueryWebhookEvent :one
+SELECT id, created_at, updated_at, event, payload, status, error, attempts, processed_at FROM webhook_events
+WHERE id = ?1
+`
+
+func (q *Queries) QueryWebhookEvent(ctx context.Context, id string) (WebhookEvent, error) {
+	row := q.db.QueryRowContext(ctx, queryWebhookEvent, id)
+	var i WebhookEvent
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Event,
+		&i.Payload,
+		&i.Status,
+		&i.Error,
+		&i.Attempts,
+		&i.ProcessedAt,
+	)
+	return i, err
+}
+
+const releaseChirp = `-- name: ReleaseChirp :one
+UPDATE chirps
+SET held_reason = NULL, updated_at = ?1
+WHERE id = ?2 AND held_reason IS NOT NULL AND deleted_at IS NULL
+RETURNING id, created_at, updated_at, body, user_id, deleted_at, deleted_by, deletion_reason, body_hash, held_reason
+`
+
+type ReleaseChirpParams struct {
+	Now time.Time
+	ID  uuid.UUID
+}
+
+func (q *Queries) ReleaseChirp(ctx context.Context, arg ReleaseChirpParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, releaseChirp, arg.Now, arg.ID)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.DeletedAt,
+		&i.DeletedBy,
+		&i.DeletionReason,
+		&i.BodyHash,
+		&i.HeldReason,
+	)
+	return i, err
+}
+
+const resolveChirpReports = `-- name: ResolveChirpReports :many
+UPDATE reports
+SET status = ?1, updated_at = ?2, resolved_at = ?2
+WHERE chirp_id = ?3 AND status IN ('open', 'assigned')
+RETURNING id, created_at, updated_at, reporter_id, user_id, chirp_id, reason, details, status, assignee_id, resolved_at
+`
+
+type ResolveChirpReportsParams struct {
+	Status  string
+	Now     time.Time
+	ChirpID uuid.NullUUID
+}
+
+func (q *Queries) ResolveChirpReports(ctx context.Context, arg ResolveChirpReportsParams) ([]Report, error) {
+	rows, err := q.db.QueryContext(ctx, resolveChirpReports, arg.Status, arg.Now, arg.ChirpID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Report
+	for rows.Next() {
+		var i Report
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.ReporterID,
+			&i.UserID,
+			&i.ChirpID,
+			&i.Reason,
+			&i.Details,
+			&i.Status,
+			&i.AssigneeID,
+			&i.ResolvedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const resolveReport = `-- name: ResolveReport :one
+UPDATE reports
+SET status = ?1, updated_at = ?2, resolved_at = ?2
+WHERE id = ?3 AND status IN ('open', 'assigned')
+RETURNING id, created_at, updated_at, reporter_id, user_id, chirp_id, reason, details, status, assignee_id, resolved_at
+`
+
+type ResolveReportParams struct {
+	Status string
+	Now    time.Time
+	ID     uuid.UUID
+}
+
+func (q *Queries) ResolveReport(ctx context.Context, arg ResolveReportParams) (Report, error) {
+	row := q.db.QueryRowContext(ctx, resolveReport, arg.Status, arg.Now, arg.ID)
+	var i Report
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.ReporterID,
+		&i.UserID,
+		&i.ChirpID,
+		&i.Reason,
+		&i.Details,
+		&i.Status,
+		&i.AssigneeID,
+		&i.ResolvedAt,
+	)
+	return i, err
+}
+
+const restoreChirp = `-- name: RestoreChirp :one
+UPDATE chirps
+SET deleted_at = NULL, deleted_by = NULL, deletion_reason = NULL, updated_at = ?1
+WHERE id = ?2 AND deleted_at IS NOT NULL
+RETURNING id, created_at, updated_at, body, user_id, deleted_at, deleted_by, deletion_reason, body_hash, held_reason
+`
+
+type RestoreChirpParams struct {
+	Now time.Time
+	ID  uuid.UUID
+}
+
+func (q *Queries) RestoreChirp(ctx context.Context, arg RestoreChirpParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, restoreChirp, arg.Now, arg.ID)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.DeletedAt,
+		&i.DeletedBy,
+		&i.DeletionReason,
+		&i.BodyHash,
+		&i.HeldReason,
+	)
+	return i, err
+}
+
+const restoreUser = `-- name: RestoreUser :one
+UPDATE users
+SET deleted_at = NULL, updated_at = ?1
+WHERE id = ?2
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, role, deleted_at, status, suspended_until
+`
+
+type RestoreUserParams struct {
+	Now time.Time
+	ID  uuid.UUID
+}
+
+func (q *Queries) RestoreUser(ctx context.Context, arg RestoreUserParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, restoreUser, arg.Now, arg.ID)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.Role,
+		&i.DeletedAt,
+		&i.Status,
+		&i.SuspendedUntil,
+	)
+	return i, err
+}
+
+const revokeToken = `-- name: RevokeToken :one
+UPDATE refresh_tokens
+SET updated_at = ?1, revoked_at = ?1
+WHERE token = ?2
+RETURNING token, created_at, updated_at, user_id, expires_at, revoked_at
+`
+
+type RevokeTokenParams struct {
+	Now   time.Time
+	Token string
+}
+
+func (q *Queries) RevokeToken(ctx context.Context, arg RevokeTokenParams) (RefreshToken, error) {
+	row := q.db.QueryRowContext(ctx, revokeToken, arg.Now, arg.Token)
+	var i RefreshToken
+	err := row.Scan(
+		&i.Token,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.ExpiresAt,
+		&i.RevokedAt,
+	)
+	return i, err
+}
+
+const revokeUserTokens = `-- name: RevokeUserTokens :exec
+UPDATE refresh_tokens
+SET updated_at = ?1, revoked_at = ?1
+WHERE user_id = ?2 AND revoked_at IS NULL
+`
+
+type RevokeUserTokensParams struct {
+	Now    time.Time
+	UserID uuid.UUID
+}
+
+func (q *Queries) RevokeUserTokens(ctx context.Context, arg RevokeUserTokensParams) error {
+	_, err := q.db.ExecContext(ctx, revokeUserTokens, arg.Now, arg.UserID)
+	return err
+}
+
+const setUserRole = `-- name: SetUserRole :one
+UPDATE users
+SET role = ?1, updated_at = ?2
+WHERE id = ?3
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, role, deleted_at, status, suspended_until
+`
+
+type SetUserRoleParams struct {
+	Role string
+	Now  time.Time
+	ID   uuid.UUID
+}
+
+func (q *Queries) SetUserRole(ctx context.Context, arg SetUserRoleParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, setUserRole, arg.Role, arg.Now, arg.ID)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.Role,
+		&i.DeletedAt,
+		&i.Status,
+		&i.SuspendedUntil,
+	)
+	return i, err
+}
+
+const setUserStatus = `-- name: SetUserStatus :one
+UPDATE users
+SET status = ?1, suspended_until = ?2, updated_at = ?3
+WHERE id = ?4
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, role, deleted_at, status, suspended_until
+`
+
+type SetUserStatusParams struct {
+	Status         string
+	SuspendedUntil sql.NullTime
+	Now            time.Time
+	ID             uuid.UUID
+}
+
+func (q *Queries) SetUserStatus(ctx context.Context, arg SetUserStatusParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, setUserStatus,
+		arg.Status,
+		arg.SuspendedUntil,
+		arg.Now,
+		arg.ID,
+	)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.Role,
+		&i.DeletedAt,
+		&i.Status,
+		&i.SuspendedUntil,
+	)
+	return i, err
+}
+
+const softDeleteChirp = `-- name: SoftDeleteChirp :one
+UPDATE chirps
+SET updated_at = ?1, deleted_at = ?1, deleted_by = ?2, deletion_reason = ?3
+WHERE id = ?4 AND deleted_at IS NULL
+RETURNING id, created_at, updated_at, body, user_id, deleted_at, deleted_by, deletion_reason, body_hash, held_reason
+`
+
+type SoftDeleteChirpParams struct {
+	Now            time.Time
+	DeletedBy      uuid.NullUUID
+	DeletionReason sql.NullString
+	ID             uuid.UUID
+}
+
+func (q *Queries) SoftDeleteChirp(ctx context.Context, arg SoftDeleteChirpParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, softDeleteChirp,
+		arg.Now,
+		arg.DeletedBy,
+		arg.DeletionReason,
+		arg.ID,
+	)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.DeletedAt,
+		&i.DeletedBy,
+		&i.DeletionReason,
+		&i.BodyHash,
+		&i.HeldReason,
+	)
+	return i, err
+}
+
+const softDeleteUser = `-- name: SoftDeleteUser :one
+UPDATE users
+SET updated_at = ?1, deleted_at = ?1
+WHERE id = ?2
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, role, deleted_at, status, suspended_until
+`
+
+type SoftDeleteUserParams struct {
+	Now time.Time
+	ID  uuid.UUID
+}
+
+func (q *Queries) SoftDeleteUser(ctx context.Context, arg SoftDeleteUserParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, softDeleteUser, arg.Now, arg.ID)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.Role,
+		&i.DeletedAt,
+		&i.Status,
+		&i.SuspendedUntil,
+	)
+	return i, err
+}
+
+const updateUserEmail = `-- name: UpdateUserEmail :one
+UPDATE users
+SET email = ?1, updated_at = ?2
+WHERE id = ?3
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, role, deleted_at, status, suspended_until
+`
+
+type UpdateUserEmailParams struct {
+	Email string
+	Now   time.Time
+	ID    uuid.UUID
+}
+
+func (q *Queries) UpdateUserEmail(ctx context.Context, arg UpdateUserEmailParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, updateUserEmail, arg.Email, arg.Now, arg.ID)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.Role,
+		&i.DeletedAt,
+		&i.Status,
+		&i.SuspendedUntil,
+	)
+	return i, err
+}
+
+const updateUserPassword = `-- name: UpdateUserPassword :one
+UPDATE users
+SET hashed_password = ?1, updated_at = ?2
+WHERE id = ?3
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, role, deleted_at, status, suspended_until
+`
+
+type UpdateUserPasswordParams struct {
+	HashedPassword string
+	Now            time.Time
+	ID             uuid.UUID
+}
+
+func (q *Queries) UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, updateUserPassword, arg.HashedPassword, arg.Now, arg.ID)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.Role,
+		&i.DeletedAt,
+		&i.Status,
+		&i.SuspendedUntil,
+	)
+	return i, err
+}
+
+const userFree = `-- name: UserFree :one
+UPDATE users
+SET is_chirpy_red = FALSE
+WHERE id = ?1
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, role, deleted_at, status, suspended_until
+`
+
+func (q *Queries) UserFree(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, userFree, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.Role,
+		&i.DeletedAt,
+		&i.Status,
+		&i.SuspendedUntil,
+	)
+	return i, err
+}
+
+const userPro = `-- name: UserPro :one
+UPDATE users
+SET is_chirpy_red = TRUE
+WHERE id = ?1
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, role, deleted_at, status, suspended_until
+`
+
+func (q *Queries) UserPro(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, userPro, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.Role,
+		&i.DeletedAt,
+		&i.Status,
+		&i.SuspendedUntil,
+	)
+	return i, err
+}
@@ -0,0 +1,74 @@
+// Package entitlements decides what each Chirpy plan allows, so handlers can
+// ask "can this user do X" without knowing how plans are sold.
+package entitlements
+
+import "time"
+
+type Plan string
+
+const (
+	PlanFree      Plan = "free"
+	PlanChirpyRed Plan = "chirpy_red"
+)
+
+type Feature string
+
+const (
+	FeatureLongChirps  Feature = "long_chirps"
+	FeatureEditWindow  Feature = "edit_window"
+	FeatureAttachments Feature = "attachments"
+)
+
+// Limits are the quotas that come with a plan.
+type Limits struct {
+	MaxChirpLength  int
+	ChirpsPerMinute int
+	EditWindow      time.Duration
+	Attachments     bool
+}
+
+var limits = map[Plan]Limits{
+	PlanFree: {
+		MaxChirpLength:  140,
+		ChirpsPerMinute: 10,
+	},
+	PlanChirpyRed: {
+		MaxChirpLength:  280,
+		ChirpsPerMinute: 30,
+		EditWindow:      30 * time.Minute,
+		Attachments:     true,
+	},
+}
+
+// PlanFor returns the plan of a user from the plan of their active
+// subscription, "" when they have none.
+func PlanFor(subscription string) Plan {
+	if subscription == "" {
+		return PlanFree
+	}
+	return Plan(subscription)
+}
+
+// For returns the limits of plan; unknown plans get the free ones.
+func For(plan Plan) Limits {
+	l, ok := limits[plan]
+	if !ok {
+		return limits[PlanFree]
+	}
+	return l
+}
+
+// Can reports whether plan includes feature.
+func Can(plan Plan, feature Feature) bool {
+	l := For(plan)
+	free := limits[PlanFree]
+	switch feature {
+	case FeatureLongChirps:
+		return l.MaxChirpLength > free.MaxChirpLength
+	case FeatureEditWindow:
+		return l.EditWindow > 0
+	case FeatureAttachments:
+		return l.Attachments
+	}
+	return false
+}
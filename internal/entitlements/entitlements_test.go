@@ -0,0 +1,41 @@
+package entitlements
+
+import "testing"
+
+func TestCan(t *testing.T) {
+	tests := []struct {
+		plan    Plan
+		feature Feature
+		want    bool
+	}{
+		{PlanFree, FeatureLongChirps, false},
+		{PlanFree, FeatureEditWindow, false},
+		{PlanFree, FeatureAttachments, false},
+		{PlanChirpyRed, FeatureLongChirps, true},
+		{PlanChirpyRed, FeatureEditWindow, true},
+		{PlanChirpyRed, FeatureAttachments, true},
+		{Plan("gold"), FeatureLongChirps, false},
+	}
+
+	for _, tt := range tests {
+		if got := Can(tt.plan, tt.feature); got != tt.want {
+			t.Errorf("Can(%v, %v) = %v, want %v", tt.plan, tt.feature, got, tt.want)
+		}
+	}
+}
+
+func TestForMaxChirpLength(t *testing.T) {
+	if got := For(PlanFor("")).MaxChirpLength; got != 140 {
+		t.Errorf("free MaxChirpLength = %v, want 140", got)
+	}
+	if got := For(PlanFor("chirpy_red")).MaxChirpLength; got != 280 {
+		t.Errorf("Chirpy Red MaxChirpLength = %v, want 280", got)
+	}
+}
+
+func TestForChirpsPerMinute(t *testing.T) {
+	free, red := For(PlanFree).ChirpsPerMinute, For(PlanChirpyRed).ChirpsPerMinute
+	if free <= 0 || red <= free {
+		t.Errorf("ChirpsPerMinute free = %d, Chirpy Red = %d; want Chirpy Red to post more", free, red)
+	}
+}
@@ -0,0 +1,93 @@
+package httpx
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"Chirpy/internal/validate"
+)
+
+// DecodeJSON reads the body of req into dst, a pointer to a struct, and
+// checks it against the struct's validate tags. The body must hold exactly
+// one JSON object without unknown fields; its size is capped by the
+// server's body limit. On failure DecodeJSON writes the error response,
+// 400 for a body that cannot be read as dst and 422 for one breaking a
+// rule, and returns false.
+func DecodeJSON(res http.ResponseWriter, req *http.Request, dst any) bool {
+	decoder := json.NewDecoder(req.Body)
+	decoder.DisallowUnknownFields()
+
+	err := decoder.Decode(dst)
+	if err == nil && decoder.Decode(&struct{}{}) != io.EOF {
+		err = errTrailingData
+	}
+	if err != nil {
+		respondDecodeError(res, req, err)
+		return false
+	}
+
+	errs := validate.Struct(dst)
+	if len(errs) > 0 {
+		RespondInvalid(res, req, http.StatusUnprocessableEntity, CodeValidation, "the request has invalid fields", errs)
+		return false
+	}
+	return true
+}
+
+var errTrailingData = errors.New("trailing data")
+
+func respondDecodeError(res http.ResponseWriter, req *http.Request, err error) {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	var tooLarge *http.MaxBytesError
+
+	switch {
+	case errors.As(err, &tooLarge):
+		RespondError(res, req, http.StatusRequestEntityTooLarge, CodeBodyTooLarge,
+			fmt.Sprintf("the body exceeds %d bytes", tooLarge.Limit))
+	case errors.Is(err, io.EOF):
+		RespondError(res, req, http.StatusBadRequest, CodeInvalidJSON, "request body is empty")
+	case errors.Is(err, errTrailingData):
+		RespondError(res, req, http.StatusBadRequest, CodeInvalidJSON, "request body must contain a single JSON object")
+	case errors.As(err, &syntaxErr):
+		RespondError(res, req, http.StatusBadRequest, CodeInvalidJSON,
+			fmt.Sprintf("malformed JSON at offset %d", syntaxErr.Offset))
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		RespondInvalid(res, req, http.StatusBadRequest, CodeInvalidJSON, "a field has the wrong type", validate.Errors{{
+			Field:   typeErr.Field,
+			Rule:    "type",
+			Message: "must be a " + typeErr.Type.String(),
+		}})
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		//encoding/json non esporta un tipo per questo errore
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		RespondInvalid(res, req, http.StatusBadRequest, CodeInvalidJSON, "the body has unknown fields", validate.Errors{{
+			Field:   field,
+			Rule:    "unknown",
+			Message: "is not a known field",
+		}})
+	default:
+		RespondError(res, req, http.StatusBadRequest, CodeInvalidJSON, "request body is not valid JSON")
+	}
+}
+
+// ParseUUID parses value, the path or query parameter named field. On
+// failure it writes a 400 response naming the parameter and returns false.
+func ParseUUID(res http.ResponseWriter, req *http.Request, field, value string) (uuid.UUID, bool) {
+	id, err := uuid.Parse(value)
+	if err != nil {
+		RespondInvalid(res, req, http.StatusBadRequest, CodeInvalidRequest, "invalid identifier", validate.Errors{{
+			Field:   field,
+			Rule:    "uuid",
+			Message: "must be a UUID",
+		}})
+		return uuid.Nil, false
+	}
+	return id, true
+}
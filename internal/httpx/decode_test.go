@@ -0,0 +1,78 @@
+package httpx
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDecodeJSON(t *testing.T) {
+	type params struct {
+		Email    string `json:"email" validate:"required,email"`
+		Password string `json:"password" validate:"required"`
+	}
+
+	tests := []struct {
+		name       string
+		body       string
+		limit      int64
+		wantOK     bool
+		wantStatus int
+		wantFields []string
+	}{
+		{"valid", `{"email":"walt@breakingbad.com","password":"04234"}`, 1024, true, 200, nil},
+		{"empty", ``, 1024, false, 400, nil},
+		{"malformed", `{"email":`, 1024, false, 400, nil},
+		{"trailing data", `{"email":"walt@breakingbad.com","password":"x"} {}`, 1024, false, 400, nil},
+		{"unknown field", `{"email":"walt@breakingbad.com","password":"x","admin":true}`, 1024, false, 400, []string{"admin"}},
+		{"wrong type", `{"email":42,"password":"x"}`, 1024, false, 400, []string{"email"}},
+		{"invalid fields", `{"email":"walt"}`, 1024, false, 422, []string{"email", "password"}},
+		{"too large", `{"email":"walt@breakingbad.com","password":"04234"}`, 10, false, 413, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "/api/users", strings.NewReader(tt.body))
+			req.Body = http.MaxBytesReader(rec, req.Body, tt.limit)
+
+			var p params
+			ok := DecodeJSON(rec, req, &p)
+			if ok != tt.wantOK {
+				t.Fatalf("DecodeJSON() = %v, want %v", ok, tt.wantOK)
+			}
+			if ok {
+				return
+			}
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			var problem Problem
+			if err := json.Unmarshal(rec.Body.Bytes(), &problem); err != nil {
+				t.Fatal(err)
+			}
+			var fields []string
+			for _, fe := range problem.Errors {
+				fields = append(fields, fe.Field)
+			}
+			if strings.Join(fields, ",") != strings.Join(tt.wantFields, ",") {
+				t.Errorf("fields = %v, want %v", fields, tt.wantFields)
+			}
+		})
+	}
+}
+
+func TestParseUUID(t *testing.T) {
+	req := httptest.NewRequest("GET", "/api/chirps/nope", nil)
+	rec := httptest.NewRecorder()
+	if _, ok := ParseUUID(rec, req, "chirpID", "nope"); ok || rec.Code != 400 {
+		t.Errorf("ParseUUID(nope) ok = %v, status = %d; want false, 400", ok, rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	id, ok := ParseUUID(rec, req, "chirpID", "0c7f8a59-5f1e-4d4a-b0b3-3b8f1f0cf6a1")
+	if !ok || id.String() != "0c7f8a59-5f1e-4d4a-b0b3-3b8f1f0cf6a1" {
+		t.Errorf("ParseUUID() = %v, %v", id, ok)
+	}
+}
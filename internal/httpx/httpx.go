@@ -0,0 +1,103 @@
+// Package httpx writes the JSON responses of the API. Errors follow RFC 7807
+// (application/problem+json) and carry a machine-readable code, so clients
+// can tell failures apart without parsing the human-readable detail.
+package httpx
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"Chirpy/internal/logging"
+	"Chirpy/internal/validate"
+)
+
+// ContentTypeProblem is the media type of error responses.
+const ContentTypeProblem = "application/problem+json"
+
+// Codes identifying the kind of problem; they are part of the API and must
+// not change once published.
+const (
+	CodeInvalidJSON        = "invalid_json"
+	CodeInvalidRequest     = "invalid_request"
+	CodeValidation         = "validation_failed"
+	CodeBodyTooLarge       = "body_too_large"
+	CodeUnauthorized       = "unauthorized"
+	CodeInvalidCredentials = "invalid_credentials"
+	CodeInvalidSignature   = "invalid_signature"
+	CodeForbidden          = "forbidden"
+	CodeAccountSuspended   = "account_suspended"
+	CodeAccountBanned      = "account_banned"
+	CodeNotFound           = "not_found"
+	CodeConflict           = "conflict"
+	CodeConstraint         = "constraint_violation"
+	CodeGone               = "gone"
+	CodeChirpTooLong       = "chirp_too_long"
+	CodeDuplicateChirp     = "duplicate_chirp"
+	CodeChirpRejected      = "chirp_rejected"
+	CodeRateLimited        = "rate_limited"
+	CodeExportFailed       = "export_failed"
+	CodeInternal           = "internal_error"
+	CodeUnavailable        = "unavailable"
+)
+
+// Problem is the body of every error response.
+type Problem struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Code      string `json:"code"`
+	Detail    string `json:"detail,omitempty"`
+	Instance  string `json:"instance,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+
+	// Errors lists the offending fields of an invalid request.
+	Errors validate.Errors `json:"errors,omitempty"`
+}
+
+// ProblemType is the URI identifying the problem type of code.
+func ProblemType(code string) string {
+	return "urn:chirpy:problem:" + code
+}
+
+// RespondJSON writes v as the JSON body of a response with the given status.
+func RespondJSON(res http.ResponseWriter, req *http.Request, status int, v any) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		slog.ErrorContext(req.Context(), "cannot marshal response", "err", err)
+		RespondError(res, req, http.StatusInternalServerError, CodeInternal, "")
+		return
+	}
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(status)
+	res.Write(data)
+}
+
+// RespondError writes a problem response. detail explains this occurrence
+// to a human and may be empty; it must never contain secrets.
+func RespondError(res http.ResponseWriter, req *http.Request, status int, code, detail string) {
+	RespondInvalid(res, req, status, code, detail, nil)
+}
+
+// RespondInvalid writes a problem response listing the fields that make
+// the request invalid.
+func RespondInvalid(res http.ResponseWriter, req *http.Request, status int, code, detail string, errs validate.Errors) {
+	writeProblem(res, Problem{
+		Type:      ProblemType(code),
+		Title:     http.StatusText(status),
+		Status:    status,
+		Code:      code,
+		Detail:    detail,
+		Instance:  req.URL.Path,
+		RequestID: logging.RequestID(req.Context()),
+		Errors:    errs,
+	})
+}
+
+func writeProblem(res http.ResponseWriter, p Problem) {
+	data, _ := json.Marshal(p)
+	res.Header().Set("Content-Type", ContentTypeProblem)
+	res.Header().Set("X-Content-Type-Options", "nosniff")
+	res.WriteHeader(p.Status)
+	res.Write(data)
+}
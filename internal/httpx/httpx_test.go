@@ -0,0 +1,67 @@
+package httpx
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"Chirpy/internal/logging"
+)
+
+func TestRespondError(t *testing.T) {
+	req := httptest.NewRequest("GET", "/api/chirps/123", nil)
+	req = req.WithContext(logging.WithRequestID(req.Context(), "req-1"))
+	rec := httptest.NewRecorder()
+
+	RespondError(rec, req, http.StatusNotFound, CodeNotFound, "chirp not found")
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want 404", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != ContentTypeProblem {
+		t.Errorf("Content-Type = %q, want %q", ct, ContentTypeProblem)
+	}
+	var got Problem
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	want := Problem{
+		Type:      "urn:chirpy:problem:not_found",
+		Title:     "Not Found",
+		Status:    404,
+		Code:      CodeNotFound,
+		Detail:    "chirp not found",
+		Instance:  "/api/chirps/123",
+		RequestID: "req-1",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("problem = %+v, want %+v", got, want)
+	}
+}
+
+func TestRespondJSON(t *testing.T) {
+	tests := []struct {
+		name       string
+		value      any
+		wantStatus int
+		wantType   string
+	}{
+		{"object", map[string]string{"token": "abc"}, http.StatusCreated, "application/json"},
+		{"unmarshalable", map[string]any{"f": func() {}}, http.StatusInternalServerError, ContentTypeProblem},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/api/login", nil)
+			rec := httptest.NewRecorder()
+			RespondJSON(rec, req, http.StatusCreated, tt.value)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if ct := rec.Header().Get("Content-Type"); ct != tt.wantType {
+				t.Errorf("Content-Type = %q, want %q", ct, tt.wantType)
+			}
+		})
+	}
+}
@@ -0,0 +1,136 @@
+// Package logging sets up Chirpy's structured JSON logs. Every record goes
+// through a redaction step so that credentials never end up in the logs,
+// whatever the caller passes in.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"regexp"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+const redacted = "[REDACTED]"
+
+// sensitiveKeys are attribute keys whose values are always redacted; a key
+// matches when it contains one of them, ignoring case.
+var sensitiveKeys = []string{
+	"token",
+	"password",
+	"secret",
+	"authorization",
+	"api_key",
+	"apikey",
+	"cookie",
+	"signature",
+}
+
+// sensitiveValues catch credentials hidden in free text: JWTs, values of
+// Authorization headers and the hex refresh tokens.
+var sensitiveValues = []*regexp.Regexp{
+	regexp.MustCompile(`eyJ[A-Za-z0-9_-]*\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`),
+	regexp.MustCompile(`(?i)\b(bearer|apikey)\s+\S+`),
+	regexp.MustCompile(`\b[0-9a-fA-F]{64}\b`),
+}
+
+// New returns a logger writing JSON records of at least level to w.
+func New(w io.Writer, level slog.Leveler) *slog.Logger {
+	h := slog.NewJSONHandler(w, &slog.HandlerOptions{
+		Level:       level,
+		ReplaceAttr: redactAttr,
+	})
+	return slog.New(&contextHandler{h})
+}
+
+// ParseLevel turns a LOG_LEVEL value (debug, info, warn, error) into a
+// level; the empty string means info.
+func ParseLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	if s == "" {
+		return slog.LevelInfo, nil
+	}
+	err := level.UnmarshalText([]byte(s))
+	if err != nil {
+		return slog.LevelInfo, fmt.Errorf("invalid log level %q", s)
+	}
+	return level, nil
+}
+
+func redactAttr(groups []string, a slog.Attr) slog.Attr {
+	if a.Value.Kind() == slog.KindGroup {
+		return a
+	}
+	if isSensitiveKey(a.Key) {
+		return slog.String(a.Key, redacted)
+	}
+	switch a.Value.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, RedactString(a.Value.String()))
+	case slog.KindAny:
+		//errori e strutture possono contenere segreti nel loro testo
+		s := fmt.Sprintf("%+v", a.Value.Any())
+		if r := RedactString(s); r != s {
+			return slog.String(a.Key, r)
+		}
+	}
+	return a
+}
+
+func isSensitiveKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, k := range sensitiveKeys {
+		if strings.Contains(key, k) {
+			return true
+		}
+	}
+	return false
+}
+
+// RedactString masks every credential-looking substring of s.
+func RedactString(s string) string {
+	for _, re := range sensitiveValues {
+		s = re.ReplaceAllString(s, redacted)
+	}
+	return s
+}
+
+type ctxKey struct{}
+
+// WithRequestID returns a context whose log records carry requestID.
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, ctxKey{}, requestID)
+}
+
+// RequestID returns the request ID stored in ctx, if any.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(ctxKey{}).(string)
+	return id
+}
+
+// contextHandler adds the request ID and the trace of the context to every
+// record logged with one of the *Context methods.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h *contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := RequestID(ctx); id != "" {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		r.AddAttrs(slog.String("trace_id", sc.TraceID().String()), slog.String("span_id", sc.SpanID().String()))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,28 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestWriterMailer(t *testing.T) {
+	var buf bytes.Buffer
+	m := &WriterMailer{W: &buf}
+
+	err := m.Send(context.Background(), Message{
+		To:      "walt@breakingbad.com",
+		Subject: "Confirm your email",
+		Body:    "token: 1234",
+	})
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	for _, want := range []string{"To: walt@breakingbad.com", "Subject: Confirm your email", "token: 1234"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("Send() wrote %q, missing %q", buf.String(), want)
+		}
+	}
+}
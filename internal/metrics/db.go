@@ -0,0 +1,57 @@
+// Package metrics instruments the database with Prometheus metrics.
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"Chirpy/internal/database"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// DB times every query run on a database.DBTX, labelled with the sqlc query name.
+type DB struct {
+	db       database.DBTX
+	duration *prometheus.HistogramVec
+	errors   *prometheus.CounterVec
+}
+
+// InstrumentDB wraps db so that query durations are observed on duration
+// and failures counted on errors; both take a single "query" label.
+func InstrumentDB(db database.DBTX, duration *prometheus.HistogramVec, errors *prometheus.CounterVec) *DB {
+	return &DB{db: db, duration: duration, errors: errors}
+}
+
+func (d *DB) observe(query string, start time.Time, err error) {
+	name := database.QueryName(query)
+	d.duration.WithLabelValues(name).Observe(time.Since(start).Seconds())
+	if err != nil && err != sql.ErrNoRows {
+		d.errors.WithLabelValues(name).Inc()
+	}
+}
+
+func (d *DB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	start := time.Now()
+	res, err := d.db.ExecContext(ctx, query, args...)
+	d.observe(query, start, err)
+	return res, err
+}
+
+func (d *DB) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return d.db.PrepareContext(ctx, query)
+}
+
+func (d *DB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	start := time.Now()
+	rows, err := d.db.QueryContext(ctx, query, args...)
+	d.observe(query, start, err)
+	return rows, err
+}
+
+func (d *DB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	start := time.Now()
+	row := d.db.QueryRowContext(ctx, query, args...)
+	d.observe(query, start, row.Err())
+	return row
+}
@@ -0,0 +1,38 @@
+package metrics
+
+import (
+	"context"
+	"testing"
+
+	"Chirpy/internal/database"
+	"Chirpy/internal/sqlitetest"
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestInstrumentDB(t *testing.T) {
+	duration := prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: "query_duration_seconds"}, []string{"query"})
+	errors := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "query_errors_total"}, []string{"query"})
+	db := InstrumentDB(sqlitetest.NewDB(t), duration, errors)
+	ctx := context.Background()
+
+	//una riga mancante non è un errore della query
+	q := database.New(db)
+	if _, err := q.QueryUserByID(ctx, uuid.New()); err == nil {
+		t.Fatal("QueryUserByID() found an unknown user")
+	}
+	if _, err := db.ExecContext(ctx, "-- name: Broken :exec\nSELECT * FROM nope"); err == nil {
+		t.Fatal("ExecContext() ran a query on a missing table")
+	}
+
+	if n := testutil.CollectAndCount(duration); n != 2 {
+		t.Errorf("durations of %d queries, want 2", n)
+	}
+	if got := testutil.ToFloat64(errors.WithLabelValues("QueryUserByID")); got != 0 {
+		t.Errorf("QueryUserByID errors = %v, want 0", got)
+	}
+	if got := testutil.ToFloat64(errors.WithLabelValues("Broken")); got != 1 {
+		t.Errorf("Broken errors = %v, want 1", got)
+	}
+}
@@ -0,0 +1,101 @@
+// Package migrate applies the embedded goose migrations of sql/schema.
+// On Postgres every operation that changes the schema holds an advisory
+// lock, so replicas starting together run the migrations once; SQLite
+// databases have a single server and need none.
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/pressly/goose/v3"
+	"github.com/pressly/goose/v3/lock"
+
+	"Chirpy/sql/schema"
+)
+
+// ErrBehind is returned by Check when migrations known to this binary have
+// not been applied yet.
+var ErrBehind = errors.New("database schema is behind")
+
+// Migrator runs the migrations against one database.
+type Migrator struct {
+	provider *goose.Provider
+}
+
+// New prepares the migrations for db, opened with the database/sql driver
+// named driver ("postgres" or "sqlite"); it does not touch the database.
+func New(db *sql.DB, driver string) (*Migrator, error) {
+	opts := []goose.ProviderOption{goose.WithDisableGlobalRegistry(true)}
+	var provider *goose.Provider
+	var err error
+	switch driver {
+	case "postgres":
+		var locker lock.SessionLocker
+		locker, err = lock.NewPostgresSessionLocker()
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, goose.WithSessionLocker(locker))
+		provider, err = goose.NewProvider(goose.DialectPostgres, db, schema.FS, opts...)
+	case "sqlite":
+		provider, err = goose.NewProvider(goose.DialectSQLite3, db, schema.SQLiteFS, opts...)
+	default:
+		return nil, fmt.Errorf("no migrations for driver %q", driver)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{provider: provider}, nil
+}
+
+// Target is the version of the newest migration, the one this binary
+// expects the database to be at.
+func (m *Migrator) Target() int64 {
+	sources := m.provider.ListSources()
+	return sources[len(sources)-1].Version
+}
+
+// Up applies every pending migration.
+func (m *Migrator) Up(ctx context.Context) ([]*goose.MigrationResult, error) {
+	return m.provider.Up(ctx)
+}
+
+// Down rolls back the last applied migration.
+func (m *Migrator) Down(ctx context.Context) (*goose.MigrationResult, error) {
+	return m.provider.Down(ctx)
+}
+
+// Redo rolls back the last applied migration and applies it again.
+func (m *Migrator) Redo(ctx context.Context) ([]*goose.MigrationResult, error) {
+	down, err := m.provider.Down(ctx)
+	if err != nil {
+		return nil, err
+	}
+	up, err := m.provider.ApplyVersion(ctx, down.Source.Version, true)
+	if err != nil {
+		return []*goose.MigrationResult{down}, err
+	}
+	return []*goose.MigrationResult{down, up}, nil
+}
+
+// Status lists every migration with the time it was applied, if it was.
+func (m *Migrator) Status(ctx context.Context) ([]*goose.MigrationStatus, error) {
+	return m.provider.Status(ctx)
+}
+
+// Check returns ErrBehind when the database has not reached Target. A
+// database ahead of the binary is accepted: during a rolling deploy the
+// old replicas keep serving while the new ones have migrated.
+func (m *Migrator) Check(ctx context.Context) error {
+	current, target, err := m.provider.GetVersions(ctx)
+	if err != nil {
+		return err
+	}
+	if current < target {
+		return fmt.Errorf("%w: at version %d, expected %d", ErrBehind, current, target)
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	_ "modernc.org/sqlite"
+
+	"Chirpy/sql/schema"
+)
+
+func TestEmbeddedMigrations(t *testing.T) {
+	names := checkMigrations(t, schema.FS)
+
+	//le migrazioni SQLite seguono quelle Postgres una per una
+	sqliteNames := checkMigrations(t, schema.SQLiteFS)
+	if strings.Join(sqliteNames, ",") != strings.Join(names, ",") {
+		t.Errorf("SQLite migrations %v do not match the Postgres ones %v", sqliteNames, names)
+	}
+}
+
+func checkMigrations(t *testing.T, fsys fs.FS) []string {
+	t.Helper()
+	names, err := fs.Glob(fsys, "*.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) == 0 {
+		t.Fatal("no migrations embedded")
+	}
+
+	for i, name := range names {
+		want := fmt.Sprintf("%03d_", i+1)
+		if !strings.HasPrefix(name, want) {
+			t.Errorf("migration %s: want prefix %s, versions must be sequential", name, want)
+		}
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, annotation := range []string{"-- +goose Up", "-- +goose Down"} {
+			if !strings.Contains(string(data), annotation) {
+				t.Errorf("migration %s has no %q section", name, annotation)
+			}
+		}
+	}
+	return names
+}
+
+// openSQLite returns an empty SQLite database and its Migrator, which the
+// tests drive version by version.
+func openSQLite(t *testing.T) (*sql.DB, *Migrator) {
+	t.Helper()
+	db, err := sql.Open("sqlite", "file:"+filepath.Join(t.TempDir(), "chirpy.db")+"?_pragma=foreign_keys(1)&_time_format=sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	m, err := New(db, "sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db, m
+}
+
+func TestSubscriptionsBackfill(t *testing.T) {
+	db, m := openSQLite(t)
+	ctx := context.Background()
+	if _, err := m.provider.UpTo(ctx, 8); err != nil {
+		t.Fatal(err)
+	}
+	users := []struct {
+		email       string
+		red, delete bool
+	}{
+		{"walt@breakingbad.com", true, false},
+		{"jesse@breakingbad.com", false, false},
+		{"gus@lospollos.com", true, true},
+	}
+	for _, u := range users {
+		var deletedAt sql.NullTime
+		if u.delete {
+			deletedAt = sql.NullTime{Time: time.Now(), Valid: true}
+		}
+		_, err := db.Exec(`INSERT INTO users (id, created_at, updated_at, email, is_chirpy_red, deleted_at)
+			VALUES (?, ?, ?, ?, ?, ?)`, uuid.NewString(), time.Now(), time.Now(), u.email, u.red, deletedAt)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := m.provider.UpTo(ctx, 9); err != nil {
+		t.Fatal(err)
+	}
+
+	//solo i membri Red ancora attivi ricevono un abbonamento
+	var email, id string
+	var end time.Time
+	err := db.QueryRow(`SELECT users.email, subscriptions.id, subscriptions.current_period_end
+		FROM subscriptions JOIN users ON users.id = subscriptions.user_id
+		WHERE subscriptions.status = 'active'`).Scan(&email, &id, &end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if email != "walt@breakingbad.com" || uuid.Validate(id) != nil {
+		t.Errorf("backfilled subscription of %s with id %q", email, id)
+	}
+	if d := time.Until(end); d < 29*24*time.Hour || d > 31*24*time.Hour {
+		t.Errorf("backfilled period ends in %v, want 30 days", d)
+	}
+}
+
+func TestSQLiteDownAndUp(t *testing.T) {
+	_, m := openSQLite(t)
+	ctx := context.Background()
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.provider.DownTo(ctx, 0); err != nil {
+		t.Fatalf("down: %v", err)
+	}
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatalf("up again: %v", err)
+	}
+}
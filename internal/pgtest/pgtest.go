@@ -0,0 +1,180 @@
+// Package pgtest runs a throwaway Postgres cluster for integration tests.
+// It uses the initdb and pg_ctl binaries of the host and skips the tests
+// that need it when they cannot be found.
+//
+// A package using it starts the cluster lazily and stops it from TestMain:
+//
+//	func TestMain(m *testing.M) {
+//		os.Exit(pgtest.Run(m))
+//	}
+package pgtest
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	_ "github.com/lib/pq"
+
+	"Chirpy/internal/migrate"
+)
+
+// BinDirEnv names a directory holding initdb and pg_ctl, for hosts where
+// they are not on PATH, e.g. binaries unpacked by embedded-postgres.
+const BinDirEnv = "CHIRPY_PG_BIN"
+
+// errNoPostgres makes the tests skip instead of fail.
+var errNoPostgres = errors.New("postgres binaries not found")
+
+var (
+	startOnce sync.Once
+	shared    *cluster
+	startErr  error
+)
+
+// cluster is a Postgres server listening only on a unix socket in dir.
+type cluster struct {
+	dir   string
+	pgCtl string
+	admin *sql.DB
+	dbs   atomic.Int64
+}
+
+// Run runs the tests of m and stops the cluster if a test started it.
+func Run(m *testing.M) int {
+	code := m.Run()
+	if shared != nil {
+		shared.stop()
+	}
+	return code
+}
+
+// NewDB returns an empty database of the shared cluster with every
+// migration applied. It is dropped when t ends.
+func NewDB(t testing.TB) *sql.DB {
+	t.Helper()
+	startOnce.Do(func() {
+		shared, startErr = start()
+	})
+	if errors.Is(startErr, errNoPostgres) {
+		t.Skipf("skipping Postgres test: %v", startErr)
+	}
+	if startErr != nil {
+		t.Fatalf("cannot start Postgres: %v", startErr)
+	}
+
+	name := fmt.Sprintf("chirpy_test_%d", shared.dbs.Add(1))
+	_, err := shared.admin.Exec("CREATE DATABASE " + name)
+	if err != nil {
+		t.Fatalf("create database: %v", err)
+	}
+	db, err := sql.Open("postgres", shared.dsn(name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		db.Close()
+		shared.admin.Exec("DROP DATABASE IF EXISTS " + name)
+	})
+
+	migrator, err := migrate.New(db, "postgres")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = migrator.Up(context.Background())
+	if err != nil {
+		t.Fatalf("migrations: %v", err)
+	}
+	return db
+}
+
+// binDir finds the directory of initdb and pg_ctl: BinDirEnv, then PATH,
+// then the versioned directories used by Debian and the source install.
+func binDir() (string, error) {
+	if dir := os.Getenv(BinDirEnv); dir != "" {
+		return dir, nil
+	}
+	if path, err := exec.LookPath("pg_ctl"); err == nil {
+		return filepath.Dir(path), nil
+	}
+	dirs, _ := filepath.Glob("/usr/lib/postgresql/*/bin")
+	//la versione più recente per ultima
+	sort.Slice(dirs, func(i, j int) bool {
+		return len(dirs[i]) < len(dirs[j]) || len(dirs[i]) == len(dirs[j]) && dirs[i] < dirs[j]
+	})
+	dirs = append([]string{"/usr/local/pgsql/bin"}, dirs...)
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if _, err := os.Stat(filepath.Join(dirs[i], "pg_ctl")); err == nil {
+			return dirs[i], nil
+		}
+	}
+	return "", errNoPostgres
+}
+
+func start() (*cluster, error) {
+	bin, err := binDir()
+	if err != nil {
+		return nil, err
+	}
+	if os.Geteuid() == 0 {
+		return nil, fmt.Errorf("%w: postgres refuses to run as root", errNoPostgres)
+	}
+
+	//un percorso corto: i socket unix hanno un limite di ~100 caratteri
+	dir, err := os.MkdirTemp("", "chirpy-pg-")
+	if err != nil {
+		return nil, err
+	}
+	c := &cluster{dir: dir, pgCtl: filepath.Join(bin, "pg_ctl")}
+
+	data := filepath.Join(dir, "data")
+	out, err := exec.Command(filepath.Join(bin, "initdb"),
+		"-D", data, "-U", "postgres", "-A", "trust", "-E", "UTF8", "--no-sync").CombinedOutput()
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("initdb: %v\n%s", err, out)
+	}
+
+	out, err = exec.Command(c.pgCtl, "start", "-w", "-D", data,
+		"-l", filepath.Join(dir, "postgres.log"),
+		"-o", fmt.Sprintf("-k %s -c listen_addresses='' -F", dir)).CombinedOutput()
+	if err != nil {
+		log, _ := os.ReadFile(filepath.Join(dir, "postgres.log"))
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("pg_ctl start: %v\n%s%s", err, out, log)
+	}
+
+	c.admin, err = sql.Open("postgres", c.dsn("postgres"))
+	if err == nil {
+		err = c.admin.Ping()
+	}
+	if err != nil {
+		c.stop()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *cluster) dsn(dbname string) string {
+	return fmt.Sprintf("host=%s user=postgres dbname=%s sslmode=disable", c.dir, dbname)
+}
+
+func (c *cluster) stop() {
+	if c.admin != nil {
+		c.admin.Close()
+	}
+	out, err := exec.Command(c.pgCtl, "stop", "-m", "immediate", "-D", filepath.Join(c.dir, "data")).CombinedOutput()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "pgtest: pg_ctl stop: %v\n%s", err, strings.TrimSpace(string(out)))
+	}
+	os.RemoveAll(c.dir)
+}
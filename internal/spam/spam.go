@@ -0,0 +1,121 @@
+// Package spam decides whether a new chirp is published, held for a
+// moderator or refused. The flood checks that need the database, rate
+// limits and repeated posts, are done by the API; this package holds the
+// content checks and the Classifier they plug into.
+package spam
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/google/uuid"
+)
+
+type Verdict string
+
+const (
+	Allow  Verdict = "allow"
+	Hold   Verdict = "hold"
+	Reject Verdict = "reject"
+)
+
+// Submission is a chirp about to be created.
+type Submission struct {
+	UserID     uuid.UUID
+	Body       string
+	AccountAge time.Duration
+	ChirpyRed  bool
+}
+
+// Decision is the verdict of a Classifier; Reason explains a Hold or a
+// Reject to moderators and authors.
+type Decision struct {
+	Verdict Verdict
+	Reason  string
+}
+
+// Classifier judges submissions. When Classify fails the decision it
+// returned still applies, and the zero Decision allows the chirp: a
+// classifier being down must not stop everyone from posting.
+type Classifier interface {
+	Classify(ctx context.Context, s Submission) (Decision, error)
+}
+
+// ClassifierFunc adapts a function to Classifier.
+type ClassifierFunc func(ctx context.Context, s Submission) (Decision, error)
+
+func (f ClassifierFunc) Classify(ctx context.Context, s Submission) (Decision, error) {
+	return f(ctx, s)
+}
+
+// Chain asks its classifiers in order and returns the strictest decision;
+// the first Reject ends the chain. A failing classifier does not stop the
+// others, its error is returned with the decision.
+type Chain []Classifier
+
+func (c Chain) Classify(ctx context.Context, s Submission) (Decision, error) {
+	decision := Decision{Verdict: Allow}
+	var errs []error
+	for _, classifier := range c {
+		d, err := classifier.Classify(ctx, s)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		switch d.Verdict {
+		case Reject:
+			return d, errors.Join(errs...)
+		case Hold:
+			if decision.Verdict == Allow {
+				decision = d
+			}
+		}
+	}
+	return decision, errors.Join(errs...)
+}
+
+// Links holds the chirps with at least Hold links and rejects those with at
+// least Reject; a zero threshold is not applied.
+type Links struct {
+	Hold   int
+	Reject int
+}
+
+func (l Links) Classify(ctx context.Context, s Submission) (Decision, error) {
+	n := CountLinks(s.Body)
+	switch {
+	case l.Reject > 0 && n >= l.Reject:
+		return Decision{Verdict: Reject, Reason: fmt.Sprintf("%d links", n)}, nil
+	case l.Hold > 0 && n >= l.Hold:
+		return Decision{Verdict: Hold, Reason: fmt.Sprintf("%d links", n)}, nil
+	}
+	return Decision{Verdict: Allow}, nil
+}
+
+var linkPattern = regexp.MustCompile(`(?i)\b(?:https?://|www\.)\S+`)
+
+// CountLinks returns the number of http(s) and www. links in body.
+func CountLinks(body string) int {
+	return len(linkPattern.FindAllStringIndex(body, -1))
+}
+
+// Normalize reduces body to its lowercase words, so that changing the case,
+// the spacing or the punctuation of a chirp does not make it a new one.
+func Normalize(body string) string {
+	words := strings.FieldsFunc(strings.ToLower(body), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	return strings.Join(words, " ")
+}
+
+// Hash is the hex SHA-256 of the normalized body.
+func Hash(body string) string {
+	sum := sha256.Sum256([]byte(Normalize(body)))
+	return hex.EncodeToString(sum[:])
+}
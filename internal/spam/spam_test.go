@@ -0,0 +1,96 @@
+package spam
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestHash(t *testing.T) {
+	tests := []struct {
+		a, b string
+		same bool
+	}{
+		{"Say my name", "say my name", true},
+		{"Say my name", "  say,   my NAME!!", true},
+		{"Perché?", "perché", true},
+		{"say my name", "say my game", false},
+		{"saymyname", "say my name", false},
+	}
+	for _, tt := range tests {
+		if got := Hash(tt.a) == Hash(tt.b); got != tt.same {
+			t.Errorf("Hash(%q) == Hash(%q) is %v, want %v", tt.a, tt.b, got, tt.same)
+		}
+	}
+}
+
+func TestCountLinks(t *testing.T) {
+	tests := []struct {
+		body string
+		want int
+	}{
+		{"say my name", 0},
+		{"see https://example.com", 1},
+		{"HTTP://a.io and www.b.io, then http://c.io/x?y=1", 3},
+		{"https:// alone is not a link", 0},
+		{"example.com", 0},
+	}
+	for _, tt := range tests {
+		if got := CountLinks(tt.body); got != tt.want {
+			t.Errorf("CountLinks(%q) = %d, want %d", tt.body, got, tt.want)
+		}
+	}
+}
+
+func TestLinks(t *testing.T) {
+	links := Links{Hold: 2, Reject: 4}
+	tests := []struct {
+		body string
+		want Verdict
+	}{
+		{"http://a.io", Allow},
+		{"http://a.io http://b.io", Hold},
+		{"http://a.io http://b.io http://c.io http://d.io", Reject},
+	}
+	for _, tt := range tests {
+		d, err := links.Classify(context.Background(), Submission{Body: tt.body})
+		if err != nil || d.Verdict != tt.want {
+			t.Errorf("Classify(%q) = %+v, %v, want %s", tt.body, d, err, tt.want)
+		}
+	}
+	if d, _ := (Links{}).Classify(context.Background(), Submission{Body: "http://a.io http://b.io"}); d.Verdict != Allow {
+		t.Errorf("zero Links = %+v, want allow", d)
+	}
+}
+
+func TestChain(t *testing.T) {
+	verdict := func(v Verdict) Classifier {
+		return ClassifierFunc(func(context.Context, Submission) (Decision, error) {
+			return Decision{Verdict: v, Reason: string(v)}, nil
+		})
+	}
+	failing := ClassifierFunc(func(context.Context, Submission) (Decision, error) {
+		return Decision{}, errors.New("down")
+	})
+	tests := []struct {
+		name    string
+		chain   Chain
+		want    Verdict
+		wantErr bool
+	}{
+		{"empty", Chain{}, Allow, false},
+		{"allow", Chain{verdict(Allow), verdict(Allow)}, Allow, false},
+		{"hold", Chain{verdict(Allow), verdict(Hold)}, Hold, false},
+		{"reject wins", Chain{verdict(Hold), verdict(Reject), verdict(Allow)}, Reject, false},
+		{"error", Chain{verdict(Hold), failing}, Hold, true},
+		{"reject after an error", Chain{failing, verdict(Reject)}, Reject, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := tt.chain.Classify(context.Background(), Submission{})
+			if d.Verdict != tt.want || (err != nil) != tt.wantErr {
+				t.Errorf("Classify() = %+v, %v, want %s", d, err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,40 @@
+// Package sqlitetest opens SQLite databases for tests, the counterpart of
+// pgtest for the SQLite store. It needs nothing installed on the host.
+package sqlitetest
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	_ "modernc.org/sqlite"
+
+	"Chirpy/internal/migrate"
+	"Chirpy/internal/store"
+)
+
+// NewDB returns an empty database, in a file under t.TempDir, with every
+// migration applied. It is closed when t ends.
+func NewDB(t testing.TB) *sql.DB {
+	t.Helper()
+	driver, dsn, err := store.ParseURL("sqlite:" + filepath.Join(t.TempDir(), "chirpy.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	migrator, err := migrate.New(db, driver)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = migrator.Up(context.Background())
+	if err != nil {
+		t.Fatalf("migrations: %v", err)
+	}
+	return db
+}
@@ -0,0 +1,56 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// Errors for writes breaking a constraint of the schema. Memory returns
+// them directly; Classify recognizes them in the errors of the drivers.
+var (
+	ErrUniqueViolation     = errors.New("unique constraint violated")
+	ErrForeignKeyViolation = errors.New("foreign key constraint violated")
+	ErrCheckViolation      = errors.New("check constraint violated")
+)
+
+// Classify wraps the error of a failed query in the constraint error it
+// stands for, whatever the backend, so that callers can test it with
+// errors.Is. Other errors are returned unchanged.
+func Classify(err error) error {
+	if err == nil {
+		return nil
+	}
+	var kind error
+	var pqErr *pq.Error
+	var sqliteErr *sqlite.Error
+	switch {
+	case errors.Is(err, ErrUniqueViolation), errors.Is(err, ErrForeignKeyViolation), errors.Is(err, ErrCheckViolation):
+		return err
+	case errors.As(err, &pqErr):
+		switch pqErr.Code.Name() {
+		case "unique_violation":
+			kind = ErrUniqueViolation
+		case "foreign_key_violation":
+			kind = ErrForeignKeyViolation
+		case "check_violation":
+			kind = ErrCheckViolation
+		}
+	case errors.As(err, &sqliteErr):
+		switch sqliteErr.Code() {
+		case sqlite3.SQLITE_CONSTRAINT_UNIQUE, sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY:
+			kind = ErrUniqueViolation
+		case sqlite3.SQLITE_CONSTRAINT_FOREIGNKEY:
+			kind = ErrForeignKeyViolation
+		case sqlite3.SQLITE_CONSTRAINT_CHECK:
+			kind = ErrCheckViolation
+		}
+	}
+	if kind == nil {
+		return err
+	}
+	return fmt.Errorf("%w: %w", kind, err)
+}
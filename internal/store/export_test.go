@@ -0,0 +1,5 @@
+package store
+
+// RunSuite lets the external tests, which can import the test helpers that
+// import this package, run the shared suite.
+var RunSuite = testStore
@@ -0,0 +1,1239 @@
+package store
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"Chirpy/internal/database"
+)
+
+// Memory is a Store keeping its tables in memory. It mirrors the queries in
+// sql/queries and the constraints in sql/schema closely enough for handler
+// tests, and is not meant for production: nothing survives a restart.
+type Memory struct {
+	// Now is the clock of the database, time.Now by default.
+	Now func() time.Time
+
+	txMu sync.Mutex
+	mu   sync.Mutex
+	t    tables
+}
+
+// tables hold rows in insertion order, which is also creation order.
+type tables struct {
+	users         []database.User
+	chirps        []database.Chirp
+	refreshTokens []database.RefreshToken
+	dataExports   []database.DataExport
+	emailChanges  []database.EmailChange
+	webhookEvents []database.WebhookEvent
+	subscriptions []database.Subscription
+	reports       []database.Report
+	reportEvents  []database.ReportEvent
+	sanctions     []database.Sanction
+	blocks        []database.Block
+	mutes         []database.Mute
+	auditLog      []database.AuditLog
+}
+
+func (t tables) clone() tables {
+	return tables{
+		users:         slices.Clone(t.users),
+		chirps:        slices.Clone(t.chirps),
+		refreshTokens: slices.Clone(t.refreshTokens),
+		dataExports:   slices.Clone(t.dataExports),
+		emailChanges:  slices.Clone(t.emailChanges),
+		webhookEvents: slices.Clone(t.webhookEvents),
+		subscriptions: slices.Clone(t.subscriptions),
+		reports:       slices.Clone(t.reports),
+		reportEvents:  slices.Clone(t.reportEvents),
+		sanctions:     slices.Clone(t.sanctions),
+		blocks:        slices.Clone(t.blocks),
+		mutes:         slices.Clone(t.mutes),
+		auditLog:      slices.Clone(t.auditLog),
+	}
+}
+
+func NewMemory() *Memory {
+	return &Memory{Now: time.Now}
+}
+
+var _ Store = (*Memory)(nil)
+
+// WithTx runs transactions one at a time and restores the tables when fn
+// fails. Writes made outside transactions meanwhile are not isolated.
+func (m *Memory) WithTx(ctx context.Context, fn func(q database.Querier) error) error {
+	m.txMu.Lock()
+	defer m.txMu.Unlock()
+
+	m.mu.Lock()
+	snapshot := m.t.clone()
+	m.mu.Unlock()
+
+	err := fn(m)
+	if err != nil {
+		m.mu.Lock()
+		m.t = snapshot
+		m.mu.Unlock()
+	}
+	return err
+}
+
+func (m *Memory) Ping(ctx context.Context) error {
+	return nil
+}
+
+// SchemaVersion reports the memory store as always up to date.
+func (m *Memory) SchemaVersion(ctx context.Context) (int64, error) {
+	return math.MaxInt64, nil
+}
+
+// now truncates to microseconds like Postgres TIMESTAMP columns.
+func (m *Memory) now() time.Time {
+	return m.Now().UTC().Truncate(time.Microsecond)
+}
+
+func (m *Memory) lock() func() {
+	m.mu.Lock()
+	return m.mu.Unlock
+}
+
+func (m *Memory) userIndex(id uuid.UUID) int {
+	return slices.IndexFunc(m.t.users, func(u database.User) bool { return u.ID == id })
+}
+
+func (m *Memory) requireUser(id uuid.UUID, table string) error {
+	if m.userIndex(id) < 0 {
+		return fmt.Errorf("%w: %s.user_id", ErrForeignKeyViolation, table)
+	}
+	return nil
+}
+
+// updateUser applies fn to the user with the given id and returns it.
+func (m *Memory) updateUser(id uuid.UUID, fn func(u *database.User)) (database.User, error) {
+	defer m.lock()()
+	i := m.userIndex(id)
+	if i < 0 {
+		return database.User{}, sql.ErrNoRows
+	}
+	fn(&m.t.users[i])
+	return m.t.users[i], nil
+}
+
+// deleteUsers removes the users matching del and, like ON DELETE CASCADE,
+// every row referencing them.
+func (m *Memory) deleteUsers(del func(u database.User) bool) int64 {
+	deleted := map[uuid.UUID]bool{}
+	m.t.users = slices.DeleteFunc(m.t.users, func(u database.User) bool {
+		if del(u) {
+			deleted[u.ID] = true
+			return true
+		}
+		return false
+	})
+	m.t.chirps = slices.DeleteFunc(m.t.chirps, func(c database.Chirp) bool { return deleted[c.UserID] })
+	m.t.refreshTokens = slices.DeleteFunc(m.t.refreshTokens, func(t database.RefreshToken) bool { return deleted[t.UserID] })
+	m.t.dataExports = slices.DeleteFunc(m.t.dataExports, func(e database.DataExport) bool { return deleted[e.UserID] })
+	m.t.emailChanges = slices.DeleteFunc(m.t.emailChanges, func(c database.EmailChange) bool { return deleted[c.UserID] })
+	m.t.subscriptions = slices.DeleteFunc(m.t.subscriptions, func(s database.Subscription) bool { return deleted[s.UserID] })
+	m.t.sanctions = slices.DeleteFunc(m.t.sanctions, func(s database.Sanction) bool { return deleted[s.UserID] })
+	m.t.blocks = slices.DeleteFunc(m.t.blocks, func(b database.Block) bool { return deleted[b.BlockerID] || deleted[b.BlockedID] })
+	m.t.mutes = slices.DeleteFunc(m.t.mutes, func(mu database.Mute) bool { return deleted[mu.MuterID] || deleted[mu.MutedID] })
+	m.deleteReports(func(r database.Report) bool { return deleted[r.ReporterID] || deleted[r.UserID] })
+	//ON DELETE SET NULL
+	for i, r := range m.t.reports {
+		if deleted[r.AssigneeID.UUID] {
+			m.t.reports[i].AssigneeID = uuid.NullUUID{}
+		}
+	}
+	for i, e := range m.t.reportEvents {
+		if deleted[e.ActorID.UUID] {
+			m.t.reportEvents[i].ActorID = uuid.NullUUID{}
+		}
+	}
+	for i, s := range m.t.sanctions {
+		if deleted[s.ActorID.UUID] {
+			m.t.sanctions[i].ActorID = uuid.NullUUID{}
+		}
+	}
+	return int64(len(deleted))
+}
+
+// deleteReports removes the reports matching del and their events.
+func (m *Memory) deleteReports(del func(r database.Report) bool) {
+	deleted := map[uuid.UUID]bool{}
+	m.t.reports = slices.DeleteFunc(m.t.reports, func(r database.Report) bool {
+		if del(r) {
+			deleted[r.ID] = true
+			return true
+		}
+		return false
+	})
+	m.t.reportEvents = slices.DeleteFunc(m.t.reportEvents, func(e database.ReportEvent) bool { return deleted[e.ReportID] })
+}
+
+// Users
+
+func (m *Memory) CreateUser(ctx context.Context, arg database.CreateUserParams) (database.User, error) {
+	defer m.lock()()
+	if slices.ContainsFunc(m.t.users, func(u database.User) bool { return u.Email == arg.Email }) {
+		return database.User{}, fmt.Errorf("%w: users.email", ErrUniqueViolation)
+	}
+	now := m.now()
+	user := database.User{
+		ID:             uuid.New(),
+		CreatedAt:      now,
+		UpdatedAt:      now,
+		Email:          arg.Email,
+		HashedPassword: arg.HashedPassword,
+		Role:           "user",
+		Status:         "active",
+	}
+	m.t.users = append(m.t.users, user)
+	return user, nil
+}
+
+func (m *Memory) QueryUser(ctx context.Context, email string) (database.User, error) {
+	defer m.lock()()
+	i := slices.IndexFunc(m.t.users, func(u database.User) bool { return u.Email == email })
+	if i < 0 {
+		return database.User{}, sql.ErrNoRows
+	}
+	return m.t.users[i], nil
+}
+
+func (m *Memory) QueryUserByID(ctx context.Context, id uuid.UUID) (database.User, error) {
+	defer m.lock()()
+	i := m.userIndex(id)
+	if i < 0 {
+		return database.User{}, sql.ErrNoRows
+	}
+	return m.t.users[i], nil
+}
+
+// LockUser has nothing to do: WithTx already runs one transaction at a
+// time.
+func (m *Memory) LockUser(ctx context.Context, id uuid.UUID) error {
+	return nil
+}
+
+func (m *Memory) UpdateUserPassword(ctx context.Context, arg database.UpdateUserPasswordParams) (database.User, error) {
+	return m.updateUser(arg.ID, func(u *database.User) {
+		u.HashedPassword = arg.HashedPassword
+		u.UpdatedAt = m.now()
+	})
+}
+
+func (m *Memory) UpdateUserEmail(ctx context.Context, arg database.UpdateUserEmailParams) (database.User, error) {
+	m.mu.Lock()
+	taken := slices.ContainsFunc(m.t.users, func(u database.User) bool { return u.Email == arg.Email && u.ID != arg.ID })
+	m.mu.Unlock()
+	if taken {
+		return database.User{}, fmt.Errorf("%w: users.email", ErrUniqueViolation)
+	}
+	return m.updateUser(arg.ID, func(u *database.User) {
+		u.Email = arg.Email
+		u.UpdatedAt = m.now()
+	})
+}
+
+func (m *Memory) UserPro(ctx context.Context, id uuid.UUID) (database.User, error) {
+	return m.updateUser(id, func(u *database.User) { u.IsChirpyRed = true })
+}
+
+func (m *Memory) UserFree(ctx context.Context, id uuid.UUID) (database.User, error) {
+	return m.updateUser(id, func(u *database.User) { u.IsChirpyRed = false })
+}
+
+func (m *Memory) SetUserRole(ctx context.Context, arg database.SetUserRoleParams) (database.User, error) {
+	switch arg.Role {
+	case "user", "moderator", "admin":
+	default:
+		return database.User{}, fmt.Errorf("%w: users.role", ErrCheckViolation)
+	}
+	return m.updateUser(arg.ID, func(u *database.User) {
+		u.Role = arg.Role
+		u.UpdatedAt = m.now()
+	})
+}
+
+var userStatuses = []string{"active", "suspended", "banned", "shadow_banned"}
+
+func (m *Memory) SetUserStatus(ctx context.Context, arg database.SetUserStatusParams) (database.User, error) {
+	if !slices.Contains(userStatuses, arg.Status) {
+		return database.User{}, fmt.Errorf("%w: users.status", ErrCheckViolation)
+	}
+	return m.updateUser(arg.ID, func(u *database.User) {
+		u.Status = arg.Status
+		u.SuspendedUntil = arg.SuspendedUntil
+		u.UpdatedAt = m.now()
+	})
+}
+
+func (m *Memory) SoftDeleteUser(ctx context.Context, id uuid.UUID) (database.User, error) {
+	return m.updateUser(id, func(u *database.User) {
+		now := m.now()
+		u.DeletedAt = sql.NullTime{Time: now, Valid: true}
+		u.UpdatedAt = now
+	})
+}
+
+func (m *Memory) RestoreUser(ctx context.Context, id uuid.UUID) (database.User, error) {
+	return m.updateUser(id, func(u *database.User) {
+		u.DeletedAt = sql.NullTime{}
+		u.UpdatedAt = m.now()
+	})
+}
+
+// TryLockJob always gets the lock: WithTx already runs one transaction at
+// a time.
+func (m *Memory) TryLockJob(ctx context.Context, job string) (bool, error) {
+	return true, nil
+}
+
+func (m *Memory) PurgeDeletedUsers(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	defer m.lock()()
+	return m.deleteUsers(func(u database.User) bool {
+		return u.DeletedAt.Valid && u.DeletedAt.Time.Before(deletedBefore)
+	}), nil
+}
+
+func (m *Memory) DeleteUsers(ctx context.Context) error {
+	defer m.lock()()
+	m.deleteUsers(func(database.User) bool { return true })
+	return nil
+}
+
+// Chirps
+
+func (m *Memory) CreateChirp(ctx context.Context, arg database.CreateChirpParams) (database.Chirp, error) {
+	defer m.lock()()
+	if err := m.requireUser(arg.UserID, "chirps"); err != nil {
+		return database.Chirp{}, err
+	}
+	now := m.now()
+	chirp := database.Chirp{
+		ID:         uuid.New(),
+		CreatedAt:  now,
+		UpdatedAt:  now,
+		Body:       arg.Body,
+		UserID:     arg.UserID,
+		BodyHash:   arg.BodyHash,
+		HeldReason: arg.HeldReason,
+	}
+	m.t.chirps = append(m.t.chirps, chirp)
+	return chirp, nil
+}
+
+// visibleChirps are the chirps that are not deleted and whose author is
+// not deleted either, oldest first.
+func (m *Memory) visibleChirps(keep func(c database.Chirp) bool) []database.Chirp {
+	return m.allChirps(func(c database.Chirp) bool {
+		i := m.userIndex(c.UserID)
+		return i >= 0 && !m.t.users[i].DeletedAt.Valid && !c.DeletedAt.Valid && keep(c)
+	})
+}
+
+// shownTo keeps the chirps shown to viewer: those of shadow-banned users
+// are only shown to themselves, those of the users viewer muted or blocked
+// are left out.
+func (m *Memory) shownTo(viewer uuid.UUID) func(c database.Chirp) bool {
+	return func(c database.Chirp) bool {
+		i := m.userIndex(c.UserID)
+		if m.t.users[i].Status == "shadow_banned" && c.UserID != viewer {
+			return false
+		}
+		return !slices.ContainsFunc(m.t.mutes, func(mu database.Mute) bool { return mu.MuterID == viewer && mu.MutedID == c.UserID }) &&
+			!slices.ContainsFunc(m.t.blocks, func(b database.Block) bool { return b.BlockerID == viewer && b.BlockedID == c.UserID })
+	}
+}
+
+// listedTo keeps the chirps listed to viewer: those shown to them but the
+// held ones.
+func (m *Memory) listedTo(viewer uuid.UUID) func(c database.Chirp) bool {
+	shown := m.shownTo(viewer)
+	return func(c database.Chirp) bool { return !c.HeldReason.Valid && shown(c) }
+}
+
+func (m *Memory) allChirps(keep func(c database.Chirp) bool) []database.Chirp {
+	var chirps []database.Chirp
+	for _, c := range m.t.chirps {
+		if keep(c) {
+			chirps = append(chirps, c)
+		}
+	}
+	return chirps
+}
+
+func firstChirp(chirps []database.Chirp) (database.Chirp, error) {
+	if len(chirps) == 0 {
+		return database.Chirp{}, sql.ErrNoRows
+	}
+	return chirps[0], nil
+}
+
+func (m *Memory) QueryAllChirps(ctx context.Context, viewerID uuid.UUID) ([]database.Chirp, error) {
+	defer m.lock()()
+	return m.visibleChirps(m.listedTo(viewerID)), nil
+}
+
+func (m *Memory) QueryAllAuthorChirps(ctx context.Context, arg database.QueryAllAuthorChirpsParams) ([]database.Chirp, error) {
+	defer m.lock()()
+	listed := m.listedTo(arg.ViewerID)
+	return m.visibleChirps(func(c database.Chirp) bool { return c.UserID == arg.UserID && listed(c) }), nil
+}
+
+func (m *Memory) QueryChirp(ctx context.Context, arg database.QueryChirpParams) (database.Chirp, error) {
+	defer m.lock()()
+	shown := m.shownTo(arg.ViewerID)
+	return firstChirp(m.visibleChirps(func(c database.Chirp) bool { return c.ID == arg.ID && shown(c) }))
+}
+
+func (m *Memory) QueryAllChirpsIncludingDeleted(ctx context.Context) ([]database.Chirp, error) {
+	defer m.lock()()
+	return m.allChirps(func(database.Chirp) bool { return true }), nil
+}
+
+func (m *Memory) QueryAllAuthorChirpsIncludingDeleted(ctx context.Context, userID uuid.UUID) ([]database.Chirp, error) {
+	defer m.lock()()
+	return m.allChirps(func(c database.Chirp) bool { return c.UserID == userID }), nil
+}
+
+func (m *Memory) QueryChirpIncludingDeleted(ctx context.Context, id uuid.UUID) (database.Chirp, error) {
+	defer m.lock()()
+	return firstChirp(m.allChirps(func(c database.Chirp) bool { return c.ID == id }))
+}
+
+// updateChirp applies fn to the chirp with the given id when it is deleted
+// or not, as wanted, and returns it.
+func (m *Memory) updateChirp(id uuid.UUID, deleted bool, fn func(c *database.Chirp)) (database.Chirp, error) {
+	defer m.lock()()
+	i := slices.IndexFunc(m.t.chirps, func(c database.Chirp) bool { return c.ID == id })
+	if i < 0 || m.t.chirps[i].DeletedAt.Valid != deleted {
+		return database.Chirp{}, sql.ErrNoRows
+	}
+	fn(&m.t.chirps[i])
+	return m.t.chirps[i], nil
+}
+
+func (m *Memory) SoftDeleteChirp(ctx context.Context, arg database.SoftDeleteChirpParams) (database.Chirp, error) {
+	return m.updateChirp(arg.ID, false, func(c *database.Chirp) {
+		now := m.now()
+		c.DeletedAt = sql.NullTime{Time: now, Valid: true}
+		c.DeletedBy = arg.DeletedBy
+		c.DeletionReason = arg.DeletionReason
+		c.UpdatedAt = now
+	})
+}
+
+func (m *Memory) RestoreChirp(ctx context.Context, id uuid.UUID) (database.Chirp, error) {
+	return m.updateChirp(id, true, func(c *database.Chirp) {
+		c.DeletedAt = sql.NullTime{}
+		c.DeletedBy = uuid.NullUUID{}
+		c.DeletionReason = sql.NullString{}
+		c.UpdatedAt = m.now()
+	})
+}
+
+func (m *Memory) PurgeDeletedChirps(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	defer m.lock()()
+	reported := map[uuid.UUID]bool{}
+	for _, r := range m.t.reports {
+		if r.ChirpID.Valid && (r.Status == "open" || r.Status == "assigned") {
+			reported[r.ChirpID.UUID] = true
+		}
+	}
+	purged := map[uuid.UUID]bool{}
+	m.t.chirps = slices.DeleteFunc(m.t.chirps, func(c database.Chirp) bool {
+		if c.DeletedAt.Valid && c.DeletedAt.Time.Before(deletedBefore) && !reported[c.ID] {
+			purged[c.ID] = true
+			return true
+		}
+		return false
+	})
+	//i report restano, senza il chirp
+	for i, r := range m.t.reports {
+		if r.ChirpID.Valid && purged[r.ChirpID.UUID] {
+			m.t.reports[i].ChirpID = uuid.NullUUID{}
+		}
+	}
+	return int64(len(purged)), nil
+}
+
+func (m *Memory) CountUserChirpsSince(ctx context.Context, arg database.CountUserChirpsSinceParams) (int64, error) {
+	defer m.lock()()
+	chirps := m.allChirps(func(c database.Chirp) bool {
+		return c.UserID == arg.UserID && c.CreatedAt.After(arg.CreatedAt)
+	})
+	return int64(len(chirps)), nil
+}
+
+func (m *Memory) CountDuplicateChirps(ctx context.Context, arg database.CountDuplicateChirpsParams) (int64, error) {
+	defer m.lock()()
+	chirps := m.allChirps(func(c database.Chirp) bool {
+		return c.UserID == arg.UserID && c.BodyHash == arg.BodyHash && c.CreatedAt.After(arg.CreatedAt)
+	})
+	return int64(len(chirps)), nil
+}
+
+func (m *Memory) QueryHeldChirps(ctx context.Context) ([]database.Chirp, error) {
+	defer m.lock()()
+	return m.allChirps(func(c database.Chirp) bool { return c.HeldReason.Valid && !c.DeletedAt.Valid }), nil
+}
+
+func (m *Memory) ReleaseChirp(ctx context.Context, id uuid.UUID) (database.Chirp, error) {
+	defer m.lock()()
+	i := slices.IndexFunc(m.t.chirps, func(c database.Chirp) bool { return c.ID == id })
+	if i < 0 || !m.t.chirps[i].HeldReason.Valid || m.t.chirps[i].DeletedAt.Valid {
+		return database.Chirp{}, sql.ErrNoRows
+	}
+	m.t.chirps[i].HeldReason = sql.NullString{}
+	m.t.chirps[i].UpdatedAt = m.now()
+	return m.t.chirps[i], nil
+}
+
+// Refresh tokens
+
+func (m *Memory) CreateRefreshToken(ctx context.Context, arg database.CreateRefreshTokenParams) (database.RefreshToken, error) {
+	defer m.lock()()
+	if err := m.requireUser(arg.UserID, "refresh_tokens"); err != nil {
+		return database.RefreshToken{}, err
+	}
+	if slices.ContainsFunc(m.t.refreshTokens, func(t database.RefreshToken) bool { return t.Token == arg.Token }) {
+		return database.RefreshToken{}, fmt.Errorf("%w: refresh_tokens.token", ErrUniqueViolation)
+	}
+	now := m.now()
+	token := database.RefreshToken{
+		Token:     arg.Token,
+		CreatedAt: now,
+		UpdatedAt: now,
+		UserID:    arg.UserID,
+		ExpiresAt: arg.ExpiresAt,
+	}
+	m.t.refreshTokens = append(m.t.refreshTokens, token)
+	return token, nil
+}
+
+func (m *Memory) QueryRefreshToken(ctx context.Context, token string) (database.RefreshToken, error) {
+	defer m.lock()()
+	i := slices.IndexFunc(m.t.refreshTokens, func(t database.RefreshToken) bool { return t.Token == token })
+	if i < 0 {
+		return database.RefreshToken{}, sql.ErrNoRows
+	}
+	return m.t.refreshTokens[i], nil
+}
+
+func (m *Memory) QueryUserRefreshTokens(ctx context.Context, userID uuid.UUID) ([]database.RefreshToken, error) {
+	defer m.lock()()
+	var tokens []database.RefreshToken
+	for _, t := range m.t.refreshTokens {
+		if t.UserID == userID {
+			tokens = append(tokens, t)
+		}
+	}
+	return tokens, nil
+}
+
+func (m *Memory) RevokeToken(ctx context.Context, token string) (database.RefreshToken, error) {
+	defer m.lock()()
+	i := slices.IndexFunc(m.t.refreshTokens, func(t database.RefreshToken) bool { return t.Token == token })
+	if i < 0 {
+		return database.RefreshToken{}, sql.ErrNoRows
+	}
+	now := m.now()
+	m.t.refreshTokens[i].RevokedAt = sql.NullTime{Time: now, Valid: true}
+	m.t.refreshTokens[i].UpdatedAt = now
+	return m.t.refreshTokens[i], nil
+}
+
+func (m *Memory) RevokeUserTokens(ctx context.Context, userID uuid.UUID) error {
+	defer m.lock()()
+	now := m.now()
+	for i, t := range m.t.refreshTokens {
+		if t.UserID == userID && !t.RevokedAt.Valid {
+			m.t.refreshTokens[i].RevokedAt = sql.NullTime{Time: now, Valid: true}
+			m.t.refreshTokens[i].UpdatedAt = now
+		}
+	}
+	return nil
+}
+
+// Data exports
+
+func (m *Memory) CreateDataExport(ctx context.Context, userID uuid.UUID) (database.DataExport, error) {
+	defer m.lock()()
+	if err := m.requireUser(userID, "data_exports"); err != nil {
+		return database.DataExport{}, err
+	}
+	if slices.ContainsFunc(m.t.dataExports, func(e database.DataExport) bool {
+		return e.UserID == userID && (e.Status == "pending" || e.Status == "running")
+	}) {
+		return database.DataExport{}, fmt.Errorf("%w: data_exports_one_open", ErrUniqueViolation)
+	}
+	now := m.now()
+	export := database.DataExport{
+		ID:        uuid.New(),
+		CreatedAt: now,
+		UpdatedAt: now,
+		UserID:    userID,
+		Status:    "pending",
+	}
+	m.t.dataExports = append(m.t.dataExports, export)
+	return export, nil
+}
+
+func (m *Memory) QueryLatestDataExport(ctx context.Context, userID uuid.UUID) (database.DataExport, error) {
+	defer m.lock()()
+	for i := len(m.t.dataExports) - 1; i >= 0; i-- {
+		if m.t.dataExports[i].UserID == userID {
+			return m.t.dataExports[i], nil
+		}
+	}
+	return database.DataExport{}, sql.ErrNoRows
+}
+
+// claimableExport reports whether e is waiting to be built, or was claimed
+// before staleBefore by a build that never finished.
+func claimableExport(e database.DataExport, staleBefore time.Time) bool {
+	return e.Status == "pending" || e.Status == "running" && e.UpdatedAt.Before(staleBefore)
+}
+
+func (m *Memory) QueryPendingDataExports(ctx context.Context, staleBefore time.Time) ([]database.DataExport, error) {
+	defer m.lock()()
+	var exports []database.DataExport
+	for _, e := range m.t.dataExports {
+		if claimableExport(e, staleBefore) {
+			exports = append(exports, e)
+		}
+	}
+	return exports, nil
+}
+
+// updateDataExport applies fn to the export with the given id when cond
+// holds for it.
+func (m *Memory) updateDataExport(id uuid.UUID, cond func(e database.DataExport) bool, fn func(e *database.DataExport)) (database.DataExport, error) {
+	defer m.lock()()
+	i := slices.IndexFunc(m.t.dataExports, func(e database.DataExport) bool { return e.ID == id && cond(e) })
+	if i < 0 {
+		return database.DataExport{}, sql.ErrNoRows
+	}
+	fn(&m.t.dataExports[i])
+	m.t.dataExports[i].UpdatedAt = m.now()
+	return m.t.dataExports[i], nil
+}
+
+func (m *Memory) ClaimDataExport(ctx context.Context, arg database.ClaimDataExportParams) (database.DataExport, error) {
+	return m.updateDataExport(arg.ID,
+		func(e database.DataExport) bool { return claimableExport(e, arg.StaleBefore) },
+		func(e *database.DataExport) { e.Status = "running" })
+}
+
+func (m *Memory) CompleteDataExport(ctx context.Context, arg database.CompleteDataExportParams) error {
+	_, err := m.updateDataExport(arg.ID,
+		func(database.DataExport) bool { return true },
+		func(e *database.DataExport) {
+			e.Status = "ready"
+			e.Archive = arg.Archive
+			e.CompletedAt = sql.NullTime{Time: m.now(), Valid: true}
+		})
+	return ignoreNoRows(err)
+}
+
+func (m *Memory) FailDataExport(ctx context.Context, id uuid.UUID) error {
+	_, err := m.updateDataExport(id,
+		func(database.DataExport) bool { return true },
+		func(e *database.DataExport) {
+			e.Status = "failed"
+			e.CompletedAt = sql.NullTime{Time: m.now(), Valid: true}
+		})
+	return ignoreNoRows(err)
+}
+
+// ignoreNoRows mimics :exec queries, which do not fail on matching nothing.
+func ignoreNoRows(err error) error {
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	return err
+}
+
+// Email changes
+
+func (m *Memory) CreateEmailChange(ctx context.Context, arg database.CreateEmailChangeParams) (database.EmailChange, error) {
+	defer m.lock()()
+	if err := m.requireUser(arg.UserID, "email_changes"); err != nil {
+		return database.EmailChange{}, err
+	}
+	if slices.ContainsFunc(m.t.emailChanges, func(c database.EmailChange) bool { return c.Token == arg.Token }) {
+		return database.EmailChange{}, fmt.Errorf("%w: email_changes.token", ErrUniqueViolation)
+	}
+	now := m.now()
+	change := database.EmailChange{
+		Token:     arg.Token,
+		CreatedAt: now,
+		UpdatedAt: now,
+		UserID:    arg.UserID,
+		NewEmail:  arg.NewEmail,
+		ExpiresAt: arg.ExpiresAt,
+	}
+	m.t.emailChanges = append(m.t.emailChanges, change)
+	return change, nil
+}
+
+func (m *Memory) QueryEmailChange(ctx context.Context, token string) (database.EmailChange, error) {
+	defer m.lock()()
+	i := slices.IndexFunc(m.t.emailChanges, func(c database.EmailChange) bool { return c.Token == token })
+	if i < 0 {
+		return database.EmailChange{}, sql.ErrNoRows
+	}
+	return m.t.emailChanges[i], nil
+}
+
+func (m *Memory) ConfirmEmailChange(ctx context.Context, token string) error {
+	defer m.lock()()
+	i := slices.IndexFunc(m.t.emailChanges, func(c database.EmailChange) bool { return c.Token == token })
+	if i >= 0 {
+		now := m.now()
+		m.t.emailChanges[i].ConfirmedAt = sql.NullTime{Time: now, Valid: true}
+		m.t.emailChanges[i].UpdatedAt = now
+	}
+	return nil
+}
+
+func (m *Memory) DeletePendingEmailChanges(ctx context.Context, userID uuid.UUID) error {
+	defer m.lock()()
+	m.t.emailChanges = slices.DeleteFunc(m.t.emailChanges, func(c database.EmailChange) bool {
+		return c.UserID == userID && !c.ConfirmedAt.Valid
+	})
+	return nil
+}
+
+// Webhook events
+
+func (m *Memory) CreateWebhookEvent(ctx context.Context, arg database.CreateWebhookEventParams) (database.WebhookEvent, error) {
+	defer m.lock()()
+	//ON CONFLICT DO NOTHING non restituisce righe
+	if slices.ContainsFunc(m.t.webhookEvents, func(e database.WebhookEvent) bool { return e.ID == arg.ID }) {
+		return database.WebhookEvent{}, sql.ErrNoRows
+	}
+	now := m.now()
+	event := database.WebhookEvent{
+		ID:        arg.ID,
+		CreatedAt: now,
+		UpdatedAt: now,
+		Event:     arg.Event,
+		Payload:   arg.Payload,
+		Status:    "received",
+	}
+	m.t.webhookEvents = append(m.t.webhookEvents, event)
+	return event, nil
+}
+
+func (m *Memory) QueryWebhookEvent(ctx context.Context, id string) (database.WebhookEvent, error) {
+	defer m.lock()()
+	i := slices.IndexFunc(m.t.webhookEvents, func(e database.WebhookEvent) bool { return e.ID == id })
+	if i < 0 {
+		return database.WebhookEvent{}, sql.ErrNoRows
+	}
+	return m.t.webhookEvents[i], nil
+}
+
+func (m *Memory) ClaimWebhookEvent(ctx context.Context, arg database.ClaimWebhookEventParams) (database.WebhookEvent, error) {
+	defer m.lock()()
+	i := slices.IndexFunc(m.t.webhookEvents, func(e database.WebhookEvent) bool {
+		return e.ID == arg.ID && (e.Status == "received" || e.Status == "failed" ||
+			e.Status == "processing" && e.UpdatedAt.Before(arg.StaleBefore))
+	})
+	if i < 0 {
+		return database.WebhookEvent{}, sql.ErrNoRows
+	}
+	e := &m.t.webhookEvents[i]
+	e.Status = "processing"
+	e.UpdatedAt = m.now()
+	return *e, nil
+}
+
+func (m *Memory) MarkWebhookEvent(ctx context.Context, arg database.MarkWebhookEventParams) (database.WebhookEvent, error) {
+	switch arg.Status {
+	case "received", "processing", "processed", "ignored", "failed":
+	default:
+		return database.WebhookEvent{}, fmt.Errorf("%w: webhook_events.status", ErrCheckViolation)
+	}
+	defer m.lock()()
+	i := slices.IndexFunc(m.t.webhookEvents, func(e database.WebhookEvent) bool { return e.ID == arg.ID })
+	if i < 0 {
+		return database.WebhookEvent{}, sql.ErrNoRows
+	}
+	now := m.now()
+	e := &m.t.webhookEvents[i]
+	e.Status = arg.Status
+	e.Error = arg.Error
+	e.Attempts++
+	e.ProcessedAt = sql.NullTime{Time: now, Valid: true}
+	e.UpdatedAt = now
+	return *e, nil
+}
+
+// Subscriptions
+
+func (m *Memory) activeSubscription(userID uuid.UUID) int {
+	return slices.IndexFunc(m.t.subscriptions, func(s database.Subscription) bool {
+		return s.UserID == userID && s.Status == "active"
+	})
+}
+
+func (m *Memory) CreateSubscription(ctx context.Context, arg database.CreateSubscriptionParams) (database.Subscription, error) {
+	defer m.lock()()
+	if err := m.requireUser(arg.UserID, "subscriptions"); err != nil {
+		return database.Subscription{}, err
+	}
+	if m.activeSubscription(arg.UserID) >= 0 {
+		return database.Subscription{}, fmt.Errorf("%w: subscriptions_one_active_per_user", ErrUniqueViolation)
+	}
+	now := m.now()
+	sub := database.Subscription{
+		ID:                 uuid.New(),
+		CreatedAt:          now,
+		UpdatedAt:          now,
+		UserID:             arg.UserID,
+		Plan:               arg.Plan,
+		Status:             "active",
+		CurrentPeriodStart: arg.CurrentPeriodStart,
+		CurrentPeriodEnd:   arg.CurrentPeriodEnd,
+	}
+	m.t.subscriptions = append(m.t.subscriptions, sub)
+	return sub, nil
+}
+
+func (m *Memory) QueryActiveSubscription(ctx context.Context, userID uuid.UUID) (database.Subscription, error) {
+	defer m.lock()()
+	i := m.activeSubscription(userID)
+	if i < 0 {
+		return database.Subscription{}, sql.ErrNoRows
+	}
+	return m.t.subscriptions[i], nil
+}
+
+func (m *Memory) ExtendSubscription(ctx context.Context, arg database.ExtendSubscriptionParams) (database.Subscription, error) {
+	defer m.lock()()
+	i := slices.IndexFunc(m.t.subscriptions, func(s database.Subscription) bool { return s.ID == arg.ID })
+	if i < 0 {
+		return database.Subscription{}, sql.ErrNoRows
+	}
+	m.t.subscriptions[i].CurrentPeriodEnd = arg.CurrentPeriodEnd
+	m.t.subscriptions[i].UpdatedAt = m.now()
+	return m.t.subscriptions[i], nil
+}
+
+func (m *Memory) CancelSubscription(ctx context.Context, userID uuid.UUID) error {
+	defer m.lock()()
+	if i := m.activeSubscription(userID); i >= 0 {
+		now := m.now()
+		m.t.subscriptions[i].Status = "canceled"
+		m.t.subscriptions[i].CurrentPeriodEnd = now
+		m.t.subscriptions[i].UpdatedAt = now
+	}
+	return nil
+}
+
+func (m *Memory) ExpireSubscriptions(ctx context.Context) ([]uuid.UUID, error) {
+	defer m.lock()()
+	now := m.now()
+	var expired []uuid.UUID
+	for i, s := range m.t.subscriptions {
+		if s.Status == "active" && s.CurrentPeriodEnd.Before(now) {
+			m.t.subscriptions[i].Status = "expired"
+			m.t.subscriptions[i].UpdatedAt = now
+			if j := m.userIndex(s.UserID); j >= 0 {
+				m.t.users[j].IsChirpyRed = false
+				m.t.users[j].UpdatedAt = now
+				expired = append(expired, s.UserID)
+			}
+		}
+	}
+	return expired, nil
+}
+
+// Reports
+
+var (
+	reportReasons  = []string{"spam", "harassment", "hate", "violence", "sexual", "misinformation", "other"}
+	reportStatuses = []string{"open", "assigned", "dismissed", "chirp_removed", "user_suspended"}
+)
+
+func reportPending(r database.Report) bool {
+	return r.Status == "open" || r.Status == "assigned"
+}
+
+func (m *Memory) CreateReport(ctx context.Context, arg database.CreateReportParams) (database.Report, error) {
+	defer m.lock()()
+	if err := m.requireUser(arg.ReporterID, "reports"); err != nil {
+		return database.Report{}, err
+	}
+	if err := m.requireUser(arg.UserID, "reports"); err != nil {
+		return database.Report{}, err
+	}
+	if arg.ChirpID.Valid && !slices.ContainsFunc(m.t.chirps, func(c database.Chirp) bool { return c.ID == arg.ChirpID.UUID }) {
+		return database.Report{}, fmt.Errorf("%w: reports.chirp_id", ErrForeignKeyViolation)
+	}
+	if !slices.Contains(reportReasons, arg.Reason) {
+		return database.Report{}, fmt.Errorf("%w: reports.reason", ErrCheckViolation)
+	}
+	if slices.ContainsFunc(m.t.reports, func(r database.Report) bool {
+		return reportPending(r) && r.ReporterID == arg.ReporterID && r.ChirpID.Valid == arg.ChirpID.Valid &&
+			(arg.ChirpID.Valid && r.ChirpID.UUID == arg.ChirpID.UUID || !arg.ChirpID.Valid && r.UserID == arg.UserID)
+	}) {
+		return database.Report{}, fmt.Errorf("%w: reports_one_pending_per_target", ErrUniqueViolation)
+	}
+	now := m.now()
+	report := database.Report{
+		ID:         uuid.New(),
+		CreatedAt:  now,
+		UpdatedAt:  now,
+		ReporterID: arg.ReporterID,
+		UserID:     arg.UserID,
+		ChirpID:    arg.ChirpID,
+		Reason:     arg.Reason,
+		Details:    arg.Details,
+		Status:     "open",
+	}
+	m.t.reports = append(m.t.reports, report)
+	return report, nil
+}
+
+func (m *Memory) QueryReport(ctx context.Context, id uuid.UUID) (database.Report, error) {
+	defer m.lock()()
+	i := slices.IndexFunc(m.t.reports, func(r database.Report) bool { return r.ID == id })
+	if i < 0 {
+		return database.Report{}, sql.ErrNoRows
+	}
+	return m.t.reports[i], nil
+}
+
+func (m *Memory) queryReports(keep func(r database.Report) bool) []database.Report {
+	var reports []database.Report
+	for _, r := range m.t.reports {
+		if keep(r) {
+			reports = append(reports, r)
+		}
+	}
+	return reports
+}
+
+func (m *Memory) QueryPendingReports(ctx context.Context) ([]database.Report, error) {
+	defer m.lock()()
+	return m.queryReports(reportPending), nil
+}
+
+func (m *Memory) QueryReportsByStatus(ctx context.Context, status string) ([]database.Report, error) {
+	defer m.lock()()
+	return m.queryReports(func(r database.Report) bool { return r.Status == status }), nil
+}
+
+// updatePendingReport applies fn to the report with the given id if it is
+// still open or assigned, and returns it.
+func (m *Memory) updatePendingReport(id uuid.UUID, fn func(r *database.Report) error) (database.Report, error) {
+	defer m.lock()()
+	i := slices.IndexFunc(m.t.reports, func(r database.Report) bool { return r.ID == id })
+	if i < 0 || !reportPending(m.t.reports[i]) {
+		return database.Report{}, sql.ErrNoRows
+	}
+	report := m.t.reports[i]
+	if err := fn(&report); err != nil {
+		return database.Report{}, err
+	}
+	report.UpdatedAt = m.now()
+	m.t.reports[i] = report
+	return report, nil
+}
+
+func (m *Memory) AssignReport(ctx context.Context, arg database.AssignReportParams) (database.Report, error) {
+	return m.updatePendingReport(arg.ID, func(r *database.Report) error {
+		if arg.AssigneeID.Valid {
+			if err := m.requireUser(arg.AssigneeID.UUID, "reports"); err != nil {
+				return err
+			}
+		}
+		r.AssigneeID = arg.AssigneeID
+		r.Status = "assigned"
+		return nil
+	})
+}
+
+func (m *Memory) ResolveReport(ctx context.Context, arg database.ResolveReportParams) (database.Report, error) {
+	return m.updatePendingReport(arg.ID, func(r *database.Report) error {
+		if !slices.Contains(reportStatuses, arg.Status) {
+			return fmt.Errorf("%w: reports.status", ErrCheckViolation)
+		}
+		r.Status = arg.Status
+		r.ResolvedAt = sql.NullTime{Time: m.now(), Valid: true}
+		return nil
+	})
+}
+
+func (m *Memory) ResolveChirpReports(ctx context.Context, arg database.ResolveChirpReportsParams) ([]database.Report, error) {
+	defer m.lock()()
+	if !slices.Contains(reportStatuses, arg.Status) {
+		return nil, fmt.Errorf("%w: reports.status", ErrCheckViolation)
+	}
+	now := m.now()
+	var resolved []database.Report
+	for i, r := range m.t.reports {
+		if !arg.ChirpID.Valid || r.ChirpID != arg.ChirpID || !reportPending(r) {
+			continue
+		}
+		r.Status = arg.Status
+		r.ResolvedAt = sql.NullTime{Time: now, Valid: true}
+		r.UpdatedAt = now
+		m.t.reports[i] = r
+		resolved = append(resolved, r)
+	}
+	return resolved, nil
+}
+
+func (m *Memory) CountChirpReporters(ctx context.Context, arg database.CountChirpReportersParams) (int64, error) {
+	defer m.lock()()
+	reporters := map[uuid.UUID]bool{}
+	for _, r := range m.t.reports {
+		if !arg.ChirpID.Valid || r.ChirpID != arg.ChirpID || !reportPending(r) {
+			continue
+		}
+		i := m.userIndex(r.ReporterID)
+		if i < 0 {
+			continue
+		}
+		u := m.t.users[i]
+		standing := u.Status == "active" || u.Status == "suspended" && u.SuspendedUntil.Valid && !u.SuspendedUntil.Time.After(arg.Now)
+		if !u.DeletedAt.Valid && u.CreatedAt.Before(arg.CreatedBefore) && standing {
+			reporters[r.ReporterID] = true
+		}
+	}
+	return int64(len(reporters)), nil
+}
+
+func (m *Memory) CreateReportEvent(ctx context.Context, arg database.CreateReportEventParams) (database.ReportEvent, error) {
+	defer m.lock()()
+	if !slices.ContainsFunc(m.t.reports, func(r database.Report) bool { return r.ID == arg.ReportID }) {
+		return database.ReportEvent{}, fmt.Errorf("%w: report_events.report_id", ErrForeignKeyViolation)
+	}
+	if arg.ActorID.Valid {
+		if err := m.requireUser(arg.ActorID.UUID, "report_events"); err != nil {
+			return database.ReportEvent{}, err
+		}
+	}
+	event := database.ReportEvent{
+		ID:        uuid.New(),
+		CreatedAt: m.now(),
+		ReportID:  arg.ReportID,
+		ActorID:   arg.ActorID,
+		Action:    arg.Action,
+		Note:      arg.Note,
+	}
+	m.t.reportEvents = append(m.t.reportEvents, event)
+	return event, nil
+}
+
+func (m *Memory) QueryReportEvents(ctx context.Context, reportID uuid.UUID) ([]database.ReportEvent, error) {
+	defer m.lock()()
+	var events []database.ReportEvent
+	for _, e := range m.t.reportEvents {
+		if e.ReportID == reportID {
+			events = append(events, e)
+		}
+	}
+	return events, nil
+}
+
+// Sanctions
+
+func (m *Memory) CreateSanction(ctx context.Context, arg database.CreateSanctionParams) (database.Sanction, error) {
+	defer m.lock()()
+	if err := m.requireUser(arg.UserID, "sanctions"); err != nil {
+		return database.Sanction{}, err
+	}
+	if arg.ActorID.Valid {
+		if err := m.requireUser(arg.ActorID.UUID, "sanctions"); err != nil {
+			return database.Sanction{}, err
+		}
+	}
+	if !slices.Contains(userStatuses, arg.Status) {
+		return database.Sanction{}, fmt.Errorf("%w: sanctions.status", ErrCheckViolation)
+	}
+	sanction := database.Sanction{
+		ID:             uuid.New(),
+		CreatedAt:      m.now(),
+		UserID:         arg.UserID,
+		ActorID:        arg.ActorID,
+		Status:         arg.Status,
+		SuspendedUntil: arg.SuspendedUntil,
+		Reason:         arg.Reason,
+	}
+	m.t.sanctions = append(m.t.sanctions, sanction)
+	return sanction, nil
+}
+
+func (m *Memory) QueryUserSanctions(ctx context.Context, userID uuid.UUID) ([]database.Sanction, error) {
+	defer m.lock()()
+	var sanctions []database.Sanction
+	for _, s := range m.t.sanctions {
+		if s.UserID == userID {
+			sanctions = append(sanctions, s)
+		}
+	}
+	return sanctions, nil
+}
+
+// Blocks and mutes
+
+// requirePair checks the foreign keys and the CHECK of a block or a mute
+// from owner to other.
+func (m *Memory) requirePair(owner, other uuid.UUID, table string) error {
+	if err := m.requireUser(owner, table); err != nil {
+		return err
+	}
+	if err := m.requireUser(other, table); err != nil {
+		return err
+	}
+	if owner == other {
+		return fmt.Errorf("%w: %s_check", ErrCheckViolation, table)
+	}
+	return nil
+}
+
+func (m *Memory) CreateBlock(ctx context.Context, arg database.CreateBlockParams) (database.Block, error) {
+	defer m.lock()()
+	if err := m.requirePair(arg.BlockerID, arg.BlockedID, "blocks"); err != nil {
+		return database.Block{}, err
+	}
+	if slices.ContainsFunc(m.t.blocks, func(b database.Block) bool {
+		return b.BlockerID == arg.BlockerID && b.BlockedID == arg.BlockedID
+	}) {
+		return database.Block{}, fmt.Errorf("%w: blocks_pkey", ErrUniqueViolation)
+	}
+	block := database.Block{BlockerID: arg.BlockerID, BlockedID: arg.BlockedID, CreatedAt: m.now()}
+	m.t.blocks = append(m.t.blocks, block)
+	return block, nil
+}
+
+func (m *Memory) DeleteBlock(ctx context.Context, arg database.DeleteBlockParams) (int64, error) {
+	defer m.lock()()
+	n := len(m.t.blocks)
+	m.t.blocks = slices.DeleteFunc(m.t.blocks, func(b database.Block) bool {
+		return b.BlockerID == arg.BlockerID && b.BlockedID == arg.BlockedID
+	})
+	return int64(n - len(m.t.blocks)), nil
+}
+
+func (m *Memory) QueryBlocks(ctx context.Context, blockerID uuid.UUID) ([]database.Block, error) {
+	defer m.lock()()
+	var blocks []database.Block
+	for _, b := range m.t.blocks {
+		if b.BlockerID == blockerID {
+			blocks = append(blocks, b)
+		}
+	}
+	return blocks, nil
+}
+
+func (m *Memory) QueryBlock(ctx context.Context, arg database.QueryBlockParams) (database.Block, error) {
+	defer m.lock()()
+	for _, b := range m.t.blocks {
+		if b.BlockerID == arg.BlockerID && b.BlockedID == arg.BlockedID {
+			return b, nil
+		}
+	}
+	return database.Block{}, sql.ErrNoRows
+}
+
+func (m *Memory) CreateMute(ctx context.Context, arg database.CreateMuteParams) (database.Mute, error) {
+	defer m.lock()()
+	if err := m.requirePair(arg.MuterID, arg.MutedID, "mutes"); err != nil {
+		return database.Mute{}, err
+	}
+	if slices.ContainsFunc(m.t.mutes, func(mu database.Mute) bool {
+		return mu.MuterID == arg.MuterID && mu.MutedID == arg.MutedID
+	}) {
+		return database.Mute{}, fmt.Errorf("%w: mutes_pkey", ErrUniqueViolation)
+	}
+	mute := database.Mute{MuterID: arg.MuterID, MutedID: arg.MutedID, CreatedAt: m.now()}
+	m.t.mutes = append(m.t.mutes, mute)
+	return mute, nil
+}
+
+func (m *Memory) DeleteMute(ctx context.Context, arg database.DeleteMuteParams) (int64, error) {
+	defer m.lock()()
+	n := len(m.t.mutes)
+	m.t.mutes = slices.DeleteFunc(m.t.mutes, func(mu database.Mute) bool {
+		return mu.MuterID == arg.MuterID && mu.MutedID == arg.MutedID
+	})
+	return int64(n - len(m.t.mutes)), nil
+}
+
+func (m *Memory) QueryMutes(ctx context.Context, muterID uuid.UUID) ([]database.Mute, error) {
+	defer m.lock()()
+	var mutes []database.Mute
+	for _, mu := range m.t.mutes {
+		if mu.MuterID == muterID {
+			mutes = append(mutes, mu)
+		}
+	}
+	return mutes, nil
+}
+
+// Audit log
+
+func (m *Memory) CreateAuditEntry(ctx context.Context, arg database.CreateAuditEntryParams) (database.AuditLog, error) {
+	defer m.lock()()
+	if slices.ContainsFunc(m.t.auditLog, func(e database.AuditLog) bool { return e.Seq == arg.Seq }) {
+		return database.AuditLog{}, fmt.Errorf("%w: audit_log_pkey", ErrUniqueViolation)
+	}
+	if slices.ContainsFunc(m.t.auditLog, func(e database.AuditLog) bool { return e.Hash == arg.Hash }) {
+		return database.AuditLog{}, fmt.Errorf("%w: audit_log_hash_key", ErrUniqueViolation)
+	}
+	entry := database.AuditLog(arg)
+	//l'ordine di inserimento deve restare quello di seq
+	i, _ := slices.BinarySearchFunc(m.t.auditLog, arg.Seq, func(e database.AuditLog, seq int64) int { return cmp.Compare(e.Seq, seq) })
+	m.t.auditLog = slices.Insert(m.t.auditLog, i, entry)
+	return entry, nil
+}
+
+func (m *Memory) QueryLastAuditEntry(ctx context.Context) (database.AuditLog, error) {
+	defer m.lock()()
+	if len(m.t.auditLog) == 0 {
+		return database.AuditLog{}, sql.ErrNoRows
+	}
+	return m.t.auditLog[len(m.t.auditLog)-1], nil
+}
+
+func (m *Memory) QueryAuditLog(ctx context.Context, arg database.QueryAuditLogParams) ([]database.AuditLog, error) {
+	defer m.lock()()
+	var entries []database.AuditLog
+	for i := len(m.t.auditLog) - 1; i >= 0 && len(entries) < int(arg.MaxEntries); i-- {
+		e := m.t.auditLog[i]
+		switch {
+		case arg.ActorID.Valid && e.ActorID != arg.ActorID,
+			arg.Action.Valid && e.Action != arg.Action.String,
+			arg.TargetType.Valid && e.TargetType != arg.TargetType.String,
+			arg.TargetID.Valid && e.TargetID != arg.TargetID,
+			arg.Since.Valid && e.CreatedAt.Before(arg.Since.Time),
+			arg.Until.Valid && !e.CreatedAt.Before(arg.Until.Time),
+			arg.BeforeSeq.Valid && e.Seq >= arg.BeforeSeq.Int64:
+			continue
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+func (m *Memory) QueryAuditChain(ctx context.Context) ([]database.AuditLog, error) {
+	defer m.lock()()
+	return slices.Clone(m.t.auditLog), nil
+}
@@ -0,0 +1,9 @@
+package store
+
+import "testing"
+
+func TestMemory(t *testing.T) {
+	testStore(t, func(t *testing.T) Store {
+		return NewMemory()
+	})
+}
@@ -0,0 +1,18 @@
+package store
+
+import (
+	"os"
+	"testing"
+
+	"Chirpy/internal/pgtest"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(pgtest.Run(m))
+}
+
+func TestPostgres(t *testing.T) {
+	testStore(t, func(t *testing.T) Store {
+		return NewPostgres(pgtest.NewDB(t), nil)
+	})
+}
@@ -0,0 +1,689 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+
+	"Chirpy/internal/database"
+	"Chirpy/internal/database/sqlitedb"
+)
+
+// SQLite is the Store backed by a SQLite database, for development and
+// small self-hosted instances. Its queries live in sql/queries/sqlite and
+// take the IDs and timestamps that Postgres generates itself.
+type SQLite struct {
+	sqliteQueries
+	db   *sql.DB
+	wrap func(database.DBTX) database.DBTX
+}
+
+// NewSQLite returns a Store running its queries on db, opened with the
+// DSN returned by ParseURL. wrap works as in NewPostgres.
+func NewSQLite(db *sql.DB, wrap func(database.DBTX) database.DBTX) *SQLite {
+	if wrap == nil {
+		wrap = func(db database.DBTX) database.DBTX { return db }
+	}
+	//SQLite serializza comunque le scritture: con una sola connessione
+	//non c'è SQLITE_BUSY, e un database :memory: non sparisce con lei
+	db.SetMaxOpenConns(1)
+	db.SetMaxIdleConns(1)
+	db.SetConnMaxLifetime(0)
+	db.SetConnMaxIdleTime(0)
+	return &SQLite{
+		sqliteQueries: sqliteQueries{sqlitedb.New(wrap(db))},
+		db:            db,
+		wrap:          wrap,
+	}
+}
+
+// WithTx runs fn in a transaction. fn must only use the queries it is
+// given: the store has a single connection, held by the transaction.
+func (s *SQLite) WithTx(ctx context.Context, fn func(q database.Querier) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	err = fn(sqliteQueries{sqlitedb.New(s.wrap(tx))})
+	if err != nil {
+		rbErr := tx.Rollback()
+		if rbErr != nil {
+			slog.ErrorContext(ctx, "rollback failed", "err", rbErr)
+		}
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *SQLite) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+func (s *SQLite) SchemaVersion(ctx context.Context) (int64, error) {
+	return schemaVersion(ctx, s.db)
+}
+
+// ExpireSubscriptions takes two statements on SQLite, run atomically.
+func (s *SQLite) ExpireSubscriptions(ctx context.Context) ([]uuid.UUID, error) {
+	var expired []uuid.UUID
+	err := s.WithTx(ctx, func(q database.Querier) error {
+		var err error
+		expired, err = q.ExpireSubscriptions(ctx)
+		return err
+	})
+	return expired, err
+}
+
+// sqliteQueries adapts the queries generated for SQLite to database.Querier.
+// The generated models have the fields of the Postgres ones, so rows
+// convert directly.
+type sqliteQueries struct {
+	q *sqlitedb.Queries
+}
+
+var _ database.Querier = sqliteQueries{}
+
+// now is the timestamp written by a query. Times are stored as UTC text,
+// with Postgres' precision, so that they compare correctly as strings.
+func now() time.Time {
+	return sqliteTime(time.Now())
+}
+
+func sqliteTime(t time.Time) time.Time {
+	return t.UTC().Truncate(time.Microsecond)
+}
+
+func sqliteNullTime(t sql.NullTime) sql.NullTime {
+	if !t.Valid {
+		return t
+	}
+	return sql.NullTime{Time: sqliteTime(t.Time), Valid: true}
+}
+
+func convertRows[From, To any](rows []From, convert func(From) To) []To {
+	if rows == nil {
+		return nil
+	}
+	out := make([]To, 0, len(rows))
+	for _, r := range rows {
+		out = append(out, convert(r))
+	}
+	return out
+}
+
+// Users
+
+func (s sqliteQueries) CreateUser(ctx context.Context, arg database.CreateUserParams) (database.User, error) {
+	user, err := s.q.CreateUser(ctx, sqlitedb.CreateUserParams{
+		ID:             uuid.New(),
+		Now:            now(),
+		Email:          arg.Email,
+		HashedPassword: arg.HashedPassword,
+	})
+	return database.User(user), err
+}
+
+func (s sqliteQueries) QueryUser(ctx context.Context, email string) (database.User, error) {
+	user, err := s.q.QueryUser(ctx, email)
+	return database.User(user), err
+}
+
+func (s sqliteQueries) QueryUserByID(ctx context.Context, id uuid.UUID) (database.User, error) {
+	user, err := s.q.QueryUserByID(ctx, id)
+	return database.User(user), err
+}
+
+func (s sqliteQueries) LockUser(ctx context.Context, id uuid.UUID) error {
+	return s.q.LockUser(ctx, id)
+}
+
+func (s sqliteQueries) UpdateUserPassword(ctx context.Context, arg database.UpdateUserPasswordParams) (database.User, error) {
+	user, err := s.q.UpdateUserPassword(ctx, sqlitedb.UpdateUserPasswordParams{
+		HashedPassword: arg.HashedPassword,
+		Now:            now(),
+		ID:             arg.ID,
+	})
+	return database.User(user), err
+}
+
+func (s sqliteQueries) UpdateUserEmail(ctx context.Context, arg database.UpdateUserEmailParams) (database.User, error) {
+	user, err := s.q.UpdateUserEmail(ctx, sqlitedb.UpdateUserEmailParams{
+		Email: arg.Email,
+		Now:   now(),
+		ID:    arg.ID,
+	})
+	return database.User(user), err
+}
+
+func (s sqliteQueries) UserPro(ctx context.Context, id uuid.UUID) (database.User, error) {
+	user, err := s.q.UserPro(ctx, id)
+	return database.User(user), err
+}
+
+func (s sqliteQueries) UserFree(ctx context.Context, id uuid.UUID) (database.User, error) {
+	user, err := s.q.UserFree(ctx, id)
+	return database.User(user), err
+}
+
+func (s sqliteQueries) SetUserRole(ctx context.Context, arg database.SetUserRoleParams) (database.User, error) {
+	user, err := s.q.SetUserRole(ctx, sqlitedb.SetUserRoleParams{
+		Role: arg.Role,
+		Now:  now(),
+		ID:   arg.ID,
+	})
+	return database.User(user), err
+}
+
+func (s sqliteQueries) SetUserStatus(ctx context.Context, arg database.SetUserStatusParams) (database.User, error) {
+	user, err := s.q.SetUserStatus(ctx, sqlitedb.SetUserStatusParams{
+		Status:         arg.Status,
+		SuspendedUntil: sqliteNullTime(arg.SuspendedUntil),
+		Now:            now(),
+		ID:             arg.ID,
+	})
+	return database.User(user), err
+}
+
+func (s sqliteQueries) SoftDeleteUser(ctx context.Context, id uuid.UUID) (database.User, error) {
+	user, err := s.q.SoftDeleteUser(ctx, sqlitedb.SoftDeleteUserParams{Now: now(), ID: id})
+	return database.User(user), err
+}
+
+func (s sqliteQueries) RestoreUser(ctx context.Context, id uuid.UUID) (database.User, error) {
+	user, err := s.q.RestoreUser(ctx, sqlitedb.RestoreUserParams{Now: now(), ID: id})
+	return database.User(user), err
+}
+
+func (s sqliteQueries) PurgeDeletedUsers(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	return s.q.PurgeDeletedUsers(ctx, sql.NullTime{Time: sqliteTime(deletedBefore), Valid: true})
+}
+
+// TryLockJob always gets the lock: a SQLite database has a single server,
+// whose transactions run one at a time.
+func (s sqliteQueries) TryLockJob(ctx context.Context, job string) (bool, error) {
+	return true, nil
+}
+
+func (s sqliteQueries) DeleteUsers(ctx context.Context) error {
+	return s.q.DeleteUsers(ctx)
+}
+
+// Chirps
+
+func toChirp(c sqlitedb.Chirp) database.Chirp {
+	return database.Chirp(c)
+}
+
+func (s sqliteQueries) CreateChirp(ctx context.Context, arg database.CreateChirpParams) (database.Chirp, error) {
+	chirp, err := s.q.CreateChirp(ctx, sqlitedb.CreateChirpParams{
+		ID:         uuid.New(),
+		Now:        now(),
+		Body:       arg.Body,
+		UserID:     arg.UserID,
+		BodyHash:   arg.BodyHash,
+		HeldReason: arg.HeldReason,
+	})
+	return database.Chirp(chirp), err
+}
+
+func (s sqliteQueries) QueryAllChirps(ctx context.Context, viewerID uuid.UUID) ([]database.Chirp, error) {
+	chirps, err := s.q.QueryAllChirps(ctx, viewerID)
+	return convertRows(chirps, toChirp), err
+}
+
+func (s sqliteQueries) QueryAllAuthorChirps(ctx context.Context, arg database.QueryAllAuthorChirpsParams) ([]database.Chirp, error) {
+	chirps, err := s.q.QueryAllAuthorChirps(ctx, sqlitedb.QueryAllAuthorChirpsParams(arg))
+	return convertRows(chirps, toChirp), err
+}
+
+func (s sqliteQueries) QueryChirp(ctx context.Context, arg database.QueryChirpParams) (database.Chirp, error) {
+	chirp, err := s.q.QueryChirp(ctx, sqlitedb.QueryChirpParams(arg))
+	return database.Chirp(chirp), err
+}
+
+func (s sqliteQueries) QueryAllChirpsIncludingDeleted(ctx context.Context) ([]database.Chirp, error) {
+	chirps, err := s.q.QueryAllChirpsIncludingDeleted(ctx)
+	return convertRows(chirps, toChirp), err
+}
+
+func (s sqliteQueries) QueryAllAuthorChirpsIncludingDeleted(ctx context.Context, userID uuid.UUID) ([]database.Chirp, error) {
+	chirps, err := s.q.QueryAllAuthorChirpsIncludingDeleted(ctx, userID)
+	return convertRows(chirps, toChirp), err
+}
+
+func (s sqliteQueries) QueryChirpIncludingDeleted(ctx context.Context, id uuid.UUID) (database.Chirp, error) {
+	chirp, err := s.q.QueryChirpIncludingDeleted(ctx, id)
+	return database.Chirp(chirp), err
+}
+
+func (s sqliteQueries) SoftDeleteChirp(ctx context.Context, arg database.SoftDeleteChirpParams) (database.Chirp, error) {
+	chirp, err := s.q.SoftDeleteChirp(ctx, sqlitedb.SoftDeleteChirpParams{
+		Now:            now(),
+		DeletedBy:      arg.DeletedBy,
+		DeletionReason: arg.DeletionReason,
+		ID:             arg.ID,
+	})
+	return database.Chirp(chirp), err
+}
+
+func (s sqliteQueries) RestoreChirp(ctx context.Context, id uuid.UUID) (database.Chirp, error) {
+	chirp, err := s.q.RestoreChirp(ctx, sqlitedb.RestoreChirpParams{Now: now(), ID: id})
+	return database.Chirp(chirp), err
+}
+
+func (s sqliteQueries) PurgeDeletedChirps(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	return s.q.PurgeDeletedChirps(ctx, sql.NullTime{Time: sqliteTime(deletedBefore), Valid: true})
+}
+
+func (s sqliteQueries) CountUserChirpsSince(ctx context.Context, arg database.CountUserChirpsSinceParams) (int64, error) {
+	return s.q.CountUserChirpsSince(ctx, sqlitedb.CountUserChirpsSinceParams{
+		UserID:    arg.UserID,
+		CreatedAt: sqliteTime(arg.CreatedAt),
+	})
+}
+
+func (s sqliteQueries) CountDuplicateChirps(ctx context.Context, arg database.CountDuplicateChirpsParams) (int64, error) {
+	return s.q.CountDuplicateChirps(ctx, sqlitedb.CountDuplicateChirpsParams{
+		UserID:    arg.UserID,
+		BodyHash:  arg.BodyHash,
+		CreatedAt: sqliteTime(arg.CreatedAt),
+	})
+}
+
+func (s sqliteQueries) QueryHeldChirps(ctx context.Context) ([]database.Chirp, error) {
+	chirps, err := s.q.QueryHeldChirps(ctx)
+	return convertRows(chirps, toChirp), err
+}
+
+func (s sqliteQueries) ReleaseChirp(ctx context.Context, id uuid.UUID) (database.Chirp, error) {
+	chirp, err := s.q.ReleaseChirp(ctx, sqlitedb.ReleaseChirpParams{Now: now(), ID: id})
+	return database.Chirp(chirp), err
+}
+
+// Refresh tokens
+
+func (s sqliteQueries) CreateRefreshToken(ctx context.Context, arg database.CreateRefreshTokenParams) (database.RefreshToken, error) {
+	token, err := s.q.CreateRefreshToken(ctx, sqlitedb.CreateRefreshTokenParams{
+		Token:     arg.Token,
+		Now:       now(),
+		UserID:    arg.UserID,
+		ExpiresAt: sqliteTime(arg.ExpiresAt),
+	})
+	return database.RefreshToken(token), err
+}
+
+func (s sqliteQueries) QueryRefreshToken(ctx context.Context, token string) (database.RefreshToken, error) {
+	t, err := s.q.QueryRefreshToken(ctx, token)
+	return database.RefreshToken(t), err
+}
+
+func (s sqliteQueries) QueryUserRefreshTokens(ctx context.Context, userID uuid.UUID) ([]database.RefreshToken, error) {
+	tokens, err := s.q.QueryUserRefreshTokens(ctx, userID)
+	return convertRows(tokens, func(t sqlitedb.RefreshToken) database.RefreshToken {
+		return database.RefreshToken(t)
+	}), err
+}
+
+func (s sqliteQueries) RevokeToken(ctx context.Context, token string) (database.RefreshToken, error) {
+	t, err := s.q.RevokeToken(ctx, sqlitedb.RevokeTokenParams{Now: now(), Token: token})
+	return database.RefreshToken(t), err
+}
+
+func (s sqliteQueries) RevokeUserTokens(ctx context.Context, userID uuid.UUID) error {
+	return s.q.RevokeUserTokens(ctx, sqlitedb.RevokeUserTokensParams{Now: now(), UserID: userID})
+}
+
+// Data exports
+
+func toDataExport(e sqlitedb.DataExport) database.DataExport {
+	return database.DataExport(e)
+}
+
+func (s sqliteQueries) CreateDataExport(ctx context.Context, userID uuid.UUID) (database.DataExport, error) {
+	export, err := s.q.CreateDataExport(ctx, sqlitedb.CreateDataExportParams{
+		ID:     uuid.New(),
+		Now:    now(),
+		UserID: userID,
+	})
+	return database.DataExport(export), err
+}
+
+func (s sqliteQueries) QueryLatestDataExport(ctx context.Context, userID uuid.UUID) (database.DataExport, error) {
+	export, err := s.q.QueryLatestDataExport(ctx, userID)
+	return database.DataExport(export), err
+}
+
+func (s sqliteQueries) QueryPendingDataExports(ctx context.Context, staleBefore time.Time) ([]database.DataExport, error) {
+	exports, err := s.q.QueryPendingDataExports(ctx, sqliteTime(staleBefore))
+	return convertRows(exports, toDataExport), err
+}
+
+func (s sqliteQueries) ClaimDataExport(ctx context.Context, arg database.ClaimDataExportParams) (database.DataExport, error) {
+	export, err := s.q.ClaimDataExport(ctx, sqlitedb.ClaimDataExportParams{
+		Now:         now(),
+		ID:          arg.ID,
+		StaleBefore: sqliteTime(arg.StaleBefore),
+	})
+	return database.DataExport(export), err
+}
+
+func (s sqliteQueries) CompleteDataExport(ctx context.Context, arg database.CompleteDataExportParams) error {
+	return s.q.CompleteDataExport(ctx, sqlitedb.CompleteDataExportParams{
+		Now:     now(),
+		Archive: arg.Archive,
+		ID:      arg.ID,
+	})
+}
+
+func (s sqliteQueries) FailDataExport(ctx context.Context, id uuid.UUID) error {
+	return s.q.FailDataExport(ctx, sqlitedb.FailDataExportParams{Now: now(), ID: id})
+}
+
+// Email changes
+
+func (s sqliteQueries) CreateEmailChange(ctx context.Context, arg database.CreateEmailChangeParams) (database.EmailChange, error) {
+	change, err := s.q.CreateEmailChange(ctx, sqlitedb.CreateEmailChangeParams{
+		Token:     arg.Token,
+		Now:       now(),
+		UserID:    arg.UserID,
+		NewEmail:  arg.NewEmail,
+		ExpiresAt: sqliteTime(arg.ExpiresAt),
+	})
+	return database.EmailChange(change), err
+}
+
+func (s sqliteQueries) QueryEmailChange(ctx context.Context, token string) (database.EmailChange, error) {
+	change, err := s.q.QueryEmailChange(ctx, token)
+	return database.EmailChange(change), err
+}
+
+func (s sqliteQueries) ConfirmEmailChange(ctx context.Context, token string) error {
+	return s.q.ConfirmEmailChange(ctx, sqlitedb.ConfirmEmailChangeParams{Now: now(), Token: token})
+}
+
+func (s sqliteQueries) DeletePendingEmailChanges(ctx context.Context, userID uuid.UUID) error {
+	return s.q.DeletePendingEmailChanges(ctx, userID)
+}
+
+// Webhook events
+
+func (s sqliteQueries) CreateWebhookEvent(ctx context.Context, arg database.CreateWebhookEventParams) (database.WebhookEvent, error) {
+	event, err := s.q.CreateWebhookEvent(ctx, sqlitedb.CreateWebhookEventParams{
+		ID:      arg.ID,
+		Now:     now(),
+		Event:   arg.Event,
+		Payload: arg.Payload,
+	})
+	return database.WebhookEvent(event), err
+}
+
+func (s sqliteQueries) QueryWebhookEvent(ctx context.Context, id string) (database.WebhookEvent, error) {
+	event, err := s.q.QueryWebhookEvent(ctx, id)
+	return database.WebhookEvent(event), err
+}
+
+func (s sqliteQueries) ClaimWebhookEvent(ctx context.Context, arg database.ClaimWebhookEventParams) (database.WebhookEvent, error) {
+	event, err := s.q.ClaimWebhookEvent(ctx, sqlitedb.ClaimWebhookEventParams{
+		Now:         now(),
+		ID:          arg.ID,
+		StaleBefore: sqliteTime(arg.StaleBefore),
+	})
+	return database.WebhookEvent(event), err
+}
+
+func (s sqliteQueries) MarkWebhookEvent(ctx context.Context, arg database.MarkWebhookEventParams) (database.WebhookEvent, error) {
+	event, err := s.q.MarkWebhookEvent(ctx, sqlitedb.MarkWebhookEventParams{
+		Status: arg.Status,
+		Error:  arg.Error,
+		Now:    now(),
+		ID:     arg.ID,
+	})
+	return database.WebhookEvent(event), err
+}
+
+// Subscriptions
+
+func (s sqliteQueries) CreateSubscription(ctx context.Context, arg database.CreateSubscriptionParams) (database.Subscription, error) {
+	sub, err := s.q.CreateSubscription(ctx, sqlitedb.CreateSubscriptionParams{
+		ID:                 uuid.New(),
+		Now:                now(),
+		UserID:             arg.UserID,
+		Plan:               arg.Plan,
+		CurrentPeriodStart: sqliteTime(arg.CurrentPeriodStart),
+		CurrentPeriodEnd:   sqliteTime(arg.CurrentPeriodEnd),
+	})
+	return database.Subscription(sub), err
+}
+
+func (s sqliteQueries) QueryActiveSubscription(ctx context.Context, userID uuid.UUID) (database.Subscription, error) {
+	sub, err := s.q.QueryActiveSubscription(ctx, userID)
+	return database.Subscription(sub), err
+}
+
+func (s sqliteQueries) ExtendSubscription(ctx context.Context, arg database.ExtendSubscriptionParams) (database.Subscription, error) {
+	sub, err := s.q.ExtendSubscription(ctx, sqlitedb.ExtendSubscriptionParams{
+		CurrentPeriodEnd: sqliteTime(arg.CurrentPeriodEnd),
+		Now:              now(),
+		ID:               arg.ID,
+	})
+	return database.Subscription(sub), err
+}
+
+func (s sqliteQueries) CancelSubscription(ctx context.Context, userID uuid.UUID) error {
+	return s.q.CancelSubscription(ctx, sqlitedb.CancelSubscriptionParams{Now: now(), UserID: userID})
+}
+
+// ExpireSubscriptions is not atomic on its own; SQLite.ExpireSubscriptions
+// runs it in a transaction.
+func (s sqliteQueries) ExpireSubscriptions(ctx context.Context) ([]uuid.UUID, error) {
+	t := now()
+	expired, err := s.q.ExpireSubscriptions(ctx, t)
+	if err != nil {
+		return nil, err
+	}
+	for _, userID := range expired {
+		err = s.q.DowngradeUser(ctx, sqlitedb.DowngradeUserParams{Now: t, ID: userID})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return expired, nil
+}
+
+// Reports
+
+func toReport(r sqlitedb.Report) database.Report {
+	return database.Report(r)
+}
+
+func (s sqliteQueries) CreateReport(ctx context.Context, arg database.CreateReportParams) (database.Report, error) {
+	report, err := s.q.CreateReport(ctx, sqlitedb.CreateReportParams{
+		ID:         uuid.New(),
+		Now:        now(),
+		ReporterID: arg.ReporterID,
+		UserID:     arg.UserID,
+		ChirpID:    arg.ChirpID,
+		Reason:     arg.Reason,
+		Details:    arg.Details,
+	})
+	return database.Report(report), err
+}
+
+func (s sqliteQueries) QueryReport(ctx context.Context, id uuid.UUID) (database.Report, error) {
+	report, err := s.q.QueryReport(ctx, id)
+	return database.Report(report), err
+}
+
+func (s sqliteQueries) QueryPendingReports(ctx context.Context) ([]database.Report, error) {
+	reports, err := s.q.QueryPendingReports(ctx)
+	return convertRows(reports, toReport), err
+}
+
+func (s sqliteQueries) QueryReportsByStatus(ctx context.Context, status string) ([]database.Report, error) {
+	reports, err := s.q.QueryReportsByStatus(ctx, status)
+	return convertRows(reports, toReport), err
+}
+
+func (s sqliteQueries) AssignReport(ctx context.Context, arg database.AssignReportParams) (database.Report, error) {
+	report, err := s.q.AssignReport(ctx, sqlitedb.AssignReportParams{
+		AssigneeID: arg.AssigneeID,
+		Now:        now(),
+		ID:         arg.ID,
+	})
+	return database.Report(report), err
+}
+
+func (s sqliteQueries) ResolveReport(ctx context.Context, arg database.ResolveReportParams) (database.Report, error) {
+	report, err := s.q.ResolveReport(ctx, sqlitedb.ResolveReportParams{
+		Status: arg.Status,
+		Now:    now(),
+		ID:     arg.ID,
+	})
+	return database.Report(report), err
+}
+
+func (s sqliteQueries) ResolveChirpReports(ctx context.Context, arg database.ResolveChirpReportsParams) ([]database.Report, error) {
+	reports, err := s.q.ResolveChirpReports(ctx, sqlitedb.ResolveChirpReportsParams{
+		Status:  arg.Status,
+		Now:     now(),
+		ChirpID: arg.ChirpID,
+	})
+	return convertRows(reports, toReport), err
+}
+
+func (s sqliteQueries) CountChirpReporters(ctx context.Context, arg database.CountChirpReportersParams) (int64, error) {
+	return s.q.CountChirpReporters(ctx, sqlitedb.CountChirpReportersParams{
+		ChirpID:       arg.ChirpID,
+		CreatedBefore: sqliteTime(arg.CreatedBefore),
+		Now:           sql.NullTime{Time: sqliteTime(arg.Now), Valid: true},
+	})
+}
+
+func (s sqliteQueries) CreateReportEvent(ctx context.Context, arg database.CreateReportEventParams) (database.ReportEvent, error) {
+	event, err := s.q.CreateReportEvent(ctx, sqlitedb.CreateReportEventParams{
+		ID:       uuid.New(),
+		Now:      now(),
+		ReportID: arg.ReportID,
+		ActorID:  arg.ActorID,
+		Action:   arg.Action,
+		Note:     arg.Note,
+	})
+	return database.ReportEvent(event), err
+}
+
+func (s sqliteQueries) QueryReportEvents(ctx context.Context, reportID uuid.UUID) ([]database.ReportEvent, error) {
+	events, err := s.q.QueryReportEvents(ctx, reportID)
+	return convertRows(events, func(e sqlitedb.ReportEvent) database.ReportEvent {
+		return database.ReportEvent(e)
+	}), err
+}
+
+// Sanctions
+
+func (s sqliteQueries) CreateSanction(ctx context.Context, arg database.CreateSanctionParams) (database.Sanction, error) {
+	sanction, err := s.q.CreateSanction(ctx, sqlitedb.CreateSanctionParams{
+		ID:             uuid.New(),
+		Now:            now(),
+		UserID:         arg.UserID,
+		ActorID:        arg.ActorID,
+		Status:         arg.Status,
+		SuspendedUntil: sqliteNullTime(arg.SuspendedUntil),
+		Reason:         arg.Reason,
+	})
+	return database.Sanction(sanction), err
+}
+
+func (s sqliteQueries) QueryUserSanctions(ctx context.Context, userID uuid.UUID) ([]database.Sanction, error) {
+	sanctions, err := s.q.QueryUserSanctions(ctx, userID)
+	return convertRows(sanctions, func(s sqlitedb.Sanction) database.Sanction {
+		return database.Sanction(s)
+	}), err
+}
+
+// Blocks and mutes
+
+func (s sqliteQueries) CreateBlock(ctx context.Context, arg database.CreateBlockParams) (database.Block, error) {
+	block, err := s.q.CreateBlock(ctx, sqlitedb.CreateBlockParams{
+		BlockerID: arg.BlockerID,
+		BlockedID: arg.BlockedID,
+		Now:       now(),
+	})
+	return database.Block(block), err
+}
+
+func (s sqliteQueries) DeleteBlock(ctx context.Context, arg database.DeleteBlockParams) (int64, error) {
+	return s.q.DeleteBlock(ctx, sqlitedb.DeleteBlockParams(arg))
+}
+
+func (s sqliteQueries) QueryBlocks(ctx context.Context, blockerID uuid.UUID) ([]database.Block, error) {
+	blocks, err := s.q.QueryBlocks(ctx, blockerID)
+	return convertRows(blocks, func(b sqlitedb.Block) database.Block {
+		return database.Block(b)
+	}), err
+}
+
+func (s sqliteQueries) QueryBlock(ctx context.Context, arg database.QueryBlockParams) (database.Block, error) {
+	block, err := s.q.QueryBlock(ctx, sqlitedb.QueryBlockParams(arg))
+	return database.Block(block), err
+}
+
+func (s sqliteQueries) CreateMute(ctx context.Context, arg database.CreateMuteParams) (database.Mute, error) {
+	mute, err := s.q.CreateMute(ctx, sqlitedb.CreateMuteParams{
+		MuterID: arg.MuterID,
+		MutedID: arg.MutedID,
+		Now:     now(),
+	})
+	return database.Mute(mute), err
+}
+
+func (s sqliteQueries) DeleteMute(ctx context.Context, arg database.DeleteMuteParams) (int64, error) {
+	return s.q.DeleteMute(ctx, sqlitedb.DeleteMuteParams(arg))
+}
+
+func (s sqliteQueries) QueryMutes(ctx context.Context, muterID uuid.UUID) ([]database.Mute, error) {
+	mutes, err := s.q.QueryMutes(ctx, muterID)
+	return convertRows(mutes, func(m sqlitedb.Mute) database.Mute {
+		return database.Mute(m)
+	}), err
+}
+
+// Audit log
+
+func (s sqliteQueries) CreateAuditEntry(ctx context.Context, arg database.CreateAuditEntryParams) (database.AuditLog, error) {
+	arg.CreatedAt = sqliteTime(arg.CreatedAt)
+	entry, err := s.q.CreateAuditEntry(ctx, sqlitedb.CreateAuditEntryParams(arg))
+	return database.AuditLog(entry), err
+}
+
+func (s sqliteQueries) QueryLastAuditEntry(ctx context.Context) (database.AuditLog, error) {
+	entry, err := s.q.QueryLastAuditEntry(ctx)
+	return database.AuditLog(entry), err
+}
+
+// QueryAuditLog passes its filters untyped: sqlc cannot infer the types of
+// the parameters of the generated query.
+func (s sqliteQueries) QueryAuditLog(ctx context.Context, arg database.QueryAuditLogParams) ([]database.AuditLog, error) {
+	entries, err := s.q.QueryAuditLog(ctx, sqlitedb.QueryAuditLogParams{
+		ActorID:    arg.ActorID,
+		Action:     arg.Action,
+		TargetType: arg.TargetType,
+		TargetID:   arg.TargetID,
+		Since:      sqliteNullTime(arg.Since),
+		Until:      sqliteNullTime(arg.Until),
+		BeforeSeq:  arg.BeforeSeq,
+		MaxEntries: int64(arg.MaxEntries),
+	})
+	return convertRows(entries, func(e sqlitedb.AuditLog) database.AuditLog {
+		return database.AuditLog(e)
+	}), err
+}
+
+func (s sqliteQueries) QueryAuditChain(ctx context.Context) ([]database.AuditLog, error) {
+	entries, err := s.q.QueryAuditChain(ctx)
+	return convertRows(entries, func(e sqlitedb.AuditLog) database.AuditLog {
+		return database.AuditLog(e)
+	}), err
+}
@@ -0,0 +1,60 @@
+package store_test
+
+import (
+	"testing"
+
+	"Chirpy/internal/sqlitetest"
+	"Chirpy/internal/store"
+)
+
+func TestSQLite(t *testing.T) {
+	store.RunSuite(t, func(t *testing.T) store.Store {
+		return store.NewSQLite(sqlitetest.NewDB(t), nil)
+	})
+}
+
+func TestSQLiteAuditLogAppendOnly(t *testing.T) {
+	db := sqlitetest.NewDB(t)
+	if _, err := db.Exec(`INSERT INTO audit_log (seq, created_at, action, target_type, prev_hash, hash)
+		VALUES (1, '2026-01-01 00:00:00', 'reset', 'users', '0', 'a')`); err != nil {
+		t.Fatal(err)
+	}
+	for _, stmt := range []string{
+		"UPDATE audit_log SET action = 'nothing'",
+		"DELETE FROM audit_log",
+	} {
+		if _, err := db.Exec(stmt); err == nil {
+			t.Errorf("%s succeeded on the audit log", stmt)
+		}
+	}
+}
+
+func TestParseURL(t *testing.T) {
+	tests := []struct {
+		url, driver, dsn string
+	}{
+		{"postgres://chirpy@localhost/chirpy?sslmode=disable", "postgres", "postgres://chirpy@localhost/chirpy?sslmode=disable"},
+		{"postgresql://localhost/chirpy", "postgres", "postgresql://localhost/chirpy"},
+		{"host=localhost dbname=chirpy sslmode=disable", "postgres", "host=localhost dbname=chirpy sslmode=disable"},
+		{"sqlite:chirpy.db", "sqlite", "file:chirpy.db?" + pragmas},
+		{"sqlite:///var/lib/chirpy.db", "sqlite", "file:/var/lib/chirpy.db?" + pragmas},
+		{"sqlite::memory:", "sqlite", "file::memory:?" + pragmas},
+		{"sqlite:chirpy.db?_pragma=synchronous(NORMAL)", "sqlite", "file:chirpy.db?" + pragmas + "&_pragma=synchronous(NORMAL)"},
+		{"mysql://localhost/chirpy", "", ""},
+		{"sqlite:", "", ""},
+	}
+	for _, tt := range tests {
+		driver, dsn, err := store.ParseURL(tt.url)
+		if tt.driver == "" {
+			if err == nil {
+				t.Errorf("ParseURL(%q) = %q, want an error", tt.url, dsn)
+			}
+			continue
+		}
+		if err != nil || driver != tt.driver || dsn != tt.dsn {
+			t.Errorf("ParseURL(%q) = %q, %q, %v, want %q, %q", tt.url, driver, dsn, err, tt.driver, tt.dsn)
+		}
+	}
+}
+
+const pragmas = "_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_time_format=sqlite"
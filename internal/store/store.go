@@ -0,0 +1,140 @@
+// Package store abstracts the data layer behind the queries generated by
+// sqlc, so that handlers can run against Postgres, SQLite or, in tests, an
+// in-memory implementation.
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"regexp"
+
+	"Chirpy/internal/database"
+)
+
+// Store is everything the server needs from its database.
+type Store interface {
+	database.Querier
+
+	// WithTx runs fn in a transaction, committing it when fn succeeds and
+	// rolling it back otherwise.
+	WithTx(ctx context.Context, fn func(q database.Querier) error) error
+	// Ping checks that the database answers.
+	Ping(ctx context.Context) error
+	// SchemaVersion is the version of the last migration applied.
+	SchemaVersion(ctx context.Context) (int64, error)
+}
+
+// Names of the database/sql drivers a Store can run on.
+const (
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+)
+
+// sqlitePragmas are added to every SQLite DSN: the schema relies on
+// foreign keys, and _time_format stores times in a format SQLite and the
+// driver both parse back.
+const sqlitePragmas = "_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_time_format=sqlite"
+
+// keyValueDSN matches the start of a Postgres connection string in
+// key=value form, such as "host=localhost dbname=chirpy".
+var keyValueDSN = regexp.MustCompile(`^\s*[a-z_]+\s*=`)
+
+// ParseURL picks the driver for a DB_URL by its scheme and returns the DSN
+// to open it with. Postgres URLs and key=value connection strings are
+// passed through; SQLite ones are sqlite:path, sqlite:///absolute/path or
+// sqlite::memory:.
+func ParseURL(dbURL string) (driver, dsn string, err error) {
+	if keyValueDSN.MatchString(dbURL) {
+		return DriverPostgres, dbURL, nil
+	}
+	u, err := url.Parse(dbURL)
+	if err != nil {
+		return "", "", err
+	}
+	switch u.Scheme {
+	case "postgres", "postgresql":
+		return DriverPostgres, dbURL, nil
+	case "sqlite":
+		path := u.Opaque
+		if path == "" {
+			path = u.Host + u.Path
+		}
+		if path == "" {
+			return "", "", fmt.Errorf("DB_URL %q has no database path", dbURL)
+		}
+		dsn := "file:" + path + "?" + sqlitePragmas
+		if u.RawQuery != "" {
+			dsn += "&" + u.RawQuery
+		}
+		return DriverSQLite, dsn, nil
+	default:
+		return "", "", fmt.Errorf("unsupported DB_URL scheme %q", u.Scheme)
+	}
+}
+
+// New returns the Store for db, opened with the driver returned by ParseURL.
+func New(driver string, db *sql.DB, wrap func(database.DBTX) database.DBTX) (Store, error) {
+	switch driver {
+	case DriverPostgres:
+		return NewPostgres(db, wrap), nil
+	case DriverSQLite:
+		return NewSQLite(db, wrap), nil
+	default:
+		return nil, fmt.Errorf("no store for driver %q", driver)
+	}
+}
+
+// Postgres is the Store backed by a Postgres database.
+type Postgres struct {
+	*database.Queries
+	db   *sql.DB
+	wrap func(database.DBTX) database.DBTX
+}
+
+// NewPostgres returns a Store running its queries on db. wrap, when not
+// nil, decorates every connection and transaction, e.g. to instrument them.
+func NewPostgres(db *sql.DB, wrap func(database.DBTX) database.DBTX) *Postgres {
+	if wrap == nil {
+		wrap = func(db database.DBTX) database.DBTX { return db }
+	}
+	return &Postgres{
+		Queries: database.New(wrap(db)),
+		db:      db,
+		wrap:    wrap,
+	}
+}
+
+func (p *Postgres) WithTx(ctx context.Context, fn func(q database.Querier) error) error {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	err = fn(database.New(p.wrap(tx)))
+	if err != nil {
+		rbErr := tx.Rollback()
+		if rbErr != nil {
+			slog.ErrorContext(ctx, "rollback failed", "err", rbErr)
+		}
+		return err
+	}
+	return tx.Commit()
+}
+
+func (p *Postgres) Ping(ctx context.Context) error {
+	return p.db.PingContext(ctx)
+}
+
+func (p *Postgres) SchemaVersion(ctx context.Context) (int64, error) {
+	return schemaVersion(ctx, p.db)
+}
+
+// schemaVersion reads the version of the last migration applied by goose.
+func schemaVersion(ctx context.Context, db *sql.DB) (int64, error) {
+	var version int64
+	err := db.QueryRowContext(ctx,
+		"SELECT COALESCE(MAX(version_id), 0) FROM goose_db_version WHERE is_applied").Scan(&version)
+	return version, err
+}
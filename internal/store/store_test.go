@@ -0,0 +1,1093 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"Chirpy/internal/database"
+)
+
+// testStore checks that a Store behaves like the queries of
+// sql/queries/users.sql; open returns an empty, migrated store.
+func testStore(t *testing.T, open func(t *testing.T) Store) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T, s Store)
+	}{
+		{"Users", testUsers},
+		{"UserDeletion", testUserDeletion},
+		{"Chirps", testChirps},
+		{"HeldChirps", testHeldChirps},
+		{"RefreshTokens", testRefreshTokens},
+		{"DataExports", testDataExports},
+		{"EmailChanges", testEmailChanges},
+		{"WebhookEvents", testWebhookEvents},
+		{"Subscriptions", testSubscriptions},
+		{"Reports", testReports},
+		{"Sanctions", testSanctions},
+		{"BlocksAndMutes", testBlocksAndMutes},
+		{"AuditLog", testAuditLog},
+		{"WithTx", testWithTx},
+		{"Health", testHealth},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.run(t, open(t))
+		})
+	}
+}
+
+func createUser(t *testing.T, s Store, email string) database.User {
+	t.Helper()
+	user, err := s.CreateUser(context.Background(), database.CreateUserParams{Email: email, HashedPassword: "hash"})
+	if err != nil {
+		t.Fatalf("CreateUser(%s): %v", email, err)
+	}
+	return user
+}
+
+func expectNoRows(t *testing.T, what string, err error) {
+	t.Helper()
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("%s: error = %v, want sql.ErrNoRows", what, err)
+	}
+}
+
+func testUsers(t *testing.T, s Store) {
+	ctx := context.Background()
+	user := createUser(t, s, "walt@breakingbad.com")
+	if user.Role != "user" || user.IsChirpyRed || user.DeletedAt.Valid || user.HashedPassword != "hash" {
+		t.Errorf("new user = %+v", user)
+	}
+	createUser(t, s, "jesse@breakingbad.com")
+
+	if _, err := s.CreateUser(ctx, database.CreateUserParams{Email: user.Email, HashedPassword: "x"}); !errors.Is(Classify(err), ErrUniqueViolation) {
+		t.Errorf("CreateUser() accepted a duplicate email: %v", err)
+	}
+	if got, err := s.QueryUser(ctx, user.Email); err != nil || got.ID != user.ID {
+		t.Errorf("QueryUser() = %v, %v", got.ID, err)
+	}
+	if got, err := s.QueryUserByID(ctx, user.ID); err != nil || got.Email != user.Email {
+		t.Errorf("QueryUserByID() = %v, %v", got.Email, err)
+	}
+	_, err := s.QueryUser(ctx, "saul@breakingbad.com")
+	expectNoRows(t, "QueryUser(unknown)", err)
+	_, err = s.QueryUserByID(ctx, uuid.New())
+	expectNoRows(t, "QueryUserByID(unknown)", err)
+
+	got, err := s.UpdateUserPassword(ctx, database.UpdateUserPasswordParams{ID: user.ID, HashedPassword: "new hash"})
+	if err != nil || got.HashedPassword != "new hash" {
+		t.Errorf("UpdateUserPassword() = %q, %v", got.HashedPassword, err)
+	}
+	got, err = s.UpdateUserEmail(ctx, database.UpdateUserEmailParams{ID: user.ID, Email: "heisenberg@breakingbad.com"})
+	if err != nil || got.Email != "heisenberg@breakingbad.com" {
+		t.Errorf("UpdateUserEmail() = %q, %v", got.Email, err)
+	}
+	if _, err := s.UpdateUserEmail(ctx, database.UpdateUserEmailParams{ID: user.ID, Email: "jesse@breakingbad.com"}); !errors.Is(Classify(err), ErrUniqueViolation) {
+		t.Errorf("UpdateUserEmail() accepted an email in use: %v", err)
+	}
+
+	if got, err := s.UserPro(ctx, user.ID); err != nil || !got.IsChirpyRed {
+		t.Errorf("UserPro() = %v, %v", got.IsChirpyRed, err)
+	}
+	if got, err := s.UserFree(ctx, user.ID); err != nil || got.IsChirpyRed {
+		t.Errorf("UserFree() = %v, %v", got.IsChirpyRed, err)
+	}
+	_, err = s.UserPro(ctx, uuid.New())
+	expectNoRows(t, "UserPro(unknown)", err)
+
+	if got, err := s.SetUserRole(ctx, database.SetUserRoleParams{ID: user.ID, Role: "moderator"}); err != nil || got.Role != "moderator" {
+		t.Errorf("SetUserRole() = %q, %v", got.Role, err)
+	}
+	if _, err := s.SetUserRole(ctx, database.SetUserRoleParams{ID: user.ID, Role: "root"}); !errors.Is(Classify(err), ErrCheckViolation) {
+		t.Errorf("SetUserRole() accepted an unknown role: %v", err)
+	}
+	_, err = s.SetUserRole(ctx, database.SetUserRoleParams{ID: uuid.New(), Role: "admin"})
+	expectNoRows(t, "SetUserRole(unknown)", err)
+}
+
+func testUserDeletion(t *testing.T, s Store) {
+	ctx := context.Background()
+	walt := createUser(t, s, "walt@breakingbad.com")
+	jesse := createUser(t, s, "jesse@breakingbad.com")
+	chirp, err := s.CreateChirp(ctx, database.CreateChirpParams{Body: "say my name", UserID: walt.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deleted, err := s.SoftDeleteUser(ctx, walt.ID)
+	if err != nil || !deleted.DeletedAt.Valid {
+		t.Fatalf("SoftDeleteUser() = %+v, %v", deleted.DeletedAt, err)
+	}
+	//i chirp di un utente cancellato non sono più visibili
+	_, err = s.QueryChirp(ctx, database.QueryChirpParams{ID: chirp.ID})
+	expectNoRows(t, "QueryChirp(deleted author)", err)
+
+	if purged, err := s.PurgeDeletedUsers(ctx, time.Now().Add(-time.Hour)); err != nil || purged != 0 {
+		t.Errorf("PurgeDeletedUsers(within grace) = %d, %v", purged, err)
+	}
+	restored, err := s.RestoreUser(ctx, walt.ID)
+	if err != nil || restored.DeletedAt.Valid {
+		t.Errorf("RestoreUser() = %+v, %v", restored.DeletedAt, err)
+	}
+	if _, err := s.QueryChirp(ctx, database.QueryChirpParams{ID: chirp.ID}); err != nil {
+		t.Errorf("QueryChirp(restored author): %v", err)
+	}
+
+	s.SoftDeleteUser(ctx, walt.ID)
+	err = s.WithTx(ctx, func(q database.Querier) error {
+		locked, err := q.TryLockJob(ctx, "purge:users")
+		if err == nil && !locked {
+			t.Error("TryLockJob() = false with no other transaction")
+		}
+		return err
+	})
+	if err != nil {
+		t.Errorf("TryLockJob() = %v", err)
+	}
+	if purged, err := s.PurgeDeletedUsers(ctx, time.Now().Add(time.Hour)); err != nil || purged != 1 {
+		t.Errorf("PurgeDeletedUsers() = %d, %v", purged, err)
+	}
+	_, err = s.QueryUserByID(ctx, walt.ID)
+	expectNoRows(t, "QueryUserByID(purged)", err)
+	//ON DELETE CASCADE
+	if tokens, err := s.QueryUserRefreshTokens(ctx, walt.ID); err != nil || len(tokens) != 0 {
+		t.Errorf("tokens of a purged user = %v, %v", tokens, err)
+	}
+	createUser(t, s, walt.Email)
+
+	if err := s.DeleteUsers(ctx); err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.QueryUserByID(ctx, jesse.ID)
+	expectNoRows(t, "QueryUserByID after DeleteUsers", err)
+}
+
+func testChirps(t *testing.T, s Store) {
+	ctx := context.Background()
+	walt := createUser(t, s, "walt@breakingbad.com")
+	jesse := createUser(t, s, "jesse@breakingbad.com")
+
+	var chirps []database.Chirp
+	for _, c := range []struct {
+		author uuid.UUID
+		body   string
+	}{{walt.ID, "first"}, {jesse.ID, "second"}, {walt.ID, "third"}} {
+		chirp, err := s.CreateChirp(ctx, database.CreateChirpParams{Body: c.body, UserID: c.author})
+		if err != nil {
+			t.Fatal(err)
+		}
+		chirps = append(chirps, chirp)
+	}
+	if _, err := s.CreateChirp(ctx, database.CreateChirpParams{Body: "orphan", UserID: uuid.New()}); !errors.Is(Classify(err), ErrForeignKeyViolation) {
+		t.Errorf("CreateChirp() accepted an unknown author: %v", err)
+	}
+
+	bodies := func(chirps []database.Chirp) string {
+		out := ""
+		for _, c := range chirps {
+			out += c.Body + ","
+		}
+		return out
+	}
+	all, err := s.QueryAllChirps(ctx, uuid.Nil)
+	if err != nil || bodies(all) != "first,second,third," {
+		t.Errorf("QueryAllChirps() = %s, %v", bodies(all), err)
+	}
+	byWalt, err := s.QueryAllAuthorChirps(ctx, database.QueryAllAuthorChirpsParams{UserID: walt.ID})
+	if err != nil || bodies(byWalt) != "first,third," {
+		t.Errorf("QueryAllAuthorChirps() = %s, %v", bodies(byWalt), err)
+	}
+	got, err := s.QueryChirp(ctx, database.QueryChirpParams{ID: chirps[1].ID})
+	if err != nil || got.Body != "second" || got.UserID != jesse.ID {
+		t.Errorf("QueryChirp() = %+v, %v", got, err)
+	}
+
+	deletion := database.SoftDeleteChirpParams{
+		ID:             chirps[1].ID,
+		DeletedBy:      uuid.NullUUID{UUID: walt.ID, Valid: true},
+		DeletionReason: sql.NullString{String: "spam", Valid: true},
+	}
+	deleted, err := s.SoftDeleteChirp(ctx, deletion)
+	if err != nil || !deleted.DeletedAt.Valid || deleted.DeletedBy.UUID != walt.ID || deleted.DeletionReason.String != "spam" {
+		t.Errorf("SoftDeleteChirp() = %+v, %v", deleted, err)
+	}
+	_, err = s.SoftDeleteChirp(ctx, deletion)
+	expectNoRows(t, "SoftDeleteChirp(deleted)", err)
+	_, err = s.QueryChirp(ctx, database.QueryChirpParams{ID: chirps[1].ID})
+	expectNoRows(t, "QueryChirp(deleted)", err)
+	if all, err := s.QueryAllChirps(ctx, uuid.Nil); err != nil || bodies(all) != "first,third," {
+		t.Errorf("QueryAllChirps() after deletion = %s, %v", bodies(all), err)
+	}
+	if got, err := s.QueryAllAuthorChirps(ctx, database.QueryAllAuthorChirpsParams{UserID: jesse.ID}); err != nil || len(got) != 0 {
+		t.Errorf("QueryAllAuthorChirps() after deletion = %s, %v", bodies(got), err)
+	}
+	if all, err := s.QueryAllChirpsIncludingDeleted(ctx); err != nil || bodies(all) != "first,second,third," {
+		t.Errorf("QueryAllChirpsIncludingDeleted() = %s, %v", bodies(all), err)
+	}
+	if got, err := s.QueryAllAuthorChirpsIncludingDeleted(ctx, jesse.ID); err != nil || bodies(got) != "second," {
+		t.Errorf("QueryAllAuthorChirpsIncludingDeleted() = %s, %v", bodies(got), err)
+	}
+	if got, err := s.QueryChirpIncludingDeleted(ctx, chirps[1].ID); err != nil || !got.DeletedAt.Valid {
+		t.Errorf("QueryChirpIncludingDeleted() = %+v, %v", got, err)
+	}
+
+	restored, err := s.RestoreChirp(ctx, chirps[1].ID)
+	if err != nil || restored.DeletedAt.Valid || restored.DeletedBy.Valid || restored.DeletionReason.Valid {
+		t.Errorf("RestoreChirp() = %+v, %v", restored, err)
+	}
+	_, err = s.RestoreChirp(ctx, chirps[1].ID)
+	expectNoRows(t, "RestoreChirp(not deleted)", err)
+	if _, err := s.QueryChirp(ctx, database.QueryChirpParams{ID: chirps[1].ID}); err != nil {
+		t.Errorf("QueryChirp(restored) = %v", err)
+	}
+
+	if _, err := s.SoftDeleteChirp(ctx, database.SoftDeleteChirpParams{ID: chirps[0].ID}); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := s.PurgeDeletedChirps(ctx, time.Now().Add(-time.Hour)); err != nil || n != 0 {
+		t.Errorf("PurgeDeletedChirps(an hour ago) = %d, %v", n, err)
+	}
+	if n, err := s.PurgeDeletedChirps(ctx, time.Now().Add(time.Hour)); err != nil || n != 1 {
+		t.Errorf("PurgeDeletedChirps(in an hour) = %d, %v", n, err)
+	}
+	_, err = s.QueryChirpIncludingDeleted(ctx, chirps[0].ID)
+	expectNoRows(t, "QueryChirpIncludingDeleted(purged)", err)
+}
+
+func testRefreshTokens(t *testing.T, s Store) {
+	ctx := context.Background()
+	user := createUser(t, s, "walt@breakingbad.com")
+	expires := time.Now().Add(time.Hour)
+
+	for _, token := range []string{"token-1", "token-2"} {
+		_, err := s.CreateRefreshToken(ctx, database.CreateRefreshTokenParams{Token: token, UserID: user.ID, ExpiresAt: expires})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := s.CreateRefreshToken(ctx, database.CreateRefreshTokenParams{Token: "token-1", UserID: user.ID, ExpiresAt: expires}); !errors.Is(Classify(err), ErrUniqueViolation) {
+		t.Errorf("CreateRefreshToken() accepted a duplicate token: %v", err)
+	}
+	if _, err := s.CreateRefreshToken(ctx, database.CreateRefreshTokenParams{Token: "token-3", UserID: uuid.New(), ExpiresAt: expires}); !errors.Is(Classify(err), ErrForeignKeyViolation) {
+		t.Errorf("CreateRefreshToken() accepted an unknown user: %v", err)
+	}
+
+	got, err := s.QueryRefreshToken(ctx, "token-1")
+	if err != nil || got.UserID != user.ID || got.RevokedAt.Valid || !got.ExpiresAt.Truncate(time.Millisecond).Equal(expires.Truncate(time.Millisecond)) {
+		t.Errorf("QueryRefreshToken() = %+v, %v", got, err)
+	}
+	_, err = s.QueryRefreshToken(ctx, "unknown")
+	expectNoRows(t, "QueryRefreshToken(unknown)", err)
+
+	revoked, err := s.RevokeToken(ctx, "token-1")
+	if err != nil || !revoked.RevokedAt.Valid {
+		t.Errorf("RevokeToken() = %+v, %v", revoked.RevokedAt, err)
+	}
+	_, err = s.RevokeToken(ctx, "unknown")
+	expectNoRows(t, "RevokeToken(unknown)", err)
+
+	if err := s.RevokeUserTokens(ctx, user.ID); err != nil {
+		t.Fatal(err)
+	}
+	tokens, err := s.QueryUserRefreshTokens(ctx, user.ID)
+	if err != nil || len(tokens) != 2 {
+		t.Fatalf("QueryUserRefreshTokens() = %d tokens, %v", len(tokens), err)
+	}
+	if tokens[0].Token != "token-1" || !tokens[0].RevokedAt.Time.Equal(revoked.RevokedAt.Time) {
+		t.Errorf("RevokeUserTokens() changed the revocation of token-1: %+v", tokens[0])
+	}
+	if !tokens[1].RevokedAt.Valid {
+		t.Errorf("token-2 was not revoked")
+	}
+}
+
+func testDataExports(t *testing.T, s Store) {
+	ctx := context.Background()
+	user := createUser(t, s, "walt@breakingbad.com")
+
+	_, err := s.QueryLatestDataExport(ctx, user.ID)
+	expectNoRows(t, "QueryLatestDataExport(none)", err)
+
+	first, err := s.CreateDataExport(ctx, user.ID)
+	if err != nil || first.Status != "pending" {
+		t.Fatalf("CreateDataExport() = %+v, %v", first, err)
+	}
+	//un utente ha al più un export in attesa o in costruzione
+	if _, err := s.CreateDataExport(ctx, user.ID); !errors.Is(Classify(err), ErrUniqueViolation) {
+		t.Errorf("CreateDataExport() accepted a second open export: %v", err)
+	}
+	jesse := createUser(t, s, "jesse@breakingbad.com")
+	second, _ := s.CreateDataExport(ctx, jesse.ID)
+	if _, err := s.CreateDataExport(ctx, uuid.New()); !errors.Is(Classify(err), ErrForeignKeyViolation) {
+		t.Errorf("CreateDataExport() accepted an unknown user: %v", err)
+	}
+
+	fresh := time.Now().Add(-time.Hour)
+	pending, err := s.QueryPendingDataExports(ctx, fresh)
+	if err != nil || len(pending) != 2 || pending[0].ID != first.ID {
+		t.Errorf("QueryPendingDataExports() = %v, %v", pending, err)
+	}
+
+	claim := func(id uuid.UUID, staleBefore time.Time) (database.DataExport, error) {
+		return s.ClaimDataExport(ctx, database.ClaimDataExportParams{ID: id, StaleBefore: staleBefore})
+	}
+	claimed, err := claim(first.ID, fresh)
+	if err != nil || claimed.Status != "running" {
+		t.Errorf("ClaimDataExport() = %q, %v", claimed.Status, err)
+	}
+	_, err = claim(first.ID, fresh)
+	expectNoRows(t, "ClaimDataExport(claimed)", err)
+	if pending, _ := s.QueryPendingDataExports(ctx, fresh); len(pending) != 1 {
+		t.Errorf("QueryPendingDataExports() with a running export = %v", pending)
+	}
+	//una costruzione ferma da troppo tempo si può riprendere
+	stale := time.Now().Add(time.Hour)
+	if pending, _ := s.QueryPendingDataExports(ctx, stale); len(pending) != 2 {
+		t.Errorf("QueryPendingDataExports() with a stale export = %v", pending)
+	}
+	if reclaimed, err := claim(first.ID, stale); err != nil || reclaimed.Status != "running" {
+		t.Errorf("ClaimDataExport(stale) = %q, %v", reclaimed.Status, err)
+	}
+
+	if err := s.CompleteDataExport(ctx, database.CompleteDataExportParams{ID: first.ID, Archive: []byte("zip")}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.FailDataExport(ctx, second.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	latest, err := s.QueryLatestDataExport(ctx, jesse.ID)
+	if err != nil || latest.ID != second.ID || latest.Status != "failed" || !latest.CompletedAt.Valid {
+		t.Errorf("QueryLatestDataExport() = %+v, %v", latest, err)
+	}
+	if pending, _ := s.QueryPendingDataExports(ctx, stale); len(pending) != 0 {
+		t.Errorf("exports still pending: %v", pending)
+	}
+
+	third, _ := s.CreateDataExport(ctx, user.ID)
+	claim(third.ID, fresh)
+	s.CompleteDataExport(ctx, database.CompleteDataExportParams{ID: third.ID, Archive: []byte("zip")})
+	latest, _ = s.QueryLatestDataExport(ctx, user.ID)
+	if latest.Status != "ready" || string(latest.Archive) != "zip" {
+		t.Errorf("completed export = %q, %q", latest.Status, latest.Archive)
+	}
+}
+
+func testEmailChanges(t *testing.T, s Store) {
+	ctx := context.Background()
+	user := createUser(t, s, "walt@breakingbad.com")
+	params := database.CreateEmailChangeParams{
+		Token:     "change-token",
+		UserID:    user.ID,
+		NewEmail:  "heisenberg@breakingbad.com",
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+
+	change, err := s.CreateEmailChange(ctx, params)
+	if err != nil || change.NewEmail != params.NewEmail || change.ConfirmedAt.Valid {
+		t.Fatalf("CreateEmailChange() = %+v, %v", change, err)
+	}
+	if _, err := s.CreateEmailChange(ctx, params); !errors.Is(Classify(err), ErrUniqueViolation) {
+		t.Errorf("CreateEmailChange() accepted a duplicate token: %v", err)
+	}
+	_, err = s.QueryEmailChange(ctx, "unknown")
+	expectNoRows(t, "QueryEmailChange(unknown)", err)
+
+	if err := s.ConfirmEmailChange(ctx, params.Token); err != nil {
+		t.Fatal(err)
+	}
+	change, err = s.QueryEmailChange(ctx, params.Token)
+	if err != nil || !change.ConfirmedAt.Valid || change.UserID != user.ID {
+		t.Errorf("QueryEmailChange() = %+v, %v", change, err)
+	}
+
+	//le richieste in attesa si cancellano, quelle confermate restano
+	pending := params
+	pending.Token = "pending-token"
+	if _, err := s.CreateEmailChange(ctx, pending); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DeletePendingEmailChanges(ctx, user.ID); err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.QueryEmailChange(ctx, pending.Token)
+	expectNoRows(t, "QueryEmailChange(deleted)", err)
+	if _, err := s.QueryEmailChange(ctx, params.Token); err != nil {
+		t.Errorf("DeletePendingEmailChanges() deleted a confirmed change: %v", err)
+	}
+}
+
+func testWebhookEvents(t *testing.T, s Store) {
+	ctx := context.Background()
+	params := database.CreateWebhookEventParams{ID: "evt_1", Event: "user.upgraded", Payload: []byte(`{"id":"evt_1"}`)}
+
+	event, err := s.CreateWebhookEvent(ctx, params)
+	if err != nil || event.Status != "received" || event.Attempts != 0 {
+		t.Fatalf("CreateWebhookEvent() = %+v, %v", event, err)
+	}
+	//ON CONFLICT DO NOTHING
+	_, err = s.CreateWebhookEvent(ctx, params)
+	expectNoRows(t, "CreateWebhookEvent(duplicate)", err)
+
+	got, err := s.QueryWebhookEvent(ctx, "evt_1")
+	var payload map[string]string
+	if err != nil || json.Unmarshal(got.Payload, &payload) != nil || payload["id"] != "evt_1" {
+		t.Errorf("QueryWebhookEvent() = %s, %v", got.Payload, err)
+	}
+	_, err = s.QueryWebhookEvent(ctx, "evt_2")
+	expectNoRows(t, "QueryWebhookEvent(unknown)", err)
+
+	claim := func(staleBefore time.Time) (database.WebhookEvent, error) {
+		return s.ClaimWebhookEvent(ctx, database.ClaimWebhookEventParams{ID: "evt_1", StaleBefore: staleBefore})
+	}
+	claimed, err := claim(time.Now().Add(-time.Hour))
+	if err != nil || claimed.Status != "processing" {
+		t.Errorf("ClaimWebhookEvent() = %+v, %v", claimed, err)
+	}
+	//una sola consegna alla volta, a meno che l'altra non si sia piantata
+	_, err = claim(time.Now().Add(-time.Hour))
+	expectNoRows(t, "ClaimWebhookEvent(processing)", err)
+	if _, err := claim(time.Now().Add(time.Hour)); err != nil {
+		t.Errorf("ClaimWebhookEvent(stale) = %v", err)
+	}
+	_, err = s.ClaimWebhookEvent(ctx, database.ClaimWebhookEventParams{ID: "evt_2"})
+	expectNoRows(t, "ClaimWebhookEvent(unknown)", err)
+
+	for attempt := int32(1); attempt <= 2; attempt++ {
+		marked, err := s.MarkWebhookEvent(ctx, database.MarkWebhookEventParams{
+			ID:     "evt_1",
+			Status: "failed",
+			Error:  sql.NullString{String: "boom", Valid: true},
+		})
+		if err != nil || marked.Attempts != attempt || marked.Error.String != "boom" || !marked.ProcessedAt.Valid {
+			t.Errorf("MarkWebhookEvent() = %+v, %v", marked, err)
+		}
+	}
+	if _, err := s.MarkWebhookEvent(ctx, database.MarkWebhookEventParams{ID: "evt_1", Status: "lost"}); !errors.Is(Classify(err), ErrCheckViolation) {
+		t.Errorf("MarkWebhookEvent() accepted an unknown status: %v", err)
+	}
+	//un evento fallito si può riprendere, uno elaborato no
+	if _, err := claim(time.Now().Add(-time.Hour)); err != nil {
+		t.Errorf("ClaimWebhookEvent(failed) = %v", err)
+	}
+	if _, err := s.MarkWebhookEvent(ctx, database.MarkWebhookEventParams{ID: "evt_1", Status: "processed"}); err != nil {
+		t.Fatal(err)
+	}
+	_, err = claim(time.Now().Add(time.Hour))
+	expectNoRows(t, "ClaimWebhookEvent(processed)", err)
+}
+
+func testReports(t *testing.T, s Store) {
+	ctx := context.Background()
+	walt := createUser(t, s, "walt@breakingbad.com")
+	jesse := createUser(t, s, "jesse@breakingbad.com")
+	hank := createUser(t, s, "hank@dea.gov")
+	chirp, err := s.CreateChirp(ctx, database.CreateChirpParams{Body: "say my name", UserID: walt.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	onChirp := uuid.NullUUID{UUID: chirp.ID, Valid: true}
+
+	report := func(reporter uuid.UUID, chirpID uuid.NullUUID, reason string) (database.Report, error) {
+		return s.CreateReport(ctx, database.CreateReportParams{
+			ReporterID: reporter,
+			UserID:     walt.ID,
+			ChirpID:    chirpID,
+			Reason:     reason,
+			Details:    sql.NullString{String: "details", Valid: true},
+		})
+	}
+	first, err := report(jesse.ID, onChirp, "spam")
+	if err != nil || first.Status != "open" || first.ChirpID != onChirp || first.AssigneeID.Valid || first.ResolvedAt.Valid {
+		t.Fatalf("CreateReport() = %+v, %v", first, err)
+	}
+	if _, err := report(jesse.ID, onChirp, "hate"); !errors.Is(Classify(err), ErrUniqueViolation) {
+		t.Errorf("CreateReport() accepted a second pending report of the chirp: %v", err)
+	}
+	if _, err := report(jesse.ID, uuid.NullUUID{}, "harassment"); err != nil {
+		t.Errorf("CreateReport(user) = %v", err)
+	}
+	if _, err := report(jesse.ID, uuid.NullUUID{}, "harassment"); !errors.Is(Classify(err), ErrUniqueViolation) {
+		t.Errorf("CreateReport() accepted a second pending report of the user: %v", err)
+	}
+	if _, err := report(hank.ID, onChirp, "rude"); !errors.Is(Classify(err), ErrCheckViolation) {
+		t.Errorf("CreateReport() accepted an unknown reason: %v", err)
+	}
+	if _, err := report(hank.ID, uuid.NullUUID{UUID: uuid.New(), Valid: true}, "spam"); !errors.Is(Classify(err), ErrForeignKeyViolation) {
+		t.Errorf("CreateReport() accepted an unknown chirp: %v", err)
+	}
+	second, err := report(hank.ID, onChirp, "violence")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reporters := func(createdBefore time.Time) (int64, error) {
+		return s.CountChirpReporters(ctx, database.CountChirpReportersParams{ChirpID: onChirp, CreatedBefore: createdBefore, Now: time.Now()})
+	}
+	if n, err := reporters(time.Now().Add(time.Hour)); err != nil || n != 2 {
+		t.Errorf("CountChirpReporters() = %d, %v", n, err)
+	}
+	//gli account troppo recenti e quelli sanzionati non contano
+	if n, err := reporters(time.Now().Add(-time.Hour)); err != nil || n != 0 {
+		t.Errorf("CountChirpReporters() of new accounts = %d, %v", n, err)
+	}
+	sanction := func(status string, until sql.NullTime) {
+		t.Helper()
+		if _, err := s.SetUserStatus(ctx, database.SetUserStatusParams{ID: hank.ID, Status: status, SuspendedUntil: until}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, tt := range []struct {
+		status string
+		until  sql.NullTime
+		want   int64
+	}{
+		{"shadow_banned", sql.NullTime{}, 1},
+		{"suspended", sql.NullTime{Time: time.Now().Add(time.Hour), Valid: true}, 1},
+		{"suspended", sql.NullTime{Time: time.Now().Add(-time.Hour), Valid: true}, 2},
+		{"active", sql.NullTime{}, 2},
+	} {
+		sanction(tt.status, tt.until)
+		if n, err := reporters(time.Now().Add(time.Hour)); err != nil || n != tt.want {
+			t.Errorf("CountChirpReporters() with a %s reporter = %d, %v, want %d", tt.status, n, err, tt.want)
+		}
+	}
+	if got, err := s.QueryReport(ctx, first.ID); err != nil || got.Reason != "spam" {
+		t.Errorf("QueryReport() = %+v, %v", got, err)
+	}
+	_, err = s.QueryReport(ctx, uuid.New())
+	expectNoRows(t, "QueryReport(unknown)", err)
+	if pending, err := s.QueryPendingReports(ctx); err != nil || len(pending) != 3 || pending[0].ID != first.ID {
+		t.Errorf("QueryPendingReports() = %d reports, %v", len(pending), err)
+	}
+
+	assigned, err := s.AssignReport(ctx, database.AssignReportParams{ID: first.ID, AssigneeID: uuid.NullUUID{UUID: hank.ID, Valid: true}})
+	if err != nil || assigned.Status != "assigned" || assigned.AssigneeID.UUID != hank.ID {
+		t.Errorf("AssignReport() = %+v, %v", assigned, err)
+	}
+	if _, err := s.ResolveReport(ctx, database.ResolveReportParams{ID: first.ID, Status: "ignored"}); !errors.Is(Classify(err), ErrCheckViolation) {
+		t.Errorf("ResolveReport() accepted an unknown status: %v", err)
+	}
+	resolved, err := s.ResolveReport(ctx, database.ResolveReportParams{ID: first.ID, Status: "dismissed"})
+	if err != nil || resolved.Status != "dismissed" || !resolved.ResolvedAt.Valid {
+		t.Errorf("ResolveReport() = %+v, %v", resolved, err)
+	}
+	_, err = s.ResolveReport(ctx, database.ResolveReportParams{ID: first.ID, Status: "chirp_removed"})
+	expectNoRows(t, "ResolveReport(resolved)", err)
+	_, err = s.AssignReport(ctx, database.AssignReportParams{ID: first.ID, AssigneeID: uuid.NullUUID{UUID: hank.ID, Valid: true}})
+	expectNoRows(t, "AssignReport(resolved)", err)
+	if dismissed, err := s.QueryReportsByStatus(ctx, "dismissed"); err != nil || len(dismissed) != 1 {
+		t.Errorf("QueryReportsByStatus(dismissed) = %d reports, %v", len(dismissed), err)
+	}
+	if n, err := reporters(time.Now().Add(time.Hour)); err != nil || n != 1 {
+		t.Errorf("CountChirpReporters() after a dismissal = %d, %v", n, err)
+	}
+	third, err := report(jesse.ID, onChirp, "spam")
+	if err != nil {
+		t.Errorf("CreateReport() after the first was resolved = %v", err)
+	}
+
+	event, err := s.CreateReportEvent(ctx, database.CreateReportEventParams{
+		ReportID: second.ID,
+		ActorID:  uuid.NullUUID{UUID: hank.ID, Valid: true},
+		Action:   "assigned",
+	})
+	if err != nil || event.Action != "assigned" {
+		t.Errorf("CreateReportEvent() = %+v, %v", event, err)
+	}
+	if _, err := s.CreateReportEvent(ctx, database.CreateReportEventParams{ReportID: second.ID, Action: "auto_hidden"}); err != nil {
+		t.Errorf("CreateReportEvent(no actor) = %v", err)
+	}
+	if _, err := s.CreateReportEvent(ctx, database.CreateReportEventParams{ReportID: uuid.New(), Action: "x"}); !errors.Is(Classify(err), ErrForeignKeyViolation) {
+		t.Errorf("CreateReportEvent() accepted an unknown report: %v", err)
+	}
+
+	//l'attore cancellato resta come NULL, il report del reporter cancellato sparisce
+	if _, err := s.SoftDeleteUser(ctx, hank.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.PurgeDeletedUsers(ctx, time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.QueryReport(ctx, second.ID)
+	expectNoRows(t, "QueryReport(reporter purged)", err)
+	if events, err := s.QueryReportEvents(ctx, second.ID); err != nil || len(events) != 0 {
+		t.Errorf("QueryReportEvents(report deleted) = %d events, %v", len(events), err)
+	}
+	if got, err := s.QueryReport(ctx, first.ID); err != nil || got.AssigneeID.Valid {
+		t.Errorf("QueryReport() after the assignee was purged = %+v, %v", got.AssigneeID, err)
+	}
+
+	//un chirp segnalato resta finché i report sono aperti, poi i report
+	//sopravvivono senza il chirp
+	if _, err := s.SoftDeleteChirp(ctx, database.SoftDeleteChirpParams{ID: chirp.ID}); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := s.PurgeDeletedChirps(ctx, time.Now().Add(time.Hour)); err != nil || n != 0 {
+		t.Errorf("PurgeDeletedChirps() with a pending report = %d, %v", n, err)
+	}
+	if _, err := s.ResolveChirpReports(ctx, database.ResolveChirpReportsParams{ChirpID: onChirp, Status: "ignored"}); !errors.Is(Classify(err), ErrCheckViolation) {
+		t.Errorf("ResolveChirpReports() accepted an unknown status: %v", err)
+	}
+	if closed, err := s.ResolveChirpReports(ctx, database.ResolveChirpReportsParams{ChirpID: onChirp, Status: "chirp_removed"}); err != nil ||
+		len(closed) != 1 || closed[0].ID != third.ID || !closed[0].ResolvedAt.Valid {
+		t.Errorf("ResolveChirpReports() = %+v, %v", closed, err)
+	}
+	if n, err := s.PurgeDeletedChirps(ctx, time.Now().Add(time.Hour)); err != nil || n != 1 {
+		t.Errorf("PurgeDeletedChirps() once resolved = %d, %v", n, err)
+	}
+	for _, id := range []uuid.UUID{first.ID, third.ID} {
+		if got, err := s.QueryReport(ctx, id); err != nil || got.ChirpID.Valid {
+			t.Errorf("QueryReport() after the chirp was purged = %+v, %v", got, err)
+		}
+	}
+	if pending, err := s.QueryPendingReports(ctx); err != nil || len(pending) != 1 || pending[0].ChirpID.Valid {
+		t.Errorf("QueryPendingReports() after the purge = %+v, %v", pending, err)
+	}
+}
+
+func testSubscriptions(t *testing.T, s Store) {
+	ctx := context.Background()
+	walt := createUser(t, s, "walt@breakingbad.com")
+	jesse := createUser(t, s, "jesse@breakingbad.com")
+	now := time.Now()
+
+	_, err := s.QueryActiveSubscription(ctx, walt.ID)
+	expectNoRows(t, "QueryActiveSubscription(none)", err)
+
+	subscribe := func(user uuid.UUID, end time.Time) (database.Subscription, error) {
+		return s.CreateSubscription(ctx, database.CreateSubscriptionParams{
+			UserID:             user,
+			Plan:               "chirpy_red",
+			CurrentPeriodStart: now,
+			CurrentPeriodEnd:   end,
+		})
+	}
+	sub, err := subscribe(walt.ID, now.Add(time.Hour))
+	if err != nil || sub.Status != "active" {
+		t.Fatalf("CreateSubscription() = %+v, %v", sub, err)
+	}
+	if _, err := subscribe(walt.ID, now.Add(time.Hour)); !errors.Is(Classify(err), ErrUniqueViolation) {
+		t.Errorf("CreateSubscription() accepted a second active subscription: %v", err)
+	}
+
+	end := now.Add(48 * time.Hour).Truncate(time.Second)
+	extended, err := s.ExtendSubscription(ctx, database.ExtendSubscriptionParams{ID: sub.ID, CurrentPeriodEnd: end})
+	if err != nil || !extended.CurrentPeriodEnd.Equal(end) {
+		t.Errorf("ExtendSubscription() = %v, %v", extended.CurrentPeriodEnd, err)
+	}
+	active, err := s.QueryActiveSubscription(ctx, walt.ID)
+	if err != nil || active.ID != sub.ID {
+		t.Errorf("QueryActiveSubscription() = %v, %v", active.ID, err)
+	}
+
+	if err := s.CancelSubscription(ctx, walt.ID); err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.QueryActiveSubscription(ctx, walt.ID)
+	expectNoRows(t, "QueryActiveSubscription(canceled)", err)
+	if _, err := subscribe(walt.ID, now.Add(time.Hour)); err != nil {
+		t.Errorf("CreateSubscription() after cancel: %v", err)
+	}
+
+	s.UserPro(ctx, jesse.ID)
+	if _, err := subscribe(jesse.ID, now.Add(-time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	expired, err := s.ExpireSubscriptions(ctx)
+	if err != nil || len(expired) != 1 || expired[0] != jesse.ID {
+		t.Fatalf("ExpireSubscriptions() = %v, %v", expired, err)
+	}
+	if user, _ := s.QueryUserByID(ctx, jesse.ID); user.IsChirpyRed {
+		t.Errorf("user is still Chirpy Red after the subscription expired")
+	}
+	_, err = s.QueryActiveSubscription(ctx, jesse.ID)
+	expectNoRows(t, "QueryActiveSubscription(expired)", err)
+}
+
+func testWithTx(t *testing.T, s Store) {
+	ctx := context.Background()
+	boom := errors.New("boom")
+
+	err := s.WithTx(ctx, func(q database.Querier) error {
+		if _, err := q.CreateUser(ctx, database.CreateUserParams{Email: "jesse@breakingbad.com"}); err != nil {
+			return err
+		}
+		return boom
+	})
+	if !errors.Is(err, boom) {
+		t.Fatalf("WithTx() error = %v", err)
+	}
+	_, err = s.QueryUser(ctx, "jesse@breakingbad.com")
+	expectNoRows(t, "user of a rolled back transaction", err)
+
+	err = s.WithTx(ctx, func(q database.Querier) error {
+		_, err := q.CreateUser(ctx, database.CreateUserParams{Email: "jesse@breakingbad.com"})
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.QueryUser(ctx, "jesse@breakingbad.com"); err != nil {
+		t.Errorf("user of a committed transaction: %v", err)
+	}
+}
+
+func testHealth(t *testing.T, s Store) {
+	ctx := context.Background()
+	if err := s.Ping(ctx); err != nil {
+		t.Errorf("Ping() = %v", err)
+	}
+	if version, err := s.SchemaVersion(ctx); err != nil || version <= 0 {
+		t.Errorf("SchemaVersion() = %d, %v", version, err)
+	}
+}
+
+func testSanctions(t *testing.T, s Store) {
+	ctx := context.Background()
+	walt := createUser(t, s, "walt@breakingbad.com")
+	hank := createUser(t, s, "hank@dea.gov")
+	if walt.Status != "active" || walt.SuspendedUntil.Valid {
+		t.Errorf("CreateUser() status = %q, until %v", walt.Status, walt.SuspendedUntil)
+	}
+	chirp, err := s.CreateChirp(ctx, database.CreateChirpParams{Body: "say my name", UserID: walt.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	until := time.Now().Add(24 * time.Hour).UTC().Truncate(time.Second)
+	suspended, err := s.SetUserStatus(ctx, database.SetUserStatusParams{
+		ID:             walt.ID,
+		Status:         "suspended",
+		SuspendedUntil: sql.NullTime{Time: until, Valid: true},
+	})
+	if err != nil || suspended.Status != "suspended" || !suspended.SuspendedUntil.Time.Equal(until) {
+		t.Errorf("SetUserStatus(suspended) = %+v, %v", suspended, err)
+	}
+	if _, err := s.SetUserStatus(ctx, database.SetUserStatusParams{ID: walt.ID, Status: "exiled"}); !errors.Is(Classify(err), ErrCheckViolation) {
+		t.Errorf("SetUserStatus() accepted an unknown status: %v", err)
+	}
+	_, err = s.SetUserStatus(ctx, database.SetUserStatusParams{ID: uuid.New(), Status: "banned"})
+	expectNoRows(t, "SetUserStatus(unknown user)", err)
+
+	//i chirp di un utente in shadow ban li vede solo lui
+	if _, err := s.SetUserStatus(ctx, database.SetUserStatusParams{ID: walt.ID, Status: "shadow_banned"}); err != nil {
+		t.Fatal(err)
+	}
+	if all, err := s.QueryAllChirps(ctx, hank.ID); err != nil || len(all) != 0 {
+		t.Errorf("QueryAllChirps(other viewer) = %d chirps, %v", len(all), err)
+	}
+	if all, err := s.QueryAllChirps(ctx, walt.ID); err != nil || len(all) != 1 {
+		t.Errorf("QueryAllChirps(shadow-banned author) = %d chirps, %v", len(all), err)
+	}
+	byWalt := database.QueryAllAuthorChirpsParams{UserID: walt.ID}
+	if got, err := s.QueryAllAuthorChirps(ctx, byWalt); err != nil || len(got) != 0 {
+		t.Errorf("QueryAllAuthorChirps(anonymous) = %d chirps, %v", len(got), err)
+	}
+	byWalt.ViewerID = walt.ID
+	if got, err := s.QueryAllAuthorChirps(ctx, byWalt); err != nil || len(got) != 1 {
+		t.Errorf("QueryAllAuthorChirps(shadow-banned author) = %d chirps, %v", len(got), err)
+	}
+	_, err = s.QueryChirp(ctx, database.QueryChirpParams{ID: chirp.ID, ViewerID: hank.ID})
+	expectNoRows(t, "QueryChirp(other viewer)", err)
+	if _, err := s.QueryChirp(ctx, database.QueryChirpParams{ID: chirp.ID, ViewerID: walt.ID}); err != nil {
+		t.Errorf("QueryChirp(shadow-banned author) = %v", err)
+	}
+
+	sanction, err := s.CreateSanction(ctx, database.CreateSanctionParams{
+		UserID:         walt.ID,
+		ActorID:        uuid.NullUUID{UUID: hank.ID, Valid: true},
+		Status:         "suspended",
+		SuspendedUntil: sql.NullTime{Time: until, Valid: true},
+		Reason:         "threats",
+	})
+	if err != nil || sanction.Status != "suspended" || sanction.ActorID.UUID != hank.ID || !sanction.SuspendedUntil.Time.Equal(until) {
+		t.Errorf("CreateSanction() = %+v, %v", sanction, err)
+	}
+	if _, err := s.CreateSanction(ctx, database.CreateSanctionParams{UserID: walt.ID, Status: "active", Reason: "appeal"}); err != nil {
+		t.Errorf("CreateSanction(no actor) = %v", err)
+	}
+	if _, err := s.CreateSanction(ctx, database.CreateSanctionParams{UserID: uuid.New(), Status: "banned", Reason: "x"}); !errors.Is(Classify(err), ErrForeignKeyViolation) {
+		t.Errorf("CreateSanction() accepted an unknown user: %v", err)
+	}
+	if _, err := s.CreateSanction(ctx, database.CreateSanctionParams{UserID: walt.ID, Status: "exiled", Reason: "x"}); !errors.Is(Classify(err), ErrCheckViolation) {
+		t.Errorf("CreateSanction() accepted an unknown status: %v", err)
+	}
+
+	//il moderatore cancellato resta come NULL nello storico
+	if _, err := s.SoftDeleteUser(ctx, hank.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.PurgeDeletedUsers(ctx, time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	history, err := s.QueryUserSanctions(ctx, walt.ID)
+	if err != nil || len(history) != 2 || history[0].ID != sanction.ID || history[0].ActorID.Valid || history[1].Status != "active" {
+		t.Errorf("QueryUserSanctions() = %+v, %v", history, err)
+	}
+}
+
+func testHeldChirps(t *testing.T, s Store) {
+	ctx := context.Background()
+	walt := createUser(t, s, "walt@breakingbad.com")
+	jesse := createUser(t, s, "jesse@breakingbad.com")
+	before := time.Now().Add(-time.Minute)
+
+	published, err := s.CreateChirp(ctx, database.CreateChirpParams{Body: "Say my name", UserID: walt.ID, BodyHash: "name"})
+	if err != nil || published.BodyHash != "name" || published.HeldReason.Valid {
+		t.Fatalf("CreateChirp() = %+v, %v", published, err)
+	}
+	held, err := s.CreateChirp(ctx, database.CreateChirpParams{Body: "say my name!", UserID: walt.ID, BodyHash: "name",
+		HeldReason: sql.NullString{String: "too many links", Valid: true}})
+	if err != nil || held.HeldReason.String != "too many links" {
+		t.Fatalf("CreateChirp(held) = %+v, %v", held, err)
+	}
+	if _, err := s.CreateChirp(ctx, database.CreateChirpParams{Body: "yeah science", UserID: jesse.ID, BodyHash: "science"}); err != nil {
+		t.Fatal(err)
+	}
+
+	//i chirp trattenuti contano per i limiti ma non vengono elencati
+	count := database.CountUserChirpsSinceParams{UserID: walt.ID, CreatedAt: before}
+	if n, err := s.CountUserChirpsSince(ctx, count); err != nil || n != 2 {
+		t.Errorf("CountUserChirpsSince() = %d, %v", n, err)
+	}
+	count.CreatedAt = time.Now().Add(time.Minute)
+	if n, err := s.CountUserChirpsSince(ctx, count); err != nil || n != 0 {
+		t.Errorf("CountUserChirpsSince(future) = %d, %v", n, err)
+	}
+	err = s.WithTx(ctx, func(q database.Querier) error {
+		if err := q.LockUser(ctx, walt.ID); err != nil {
+			return err
+		}
+		_, err := q.CountUserChirpsSince(ctx, database.CountUserChirpsSinceParams{UserID: walt.ID, CreatedAt: before})
+		return err
+	})
+	if err != nil {
+		t.Errorf("LockUser() = %v", err)
+	}
+	duplicates := database.CountDuplicateChirpsParams{UserID: walt.ID, BodyHash: "name", CreatedAt: before}
+	if n, err := s.CountDuplicateChirps(ctx, duplicates); err != nil || n != 2 {
+		t.Errorf("CountDuplicateChirps() = %d, %v", n, err)
+	}
+	duplicates.UserID = jesse.ID
+	if n, err := s.CountDuplicateChirps(ctx, duplicates); err != nil || n != 0 {
+		t.Errorf("CountDuplicateChirps(other author) = %d, %v", n, err)
+	}
+	if all, err := s.QueryAllChirps(ctx, walt.ID); err != nil || len(all) != 2 {
+		t.Errorf("QueryAllChirps() = %d chirps, %v", len(all), err)
+	}
+	if got, err := s.QueryChirp(ctx, database.QueryChirpParams{ID: held.ID}); err != nil || !got.HeldReason.Valid {
+		t.Errorf("QueryChirp(held) = %+v, %v", got, err)
+	}
+	if got, err := s.QueryHeldChirps(ctx); err != nil || len(got) != 1 || got[0].ID != held.ID {
+		t.Errorf("QueryHeldChirps() = %+v, %v", got, err)
+	}
+
+	released, err := s.ReleaseChirp(ctx, held.ID)
+	if err != nil || released.HeldReason.Valid {
+		t.Errorf("ReleaseChirp() = %+v, %v", released, err)
+	}
+	_, err = s.ReleaseChirp(ctx, held.ID)
+	expectNoRows(t, "ReleaseChirp(released)", err)
+	_, err = s.ReleaseChirp(ctx, published.ID)
+	expectNoRows(t, "ReleaseChirp(published)", err)
+	if got, err := s.QueryAllAuthorChirps(ctx, database.QueryAllAuthorChirpsParams{UserID: walt.ID}); err != nil || len(got) != 2 {
+		t.Errorf("QueryAllAuthorChirps() after release = %d chirps, %v", len(got), err)
+	}
+	if got, err := s.QueryHeldChirps(ctx); err != nil || len(got) != 0 {
+		t.Errorf("QueryHeldChirps() after release = %+v, %v", got, err)
+	}
+}
+
+func testBlocksAndMutes(t *testing.T, s Store) {
+	ctx := context.Background()
+	walt := createUser(t, s, "walt@breakingbad.com")
+	jesse := createUser(t, s, "jesse@breakingbad.com")
+	hank := createUser(t, s, "hank@dea.gov")
+	var chirps []database.Chirp
+	for _, u := range []database.User{walt, jesse, hank} {
+		chirp, err := s.CreateChirp(ctx, database.CreateChirpParams{Body: u.Email, UserID: u.ID})
+		if err != nil {
+			t.Fatal(err)
+		}
+		chirps = append(chirps, chirp)
+	}
+	listed := func(viewer uuid.UUID) int {
+		t.Helper()
+		chirps, err := s.QueryAllChirps(ctx, viewer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(chirps)
+	}
+
+	block, err := s.CreateBlock(ctx, database.CreateBlockParams{BlockerID: walt.ID, BlockedID: jesse.ID})
+	if err != nil || block.BlockerID != walt.ID || block.BlockedID != jesse.ID || block.CreatedAt.IsZero() {
+		t.Errorf("CreateBlock() = %+v, %v", block, err)
+	}
+	if _, err := s.CreateBlock(ctx, database.CreateBlockParams{BlockerID: walt.ID, BlockedID: jesse.ID}); !errors.Is(Classify(err), ErrUniqueViolation) {
+		t.Errorf("CreateBlock() accepted a duplicate: %v", err)
+	}
+	if _, err := s.CreateBlock(ctx, database.CreateBlockParams{BlockerID: walt.ID, BlockedID: walt.ID}); !errors.Is(Classify(err), ErrCheckViolation) {
+		t.Errorf("CreateBlock() accepted blocking oneself: %v", err)
+	}
+	if _, err := s.CreateBlock(ctx, database.CreateBlockParams{BlockerID: walt.ID, BlockedID: uuid.New()}); !errors.Is(Classify(err), ErrForeignKeyViolation) {
+		t.Errorf("CreateBlock() accepted an unknown user: %v", err)
+	}
+	mute, err := s.CreateMute(ctx, database.CreateMuteParams{MuterID: walt.ID, MutedID: hank.ID})
+	if err != nil || mute.MutedID != hank.ID {
+		t.Errorf("CreateMute() = %+v, %v", mute, err)
+	}
+	if _, err := s.CreateMute(ctx, database.CreateMuteParams{MuterID: walt.ID, MutedID: hank.ID}); !errors.Is(Classify(err), ErrUniqueViolation) {
+		t.Errorf("CreateMute() accepted a duplicate: %v", err)
+	}
+	if _, err := s.CreateMute(ctx, database.CreateMuteParams{MuterID: hank.ID, MutedID: hank.ID}); !errors.Is(Classify(err), ErrCheckViolation) {
+		t.Errorf("CreateMute() accepted muting oneself: %v", err)
+	}
+
+	//solo chi ha bloccato o silenziato smette di vedere i chirp
+	if n := listed(walt.ID); n != 1 {
+		t.Errorf("QueryAllChirps(walt) = %d chirps, want 1", n)
+	}
+	if n := listed(jesse.ID); n != 3 {
+		t.Errorf("QueryAllChirps(jesse) = %d chirps, want 3", n)
+	}
+	if n := listed(uuid.Nil); n != 3 {
+		t.Errorf("QueryAllChirps(anonymous) = %d chirps, want 3", n)
+	}
+	byHank := database.QueryAllAuthorChirpsParams{UserID: hank.ID, ViewerID: walt.ID}
+	if got, err := s.QueryAllAuthorChirps(ctx, byHank); err != nil || len(got) != 0 {
+		t.Errorf("QueryAllAuthorChirps(muted) = %d chirps, %v", len(got), err)
+	}
+	for _, c := range chirps[1:] {
+		_, err := s.QueryChirp(ctx, database.QueryChirpParams{ID: c.ID, ViewerID: walt.ID})
+		expectNoRows(t, "QueryChirp(blocked or muted)", err)
+	}
+	if _, err := s.QueryChirp(ctx, database.QueryChirpParams{ID: chirps[2].ID, ViewerID: jesse.ID}); err != nil {
+		t.Errorf("QueryChirp(not muted) = %v", err)
+	}
+
+	if blocks, err := s.QueryBlocks(ctx, walt.ID); err != nil || len(blocks) != 1 {
+		t.Errorf("QueryBlocks() = %+v, %v", blocks, err)
+	}
+	if mutes, err := s.QueryMutes(ctx, walt.ID); err != nil || len(mutes) != 1 {
+		t.Errorf("QueryMutes() = %+v, %v", mutes, err)
+	}
+	if got, err := s.QueryBlock(ctx, database.QueryBlockParams{BlockerID: walt.ID, BlockedID: jesse.ID}); err != nil || got.BlockedID != jesse.ID {
+		t.Errorf("QueryBlock() = %+v, %v", got, err)
+	}
+	if _, err := s.QueryBlock(ctx, database.QueryBlockParams{BlockerID: jesse.ID, BlockedID: walt.ID}); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("QueryBlock(other way round) = %v, want sql.ErrNoRows", err)
+	}
+	if n, err := s.DeleteBlock(ctx, database.DeleteBlockParams{BlockerID: walt.ID, BlockedID: jesse.ID}); err != nil || n != 1 {
+		t.Errorf("DeleteBlock() = %d, %v", n, err)
+	}
+	if n, err := s.DeleteBlock(ctx, database.DeleteBlockParams{BlockerID: walt.ID, BlockedID: jesse.ID}); err != nil || n != 0 {
+		t.Errorf("DeleteBlock(again) = %d, %v", n, err)
+	}
+	if n, err := s.DeleteMute(ctx, database.DeleteMuteParams{MuterID: walt.ID, MutedID: hank.ID}); err != nil || n != 1 {
+		t.Errorf("DeleteMute() = %d, %v", n, err)
+	}
+	if n := listed(walt.ID); n != 3 {
+		t.Errorf("QueryAllChirps(walt) after unblocking = %d chirps, want 3", n)
+	}
+
+	//i blocchi spariscono con gli utenti
+	if _, err := s.CreateBlock(ctx, database.CreateBlockParams{BlockerID: walt.ID, BlockedID: hank.ID}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.SoftDeleteUser(ctx, hank.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.PurgeDeletedUsers(ctx, time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if blocks, err := s.QueryBlocks(ctx, walt.ID); err != nil || len(blocks) != 0 {
+		t.Errorf("QueryBlocks() after the blocked user was purged = %+v, %v", blocks, err)
+	}
+}
+
+func testAuditLog(t *testing.T, s Store) {
+	ctx := context.Background()
+	walt := createUser(t, s, "walt@breakingbad.com")
+	_, err := s.QueryLastAuditEntry(ctx)
+	expectNoRows(t, "QueryLastAuditEntry(empty)", err)
+
+	start := time.Now().UTC().Truncate(time.Second)
+	actor := uuid.NullUUID{UUID: walt.ID, Valid: true}
+	entries := []database.CreateAuditEntryParams{
+		{Seq: 1, CreatedAt: start, Action: "reset", TargetType: "users", PrevHash: "0", Hash: "a"},
+		{Seq: 2, CreatedAt: start.Add(time.Minute), ActorID: actor, Action: "chirp.delete", TargetType: "chirp",
+			TargetID: sql.NullString{String: "c1", Valid: true}, Ip: sql.NullString{String: "127.0.0.1", Valid: true},
+			BeforeState: sql.NullString{String: `{"body":"say my name"}`, Valid: true}, PrevHash: "a", Hash: "b"},
+		{Seq: 3, CreatedAt: start.Add(2 * time.Minute), ActorID: actor, Action: "chirp.restore", TargetType: "chirp",
+			TargetID: sql.NullString{String: "c1", Valid: true}, PrevHash: "b", Hash: "c"},
+	}
+	for _, e := range entries {
+		got, err := s.CreateAuditEntry(ctx, e)
+		if err != nil || got.Seq != e.Seq || got.Hash != e.Hash || got.ActorID != e.ActorID || !got.CreatedAt.Equal(e.CreatedAt) {
+			t.Fatalf("CreateAuditEntry(%d) = %+v, %v", e.Seq, got, err)
+		}
+	}
+	//due scritture concorrenti non possono prendere lo stesso seq
+	if _, err := s.CreateAuditEntry(ctx, database.CreateAuditEntryParams{Seq: 3, CreatedAt: start, Action: "reset", TargetType: "users", PrevHash: "b", Hash: "d"}); !errors.Is(Classify(err), ErrUniqueViolation) {
+		t.Errorf("CreateAuditEntry() accepted a duplicate seq: %v", err)
+	}
+	if last, err := s.QueryLastAuditEntry(ctx); err != nil || last.Seq != 3 || last.PrevHash != "b" {
+		t.Errorf("QueryLastAuditEntry() = %+v, %v", last, err)
+	}
+	if chain, err := s.QueryAuditChain(ctx); err != nil || len(chain) != 3 || chain[0].Seq != 1 || chain[2].Seq != 3 {
+		t.Errorf("QueryAuditChain() = %+v, %v", chain, err)
+	}
+
+	tests := []struct {
+		name string
+		arg  database.QueryAuditLogParams
+		want []int64
+	}{
+		{"all", database.QueryAuditLogParams{}, []int64{3, 2, 1}},
+		{"limit", database.QueryAuditLogParams{MaxEntries: 2}, []int64{3, 2}},
+		{"actor", database.QueryAuditLogParams{ActorID: actor}, []int64{3, 2}},
+		{"action", database.QueryAuditLogParams{Action: sql.NullString{String: "reset", Valid: true}}, []int64{1}},
+		{"target", database.QueryAuditLogParams{TargetType: sql.NullString{String: "chirp", Valid: true},
+			TargetID: sql.NullString{String: "c1", Valid: true}}, []int64{3, 2}},
+		{"unknown target", database.QueryAuditLogParams{TargetID: sql.NullString{String: "c2", Valid: true}}, nil},
+		{"since", database.QueryAuditLogParams{Since: sql.NullTime{Time: start.Add(time.Minute), Valid: true}}, []int64{3, 2}},
+		{"until", database.QueryAuditLogParams{Until: sql.NullTime{Time: start.Add(time.Minute), Valid: true}}, []int64{1}},
+		{"before", database.QueryAuditLogParams{BeforeSeq: sql.NullInt64{Int64: 3, Valid: true}}, []int64{2, 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.arg.MaxEntries == 0 {
+				tt.arg.MaxEntries = 10
+			}
+			got, err := s.QueryAuditLog(ctx, tt.arg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var seqs []int64
+			for _, e := range got {
+				seqs = append(seqs, e.Seq)
+			}
+			if !slices.Equal(seqs, tt.want) {
+				t.Errorf("QueryAuditLog() = %v, want %v", seqs, tt.want)
+			}
+		})
+	}
+
+	//il registro sopravvive alla cancellazione degli utenti
+	if err := s.DeleteUsers(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if chain, err := s.QueryAuditChain(ctx); err != nil || len(chain) != 3 {
+		t.Errorf("QueryAuditChain() after DeleteUsers = %d entries, %v", len(chain), err)
+	}
+}
@@ -0,0 +1,155 @@
+// Package validate checks request structs against rules declared in their
+// `validate` struct tags, e.g.
+//
+//	Email string `json:"email" validate:"required,email,max=254"`
+//
+// Supported rules:
+//
+//	required    the value is not empty (for pointers: not nil)
+//	email       the value is a plain address like name@example.com
+//	min=N       at least N characters
+//	max=N       at most N characters
+//	maxbytes=N  at most N bytes once UTF-8 encoded, e.g. for bcrypt's limit
+//	oneof=a b   one of the space-separated values
+//	uuid        a UUID in canonical form
+//
+// Rules other than required apply to pointer fields only when they are set,
+// which suits PATCH bodies where an absent field means "leave unchanged".
+package validate
+
+import (
+	"fmt"
+	"net/mail"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+// FieldError describes one field breaking one rule.
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// Errors lists every rule broken by a struct; it is empty when the struct
+// is valid.
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, fe := range e {
+		msgs[i] = fe.Field + ": " + fe.Message
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Struct validates v, a struct or a pointer to one. Fields are reported by
+// their JSON name. It panics on a malformed rule, which is a programming
+// error.
+func Struct(v any) Errors {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	rt := rv.Type()
+	var errs Errors
+	for i := 0; i < rt.NumField(); i++ {
+		tag := rt.Field(i).Tag.Get("validate")
+		if tag == "" {
+			continue
+		}
+		name := fieldName(rt.Field(i))
+		field := rv.Field(i)
+		for _, rule := range strings.Split(tag, ",") {
+			msg := check(field, rule)
+			if msg != "" {
+				errs = append(errs, FieldError{Field: name, Rule: ruleName(rule), Message: msg})
+				break
+			}
+		}
+	}
+	return errs
+}
+
+func fieldName(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+	if name == "" || name == "-" {
+		return f.Name
+	}
+	return name
+}
+
+func ruleName(rule string) string {
+	name, _, _ := strings.Cut(rule, "=")
+	return name
+}
+
+// check returns why field breaks rule, or "" when it does not.
+func check(field reflect.Value, rule string) string {
+	name, arg, _ := strings.Cut(rule, "=")
+
+	if field.Kind() == reflect.Pointer {
+		if field.IsNil() {
+			if name == "required" {
+				return "is required"
+			}
+			return ""
+		}
+		if name == "required" {
+			return ""
+		}
+		field = field.Elem()
+	}
+	if field.Kind() != reflect.String {
+		panic(fmt.Sprintf("validate: rule %q on unsupported type %v", rule, field.Type()))
+	}
+	s := field.String()
+
+	switch name {
+	case "required":
+		if strings.TrimSpace(s) == "" {
+			return "is required"
+		}
+	case "email":
+		addr, err := mail.ParseAddress(s)
+		if err != nil || addr.Address != s || addr.Name != "" {
+			return "must be a valid email address"
+		}
+	case "min":
+		if utf8.RuneCountInString(s) < number(rule, arg) {
+			return fmt.Sprintf("must be at least %s characters long", arg)
+		}
+	case "max":
+		if utf8.RuneCountInString(s) > number(rule, arg) {
+			return fmt.Sprintf("must be at most %s characters long", arg)
+		}
+	case "maxbytes":
+		if len(s) > number(rule, arg) {
+			return fmt.Sprintf("must be at most %s bytes long", arg)
+		}
+	case "oneof":
+		options := strings.Fields(arg)
+		for _, o := range options {
+			if s == o {
+				return ""
+			}
+		}
+		return "must be one of " + strings.Join(options, ", ")
+	case "uuid":
+		if _, err := uuid.Parse(s); err != nil || len(s) != 36 {
+			return "must be a UUID"
+		}
+	default:
+		panic(fmt.Sprintf("validate: unknown rule %q", rule))
+	}
+	return ""
+}
+
+func number(rule, arg string) int {
+	n, err := strconv.Atoi(arg)
+	if err != nil {
+		panic(fmt.Sprintf("validate: rule %q needs a number", rule))
+	}
+	return n
+}
@@ -0,0 +1,61 @@
+package validate
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type signup struct {
+	Email    string  `json:"email" validate:"required,email,max=254"`
+	Password string  `json:"password" validate:"required,maxbytes=72"`
+	Role     string  `json:"role" validate:"oneof=user moderator admin"`
+	Nickname *string `json:"nickname" validate:"min=1,max=5"`
+	Invite   *string `json:"invite" validate:"required,uuid"`
+	Ignored  string  `json:"ignored"`
+}
+
+func ptr(s string) *string { return &s }
+
+func TestStruct(t *testing.T) {
+	valid := signup{
+		Email:    "walt@breakingbad.com",
+		Password: "04234",
+		Role:     "user",
+		Invite:   ptr("0c7f8a59-5f1e-4d4a-b0b3-3b8f1f0cf6a1"),
+	}
+
+	tests := []struct {
+		name   string
+		modify func(s *signup)
+		want   []string
+	}{
+		{"valid", func(s *signup) {}, nil},
+		{"missing email", func(s *signup) { s.Email = " " }, []string{"email:required"}},
+		{"bad email", func(s *signup) { s.Email = "Walt <walt@example.com>" }, []string{"email:email"}},
+		{"not an email", func(s *signup) { s.Email = "walt" }, []string{"email:email"}},
+		{"long password", func(s *signup) { s.Password = string(make([]byte, 73)) }, []string{"password:maxbytes"}},
+		{"multibyte password", func(s *signup) { s.Password = strings.Repeat("é", 40) }, []string{"password:maxbytes"}},
+		{"short multibyte password", func(s *signup) { s.Password = strings.Repeat("é", 36) }, nil},
+		{"unknown role", func(s *signup) { s.Role = "root" }, []string{"role:oneof"}},
+		{"empty optional", func(s *signup) { s.Nickname = ptr("") }, []string{"nickname:min"}},
+		{"long optional", func(s *signup) { s.Nickname = ptr("àèìòùé") }, []string{"nickname:max"}},
+		{"short optional", func(s *signup) { s.Nickname = ptr("àèì") }, nil},
+		{"nil required", func(s *signup) { s.Invite = nil }, []string{"invite:required"}},
+		{"bad uuid", func(s *signup) { s.Invite = ptr("123") }, []string{"invite:uuid"}},
+		{"several", func(s *signup) { s.Email = ""; s.Role = "" }, []string{"email:required", "role:oneof"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := valid
+			tt.modify(&s)
+			var got []string
+			for _, fe := range Struct(&s) {
+				got = append(got, fe.Field+":"+fe.Rule)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Struct() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package webhook
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestVerify(t *testing.T) {
+	secret := "polkaSecret"
+	body := []byte(`{"id":"evt_1","event":"user.upgraded","data":{"user_id":"3311741c-680c-4546-99f3-fc9efac2036c"}}`)
+	now := time.Now()
+	tolerance := 5 * time.Minute
+
+	tests := []struct {
+		name    string
+		header  string
+		body    []byte
+		wantErr error
+	}{
+		{
+			name:    "Valid signature",
+			header:  Sign(secret, now, body),
+			body:    body,
+			wantErr: nil,
+		},
+		{
+			name:    "Missing signature",
+			header:  "",
+			body:    body,
+			wantErr: ErrMissingSignature,
+		},
+		{
+			name:    "Wrong secret",
+			header:  Sign("wrongSecret", now, body),
+			body:    body,
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name:    "Tampered body",
+			header:  Sign(secret, now, body),
+			body:    []byte(`{"id":"evt_1","event":"user.upgraded","data":{"user_id":"someone-else"}}`),
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name:    "Old delivery",
+			header:  Sign(secret, now.Add(-time.Hour), body),
+			body:    body,
+			wantErr: ErrStaleSignature,
+		},
+		{
+			name:    "Malformed header",
+			header:  "v1=zz",
+			body:    body,
+			wantErr: ErrInvalidSignature,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Verify(secret, tt.header, tt.body, now, tolerance)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Verify() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"Chirpy/internal/config"
+)
+
+// configureDBPool sizes the connection pool.
+func configureDBPool(db *sql.DB, conf config.DB) {
+	db.SetMaxOpenConns(conf.MaxOpenConns)
+	db.SetMaxIdleConns(conf.MaxIdleConns)
+	db.SetConnMaxLifetime(conf.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(conf.ConnMaxIdleTime)
+}
+
+// serve runs server until ctx is cancelled. It then calls draining, keeps
+// serving for delay so that load balancers notice the instance is going
+// away, and finally stops accepting connections and waits up to timeout for
+// the requests in flight to finish.
+func serve(ctx context.Context, server *http.Server, settings config.Server, draining func()) error {
+	errc := make(chan error, 1)
+	go func() {
+		slog.Info("starting server", "addr", server.Addr)
+		errc <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+
+	draining()
+	slog.Info("shutting down", "delay", settings.ShutdownDelay.String(), "timeout", settings.ShutdownTimeout.String())
+	select {
+	case err := <-errc:
+		return err
+	case <-time.After(settings.ShutdownDelay):
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), settings.ShutdownTimeout)
+	defer cancel()
+	err := server.Shutdown(shutdownCtx)
+	if err != nil {
+		return fmt.Errorf("graceful shutdown: %w", err)
+	}
+	slog.Info("server stopped")
+	return nil
+}
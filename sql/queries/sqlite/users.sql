@@ -0,0 +1,432 @@
+-- The queries of sql/queries/users.sql for SQLite. IDs and timestamps are
+-- generated in Go and passed as @id and @now: SQLite has no
+-- gen_random_uuid() and stores timestamps as text, which only compare
+-- correctly when written in the same format.
+
+-- name: CreateUser :one
+INSERT INTO users (id, created_at, updated_at, email, hashed_password)
+VALUES (@id, @now, @now, @email, @hashed_password)
+RETURNING *;
+
+-- name: CreateChirp :one
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, body_hash, held_reason)
+VALUES (@id, @now, @now, @body, @user_id, @body_hash, @held_reason)
+RETURNING *;
+
+-- name: CreateRefreshToken :one
+INSERT INTO refresh_tokens (token, created_at, updated_at, user_id, expires_at)
+VALUES (@token, @now, @now, @user_id, @expires_at)
+RETURNING *;
+
+-- Listings are for viewer_id, the nil UUID for anonymous viewers: the
+-- chirps of shadow-banned users are only listed to themselves, and those of
+-- the users the viewer muted or blocked are left out. Held chirps are not
+-- listed until a moderator releases them.
+
+-- name: QueryAllChirps :many
+SELECT chirps.* FROM chirps
+JOIN users ON users.id = chirps.user_id
+LEFT JOIN mutes ON mutes.muter_id = @viewer_id AND mutes.muted_id = chirps.user_id
+LEFT JOIN blocks ON blocks.blocker_id = @viewer_id AND blocks.blocked_id = chirps.user_id
+WHERE users.deleted_at IS NULL AND chirps.deleted_at IS NULL
+    AND (users.status <> 'shadow_banned' OR users.id = @viewer_id)
+    AND mutes.muter_id IS NULL AND blocks.blocker_id IS NULL
+    AND chirps.held_reason IS NULL
+ORDER BY chirps.created_at ASC;
+
+-- name: QueryAllAuthorChirps :many
+SELECT chirps.* FROM chirps
+JOIN users ON users.id = chirps.user_id
+LEFT JOIN mutes ON mutes.muter_id = @viewer_id AND mutes.muted_id = chirps.user_id
+LEFT JOIN blocks ON blocks.blocker_id = @viewer_id AND blocks.blocked_id = chirps.user_id
+WHERE chirps.user_id = @user_id AND users.deleted_at IS NULL AND chirps.deleted_at IS NULL
+    AND (users.status <> 'shadow_banned' OR users.id = @viewer_id)
+    AND mutes.muter_id IS NULL AND blocks.blocker_id IS NULL
+    AND chirps.held_reason IS NULL
+ORDER BY chirps.created_at ASC;
+
+-- QueryChirp hides what QueryAllChirps does from the viewer but held
+-- chirps, which their author still gets to see.
+
+-- name: QueryChirp :one
+SELECT chirps.* FROM chirps
+JOIN users ON users.id = chirps.user_id
+LEFT JOIN mutes ON mutes.muter_id = @viewer_id AND mutes.muted_id = chirps.user_id
+LEFT JOIN blocks ON blocks.blocker_id = @viewer_id AND blocks.blocked_id = chirps.user_id
+WHERE chirps.id = @id AND users.deleted_at IS NULL AND chirps.deleted_at IS NULL
+    AND (users.status <> 'shadow_banned' OR users.id = @viewer_id)
+    AND mutes.muter_id IS NULL AND blocks.blocker_id IS NULL;
+
+-- name: QueryAllChirpsIncludingDeleted :many
+SELECT * FROM chirps
+ORDER BY created_at ASC;
+
+-- name: QueryAllAuthorChirpsIncludingDeleted :many
+SELECT * FROM chirps
+WHERE user_id = @user_id
+ORDER BY created_at ASC;
+
+-- name: QueryChirpIncludingDeleted :one
+SELECT * FROM chirps
+WHERE id = @id;
+
+-- name: SoftDeleteChirp :one
+UPDATE chirps
+SET updated_at = @now, deleted_at = @now, deleted_by = @deleted_by, deletion_reason = @deletion_reason
+WHERE id = @id AND deleted_at IS NULL
+RETURNING *;
+
+-- name: RestoreChirp :one
+UPDATE chirps
+SET deleted_at = NULL, deleted_by = NULL, deletion_reason = NULL, updated_at = @now
+WHERE id = @id AND deleted_at IS NOT NULL
+RETURNING *;
+
+-- name: PurgeDeletedChirps :execrows
+-- Chirps with pending reports are kept until a moderator resolves them.
+DELETE FROM chirps
+WHERE deleted_at IS NOT NULL AND deleted_at < @deleted_before
+    AND NOT EXISTS (
+        SELECT 1 FROM reports
+        WHERE reports.chirp_id = chirps.id AND reports.status IN ('open', 'assigned')
+    );
+
+-- Counts for the flood checks; they include deleted and held chirps, so
+-- that deleting chirps does not make room for more.
+
+-- name: CountUserChirpsSince :one
+SELECT COUNT(*) FROM chirps
+WHERE user_id = @user_id AND created_at > @created_at;
+
+-- name: CountDuplicateChirps :one
+SELECT COUNT(*) FROM chirps
+WHERE user_id = @user_id AND body_hash = @body_hash AND created_at > @created_at;
+
+-- name: QueryHeldChirps :many
+SELECT * FROM chirps
+WHERE held_reason IS NOT NULL AND deleted_at IS NULL
+ORDER BY created_at ASC;
+
+-- name: ReleaseChirp :one
+UPDATE chirps
+SET held_reason = NULL, updated_at = @now
+WHERE id = @id AND held_reason IS NOT NULL AND deleted_at IS NULL
+RETURNING *;
+
+-- name: QueryRefreshToken :one
+SELECT * FROM refresh_tokens
+WHERE token = @token;
+
+-- name: QueryUserRefreshTokens :many
+SELECT * FROM refresh_tokens
+WHERE user_id = @user_id
+ORDER BY created_at ASC;
+
+-- name: RevokeUserTokens :exec
+UPDATE refresh_tokens
+SET updated_at = @now, revoked_at = @now
+WHERE user_id = @user_id AND revoked_at IS NULL;
+
+-- name: RevokeToken :one
+UPDATE refresh_tokens
+SET updated_at = @now, revoked_at = @now
+WHERE token = @token
+RETURNING *;
+
+-- name: QueryUser :one
+SELECT * FROM users
+WHERE email = @email;
+
+-- name: QueryUserByID :one
+SELECT * FROM users
+WHERE id = @id;
+
+-- SQLite has no row locks; the store has a single connection, so its
+-- transactions already run one at a time.
+
+-- name: LockUser :exec
+SELECT id FROM users
+WHERE id = @id;
+
+-- name: UpdateUserPassword :one
+UPDATE users
+SET hashed_password = @hashed_password, updated_at = @now
+WHERE id = @id
+RETURNING *;
+
+-- name: UpdateUserEmail :one
+UPDATE users
+SET email = @email, updated_at = @now
+WHERE id = @id
+RETURNING *;
+
+-- name: UserPro :one
+UPDATE users
+SET is_chirpy_red = TRUE
+WHERE id = @id
+RETURNING *;
+
+-- name: SetUserRole :one
+UPDATE users
+SET role = @role, updated_at = @now
+WHERE id = @id
+RETURNING *;
+
+-- name: SetUserStatus :one
+UPDATE users
+SET status = @status, suspended_until = @suspended_until, updated_at = @now
+WHERE id = @id
+RETURNING *;
+
+-- name: SoftDeleteUser :one
+UPDATE users
+SET updated_at = @now, deleted_at = @now
+WHERE id = @id
+RETURNING *;
+
+-- name: RestoreUser :one
+UPDATE users
+SET deleted_at = NULL, updated_at = @now
+WHERE id = @id
+RETURNING *;
+
+-- name: PurgeDeletedUsers :execrows
+DELETE FROM users
+WHERE deleted_at IS NOT NULL AND deleted_at < @deleted_before;
+
+-- name: UserFree :one
+UPDATE users
+SET is_chirpy_red = FALSE
+WHERE id = @id
+RETURNING *;
+
+-- name: DeleteUsers :exec
+DELETE FROM users;
+
+-- name: CreateDataExport :one
+INSERT INTO data_exports (id, created_at, updated_at, user_id, status)
+VALUES (@id, @now, @now, @user_id, 'pending')
+RETURNING *;
+
+-- name: QueryLatestDataExport :one
+SELECT * FROM data_exports
+WHERE user_id = @user_id
+ORDER BY created_at DESC
+LIMIT 1;
+
+-- name: QueryPendingDataExports :many
+SELECT * FROM data_exports
+WHERE status = 'pending' OR status = 'running' AND updated_at < @stale_before
+ORDER BY created_at ASC;
+
+-- name: ClaimDataExport :one
+UPDATE data_exports
+SET status = 'running', updated_at = @now
+WHERE id = @id AND (status = 'pending' OR status = 'running' AND updated_at < @stale_before)
+RETURNING *;
+
+-- name: CompleteDataExport :exec
+UPDATE data_exports
+SET status = 'ready', updated_at = @now, archive = @archive, completed_at = @now
+WHERE id = @id;
+
+-- name: FailDataExport :exec
+UPDATE data_exports
+SET status = 'failed', updated_at = @now, completed_at = @now
+WHERE id = @id;
+
+-- name: CreateEmailChange :one
+INSERT INTO email_changes (token, created_at, updated_at, user_id, new_email, expires_at)
+VALUES (@token, @now, @now, @user_id, @new_email, @expires_at)
+RETURNING *;
+
+-- name: QueryEmailChange :one
+SELECT * FROM email_changes
+WHERE token = @token;
+
+-- name: ConfirmEmailChange :exec
+UPDATE email_changes
+SET updated_at = @now, confirmed_at = @now
+WHERE token = @token;
+
+-- name: DeletePendingEmailChanges :exec
+DELETE FROM email_changes
+WHERE user_id = @user_id AND confirmed_at IS NULL;
+
+-- name: CreateWebhookEvent :one
+INSERT INTO webhook_events (id, created_at, updated_at, event, payload)
+VALUES (@id, @now, @now, @event, @payload)
+ON CONFLICT (id) DO NOTHING
+RETURNING *;
+
+-- name: QueryWebhookEvent :one
+SELECT * FROM webhook_events
+WHERE id = @id;
+
+-- name: ClaimWebhookEvent :one
+UPDATE webhook_events
+SET status = 'processing', updated_at = @now
+WHERE id = @id AND (status IN ('received', 'failed') OR status = 'processing' AND updated_at < @stale_before)
+RETURNING *;
+
+-- name: MarkWebhookEvent :one
+UPDATE webhook_events
+SET status = @status, error = @error, attempts = attempts + 1, updated_at = @now, processed_at = @now
+WHERE id = @id
+RETURNING *;
+
+-- name: CreateSubscription :one
+INSERT INTO subscriptions (id, created_at, updated_at, user_id, plan, status, current_period_start, current_period_end)
+VALUES (@id, @now, @now, @user_id, @plan, 'active', @current_period_start, @current_period_end)
+RETURNING *;
+
+-- name: QueryActiveSubscription :one
+SELECT * FROM subscriptions
+WHERE user_id = @user_id AND status = 'active';
+
+-- name: ExtendSubscription :one
+UPDATE subscriptions
+SET current_period_end = @current_period_end, updated_at = @now
+WHERE id = @id
+RETURNING *;
+
+-- name: CancelSubscription :exec
+UPDATE subscriptions
+SET status = 'canceled', current_period_end = @now, updated_at = @now
+WHERE user_id = @user_id AND status = 'active';
+
+-- name: ExpireSubscriptions :many
+-- SQLite has no UPDATE in WITH: the store calls DowngradeUser for each
+-- returned user in the same transaction.
+UPDATE subscriptions
+SET status = 'expired', updated_at = @now
+WHERE status = 'active' AND current_period_end < @now
+RETURNING user_id;
+
+-- name: DowngradeUser :exec
+UPDATE users
+SET is_chirpy_red = FALSE, updated_at = @now
+WHERE id = @id;
+
+-- name: CreateReport :one
+INSERT INTO reports (id, created_at, updated_at, reporter_id, user_id, chirp_id, reason, details)
+VALUES (@id, @now, @now, @reporter_id, @user_id, @chirp_id, @reason, @details)
+RETURNING *;
+
+-- name: QueryReport :one
+SELECT * FROM reports
+WHERE id = @id;
+
+-- name: QueryPendingReports :many
+SELECT * FROM reports
+WHERE status IN ('open', 'assigned')
+ORDER BY created_at ASC;
+
+-- name: QueryReportsByStatus :many
+SELECT * FROM reports
+WHERE status = @status
+ORDER BY created_at ASC;
+
+-- name: AssignReport :one
+UPDATE reports
+SET assignee_id = @assignee_id, status = 'assigned', updated_at = @now
+WHERE id = @id AND status IN ('open', 'assigned')
+RETURNING *;
+
+-- name: ResolveReport :one
+UPDATE reports
+SET status = @status, updated_at = @now, resolved_at = @now
+WHERE id = @id AND status IN ('open', 'assigned')
+RETURNING *;
+
+-- name: ResolveChirpReports :many
+UPDATE reports
+SET status = @status, updated_at = @now, resolved_at = @now
+WHERE chirp_id = @chirp_id AND status IN ('open', 'assigned')
+RETURNING *;
+
+-- name: CountChirpReporters :one
+SELECT COUNT(DISTINCT reports.reporter_id) FROM reports
+JOIN users ON users.id = reports.reporter_id
+WHERE reports.chirp_id = @chirp_id AND reports.status IN ('open', 'assigned')
+    AND users.deleted_at IS NULL
+    AND users.created_at < @created_before
+    AND (users.status = 'active' OR users.status = 'suspended' AND users.suspended_until <= @now);
+
+-- name: CreateReportEvent :one
+INSERT INTO report_events (id, created_at, report_id, actor_id, action, note)
+VALUES (@id, @now, @report_id, @actor_id, @action, @note)
+RETURNING *;
+
+-- name: QueryReportEvents :many
+SELECT * FROM report_events
+WHERE report_id = @report_id
+ORDER BY created_at ASC;
+
+-- name: CreateSanction :one
+INSERT INTO sanctions (id, created_at, user_id, actor_id, status, suspended_until, reason)
+VALUES (@id, @now, @user_id, @actor_id, @status, @suspended_until, @reason)
+RETURNING *;
+
+-- name: QueryUserSanctions :many
+SELECT * FROM sanctions
+WHERE user_id = @user_id
+ORDER BY created_at ASC;
+
+-- name: CreateBlock :one
+INSERT INTO blocks (blocker_id, blocked_id, created_at)
+VALUES (@blocker_id, @blocked_id, @now)
+RETURNING *;
+
+-- name: DeleteBlock :execrows
+DELETE FROM blocks
+WHERE blocker_id = @blocker_id AND blocked_id = @blocked_id;
+
+-- name: QueryBlocks :many
+SELECT * FROM blocks
+WHERE blocker_id = @blocker_id
+ORDER BY created_at ASC;
+
+-- name: QueryBlock :one
+SELECT * FROM blocks
+WHERE blocker_id = @blocker_id AND blocked_id = @blocked_id;
+
+-- name: CreateMute :one
+INSERT INTO mutes (muter_id, muted_id, created_at)
+VALUES (@muter_id, @muted_id, @now)
+RETURNING *;
+
+-- name: DeleteMute :execrows
+DELETE FROM mutes
+WHERE muter_id = @muter_id AND muted_id = @muted_id;
+
+-- name: QueryMutes :many
+SELECT * FROM mutes
+WHERE muter_id = @muter_id
+ORDER BY created_at ASC;
+
+-- name: CreateAuditEntry :one
+INSERT INTO audit_log (seq, created_at, actor_id, action, target_type, target_id, ip, request_id, before_state, after_state, prev_hash, hash)
+VALUES (@seq, @created_at, @actor_id, @action, @target_type, @target_id, @ip, @request_id, @before_state, @after_state, @prev_hash, @hash)
+RETURNING *;
+
+-- name: QueryLastAuditEntry :one
+SELECT * FROM audit_log
+ORDER BY seq DESC
+LIMIT 1;
+
+-- name: QueryAuditLog :many
+SELECT * FROM audit_log
+WHERE (sqlc.narg(actor_id) IS NULL OR actor_id = sqlc.narg(actor_id))
+    AND (sqlc.narg(action) IS NULL OR action = sqlc.narg(action))
+    AND (sqlc.narg(target_type) IS NULL OR target_type = sqlc.narg(target_type))
+    AND (sqlc.narg(target_id) IS NULL OR target_id = sqlc.narg(target_id))
+    AND (sqlc.narg(since) IS NULL OR created_at >= sqlc.narg(since))
+    AND (sqlc.narg(until) IS NULL OR created_at < sqlc.narg(until))
+    AND (sqlc.narg(before_seq) IS NULL OR seq < sqlc.narg(before_seq))
+ORDER BY seq DESC
+LIMIT sqlc.arg(max_entries);
+
+-- name: QueryAuditChain :many
+SELECT * FROM audit_log
+ORDER BY seq ASC;
@@ -10,13 +10,15 @@ VALUES (
 RETURNING *;
 
 -- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, body_hash, held_reason)
 VALUES (
     gen_random_uuid(),
     NOW(),
     NOW(),
     $1,
-    $2
+    $2,
+    $3,
+    $4
 )
 RETURNING *;
 
@@ -31,27 +33,118 @@ VALUES (
 )
 RETURNING *;
 
+-- Listings are for viewer_id, the nil UUID for anonymous viewers: the
+-- chirps of shadow-banned users are only listed to themselves, and those of
+-- the users the viewer muted or blocked are left out. Held chirps are not
+-- listed until a moderator releases them.
+
 -- name: QueryAllChirps :many
+SELECT chirps.* FROM chirps
+JOIN users ON users.id = chirps.user_id
+LEFT JOIN mutes ON mutes.muter_id = @viewer_id AND mutes.muted_id = chirps.user_id
+LEFT JOIN blocks ON blocks.blocker_id = @viewer_id AND blocks.blocked_id = chirps.user_id
+WHERE users.deleted_at IS NULL AND chirps.deleted_at IS NULL
+    AND (users.status <> 'shadow_banned' OR users.id = @viewer_id)
+    AND mutes.muter_id IS NULL AND blocks.blocker_id IS NULL
+    AND chirps.held_reason IS NULL
+ORDER BY chirps.created_at ASC;
+
+-- name: QueryAllAuthorChirps :many
+SELECT chirps.* FROM chirps
+JOIN users ON users.id = chirps.user_id
+LEFT JOIN mutes ON mutes.muter_id = @viewer_id AND mutes.muted_id = chirps.user_id
+LEFT JOIN blocks ON blocks.blocker_id = @viewer_id AND blocks.blocked_id = chirps.user_id
+WHERE chirps.user_id = @user_id AND users.deleted_at IS NULL AND chirps.deleted_at IS NULL
+    AND (users.status <> 'shadow_banned' OR users.id = @viewer_id)
+    AND mutes.muter_id IS NULL AND blocks.blocker_id IS NULL
+    AND chirps.held_reason IS NULL
+ORDER BY chirps.created_at ASC;
+
+-- QueryChirp hides what QueryAllChirps does from the viewer but held
+-- chirps, which their author still gets to see.
+
+-- name: QueryChirp :one
+SELECT chirps.* FROM chirps
+JOIN users ON users.id = chirps.user_id
+LEFT JOIN mutes ON mutes.muter_id = @viewer_id AND mutes.muted_id = chirps.user_id
+LEFT JOIN blocks ON blocks.blocker_id = @viewer_id AND blocks.blocked_id = chirps.user_id
+WHERE chirps.id = @id AND users.deleted_at IS NULL AND chirps.deleted_at IS NULL
+    AND (users.status <> 'shadow_banned' OR users.id = @viewer_id)
+    AND mutes.muter_id IS NULL AND blocks.blocker_id IS NULL;
+
+-- The IncludingDeleted queries are the moderators' view: tombstones and
+-- the chirps of deleted accounts too.
+
+-- name: QueryAllChirpsIncludingDeleted :many
 SELECT * FROM chirps
 ORDER BY created_at ASC;
 
--- name: QueryAllAuthorChirps :many
+-- name: QueryAllAuthorChirpsIncludingDeleted :many
 SELECT * FROM chirps
 WHERE user_id = $1
 ORDER BY created_at ASC;
 
--- name: QueryChirp :one
+-- name: QueryChirpIncludingDeleted :one
 SELECT * FROM chirps
 WHERE id = $1;
 
--- name: DeleteChirp :exec
+-- name: SoftDeleteChirp :one
+UPDATE chirps
+SET deleted_at = NOW(), updated_at = NOW(), deleted_by = $2, deletion_reason = $3
+WHERE id = $1 AND deleted_at IS NULL
+RETURNING *;
+
+-- name: RestoreChirp :one
+UPDATE chirps
+SET deleted_at = NULL, deleted_by = NULL, deletion_reason = NULL, updated_at = NOW()
+WHERE id = $1 AND deleted_at IS NOT NULL
+RETURNING *;
+
+-- name: PurgeDeletedChirps :execrows
+-- Chirps with pending reports are kept until a moderator resolves them.
 DELETE FROM chirps
-WHERE id = $1;
+WHERE deleted_at IS NOT NULL AND deleted_at < @deleted_before::timestamp
+    AND NOT EXISTS (
+        SELECT 1 FROM reports
+        WHERE reports.chirp_id = chirps.id AND reports.status IN ('open', 'assigned')
+    );
+
+-- Counts for the flood checks; they include deleted and held chirps, so
+-- that deleting chirps does not make room for more.
+
+-- name: CountUserChirpsSince :one
+SELECT COUNT(*) FROM chirps
+WHERE user_id = $1 AND created_at > $2;
+
+-- name: CountDuplicateChirps :one
+SELECT COUNT(*) FROM chirps
+WHERE user_id = $1 AND body_hash = $2 AND created_at > $3;
+
+-- name: QueryHeldChirps :many
+SELECT * FROM chirps
+WHERE held_reason IS NOT NULL AND deleted_at IS NULL
+ORDER BY created_at ASC;
+
+-- name: ReleaseChirp :one
+UPDATE chirps
+SET held_reason = NULL, updated_at = NOW()
+WHERE id = $1 AND held_reason IS NOT NULL AND deleted_at IS NULL
+RETURNING *;
 
 -- name: QueryRefreshToken :one
 SELECT * FROM refresh_tokens
 WHERE token = $1;
 
+-- name: QueryUserRefreshTokens :many
+SELECT * FROM refresh_tokens
+WHERE user_id = $1
+ORDER BY created_at ASC;
+
+-- name: RevokeUserTokens :exec
+UPDATE refresh_tokens
+SET revoked_at = NOW(), updated_at = NOW()
+WHERE user_id = $1 AND revoked_at IS NULL;
+
 -- name: RevokeToken :one
 UPDATE refresh_tokens
 SET revoked_at = NOW(), updated_at = NOW()
@@ -63,9 +156,27 @@ RETURNING *;
 SELECT * FROM users
 WHERE email = $1;
 
--- name: UpdateUser :one
+-- name: QueryUserByID :one
+SELECT * FROM users
+WHERE id = $1;
+
+-- LockUser holds the row of a user until the end of the transaction, so
+-- that the checks on their recent chirps see the previous ones.
+
+-- name: LockUser :exec
+SELECT id FROM users
+WHERE id = $1
+FOR UPDATE;
+
+-- name: UpdateUserPassword :one
+UPDATE users
+SET hashed_password = $2, updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: UpdateUserEmail :one
 UPDATE users
-SET hashed_password = $2, email = $3, updated_at = NOW()
+SET email = $2, updated_at = NOW()
 WHERE id = $1
 RETURNING *;
 
@@ -75,5 +186,337 @@ SET is_chirpy_red = TRUE
 WHERE id = $1
 RETURNING *;
 
+-- name: SetUserRole :one
+UPDATE users
+SET role = $2, updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: SetUserStatus :one
+UPDATE users
+SET status = $2, suspended_until = $3, updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: SoftDeleteUser :one
+UPDATE users
+SET deleted_at = NOW(), updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: RestoreUser :one
+UPDATE users
+SET deleted_at = NULL, updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: PurgeDeletedUsers :execrows
+DELETE FROM users
+WHERE deleted_at IS NOT NULL AND deleted_at < @deleted_before::timestamp;
+
+-- TryLockJob takes the advisory lock of a background job until the end of
+-- the transaction, so that only one replica runs it at a time. It returns
+-- false when another replica holds it.
+
+-- name: TryLockJob :one
+SELECT pg_try_advisory_xact_lock(hashtext(@job::text));
+
+-- name: UserFree :one
+UPDATE users
+SET is_chirpy_red = FALSE
+WHERE id = $1
+RETURNING *;
+
 -- name: DeleteUsers :exec
-DELETE FROM users;
\ No newline at end of file
+DELETE FROM users;
+
+-- name: CreateDataExport :one
+INSERT INTO data_exports (id, created_at, updated_at, user_id, status)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    'pending'
+)
+RETURNING *;
+
+-- name: QueryLatestDataExport :one
+SELECT * FROM data_exports
+WHERE user_id = $1
+ORDER BY created_at DESC
+LIMIT 1;
+
+-- name: QueryPendingDataExports :many
+SELECT * FROM data_exports
+WHERE status = 'pending' OR status = 'running' AND updated_at < @stale_before::timestamp
+ORDER BY created_at ASC;
+
+-- name: ClaimDataExport :one
+UPDATE data_exports
+SET status = 'running', updated_at = NOW()
+WHERE id = @id AND (status = 'pending' OR status = 'running' AND updated_at < @stale_before::timestamp)
+RETURNING *;
+
+-- name: CompleteDataExport :exec
+UPDATE data_exports
+SET status = 'ready', archive = $2, completed_at = NOW(), updated_at = NOW()
+WHERE id = $1;
+
+-- name: FailDataExport :exec
+UPDATE data_exports
+SET status = 'failed', completed_at = NOW(), updated_at = NOW()
+WHERE id = $1;
+
+-- name: CreateEmailChange :one
+INSERT INTO email_changes (token, created_at, updated_at, user_id, new_email, expires_at)
+VALUES (
+    $1,
+    NOW(),
+    NOW(),
+    $2,
+    $3,
+    $4
+)
+RETURNING *;
+
+-- name: QueryEmailChange :one
+SELECT * FROM email_changes
+WHERE token = $1;
+
+-- name: ConfirmEmailChange :exec
+UPDATE email_changes
+SET confirmed_at = NOW(), updated_at = NOW()
+WHERE token = $1;
+
+-- name: DeletePendingEmailChanges :exec
+DELETE FROM email_changes
+WHERE user_id = $1 AND confirmed_at IS NULL;
+
+-- name: CreateWebhookEvent :one
+INSERT INTO webhook_events (id, created_at, updated_at, event, payload)
+VALUES (
+    $1,
+    NOW(),
+    NOW(),
+    $2,
+    $3
+)
+ON CONFLICT (id) DO NOTHING
+RETURNING *;
+
+-- name: QueryWebhookEvent :one
+SELECT * FROM webhook_events
+WHERE id = $1;
+
+-- name: ClaimWebhookEvent :one
+UPDATE webhook_events
+SET status = 'processing', updated_at = NOW()
+WHERE id = @id AND (status IN ('received', 'failed') OR status = 'processing' AND updated_at < @stale_before::timestamp)
+RETURNING *;
+
+-- name: MarkWebhookEvent :one
+UPDATE webhook_events
+SET status = $2, error = $3, attempts = attempts + 1, processed_at = NOW(), updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: CreateSubscription :one
+INSERT INTO subscriptions (id, created_at, updated_at, user_id, plan, status, current_period_start, current_period_end)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2,
+    'active',
+    $3,
+    $4
+)
+RETURNING *;
+
+-- name: QueryActiveSubscription :one
+SELECT * FROM subscriptions
+WHERE user_id = $1 AND status = 'active';
+
+-- name: ExtendSubscription :one
+UPDATE subscriptions
+SET current_period_end = $2, updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: CancelSubscription :exec
+UPDATE subscriptions
+SET status = 'canceled', current_period_end = NOW(), updated_at = NOW()
+WHERE user_id = $1 AND status = 'active';
+
+-- name: ExpireSubscriptions :many
+WITH expired AS (
+    UPDATE subscriptions
+    SET status = 'expired', updated_at = NOW()
+    WHERE status = 'active' AND current_period_end < NOW()
+    RETURNING user_id
+)
+UPDATE users
+SET is_chirpy_red = FALSE, updated_at = NOW()
+WHERE id IN (SELECT user_id FROM expired)
+RETURNING id;
+
+-- name: CreateReport :one
+INSERT INTO reports (id, created_at, updated_at, reporter_id, user_id, chirp_id, reason, details)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4,
+    $5
+)
+RETURNING *;
+
+-- name: QueryReport :one
+SELECT * FROM reports
+WHERE id = $1;
+
+-- name: QueryPendingReports :many
+SELECT * FROM reports
+WHERE status IN ('open', 'assigned')
+ORDER BY created_at ASC;
+
+-- name: QueryReportsByStatus :many
+SELECT * FROM reports
+WHERE status = $1
+ORDER BY created_at ASC;
+
+-- name: AssignReport :one
+UPDATE reports
+SET assignee_id = $2, status = 'assigned', updated_at = NOW()
+WHERE id = $1 AND status IN ('open', 'assigned')
+RETURNING *;
+
+-- name: ResolveReport :one
+UPDATE reports
+SET status = $2, resolved_at = NOW(), updated_at = NOW()
+WHERE id = $1 AND status IN ('open', 'assigned')
+RETURNING *;
+
+-- ResolveChirpReports closes the other pending reports of a chirp along
+-- with the one a moderator resolved.
+
+-- name: ResolveChirpReports :many
+UPDATE reports
+SET status = @status, resolved_at = NOW(), updated_at = NOW()
+WHERE chirp_id = @chirp_id AND status IN ('open', 'assigned')
+RETURNING *;
+
+-- CountChirpReporters only counts reporters in good standing who signed up
+-- before created_before, so that throwaway or sanctioned accounts cannot
+-- hide chirps.
+
+-- name: CountChirpReporters :one
+SELECT COUNT(DISTINCT reports.reporter_id) FROM reports
+JOIN users ON users.id = reports.reporter_id
+WHERE reports.chirp_id = @chirp_id AND reports.status IN ('open', 'assigned')
+    AND users.deleted_at IS NULL
+    AND users.created_at < @created_before::timestamp
+    AND (users.status = 'active' OR users.status = 'suspended' AND users.suspended_until <= @now::timestamp);
+
+-- name: CreateReportEvent :one
+INSERT INTO report_events (id, created_at, report_id, actor_id, action, note)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4
+)
+RETURNING *;
+
+-- name: QueryReportEvents :many
+SELECT * FROM report_events
+WHERE report_id = $1
+ORDER BY created_at ASC;
+
+-- name: CreateSanction :one
+INSERT INTO sanctions (id, created_at, user_id, actor_id, status, suspended_until, reason)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4,
+    $5
+)
+RETURNING *;
+
+-- name: QueryUserSanctions :many
+SELECT * FROM sanctions
+WHERE user_id = $1
+ORDER BY created_at ASC;
+
+-- name: CreateBlock :one
+INSERT INTO blocks (blocker_id, blocked_id, created_at)
+VALUES ($1, $2, NOW())
+RETURNING *;
+
+-- name: DeleteBlock :execrows
+DELETE FROM blocks
+WHERE blocker_id = $1 AND blocked_id = $2;
+
+-- name: QueryBlocks :many
+SELECT * FROM blocks
+WHERE blocker_id = $1
+ORDER BY created_at ASC;
+
+-- name: QueryBlock :one
+SELECT * FROM blocks
+WHERE blocker_id = $1 AND blocked_id = $2;
+
+-- name: CreateMute :one
+INSERT INTO mutes (muter_id, muted_id, created_at)
+VALUES ($1, $2, NOW())
+RETURNING *;
+
+-- name: DeleteMute :execrows
+DELETE FROM mutes
+WHERE muter_id = $1 AND muted_id = $2;
+
+-- name: QueryMutes :many
+SELECT * FROM mutes
+WHERE muter_id = $1
+ORDER BY created_at ASC;
+
+-- The audit log is append-only: entries are created with the next seq and
+-- the hash of the last entry, and never changed.
+
+-- name: CreateAuditEntry :one
+INSERT INTO audit_log (seq, created_at, actor_id, action, target_type, target_id, ip, request_id, before_state, after_state, prev_hash, hash)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+RETURNING *;
+
+-- name: QueryLastAuditEntry :one
+SELECT * FROM audit_log
+ORDER BY seq DESC
+LIMIT 1;
+
+-- QueryAuditLog lists entries newest first; NULL filters match anything.
+
+-- name: QueryAuditLog :many
+SELECT * FROM audit_log
+WHERE (sqlc.narg(actor_id)::uuid IS NULL OR actor_id = sqlc.narg(actor_id))
+    AND (sqlc.narg(action)::text IS NULL OR action = sqlc.narg(action))
+    AND (sqlc.narg(target_type)::text IS NULL OR target_type = sqlc.narg(target_type))
+    AND (sqlc.narg(target_id)::text IS NULL OR target_id = sqlc.narg(target_id))
+    AND (sqlc.narg(since)::timestamp IS NULL OR created_at >= sqlc.narg(since))
+    AND (sqlc.narg(until)::timestamp IS NULL OR created_at < sqlc.narg(until))
+    AND (sqlc.narg(before_seq)::bigint IS NULL OR seq < sqlc.narg(before_seq))
+ORDER BY seq DESC
+LIMIT sqlc.arg(max_entries);
+
+-- name: QueryAuditChain :many
+SELECT * FROM audit_log
+ORDER BY seq ASC;
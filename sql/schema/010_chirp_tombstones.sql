@@ -0,0 +1,17 @@
+-- +goose Up
+-- deleted_by has no foreign key: the tombstone keeps naming the moderator
+-- after their account is gone.
+ALTER TABLE chirps
+ADD COLUMN deleted_at TIMESTAMP;
+ALTER TABLE chirps
+ADD COLUMN deleted_by UUID;
+ALTER TABLE chirps
+ADD COLUMN deletion_reason TEXT;
+
+-- +goose Down
+ALTER TABLE chirps
+DROP COLUMN deletion_reason;
+ALTER TABLE chirps
+DROP COLUMN deleted_by;
+ALTER TABLE chirps
+DROP COLUMN deleted_at;
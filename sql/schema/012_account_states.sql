@@ -0,0 +1,29 @@
+-- +goose Up
+-- A suspension ends by itself at suspended_until; the status is only
+-- reset to active when a moderator lifts a sanction.
+ALTER TABLE users
+ADD COLUMN status TEXT NOT NULL DEFAULT 'active'
+    CHECK (status IN ('active', 'suspended', 'banned', 'shadow_banned'));
+ALTER TABLE users
+ADD COLUMN suspended_until TIMESTAMP;
+
+-- the history of the sanctions of a user; lifting one is recorded with
+-- status active
+CREATE TABLE sanctions (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    actor_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    status TEXT NOT NULL
+        CHECK (status IN ('active', 'suspended', 'banned', 'shadow_banned')),
+    suspended_until TIMESTAMP,
+    reason TEXT NOT NULL
+);
+
+-- +goose Down
+DROP TABLE sanctions;
+
+ALTER TABLE users
+DROP COLUMN suspended_until;
+ALTER TABLE users
+DROP COLUMN status;
@@ -0,0 +1,43 @@
+-- +goose Up
+-- The audit trail of privileged and destructive actions. actor_id is NULL
+-- for actions taken by the system or by Polka, and has no foreign key, like
+-- target_id, so that entries outlive the users they name. Each entry
+-- hashes the one before it: changing or removing an entry breaks the chain
+-- from there on.
+CREATE TABLE audit_log (
+    seq BIGINT PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    actor_id UUID,
+    action TEXT NOT NULL,
+    target_type TEXT NOT NULL,
+    target_id TEXT,
+    ip TEXT,
+    request_id TEXT,
+    before_state TEXT,
+    after_state TEXT,
+    prev_hash TEXT NOT NULL,
+    hash TEXT NOT NULL UNIQUE
+);
+
+CREATE INDEX audit_log_actor ON audit_log (actor_id, seq);
+CREATE INDEX audit_log_target ON audit_log (target_type, target_id, seq);
+
+-- +goose StatementBegin
+CREATE FUNCTION audit_log_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER audit_log_append_only
+BEFORE UPDATE OR DELETE ON audit_log
+FOR EACH ROW EXECUTE FUNCTION audit_log_append_only();
+
+CREATE TRIGGER audit_log_no_truncate
+BEFORE TRUNCATE ON audit_log
+FOR EACH STATEMENT EXECUTE FUNCTION audit_log_append_only();
+
+-- +goose Down
+DROP TABLE audit_log;
+DROP FUNCTION audit_log_append_only();
@@ -0,0 +1,13 @@
+-- +goose Up
+-- Reports outlive the chirps they are about: purging a chirp only clears
+-- chirp_id, so that resolved reports and their events stay as evidence.
+ALTER TABLE reports
+DROP CONSTRAINT reports_chirp_id_fkey,
+ADD CONSTRAINT reports_chirp_id_fkey
+    FOREIGN KEY (chirp_id) REFERENCES chirps(id) ON DELETE SET NULL;
+
+-- +goose Down
+ALTER TABLE reports
+DROP CONSTRAINT reports_chirp_id_fkey,
+ADD CONSTRAINT reports_chirp_id_fkey
+    FOREIGN KEY (chirp_id) REFERENCES chirps(id) ON DELETE CASCADE;
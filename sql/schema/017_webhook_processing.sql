@@ -0,0 +1,12 @@
+-- +goose Up
+-- A delivery claims its event as processing before applying it, so that
+-- two deliveries of the same event cannot both apply it.
+ALTER TABLE webhook_events DROP CONSTRAINT webhook_events_status_check;
+ALTER TABLE webhook_events ADD CONSTRAINT webhook_events_status_check
+    CHECK (status IN ('received', 'processing', 'processed', 'ignored', 'failed'));
+
+-- +goose Down
+UPDATE webhook_events SET status = 'received' WHERE status = 'processing';
+ALTER TABLE webhook_events DROP CONSTRAINT webhook_events_status_check;
+ALTER TABLE webhook_events ADD CONSTRAINT webhook_events_status_check
+    CHECK (status IN ('received', 'processed', 'ignored', 'failed'));
@@ -0,0 +1,15 @@
+-- +goose Up
+-- A user has at most one export waiting or being built: asking again
+-- returns that one. Older duplicates are given up first.
+UPDATE data_exports AS d
+SET status = 'failed', completed_at = NOW(), updated_at = NOW()
+WHERE status IN ('pending', 'running') AND EXISTS (
+    SELECT 1 FROM data_exports AS o
+    WHERE o.user_id = d.user_id AND o.status IN ('pending', 'running')
+        AND (o.created_at, o.id) > (d.created_at, d.id)
+);
+CREATE UNIQUE INDEX data_exports_one_open ON data_exports (user_id)
+    WHERE status IN ('pending', 'running');
+
+-- +goose Down
+DROP INDEX data_exports_one_open;
@@ -0,0 +1,20 @@
+// Package schema embeds the goose migrations of this directory, so that
+// the binary can apply them without the source tree at hand.
+package schema
+
+import (
+	"embed"
+	"io/fs"
+)
+
+// FS holds every migration, named NNN_description.sql.
+//
+//go:embed *.sql
+var FS embed.FS
+
+//go:embed sqlite/*.sql
+var sqliteFiles embed.FS
+
+// SQLiteFS holds the same migrations written for SQLite; they must keep
+// the version numbers and column order of their Postgres counterparts.
+var SQLiteFS, _ = fs.Sub(sqliteFiles, "sqlite")
@@ -0,0 +1,13 @@
+-- +goose Up
+CREATE TABLE email_changes (
+    token TEXT PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    new_email TEXT NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    confirmed_at TIMESTAMP
+);
+
+-- +goose Down
+DROP TABLE email_changes;
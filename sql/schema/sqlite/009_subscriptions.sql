@@ -0,0 +1,30 @@
+-- +goose Up
+CREATE TABLE subscriptions (
+    id TEXT PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    plan TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'active'
+        CHECK (status IN ('active', 'canceled', 'expired')),
+    current_period_start TIMESTAMP NOT NULL,
+    current_period_end TIMESTAMP NOT NULL
+);
+
+CREATE UNIQUE INDEX subscriptions_one_active_per_user
+ON subscriptions (user_id)
+WHERE status = 'active';
+
+-- Members from before subscriptions existed get one period from now, so
+-- that they expire like everyone else unless Polka renews them. The id is
+-- a random version 4 UUID.
+INSERT INTO subscriptions (id, created_at, updated_at, user_id, plan, status, current_period_start, current_period_end)
+SELECT
+    lower(hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-4' || substr(hex(randomblob(2)), 2) || '-'
+        || substr('89ab', 1 + abs(random()) % 4, 1) || substr(hex(randomblob(2)), 2) || '-' || hex(randomblob(6))),
+    datetime('now'), datetime('now'), id, 'chirpy_red', 'active', datetime('now'), datetime('now', '+30 days')
+FROM users
+WHERE is_chirpy_red AND deleted_at IS NULL;
+
+-- +goose Down
+DROP TABLE subscriptions;
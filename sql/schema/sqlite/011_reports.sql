@@ -0,0 +1,41 @@
+-- +goose Up
+-- user_id is the reported user, the author for a chirp report, so that the
+-- queue can group reports by user whatever their target.
+CREATE TABLE reports (
+    id TEXT PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    reporter_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    chirp_id TEXT REFERENCES chirps(id) ON DELETE CASCADE,
+    reason TEXT NOT NULL
+        CHECK (reason IN ('spam', 'harassment', 'hate', 'violence', 'sexual', 'misinformation', 'other')),
+    details TEXT,
+    status TEXT NOT NULL DEFAULT 'open'
+        CHECK (status IN ('open', 'assigned', 'dismissed', 'chirp_removed', 'user_suspended')),
+    assignee_id TEXT REFERENCES users(id) ON DELETE SET NULL,
+    resolved_at TIMESTAMP
+);
+
+-- a reporter has at most one pending report per target
+CREATE UNIQUE INDEX reports_one_pending_per_chirp
+ON reports (reporter_id, chirp_id)
+WHERE chirp_id IS NOT NULL AND status IN ('open', 'assigned');
+
+CREATE UNIQUE INDEX reports_one_pending_per_user
+ON reports (reporter_id, user_id)
+WHERE chirp_id IS NULL AND status IN ('open', 'assigned');
+
+-- the audit trail of a report; actor_id is NULL for automatic actions
+CREATE TABLE report_events (
+    id TEXT PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    report_id TEXT NOT NULL REFERENCES reports(id) ON DELETE CASCADE,
+    actor_id TEXT REFERENCES users(id) ON DELETE SET NULL,
+    action TEXT NOT NULL,
+    note TEXT
+);
+
+-- +goose Down
+DROP TABLE report_events;
+DROP TABLE reports;
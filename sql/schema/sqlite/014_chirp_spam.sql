@@ -0,0 +1,19 @@
+-- +goose Up
+-- body_hash identifies a body up to case, spacing and punctuation, to spot
+-- an author posting the same chirp again. held_reason is set on the chirps
+-- held for review, which stay unlisted until a moderator releases them.
+ALTER TABLE chirps
+ADD COLUMN body_hash TEXT NOT NULL DEFAULT '';
+ALTER TABLE chirps
+ADD COLUMN held_reason TEXT;
+
+-- the rate limits count the recent chirps of an author
+CREATE INDEX chirps_user_created ON chirps (user_id, created_at);
+
+-- +goose Down
+DROP INDEX chirps_user_created;
+
+ALTER TABLE chirps
+DROP COLUMN held_reason;
+ALTER TABLE chirps
+DROP COLUMN body_hash;
@@ -0,0 +1,75 @@
+-- +goose NO TRANSACTION
+-- +goose Up
+-- Reports outlive the chirps they are about: purging a chirp only clears
+-- chirp_id, so that resolved reports and their events stay as evidence.
+-- SQLite cannot change a foreign key in place, so reports is rebuilt, with
+-- foreign keys off so that dropping it does not cascade to report_events.
+-- Both pragmas only work outside a transaction.
+PRAGMA foreign_keys = OFF;
+BEGIN;
+
+CREATE TABLE reports_new (
+    id TEXT PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    reporter_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    chirp_id TEXT REFERENCES chirps(id) ON DELETE SET NULL,
+    reason TEXT NOT NULL
+        CHECK (reason IN ('spam', 'harassment', 'hate', 'violence', 'sexual', 'misinformation', 'other')),
+    details TEXT,
+    status TEXT NOT NULL DEFAULT 'open'
+        CHECK (status IN ('open', 'assigned', 'dismissed', 'chirp_removed', 'user_suspended')),
+    assignee_id TEXT REFERENCES users(id) ON DELETE SET NULL,
+    resolved_at TIMESTAMP
+);
+
+INSERT INTO reports_new SELECT * FROM reports;
+DROP TABLE reports;
+ALTER TABLE reports_new RENAME TO reports;
+
+CREATE UNIQUE INDEX reports_one_pending_per_chirp
+ON reports (reporter_id, chirp_id)
+WHERE chirp_id IS NOT NULL AND status IN ('open', 'assigned');
+
+CREATE UNIQUE INDEX reports_one_pending_per_user
+ON reports (reporter_id, user_id)
+WHERE chirp_id IS NULL AND status IN ('open', 'assigned');
+
+COMMIT;
+PRAGMA foreign_keys = ON;
+
+-- +goose Down
+PRAGMA foreign_keys = OFF;
+BEGIN;
+
+CREATE TABLE reports_new (
+    id TEXT PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    reporter_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    chirp_id TEXT REFERENCES chirps(id) ON DELETE CASCADE,
+    reason TEXT NOT NULL
+        CHECK (reason IN ('spam', 'harassment', 'hate', 'violence', 'sexual', 'misinformation', 'other')),
+    details TEXT,
+    status TEXT NOT NULL DEFAULT 'open'
+        CHECK (status IN ('open', 'assigned', 'dismissed', 'chirp_removed', 'user_suspended')),
+    assignee_id TEXT REFERENCES users(id) ON DELETE SET NULL,
+    resolved_at TIMESTAMP
+);
+
+INSERT INTO reports_new SELECT * FROM reports;
+DROP TABLE reports;
+ALTER TABLE reports_new RENAME TO reports;
+
+CREATE UNIQUE INDEX reports_one_pending_per_chirp
+ON reports (reporter_id, chirp_id)
+WHERE chirp_id IS NOT NULL AND status IN ('open', 'assigned');
+
+CREATE UNIQUE INDEX reports_one_pending_per_user
+ON reports (reporter_id, user_id)
+WHERE chirp_id IS NULL AND status IN ('open', 'assigned');
+
+COMMIT;
+PRAGMA foreign_keys = ON;
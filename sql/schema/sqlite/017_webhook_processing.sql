@@ -0,0 +1,43 @@
+-- +goose Up
+-- A delivery claims its event as processing before applying it, so that
+-- two deliveries of the same event cannot both apply it. SQLite cannot
+-- change a CHECK in place, so webhook_events is rebuilt; no table refers
+-- to it.
+CREATE TABLE webhook_events_new (
+    id TEXT PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    event TEXT NOT NULL,
+    payload BLOB NOT NULL,
+    status TEXT NOT NULL DEFAULT 'received'
+        CHECK (status IN ('received', 'processing', 'processed', 'ignored', 'failed')),
+    error TEXT,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    processed_at TIMESTAMP
+);
+
+INSERT INTO webhook_events_new SELECT * FROM webhook_events;
+DROP TABLE webhook_events;
+ALTER TABLE webhook_events_new RENAME TO webhook_events;
+
+-- +goose Down
+CREATE TABLE webhook_events_new (
+    id TEXT PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    event TEXT NOT NULL,
+    payload BLOB NOT NULL,
+    status TEXT NOT NULL DEFAULT 'received'
+        CHECK (status IN ('received', 'processed', 'ignored', 'failed')),
+    error TEXT,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    processed_at TIMESTAMP
+);
+
+INSERT INTO webhook_events_new
+SELECT id, created_at, updated_at, event, payload,
+    CASE status WHEN 'processing' THEN 'received' ELSE status END,
+    error, attempts, processed_at
+FROM webhook_events;
+DROP TABLE webhook_events;
+ALTER TABLE webhook_events_new RENAME TO webhook_events;